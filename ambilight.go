@@ -0,0 +1,105 @@
+package catglow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"time"
+
+	"libdb.so/catglow/internal/ambilight"
+	"libdb.so/catglow/internal/clock"
+)
+
+// ambilightAnimator draws the edges of the frames of a screen.
+type ambilightAnimator struct {
+	*ambilight.Output
+	source ambilight.Source
+}
+
+var _ silencer = (*ambilightAnimator)(nil)
+
+// Run reads frames from the source until the context is canceled or the
+// source ends.
+func (a *ambilightAnimator) Run(ctx context.Context) error {
+	return a.source.Run(ctx, func(img image.Image) { a.Output.Update(img) })
+}
+
+func (c *AmbilightConfig) geometry() ambilight.Geometry {
+	return ambilight.Geometry{
+		Top:       c.Top,
+		Right:     c.Right,
+		Bottom:    c.Bottom,
+		Left:      c.Left,
+		Start:     ambilight.Corner(c.Start),
+		Direction: ambilight.Direction(c.Direction),
+		Depth:     c.Depth,
+	}
+}
+
+func (c *AmbilightConfig) smoothing() time.Duration {
+	if c.Smoothing == 0 {
+		return 200 * time.Millisecond
+	}
+	return time.Duration(c.Smoothing)
+}
+
+func (c *AmbilightConfig) timeout() time.Duration {
+	if c.Timeout == 0 {
+		return ambilight.DataTimeout
+	}
+	return time.Duration(c.Timeout)
+}
+
+func (c *AmbilightConfig) interval() time.Duration {
+	if c.Interval == 0 {
+		return time.Second
+	}
+	return time.Duration(c.Interval)
+}
+
+func (c *AmbilightConfig) validate(numLEDs int) error {
+	switch c.Source {
+	case DirAmbilightSource:
+	case PipeAmbilightSource:
+		if c.Width <= 0 || c.Height <= 0 {
+			return errors.New("width and height must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown source %q", c.Source)
+	}
+	if c.Path == "" {
+		return errors.New("missing path")
+	}
+	if c.Interval < 0 || c.Smoothing < 0 || c.Timeout < 0 {
+		return errors.New("durations must not be negative")
+	}
+
+	g := c.geometry()
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	if g.NumLEDs() != numLEDs {
+		return fmt.Errorf("the edges have %d LEDs, but the range has %d", g.NumLEDs(), numLEDs)
+	}
+	return nil
+}
+
+func (c *AmbilightConfig) animator(numLEDs int, clock clock.Clock) (*ambilightAnimator, error) {
+	if err := c.validate(numLEDs); err != nil {
+		return nil, err
+	}
+
+	var source ambilight.Source
+	switch c.Source {
+	case DirAmbilightSource:
+		source = ambilight.DirSource{Dir: c.Path, Interval: c.interval(), Clock: clock}
+	case PipeAmbilightSource:
+		source = ambilight.PipeSource{Path: c.Path, Width: c.Width, Height: c.Height}
+	}
+
+	return &ambilightAnimator{
+		Output: ambilight.NewOutput(c.geometry(), c.smoothing(), c.timeout(), clock),
+		source: source,
+	}, nil
+}
@@ -0,0 +1,76 @@
+package catglow
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+func TestAmbilight(t *testing.T) {
+	// The top half of the screen is red and the bottom half blue.
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			c := color.RGBA{255, 0, 0, 255}
+			if y >= 8 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "screen.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	cfg := AmbilightConfig{
+		Source:    DirAmbilightSource,
+		Path:      dir,
+		Top:       1,
+		Bottom:    1,
+		Start:     "top-left",
+		Smoothing: TOMLDuration(time.Second),
+	}
+	if _, err := cfg.animator(3, clock.Real); err == nil {
+		t.Error("expected an error for a range larger than the edges")
+	}
+
+	clk := clock.NewManual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	a, err := cfg.animator(2, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.Run(ctx)
+
+	for a.Silent() {
+		time.Sleep(time.Millisecond)
+	}
+
+	var got led.LEDs
+	a.AcquireFrame(func(leds led.LEDs) { got = append(got, leds...) })
+	if want := (led.LEDs{{255, 0, 0}, {0, 0, 255}}); got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	pipe := AmbilightConfig{Source: PipeAmbilightSource, Path: "/tmp/screen", Left: 2}
+	if err := pipe.validate(2); err == nil {
+		t.Error("expected an error for a pipe without a frame size")
+	}
+}
@@ -0,0 +1,388 @@
+package catglow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"libdb.so/catglow/internal/artnet"
+	"libdb.so/catglow/internal/audioin"
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/ddp"
+	"libdb.so/catglow/internal/e131"
+	"libdb.so/catglow/internal/led"
+	"libdb.so/catglow/internal/ledanim"
+	"libdb.so/catglow/internal/ledvis"
+)
+
+// animatorRunner is implemented by animators that need to run in the
+// background while their scene is shown, such as visualizers capturing audio.
+type animatorRunner interface {
+	// Run runs the animator until the given context is canceled.
+	Run(ctx context.Context) error
+}
+
+// silencer is an animator that runs in the background and can go silent,
+// such as a visualizer while no audio plays, after which an idle animation
+// takes over.
+type silencer interface {
+	Animator
+	animatorRunner
+	// Silent returns true if the animator has been silent for a while.
+	Silent() bool
+}
+
+// visualizer is an animator that draws audio.
+type visualizer interface {
+	silencer
+	ledvis.BeatSource
+	// SetDevice switches the visualizer to capture from another device, or
+	// back to its own if empty.
+	SetDevice(device string)
+	// NextColor advances the gradient of the visualizer.
+	NextColor()
+	// SetGradient replaces the colors of the gradient, or restores them if
+	// empty.
+	SetGradient(colors []led.RGBColor)
+}
+
+// animatorEnv is the environment that animators are created in.
+type animatorEnv struct {
+	// rate is the frame rate of the LEDs.
+	rate  int
+	clock clock.Clock
+	// sources is shared by all visualizers so that they capture audio from
+	// each device only once.
+	sources *ledvis.Sources
+	// e131 is shared by all E1.31 receivers so that they can listen on the
+	// same address. If nil, then nothing is received.
+	e131 *e131.Receivers
+	// artnet is shared by all Art-Net receivers so that they make up one
+	// node per address. If nil, then nothing is received.
+	artnet *artnet.Nodes
+	// ddp is shared by all DDP receivers so that they can listen on the same
+	// address. If nil, then nothing is received.
+	ddp *ddp.Receivers
+	// players is the media player that visualizers follow. If nil, then
+	// they don't.
+	players *players
+}
+
+// newAnimator creates an animator for the given LED configuration. It returns
+// nil if the configuration has nothing to animate.
+func newAnimator(cfg LEDConfig, env animatorEnv) (Animator, error) {
+	numLEDs := cfg.Range[1] - cfg.Range[0]
+	clock := env.clock
+
+	switch {
+	case cfg.Color != nil:
+		return staticAnimator(numLEDs, *cfg.Color), nil
+	case cfg.Snake != nil:
+		return cfg.Snake.animator(numLEDs, clock), nil
+	case cfg.Breathing != nil:
+		return cfg.Breathing.animator(numLEDs, clock)
+	case cfg.Flag != nil:
+		return cfg.Flag.animator(numLEDs)
+	case cfg.Cycle != nil:
+		return cfg.Cycle.animator(numLEDs, clock), nil
+	case cfg.Pattern != nil:
+		return cfg.Pattern.animator(numLEDs, clock)
+	case cfg.Visualizer != nil:
+		vis, err := cfg.Visualizer.visualizer(numLEDs, env)
+		if err != nil {
+			return nil, err
+		}
+		if env.players != nil {
+			albumArt := cfg.Visualizer.GradientSource == AlbumArtGradientSource
+			vis = env.players.visualizer(vis, albumArt, cfg.Idle == nil, clock)
+		}
+		return cfg.withIdle(vis, numLEDs, clock)
+	case cfg.E131 != nil:
+		receiver, err := cfg.E131.animator(numLEDs, env)
+		if err != nil {
+			return nil, err
+		}
+		return cfg.withIdle(receiver, numLEDs, clock)
+	case cfg.ArtNet != nil:
+		receiver, err := cfg.ArtNet.animator(numLEDs, env)
+		if err != nil {
+			return nil, err
+		}
+		return cfg.withIdle(receiver, numLEDs, clock)
+	case cfg.DDP != nil:
+		receiver, err := cfg.DDP.animator(numLEDs, env)
+		if err != nil {
+			return nil, err
+		}
+		return cfg.withIdle(receiver, numLEDs, clock)
+	case cfg.Ambilight != nil:
+		screen, err := cfg.Ambilight.animator(numLEDs, clock)
+		if err != nil {
+			return nil, err
+		}
+		return cfg.withIdle(screen, numLEDs, clock)
+	default:
+		return nil, nil
+	}
+}
+
+// withIdle wraps the animator to fall back to the idle animation while it is
+// silent, if one is configured.
+func (cfg LEDConfig) withIdle(a silencer, numLEDs int, clock clock.Clock) (Animator, error) {
+	if cfg.Idle == nil {
+		return a, nil
+	}
+	idle, err := cfg.Idle.animator(numLEDs, clock)
+	if err != nil {
+		return nil, err
+	}
+	return newIdleAnimator(a, idle, time.Duration(cfg.Idle.Fade), clock), nil
+}
+
+// staticAnimator returns an animator that always draws the given color.
+func staticAnimator(numLEDs int, color led.RGBColor) Animator {
+	leds := led.NewLEDs(numLEDs)
+	leds.SetRange(0, numLEDs, color)
+	return frameAnimator(leds)
+}
+
+// frameAnimator is an Animator that always draws the same frame.
+type frameAnimator led.LEDs
+
+func (a frameAnimator) AcquireFrame(f func(led.LEDs)) { f(led.LEDs(a)) }
+
+func (c *SnakeAnimationConfig) animator(numLEDs int, clock clock.Clock) Animator {
+	colors := make([]led.RGBColor, len(c.Chunks))
+	for i, chunk := range c.Chunks {
+		colors[i] = chunk.Color
+	}
+	return ledanim.NewSnake(numLEDs, ledanim.SnakeConfig{
+		Colors: colors,
+		Speed:  time.Duration(c.Speed),
+	}, clock)
+}
+
+func (c *BreathingAnimationConfig) animator(numLEDs int, clock clock.Clock) (Animator, error) {
+	function := ledanim.BreathingFunction(c.Function)
+	switch function {
+	case "", ledanim.BreathingSine, ledanim.BreathingLinear:
+	default:
+		return nil, fmt.Errorf("unknown breathing function %q", c.Function)
+	}
+
+	var frame led.LEDs
+	if c.Flag != nil {
+		stripes, err := c.Flag.stripes()
+		if err != nil {
+			return nil, err
+		}
+		frame = ledanim.NewFlag(numLEDs, stripes)
+	}
+
+	return ledanim.NewBreathing(numLEDs, ledanim.BreathingConfig{
+		Color:    c.Color,
+		Frame:    frame,
+		Period:   time.Duration(c.Period),
+		Function: function,
+	}, clock), nil
+}
+
+func (c *FlagAnimationConfig) stripes() ([]led.RGBColor, error) {
+	if c.Preset == "" {
+		return c.Stripes, nil
+	}
+	stripes, ok := ledanim.Flags[c.Preset]
+	if !ok {
+		return nil, fmt.Errorf(
+			"unknown flag preset %q, must be one of %s",
+			c.Preset, strings.Join(ledanim.FlagNames(), ", "))
+	}
+	return stripes, nil
+}
+
+func (c *FlagAnimationConfig) animator(numLEDs int) (Animator, error) {
+	stripes, err := c.stripes()
+	if err != nil {
+		return nil, err
+	}
+	return frameAnimator(ledanim.NewFlag(numLEDs, stripes)), nil
+}
+
+func (c *CycleAnimationConfig) animator(numLEDs int, clock clock.Clock) Animator {
+	return ledanim.NewCycle(numLEDs, ledanim.CycleConfig{
+		Colors:   c.Colors,
+		Interval: time.Duration(c.Interval),
+		Fade:     time.Duration(c.Fade),
+	}, clock)
+}
+
+func (c *PatternConfig) animator(numLEDs int, clock clock.Clock) (Animator, error) {
+	if c.Density != nil && (*c.Density < 0 || *c.Density > 1) {
+		return nil, fmt.Errorf("pattern density must be within [0, 1]")
+	}
+	if c.Decay != nil && (*c.Decay < 0 || *c.Decay > 1) {
+		return nil, fmt.Errorf("pattern decay must be within [0, 1]")
+	}
+
+	cfg := ledanim.PatternConfig{
+		Speed:   time.Duration(c.Speed),
+		Colors:  c.Colors,
+		Size:    c.Size,
+		Density: c.Density,
+		Decay:   c.Decay,
+		Seed:    c.Seed,
+	}
+
+	switch c.Kind {
+	case RainbowPattern:
+		return ledanim.NewRainbow(numLEDs, cfg, clock), nil
+	case WipePattern:
+		return ledanim.NewWipe(numLEDs, cfg, clock), nil
+	case TheaterChasePattern:
+		return ledanim.NewTheaterChase(numLEDs, cfg, clock), nil
+	case TwinklePattern:
+		return ledanim.NewTwinkle(numLEDs, cfg, clock), nil
+	case FirePattern:
+		return ledanim.NewFire(numLEDs, cfg, clock), nil
+	case MeteorPattern:
+		return ledanim.NewMeteor(numLEDs, cfg, clock), nil
+	case CometPattern:
+		return ledanim.NewComet(numLEDs, cfg, clock), nil
+	case LarsonPattern:
+		return ledanim.NewLarson(numLEDs, cfg, clock), nil
+	case PlasmaPattern:
+		return ledanim.NewPlasma(numLEDs, cfg, clock), nil
+	default:
+		return nil, fmt.Errorf("unknown pattern kind %q", c.Kind)
+	}
+}
+
+func (c *IdleConfig) animator(numLEDs int, clock clock.Clock) (Animator, error) {
+	switch {
+	case c.Color != nil:
+		return staticAnimator(numLEDs, *c.Color), nil
+	case c.Snake != nil:
+		return c.Snake.animator(numLEDs, clock), nil
+	case c.Breathing != nil:
+		return c.Breathing.animator(numLEDs, clock)
+	case c.Flag != nil:
+		return c.Flag.animator(numLEDs)
+	case c.Cycle != nil:
+		return c.Cycle.animator(numLEDs, clock), nil
+	case c.Pattern != nil:
+		return c.Pattern.animator(numLEDs, clock)
+	default:
+		return staticAnimator(numLEDs, led.RGBColor{}), nil
+	}
+}
+
+func (c *VisualizerConfig) visualizer(numLEDs int, env animatorEnv) (visualizer, error) {
+	var format audioin.Format
+	if c.Format != "" {
+		f, err := audioin.ParseFormat(c.Format)
+		if err != nil {
+			return nil, err
+		}
+		format = f
+	}
+
+	switch c.GradientSource {
+	case "", AlbumArtGradientSource:
+	default:
+		return nil, fmt.Errorf("unknown gradient source %q", c.GradientSource)
+	}
+
+	var channelStyle ledvis.ChannelStyle
+	switch {
+	case c.ChannelStyle != "":
+		s, err := ledvis.ParseChannelStyle(c.ChannelStyle)
+		if err != nil {
+			return nil, err
+		}
+		channelStyle = s
+	case c.Kind == VUVisualizer:
+		channelStyle = ledvis.StereoSplit
+	}
+
+	cfg := ledvis.VisualizerConfig{
+		Backend:      c.Backend,
+		Device:       c.Device,
+		Format:       format,
+		Pace:         c.Pace,
+		Loop:         c.Loop,
+		Sources:      env.sources,
+		NumLEDs:      numLEDs,
+		Bins:         c.Bins,
+		SmoothFactor: c.Smooth,
+		ChannelStyle: channelStyle,
+		Frequency: ledvis.FrequencyConfig{
+			MinFreq: c.MinFreq,
+			MaxFreq: c.MaxFreq,
+			Scale:   ledvis.FrequencyScale(c.FreqScale),
+			Gains:   c.Gains,
+		},
+		AGC: ledvis.AGCConfig{
+			Enabled: c.AGC,
+			Attack:  time.Duration(c.AGCAttack),
+			Release: time.Duration(c.AGCRelease),
+		},
+		Flip:      c.Flip,
+		FrameRate: env.rate,
+		Clock:     env.clock,
+		Gradient: ledvis.GradientConfig{
+			Colors:     c.Gradients,
+			Mode:       ledvis.GradientMode(c.GradientMode),
+			PeakSwitch: c.GradientPeakSwitch,
+			PeakBin:    c.GradientPeakBin,
+			Duration:   time.Duration(c.GradientDuration),
+		},
+		Silence: ledvis.SilenceConfig{
+			Threshold: c.SilenceThreshold,
+			Duration:  time.Duration(c.SilenceDuration),
+		},
+		Beat: ledvis.BeatConfig{
+			Sensitivity: c.BeatSensitivity,
+			MinBPM:      c.BeatMinBPM,
+			MaxBPM:      c.BeatMaxBPM,
+			Decay:       time.Duration(c.BeatDecay),
+		},
+		Waterfall: ledvis.WaterfallConfig{
+			BassBins: c.BassBins,
+			Speed:    c.ScrollSpeed,
+		},
+		Pulse: ledvis.PulseConfig{
+			Softness: c.PulseSoftness,
+		},
+		VU: ledvis.VUConfig{
+			PeakHold:  time.Duration(c.PeakHold),
+			PeakFall:  c.PeakFall,
+			PeakColor: c.PeakColor,
+		},
+		SpectrumHue: ledvis.SpectrumHueConfig{
+			Low:  c.HueRange[0],
+			High: c.HueRange[1],
+		},
+	}
+
+	switch c.Kind {
+	case GlowingVisualizer:
+		return ledvis.NewGlowing(cfg)
+	case BlinkingVisualizer:
+		return ledvis.NewBlinking(cfg)
+	case MeterVisualizer:
+		return ledvis.NewMeter(cfg)
+	case BeatVisualizer:
+		return ledvis.NewBeatFlash(cfg)
+	case WaterfallVisualizer:
+		return ledvis.NewWaterfall(cfg)
+	case PulseVisualizer:
+		return ledvis.NewPulse(cfg)
+	case VUVisualizer:
+		return ledvis.NewVU(cfg)
+	case SpectrumHueVisualizer:
+		return ledvis.NewSpectrumHue(cfg)
+	default:
+		return nil, fmt.Errorf("unknown visualizer kind %q", c.Kind)
+	}
+}
@@ -0,0 +1,99 @@
+package catglow
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestFlagAnimatorGolden(t *testing.T) {
+	const numLEDs = 12
+
+	for name, cfg := range map[string]FlagAnimationConfig{
+		"flag": {Preset: "trans"},
+		"flag-stripes": {Stripes: []led.RGBColor{
+			{255, 0, 0},
+			{0, 255, 0},
+			{0, 0, 255},
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			anim, err := cfg.animator(numLEDs)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var b strings.Builder
+			anim.AcquireFrame(func(leds led.LEDs) {
+				for i, c := range leds {
+					if i > 0 {
+						b.WriteByte(' ')
+					}
+					fmt.Fprintf(&b, "%02x%02x%02x", c[0], c[1], c[2])
+				}
+				b.WriteByte('\n')
+			})
+			expectGolden(t, name, b.String())
+		})
+	}
+
+	if _, err := (&FlagAnimationConfig{Preset: "nope"}).animator(numLEDs); err == nil {
+		t.Error("expected an error for an unknown preset")
+	}
+}
+
+func TestPatternAnimatorRatios(t *testing.T) {
+	zero, half, over := 0.0, 0.5, 1.5
+
+	for _, test := range []struct {
+		name    string
+		cfg     PatternConfig
+		wantErr bool
+	}{
+		{"defaults", PatternConfig{Kind: TwinklePattern}, false},
+		{"zero", PatternConfig{Kind: TwinklePattern, Density: &zero, Decay: &zero}, false},
+		{"half", PatternConfig{Kind: FirePattern, Density: &half, Decay: &half}, false},
+		{"density too high", PatternConfig{Kind: TwinklePattern, Density: &over}, true},
+		{"decay too high", PatternConfig{Kind: MeteorPattern, Decay: &over}, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.cfg.animator(8, clock.Real)
+			if (err != nil) != test.wantErr {
+				t.Errorf("got error %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+// expectGolden compares got to testdata/<name>.golden, or writes it there
+// with -update.
+func expectGolden(t *testing.T, name, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update?): %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("frame differs from %s:\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
@@ -5,12 +5,20 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
+	"net"
 	"time"
 
 	"github.com/pkg/errors"
 	"go.bug.st/serial"
 	"golang.org/x/sync/errgroup"
+	"libdb.so/catglow/internal/artnet"
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/ddp"
+	"libdb.so/catglow/internal/e131"
 	"libdb.so/catglow/internal/led"
+	"libdb.so/catglow/internal/ledrec"
+	"libdb.so/catglow/internal/ledvis"
 	"libdb.so/catglow/ledserial"
 )
 
@@ -39,21 +47,92 @@ type Daemon struct {
 	cfg     *Config
 	logger  *slog.Logger
 	refresh chan struct{}
+
+	scene      latest[sceneRequest]
+	brightness latest[brightnessRequest]
+	control    *control
+	// ddp is shared by the DDP receivers of the strip and the animators.
+	ddp *ddp.Receivers
+	// players is the media player that visualizers follow, if configured.
+	players *players
+
+	record io.Writer
+}
+
+type sceneRequest struct {
+	name      string
+	crossfade time.Duration
+}
+
+type brightnessRequest struct {
+	brightness float64
+	transition time.Duration
 }
 
 var _ RefreshQueuer = (*Daemon)(nil)
 
+// recordFlushInterval is how often the recording is flushed to disk.
+const recordFlushInterval = time.Second
+
 // NewDaemon creates a new catglow daemon.
 func NewDaemon(cfg *Config, logger *slog.Logger) (*Daemon, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, errors.Wrap(err, "invalid configuration")
 	}
 
-	return &Daemon{
+	d := &Daemon{
 		cfg:     cfg,
 		logger:  logger,
 		refresh: make(chan struct{}, 1),
-	}, nil
+
+		scene:      newLatest[sceneRequest](),
+		brightness: newLatest[brightnessRequest](),
+		control:    newControl(cfg),
+		ddp:        ddp.NewReceivers(),
+	}
+	if cfg.MPRIS != nil {
+		d.players = newPlayers(cfg, clock.Real)
+	}
+	return d, nil
+}
+
+// SetScene switches the daemon to the scene with the given name. The switch
+// happens on the next frame, crossfading from the current scene if configured.
+// The serial port is kept open.
+func (d *Daemon) SetScene(name string) error {
+	return d.setScene(name, time.Duration(d.cfg.Crossfade))
+}
+
+func (d *Daemon) setScene(name string, crossfade time.Duration) error {
+	if d.cfg.Scene(name) == nil {
+		return fmt.Errorf("unknown scene %q", name)
+	}
+
+	d.control.mu.Lock()
+	d.control.scene = name
+	d.control.notify()
+	d.control.mu.Unlock()
+
+	d.scene.send(sceneRequest{name, crossfade})
+	return nil
+}
+
+// SetBrightness sets the brightness of the whole strip within [0, 1],
+// transitioning to it linearly over the given duration. If the strip is off,
+// then the brightness is used once it is turned back on.
+func (d *Daemon) SetBrightness(brightness float64, transition time.Duration) {
+	d.control.mu.Lock()
+	defer d.control.mu.Unlock()
+
+	d.control.brightness = math.Max(0, math.Min(1, brightness))
+	d.sendBrightness(transition)
+}
+
+// RecordTo records every frame sent to the controller into w in the ledrec
+// format. It must be called before Run. The recording is finished once Run
+// returns, but w is not closed.
+func (d *Daemon) RecordTo(w io.Writer) {
+	d.record = w
 }
 
 // QueueRefresh queues a refresh of the led.LEDs.
@@ -105,14 +184,74 @@ func (d *internalDaemon) Run(ctx context.Context) error {
 	errg.Go(func() error {
 		return d.mainLoop(ctx, outPackets)
 	})
+	errg.Go(func() error {
+		return d.runScheduler(ctx)
+	})
 	errg.Go(func() error {
 		return d.readPackets(ctx, outPackets)
 	})
+	if d.cfg.WLED != nil {
+		errg.Go(func() error {
+			return d.runWLED(ctx)
+		})
+	}
+	if d.cfg.DDP != nil {
+		errg.Go(func() error {
+			return d.runDDP(ctx)
+		})
+	}
+	if d.cfg.OpenRGB != nil {
+		errg.Go(func() error {
+			return d.runOpenRGB(ctx)
+		})
+	}
+	if d.cfg.MQTT != nil {
+		errg.Go(func() error {
+			return d.runMQTT(ctx)
+		})
+	}
+	if d.cfg.HTTP != nil {
+		errg.Go(func() error {
+			return d.runHTTP(ctx)
+		})
+	}
+	if d.cfg.DBus != nil {
+		errg.Go(func() error {
+			return d.runDBus(ctx)
+		})
+	}
+	if d.cfg.MPRIS != nil {
+		errg.Go(func() error {
+			return d.runMPRIS(ctx)
+		})
+	}
+	if d.cfg.Notifications != nil {
+		errg.Go(func() error {
+			return d.runNotifications(ctx)
+		})
+	}
 
 	return errg.Wait()
 }
 
-func (d *internalDaemon) mainLoop(ctx context.Context, packets <-chan ledserial.OutgoingPacket) error {
+// optionalServer returns the error that the server of an optional frontend
+// stopped with. If it could not bind its address, such as when another program
+// already uses it, then the error is logged and nil is returned instead, so
+// that the daemon carries on without the frontend like it does for WLED.
+func (d *Daemon) optionalServer(name, listen string, err error) error {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "listen" {
+		d.logger.Warn(
+			"failed to listen, disabling the server",
+			"server", name,
+			"listen", listen,
+			"error", err)
+		return nil
+	}
+	return err
+}
+
+func (d *internalDaemon) mainLoop(ctx context.Context, packets <-chan ledserial.OutgoingPacket) (err error) {
 	d.logger.Debug("waiting 100ms for the read loop to start...")
 	time.Sleep(100 * time.Millisecond)
 
@@ -123,21 +262,58 @@ func (d *internalDaemon) mainLoop(ctx context.Context, packets <-chan ledserial.
 		return errors.New("failed to initialize LEDs")
 	}
 
+	d.control.sendEvent(ControllerEvent{Kind: LinkUpEvent})
+	defer func() {
+		ev := ControllerEvent{Kind: LinkDownEvent}
+		if err != nil {
+			ev.Message = err.Error()
+		}
+		d.control.sendEvent(ev)
+	}()
+
 	leds := led.NewLEDs(d.cfg.NumLEDs())
-	var animators []trackedAnimator
-
-	for _, led := range d.cfg.LEDs {
-		switch {
-		case led.Color != nil:
-			// Pre-initialize with static colors and skip the animator.
-			if led.Color != nil {
-				leds.SetRange(led.Range[0], led.Range[1], *led.Color)
+
+	var recorder *ledrec.Writer
+	var recorderFlushed time.Time
+	if d.record != nil {
+		w, err := ledrec.NewWriter(d.record, len(leds), time.Now())
+		if err != nil {
+			return errors.Wrap(err, "failed to start recording")
+		}
+		defer func() {
+			if err := w.Close(); err != nil {
+				d.logger.Warn("failed to finish recording", "error", err)
 			}
-		case led.Snake != nil:
-		case led.Visualizer != nil:
+		}()
+		recorder = w
+		recorderFlushed = time.Now()
+	}
+
+	env := animatorEnv{
+		rate:    d.cfg.Rate,
+		clock:   clock.Real,
+		sources: ledvis.NewSources(),
+		e131:    e131.NewReceivers(),
+		artnet:  artnet.NewNodes(),
+		ddp:     d.ddp,
+		players: d.players,
+	}
+
+	scenes := make(map[string]*scene)
+	for _, cfg := range d.cfg.AllScenes() {
+		s, err := newScene(cfg, len(leds), env)
+		if err != nil {
+			return errors.Wrapf(err, "scene %q", cfg.Name)
 		}
+		scenes[cfg.Name] = s
 	}
 
+	mixer := sceneMixer{ctx: ctx, logger: d.logger}
+	mixer.switchTo(scenes[d.cfg.InitialScene()], time.Now(), 0)
+
+	brightness := brightnessFader{to: 1}
+	overrides := newOverrides(d.control, env.clock)
+
 	frameTicker := time.NewTicker(time.Second / time.Duration(d.cfg.Rate))
 	defer frameTicker.Stop()
 
@@ -156,6 +332,14 @@ eventLoop:
 		// 	nextFrame = frameTicker.C
 		// 	refresh = nil
 
+		case req := <-d.scene.recv():
+			d.logger.Info("switching scene", "scene", req.name)
+			mixer.switchTo(scenes[req.name], time.Now(), req.crossfade)
+
+		case req := <-d.brightness.recv():
+			d.logger.Info("setting brightness", "brightness", req.brightness)
+			brightness.set(req.brightness, time.Now(), req.transition)
+
 		case p := <-packets:
 			d.logger.Debug("handling packet", "type", p.Type())
 
@@ -170,12 +354,14 @@ eventLoop:
 				d.logger.Warn(
 					"received error packet from controller",
 					"message", p.Message)
+				d.control.sendEvent(ControllerEvent{ControllerErrorEvent, p.Message})
 				return errors.New("controller reported error")
 
 			case ledserial.PanicPacket:
 				d.logger.Error(
 					"controller unrecoverably panicked",
 					"message", p.Message)
+				d.control.sendEvent(ControllerEvent{ControllerErrorEvent, p.Message})
 				return errors.New("controller panicked")
 
 			case ledserial.LogPacket:
@@ -191,16 +377,32 @@ eventLoop:
 			// nextFrame = nil
 			// refresh = d.refresh
 
-			for _, animator := range animators {
-				animator.AcquireFrame(func(f led.LEDs) {
-					f.Draw(animator.cfg.Range[0], leds)
-				})
-			}
+			now := time.Now()
+			mixer.draw(leds, now)
+			overrides.draw(leds, now)
+			leds.Scale(brightness.at(now))
+			overrides.drawNotification(leds, now)
+			d.control.sendFrame(leds)
 
 			d.writePacket(ctx, ledserial.SetPacket{
 				Pix: leds.AsPixels(),
 			})
 
+			if recorder != nil {
+				if err := recorder.WriteFrame(now, leds); err != nil {
+					d.logger.Warn("failed to record frame, stopping recording", "error", err)
+					recorder = nil
+				} else if now.Sub(recorderFlushed) >= recordFlushInterval {
+					// Flush regularly so that the recording is readable while
+					// the daemon runs and survives it being killed.
+					if err := recorder.Flush(); err != nil {
+						d.logger.Warn("failed to flush recording, stopping recording", "error", err)
+						recorder = nil
+					}
+					recorderFlushed = now
+				}
+			}
+
 			// Reset the frame scheduler and wait until we get an ack.
 			nextFrame = nil
 		}
@@ -15,15 +15,44 @@ import (
 var (
 	config  = "catglow.toml"
 	verbose = false
+	record  = ""
 )
 
 func init() {
 	pflag.StringVarP(&config, "config", "c", config, "configuration file")
 	pflag.BoolVarP(&verbose, "verbose", "v", verbose, "verbose output")
+	pflag.StringVar(&record, "record", record, "record the frames sent to the device into this file")
+}
+
+// command is a subcommand of catglow.
+type command struct {
+	flags *pflag.FlagSet
+	run   func(ctx context.Context) error
+}
+
+// commands are the subcommands of catglow. Running catglow without one runs
+// the daemon.
+var commands = map[string]command{}
+
+// globalFlags returns a new flag set for a subcommand that has the global
+// flags.
+func globalFlags(name string) *pflag.FlagSet {
+	flags := pflag.NewFlagSet(name, pflag.ExitOnError)
+	flags.StringVarP(&config, "config", "c", config, "configuration file")
+	flags.BoolVarP(&verbose, "verbose", "v", verbose, "verbose output")
+	return flags
 }
 
 func main() {
-	pflag.Parse()
+	cmd := command{flags: pflag.CommandLine, run: run}
+	args := os.Args[1:]
+	if len(args) > 0 {
+		if c, ok := commands[args[0]]; ok {
+			cmd = c
+			args = args[1:]
+		}
+	}
+	cmd.flags.Parse(args)
 
 	logLevel := slog.LevelWarn
 	if verbose {
@@ -35,21 +64,21 @@ func main() {
 	}))
 	slog.SetDefault(logger)
 
-	if err := run(); err != nil {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if err := cmd.run(ctx); err != nil && !errors.Is(err, context.Canceled) {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
-func run() error {
+func run(ctx context.Context) error {
 	cfg, err := readConfig()
 	if err != nil {
 		return err
 	}
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
-	defer cancel()
-
 	// TODO: add a file detector for when /dev/ttyUSB0 is not available, and
 	// automatically start the daemon when it is available.
 
@@ -58,6 +87,15 @@ func run() error {
 		return fmt.Errorf("failed to create daemon: %w", err)
 	}
 
+	if record != "" {
+		f, err := os.Create(record)
+		if err != nil {
+			return fmt.Errorf("failed to create recording: %w", err)
+		}
+		defer f.Close()
+		d.RecordTo(f)
+	}
+
 	if err := d.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
 		return fmt.Errorf("daemon failed: %w", err)
 	}
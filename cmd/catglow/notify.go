@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"libdb.so/catglow"
+	"libdb.so/catglow/internal/led"
+)
+
+var notifyOpts = struct {
+	color    string
+	count    int
+	duration time.Duration
+	priority int
+}{
+	color:    "white",
+	count:    1,
+	duration: catglow.DefaultNotificationDuration,
+}
+
+func init() {
+	flags := globalFlags("notify")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: catglow notify [flags]")
+		fmt.Fprintln(os.Stderr, "Flashes the strip of the running daemon, which needs [http] or [dbus] configured.")
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&notifyOpts.color, "color", notifyOpts.color, "color to flash: a name, #rrggbb or r,g,b")
+	flags.IntVar(&notifyOpts.count, "count", notifyOpts.count, "how many times to flash")
+	flags.DurationVar(&notifyOpts.duration, "duration", notifyOpts.duration, "how long each flash takes")
+	flags.IntVar(&notifyOpts.priority, "priority", notifyOpts.priority, "notifications interrupt those of a lower priority")
+
+	commands["notify"] = command{flags: flags, run: runNotify}
+}
+
+func runNotify(ctx context.Context) error {
+	color, err := parseColor(notifyOpts.color)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := readConfig()
+	if err != nil {
+		return err
+	}
+
+	return catglow.SendNotification(ctx, cfg, catglow.Notification{
+		Color:    color,
+		Count:    notifyOpts.count,
+		Duration: notifyOpts.duration,
+		Priority: notifyOpts.priority,
+	})
+}
+
+// colorNames are the colors that can be given by name.
+var colorNames = map[string]led.RGBColor{
+	"white":   {255, 255, 255},
+	"red":     {255, 0, 0},
+	"orange":  {255, 96, 0},
+	"yellow":  {255, 200, 0},
+	"green":   {0, 255, 0},
+	"cyan":    {0, 255, 255},
+	"blue":    {0, 0, 255},
+	"purple":  {128, 0, 255},
+	"magenta": {255, 0, 255},
+	"pink":    {255, 94, 155},
+}
+
+// parseColor parses a color name, a hex color such as #ff0000, or the
+// channels separated by commas such as 255,0,0.
+func parseColor(s string) (led.RGBColor, error) {
+	if c, ok := colorNames[strings.ToLower(s)]; ok {
+		return c, nil
+	}
+
+	if h, ok := strings.CutPrefix(s, "#"); ok {
+		b, err := hex.DecodeString(h)
+		if err != nil || len(b) != 3 {
+			return led.RGBColor{}, fmt.Errorf("invalid hex color %q", s)
+		}
+		return led.RGBColor{b[0], b[1], b[2]}, nil
+	}
+
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return led.RGBColor{}, fmt.Errorf("unknown color %q", s)
+	}
+	var c led.RGBColor
+	for i, part := range parts {
+		v, err := strconv.ParseUint(strings.TrimSpace(part), 10, 8)
+		if err != nil {
+			return led.RGBColor{}, fmt.Errorf("invalid color %q", s)
+		}
+		c[i] = uint8(v)
+	}
+	return c, nil
+}
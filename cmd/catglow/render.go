@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"libdb.so/catglow"
+	"libdb.so/catglow/internal/audioin"
+	"libdb.so/catglow/internal/led"
+	"libdb.so/catglow/internal/ledimg"
+)
+
+var renderOpts = struct {
+	out      string
+	duration time.Duration
+	scene    string
+	audio    string
+	format   string
+	rate     int
+	size     int
+}{
+	duration: 10 * time.Second,
+	format:   audioin.DefaultFormat.String(),
+	size:     12,
+}
+
+func init() {
+	flags := globalFlags("render")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: catglow render [flags] --out <file.gif|file.png|file.apng>")
+		flags.PrintDefaults()
+	}
+	flags.StringVarP(&renderOpts.out, "out", "o", renderOpts.out, "output file: .gif, .apng or .png for a sprite sheet")
+	flags.DurationVarP(&renderOpts.duration, "duration", "d", renderOpts.duration, "how long to render for")
+	flags.StringVar(&renderOpts.scene, "scene", renderOpts.scene, "scene to render, defaults to the initial scene")
+	flags.StringVar(&renderOpts.audio, "audio", renderOpts.audio, "WAV, FLAC or raw PCM file for visualizers, defaults to silence")
+	flags.StringVar(&renderOpts.format, "format", renderOpts.format, "format of raw PCM audio")
+	flags.IntVar(&renderOpts.rate, "rate", renderOpts.rate, "frame rate, defaults to the configured one, or 50 for GIFs")
+	flags.IntVar(&renderOpts.size, "size", renderOpts.size, "size of each LED in pixels")
+
+	commands["render"] = command{flags: flags, run: runRender}
+}
+
+func runRender(ctx context.Context) error {
+	if renderOpts.out == "" {
+		commands["render"].flags.Usage()
+		return errors.New("no output file given")
+	}
+
+	ext := strings.ToLower(filepath.Ext(renderOpts.out))
+	switch ext {
+	case ".gif", ".apng", ".png":
+	default:
+		return fmt.Errorf("unknown output format %q", ext)
+	}
+
+	cfg, err := readConfig()
+	if err != nil {
+		return err
+	}
+
+	strip := ledimg.Strip{
+		Size: renderOpts.size,
+		Rate: renderOpts.rate,
+	}
+	if strip.Rate <= 0 && ext == ".gif" {
+		// GIF delays are in hundredths of a second, which 50 fps divides
+		// evenly and most viewers play back properly.
+		strip.Rate = 50
+	}
+	if strip.Rate <= 0 {
+		strip.Rate = cfg.Rate
+	}
+	if strip.Rate <= 0 {
+		strip.Rate = 60
+	}
+
+	var encode func(io.Writer, []led.LEDs) error
+	switch ext {
+	case ".gif":
+		encode = strip.EncodeGIF
+	case ".apng":
+		encode = strip.EncodeAPNG
+	case ".png":
+		encode = strip.EncodeSpriteSheet
+	}
+
+	opts := catglow.RenderOptions{
+		Scene:    renderOpts.scene,
+		Duration: renderOpts.duration,
+		Rate:     strip.Rate,
+	}
+
+	if renderOpts.audio != "" {
+		format, err := audioin.ParseFormat(renderOpts.format)
+		if err != nil {
+			return fmt.Errorf("invalid --format: %w", err)
+		}
+
+		dec, closer, err := audioin.OpenFile(renderOpts.audio, format)
+		if err != nil {
+			return fmt.Errorf("failed to open audio: %w", err)
+		}
+		defer closer.Close()
+
+		opts.Audio = dec
+	}
+
+	var frames []led.LEDs
+	err = catglow.Render(ctx, cfg, opts, func(leds led.LEDs) error {
+		frames = append(frames, append(led.LEDs(nil), leds...))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render: %w", err)
+	}
+
+	f, err := os.Create(renderOpts.out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := encode(f, frames); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", renderOpts.out, err)
+	}
+
+	return f.Close()
+}
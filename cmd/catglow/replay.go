@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"go.bug.st/serial"
+	"libdb.so/catglow/internal/ledrec"
+	"libdb.so/catglow/ledserial"
+)
+
+var replayOpts = struct {
+	to     string
+	device string
+	baud   int
+	seek   time.Duration
+	speed  float64
+}{
+	to:    "terminal",
+	speed: 1,
+}
+
+func init() {
+	flags := globalFlags("replay")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: catglow replay [flags] <recording>")
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&replayOpts.to, "to", replayOpts.to, `where to replay to: "terminal" or "device"`)
+	flags.StringVar(&replayOpts.device, "device", replayOpts.device, "serial device to replay to, defaults to the configured one")
+	flags.IntVar(&replayOpts.baud, "baud", replayOpts.baud, "baud rate of the serial device, defaults to the configured one")
+	flags.DurationVar(&replayOpts.seek, "seek", replayOpts.seek, "start replaying from this offset")
+	flags.Float64Var(&replayOpts.speed, "speed", replayOpts.speed, "playback speed relative to the recording")
+
+	commands["replay"] = command{flags: flags, run: runReplay}
+}
+
+func runReplay(ctx context.Context) error {
+	flags := commands["replay"].flags
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected a recording to replay")
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer f.Close()
+
+	r, err := ledrec.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	var draw func(ledrec.Frame) error
+
+	switch replayOpts.to {
+	case "terminal":
+		t := newTerminalOutput(os.Stdout)
+		defer t.close()
+		draw = t.draw
+
+	case "device":
+		d, err := openDeviceOutput(ctx, r.NumLEDs)
+		if err != nil {
+			return err
+		}
+		defer d.close()
+		draw = d.draw
+
+	default:
+		return fmt.Errorf("unknown replay output %q", replayOpts.to)
+	}
+
+	return ledrec.Play(ctx, r, ledrec.PlayOptions{
+		Seek:  replayOpts.seek,
+		Speed: replayOpts.speed,
+	}, draw)
+}
+
+// terminalOutput draws frames as a row of colored blocks on a terminal that
+// supports 24-bit colors.
+type terminalOutput struct {
+	w     *bufio.Writer
+	width int
+}
+
+func newTerminalOutput(w io.Writer) *terminalOutput {
+	width, _ := strconv.Atoi(os.Getenv("COLUMNS"))
+	if width <= 0 {
+		width = 80
+	}
+	return &terminalOutput{
+		w:     bufio.NewWriter(w),
+		width: width,
+	}
+}
+
+func (t *terminalOutput) draw(f ledrec.Frame) error {
+	// Strips that are wider than the terminal are drawn with every nth LED.
+	n := len(f.LEDs)
+	if n > t.width {
+		n = t.width
+	}
+
+	t.w.WriteString("\r")
+	for i := 0; i < n; i++ {
+		c := f.LEDs[i*len(f.LEDs)/n]
+		fmt.Fprintf(t.w, "\x1b[38;2;%d;%d;%dm█", c[0], c[1], c[2])
+	}
+	t.w.WriteString("\x1b[0m")
+	return t.w.Flush()
+}
+
+func (t *terminalOutput) close() {
+	t.w.WriteString("\n")
+	t.w.Flush()
+}
+
+// deviceOutput sends frames to a controller over ledserial, such as the
+// ESP32 or an emulator behind a pseudo-terminal. Frames are dropped while the
+// controller has not acknowledged the previous one.
+type deviceOutput struct {
+	port serial.Port
+	acks chan struct{}
+	errs chan error
+	busy bool
+}
+
+func openDeviceOutput(ctx context.Context, numLEDs int) (*deviceOutput, error) {
+	device := replayOpts.device
+	baud := replayOpts.baud
+	if device == "" || baud == 0 {
+		cfg, err := readConfig()
+		if err != nil {
+			return nil, fmt.Errorf("no --device and --baud given: %w", err)
+		}
+		if device == "" {
+			device = cfg.Device
+		}
+		if baud == 0 {
+			baud = cfg.Baud
+		}
+	}
+
+	port, err := serial.Open(device, &serial.Mode{BaudRate: baud})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open serial port: %w", err)
+	}
+
+	d := &deviceOutput{
+		port: port,
+		acks: make(chan struct{}, 1),
+		errs: make(chan error, 1),
+	}
+	go d.readPackets(ctx)
+
+	if err := d.write(ledserial.InitializePacket{NumLEDs: uint16(numLEDs)}); err != nil {
+		port.Close()
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (d *deviceOutput) readPackets(ctx context.Context) {
+	for ctx.Err() == nil {
+		p, err := ledserial.ReadOutgoingPacket(d.port, ledserial.ReadContext{})
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				continue
+			}
+			if ctx.Err() == nil {
+				d.fail(fmt.Errorf("failed to read packet: %w", err))
+			}
+			return
+		}
+
+		switch p := p.(type) {
+		case ledserial.AckPacket:
+			select {
+			case d.acks <- struct{}{}:
+			default:
+			}
+		case ledserial.LogPacket:
+			slog.Info("received log packet from controller", "message", p.Message)
+		case ledserial.ErrorPacket:
+			d.fail(fmt.Errorf("controller reported error: %s", p.Message))
+		case ledserial.PanicPacket:
+			d.fail(fmt.Errorf("controller panicked: %s", p.Message))
+		}
+	}
+}
+
+func (d *deviceOutput) fail(err error) {
+	select {
+	case d.errs <- err:
+	default:
+	}
+}
+
+func (d *deviceOutput) write(p ledserial.IncomingPacket) error {
+	if err := ledserial.WriteIncomingPacket(d.port, p); err != nil {
+		return fmt.Errorf("failed to write %s packet: %w", p.Type(), err)
+	}
+	d.busy = true
+	return nil
+}
+
+func (d *deviceOutput) draw(f ledrec.Frame) error {
+	select {
+	case err := <-d.errs:
+		return err
+	case <-d.acks:
+		d.busy = false
+	default:
+	}
+
+	if d.busy {
+		slog.Debug("dropping frame, controller is busy", "offset", f.Offset)
+		return nil
+	}
+
+	return d.write(ledserial.SetPacket{Pix: f.LEDs.AsPixels()})
+}
+
+func (d *deviceOutput) close() {
+	d.port.Close()
+}
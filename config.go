@@ -8,6 +8,7 @@ import (
 
 	"github.com/pelletier/go-toml"
 	"github.com/pkg/errors"
+	"libdb.so/catglow/internal/cron"
 	"libdb.so/catglow/internal/led"
 )
 
@@ -20,7 +21,55 @@ type Config struct {
 	Baud int `toml:"baud"`
 	// Rate is the refresh rate for the LEDs.
 	Rate int `toml:"rate"`
-	// LEDs is a list of LED configurations.
+	// LEDs is a list of LED configurations. If set, it is treated as a scene
+	// named "default" that comes before all other scenes.
+	LEDs []LEDConfig `toml:"led"`
+	// Scenes is a list of named scenes that the daemon can switch between.
+	Scenes []SceneConfig `toml:"scene"`
+	// DefaultScene is the name of the scene to start with. If empty, then the
+	// first scene is used.
+	DefaultScene string `toml:"default_scene"`
+	// Crossfade is the duration to crossfade between scenes when switching.
+	// If zero, then scenes are switched instantly.
+	Crossfade TOMLDuration `toml:"crossfade"`
+	// Schedule is a list of rules that switch scenes and brightness at certain
+	// times of the day.
+	Schedule []ScheduleConfig `toml:"schedule"`
+	// Segments are named ranges of LEDs that can be controlled at runtime
+	// independently of the scene. If empty, then the whole strip is a single
+	// segment named "strip".
+	Segments []SegmentConfig `toml:"segment"`
+	// WLED enables the WLED-compatible API if set.
+	WLED *WLEDConfig `toml:"wled,omitempty"`
+	// DDP enables receiving the whole strip over DDP if set. Received frames
+	// are drawn over everything else until they time out.
+	DDP *DDPConfig `toml:"ddp,omitempty"`
+	// OpenRGB enables the OpenRGB SDK server if set.
+	OpenRGB *OpenRGBConfig `toml:"openrgb,omitempty"`
+	// MQTT enables the MQTT client, which makes the segments show up as
+	// lights in Home Assistant, if set.
+	MQTT *MQTTConfig `toml:"mqtt,omitempty"`
+	// HTTP enables the HTTP API and web UI if set.
+	HTTP *HTTPConfig `toml:"http,omitempty"`
+	// DBus enables the D-Bus service on the session bus if set.
+	DBus *DBusConfig `toml:"dbus,omitempty"`
+	// MPRIS makes visualizers follow the media player on the session bus if
+	// set.
+	MPRIS *MPRISConfig `toml:"mpris,omitempty"`
+	// Notifications flashes the strip for desktop notifications if set.
+	Notifications *NotificationsConfig `toml:"notifications,omitempty"`
+}
+
+// DefaultSceneName is the name of the scene made from the top-level LED
+// configurations.
+const DefaultSceneName = "default"
+
+// SceneConfig is the configuration for a scene. A scene is a named preset of
+// LED configurations.
+type SceneConfig struct {
+	// Name is the name of the scene.
+	Name string `toml:"name"`
+	// LEDs is a list of LED configurations for this scene.
 	LEDs []LEDConfig `toml:"led"`
 }
 
@@ -30,18 +79,95 @@ func (c *Config) Validate() error {
 		return errors.New("no LEDs configured")
 	}
 
-	// Check for overlapping LED ranges.
-	for i, led1 := range c.LEDs {
-		for j, led2 := range c.LEDs {
-			if i == j {
-				continue
+	scenes := c.AllScenes()
+	for i, scene := range scenes {
+		if scene.Name == "" {
+			return fmt.Errorf("scene %d has no name", i)
+		}
+
+		for _, other := range scenes[:i] {
+			if other.Name == scene.Name {
+				return fmt.Errorf("duplicate scene %q", scene.Name)
 			}
+		}
 
-			if led1.Range[0] >= led2.Range[0] && led1.Range[0] <= led2.Range[1] {
-				return fmt.Errorf("LED range %v overlaps with %v", led1.Range, led2.Range)
+		if err := validateLEDs(scene.LEDs); err != nil {
+			return errors.Wrapf(err, "scene %q", scene.Name)
+		}
+
+		for _, ledCfg := range scene.LEDs {
+			if ledCfg.Visualizer != nil && ledCfg.Visualizer.GradientSource == AlbumArtGradientSource && c.MPRIS == nil {
+				return fmt.Errorf("scene %q: album-art gradients require mpris to be configured", scene.Name)
+			}
+		}
+	}
+
+	if c.DefaultScene != "" && c.Scene(c.DefaultScene) == nil {
+		return fmt.Errorf("default scene %q does not exist", c.DefaultScene)
+	}
+
+	for i, rule := range c.Schedule {
+		if _, err := rule.Parse(); err != nil {
+			return errors.Wrapf(err, "schedule rule %d", i)
+		}
+		if rule.Scene != "" && c.Scene(rule.Scene) == nil {
+			return fmt.Errorf("schedule rule %d: scene %q does not exist", i, rule.Scene)
+		}
+		if rule.Brightness != nil && (*rule.Brightness < 0 || *rule.Brightness > 1) {
+			return fmt.Errorf("schedule rule %d: brightness must be within [0, 1]", i)
+		}
+	}
+
+	numLEDs := c.NumLEDs()
+	segments := c.AllSegments()
+	for i, segment := range segments {
+		if segment.Name == "" {
+			return fmt.Errorf("segment %d has no name", i)
+		}
+		if segment.Range[0] < 0 || segment.Range[0] >= segment.Range[1] || segment.Range[1] > numLEDs {
+			return fmt.Errorf("segment %q has invalid range %v", segment.Name, segment.Range)
+		}
+		for _, other := range segments[:i] {
+			if other.Name == segment.Name {
+				return fmt.Errorf("duplicate segment %q", segment.Name)
+			}
+			if segment.Range[0] < other.Range[1] && other.Range[0] < segment.Range[1] {
+				return fmt.Errorf("segment %q overlaps with %q", segment.Name, other.Name)
 			}
+		}
+	}
 
-			if led1.Range[1] >= led2.Range[0] && led1.Range[1] <= led2.Range[1] {
+	if c.DDP != nil {
+		if err := c.DDP.validate(); err != nil {
+			return errors.Wrap(err, "ddp")
+		}
+	}
+
+	if c.MPRIS != nil {
+		if err := c.MPRIS.validate(); err != nil {
+			return errors.Wrap(err, "mpris")
+		}
+	}
+
+	if c.Notifications != nil {
+		if err := c.Notifications.validate(); err != nil {
+			return errors.Wrap(err, "notifications")
+		}
+	}
+
+	return nil
+}
+
+func validateLEDs(leds []LEDConfig) error {
+	// Check for empty and overlapping LED ranges. Ranges are half-open, so
+	// [0, 40] and [40, 192] do not overlap, and [40, 40] is empty.
+	for i, led1 := range leds {
+		if led1.Range[0] < 0 || led1.Range[0] >= led1.Range[1] {
+			return fmt.Errorf("invalid LED range %v", led1.Range)
+		}
+
+		for _, led2 := range leds[:i] {
+			if led1.Range[0] < led2.Range[1] && led2.Range[0] < led1.Range[1] {
 				return fmt.Errorf("LED range %v overlaps with %v", led1.Range, led2.Range)
 			}
 		}
@@ -50,17 +176,102 @@ func (c *Config) Validate() error {
 	return nil
 }
 
-// NumLEDs returns the number of LEDs configured.
+// NumLEDs returns the number of LEDs configured across all scenes.
 func (c *Config) NumLEDs() int {
 	var numLEDs int
-	for _, led := range c.LEDs {
-		if led.Range[1] > numLEDs {
-			numLEDs = led.Range[1]
+	for _, scene := range c.AllScenes() {
+		for _, led := range scene.LEDs {
+			if led.Range[1] > numLEDs {
+				numLEDs = led.Range[1]
+			}
 		}
 	}
 	return numLEDs
 }
 
+// AllScenes returns all scenes, including the implicit default scene made from
+// the top-level LED configurations.
+func (c *Config) AllScenes() []SceneConfig {
+	if len(c.LEDs) == 0 {
+		return c.Scenes
+	}
+
+	scenes := make([]SceneConfig, 0, len(c.Scenes)+1)
+	scenes = append(scenes, SceneConfig{Name: DefaultSceneName, LEDs: c.LEDs})
+	scenes = append(scenes, c.Scenes...)
+	return scenes
+}
+
+// Scene returns the scene with the given name, or nil if there is none.
+func (c *Config) Scene(name string) *SceneConfig {
+	for _, scene := range c.AllScenes() {
+		if scene.Name == name {
+			return &scene
+		}
+	}
+	return nil
+}
+
+// InitialScene returns the name of the scene that the daemon starts with.
+func (c *Config) InitialScene() string {
+	if c.DefaultScene != "" {
+		return c.DefaultScene
+	}
+	if scenes := c.AllScenes(); len(scenes) > 0 {
+		return scenes[0].Name
+	}
+	return ""
+}
+
+// DefaultSegmentName is the name of the segment that covers the whole strip
+// if no segments are configured.
+const DefaultSegmentName = "strip"
+
+// AllSegments returns the configured segments, or a single segment covering
+// the whole strip if there are none.
+func (c *Config) AllSegments() []SegmentConfig {
+	if len(c.Segments) > 0 {
+		return c.Segments
+	}
+	return []SegmentConfig{{Name: DefaultSegmentName, Range: [2]int{0, c.NumLEDs()}}}
+}
+
+// ScheduleConfig is a rule that picks a scene and brightness at certain times.
+type ScheduleConfig struct {
+	// At is the time of the day to fire this rule at, in "15:04" format.
+	// Either At or Cron must be set.
+	At string `toml:"at,omitempty"`
+	// Cron is a cron expression for when to fire this rule, e.g.
+	// "30 21 * * mon-fri".
+	Cron string `toml:"cron,omitempty"`
+	// Scene is the scene to switch to. If empty, then the scene is unchanged.
+	Scene string `toml:"scene,omitempty"`
+	// Brightness is the brightness to set within [0, 1]. If nil, then the
+	// brightness is unchanged.
+	Brightness *float64 `toml:"brightness,omitempty"`
+	// Transition is the duration to transition to the new scene and brightness
+	// over.
+	Transition TOMLDuration `toml:"transition,omitempty"`
+}
+
+// Parse parses the rule's time into a cron schedule.
+func (c ScheduleConfig) Parse() (*cron.Schedule, error) {
+	switch {
+	case c.At != "" && c.Cron != "":
+		return nil, errors.New("only one of at and cron may be set")
+	case c.At != "":
+		t, err := time.Parse("15:04", c.At)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time %q, must be HH:MM", c.At)
+		}
+		return cron.Daily(t.Hour(), t.Minute()), nil
+	case c.Cron != "":
+		return cron.Parse(c.Cron)
+	default:
+		return nil, errors.New("either at or cron must be set")
+	}
+}
+
 // LEDConfig is the configuration for a range of LEDs.
 type LEDConfig struct {
 	// Range is the range of LEDs to configure.
@@ -73,8 +284,64 @@ type LEDConfig struct {
 	Color *led.RGBColor `toml:"color,omitempty"`
 	// Snake is the configuration for the snake animation.
 	Snake *SnakeAnimationConfig `toml:"snake,omitempty"`
+	// Breathing is the configuration for the breathing animation.
+	Breathing *BreathingAnimationConfig `toml:"breathing,omitempty"`
+	// Flag is the configuration for drawing a flag.
+	Flag *FlagAnimationConfig `toml:"flag,omitempty"`
+	// Cycle is the configuration for the color cycling animation.
+	Cycle *CycleAnimationConfig `toml:"cycle,omitempty"`
+	// Pattern is the configuration for a built-in procedural pattern.
+	Pattern *PatternConfig `toml:"pattern,omitempty"`
 	// Visualizer is the configuration for the visualizer.
 	Visualizer *VisualizerConfig `toml:"visualizer,omitempty"`
+	// E131 is the configuration for receiving the LEDs over E1.31 (sACN).
+	E131 *E131Config `toml:"e131,omitempty"`
+	// ArtNet is the configuration for receiving the LEDs over Art-Net.
+	ArtNet *ArtNetConfig `toml:"artnet,omitempty"`
+	// DDP is the configuration for receiving the LEDs over DDP.
+	DDP *DDPConfig `toml:"ddp,omitempty"`
+	// Ambilight is the configuration for mirroring the edges of a screen.
+	Ambilight *AmbilightConfig `toml:"ambilight,omitempty"`
+
+	// Idle is the animation to fall back to when the visualizer has been
+	// silent for a while, or when nothing was received over the network or
+	// from the screen. It is only used with Visualizer, E131, ArtNet, DDP and
+	// Ambilight.
+	Idle *IdleConfig `toml:"idle,omitempty"`
+}
+
+// SegmentConfig is a named range of LEDs that can be controlled at runtime,
+// such as over the WLED API. A segment can follow the scene, which is the
+// default, or override it with a color or an effect.
+type SegmentConfig struct {
+	// Name is the name of the segment.
+	Name string `toml:"name"`
+	// Range is the range of LEDs in the segment.
+	Range [2]int `toml:"range"`
+}
+
+// IdleConfig is the configuration for the animation that a visualizer falls
+// back to when the audio is silent, or that a network receiver falls back to
+// when nothing is received.
+type IdleConfig struct {
+	// Fade is the duration to crossfade between the visualizer and the idle
+	// animation.
+	Fade TOMLDuration `toml:"fade"`
+
+	// Only one of the following fields should be set.
+
+	// Color is the static color to show.
+	Color *led.RGBColor `toml:"color,omitempty"`
+	// Snake is the configuration for the snake animation.
+	Snake *SnakeAnimationConfig `toml:"snake,omitempty"`
+	// Breathing is the configuration for the breathing animation.
+	Breathing *BreathingAnimationConfig `toml:"breathing,omitempty"`
+	// Flag is the configuration for drawing a flag.
+	Flag *FlagAnimationConfig `toml:"flag,omitempty"`
+	// Cycle is the configuration for the color cycling animation.
+	Cycle *CycleAnimationConfig `toml:"cycle,omitempty"`
+	// Pattern is the configuration for a built-in procedural pattern.
+	Pattern *PatternConfig `toml:"pattern,omitempty"`
 }
 
 // SnakeAnimationConfig is the configuration for the snake animation.
@@ -91,6 +358,100 @@ type SnakeAnimationChunk struct {
 	Color led.RGBColor `toml:"color"`
 }
 
+// BreathingAnimationConfig is the configuration for the breathing animation.
+type BreathingAnimationConfig struct {
+	// Color is the color at full brightness.
+	Color led.RGBColor `toml:"color"`
+	// Flag is the flag to breathe. If set, then it is used instead of Color.
+	Flag *FlagAnimationConfig `toml:"flag,omitempty"`
+	// Period is the duration of one full breath.
+	Period TOMLDuration `toml:"period"`
+	// Function is the breathing function, either "sine" (default) or
+	// "linear".
+	Function string `toml:"function"`
+}
+
+// FlagAnimationConfig is the configuration for drawing a flag as stripes
+// along the LEDs.
+type FlagAnimationConfig struct {
+	// Preset is the name of a built-in flag, such as "trans", "pride", "bi",
+	// "lesbian", "nonbinary", "pan", "ace" or "genderfluid".
+	Preset string `toml:"preset,omitempty"`
+	// Stripes is the list of stripe colors. It is used if Preset is empty.
+	Stripes []led.RGBColor `toml:"stripes,omitempty"`
+}
+
+// CycleAnimationConfig is the configuration for the color cycling animation.
+type CycleAnimationConfig struct {
+	// Colors is the list of colors to cycle through.
+	Colors []led.RGBColor `toml:"colors"`
+	// Interval is how long each color is shown for.
+	Interval TOMLDuration `toml:"interval"`
+	// Fade is how long to fade into the next color. If zero, then colors are
+	// switched instantly.
+	Fade TOMLDuration `toml:"fade"`
+}
+
+// PatternConfig is the configuration for a built-in procedural pattern. Not
+// all patterns use all fields; unset fields mean the pattern's defaults.
+type PatternConfig struct {
+	// Kind is the kind of pattern to draw.
+	Kind PatternKind `toml:"kind"`
+	// Speed is the duration of one step of the pattern, which is usually
+	// moving by one LED.
+	Speed TOMLDuration `toml:"speed,omitempty"`
+	// Colors is the palette of the pattern.
+	Colors []led.RGBColor `toml:"colors,omitempty"`
+	// Size is the size of the moving part of the pattern, such as the tail
+	// of a comet or the spacing of a theater chase.
+	Size int `toml:"size,omitempty"`
+	// Density is the chance of a twinkle or spark appearing per step within
+	// [0, 1]. If nil, then the pattern's default is used.
+	Density *float64 `toml:"density,omitempty"`
+	// Decay is how quickly trails fade or the fire cools per step within
+	// [0, 1]. If nil, then the pattern's default is used.
+	Decay *float64 `toml:"decay,omitempty"`
+	// Seed is the seed for the random number generator.
+	Seed int64 `toml:"seed,omitempty"`
+}
+
+// PatternKind is the kind of procedural pattern to draw.
+type PatternKind string
+
+const (
+	// RainbowPattern scrolls a rainbow along the strip.
+	RainbowPattern PatternKind = "rainbow"
+	// WipePattern fills the strip one LED at a time with each color.
+	WipePattern PatternKind = "wipe"
+	// TheaterChasePattern crawls every few LEDs like marquee lights.
+	TheaterChasePattern PatternKind = "theater-chase"
+	// TwinklePattern lights up random LEDs that fade out.
+	TwinklePattern PatternKind = "twinkle"
+	// FirePattern simulates a fire rising along the strip.
+	FirePattern PatternKind = "fire"
+	// MeteorPattern flies a meteor with a sparkling trail.
+	MeteorPattern PatternKind = "meteor"
+	// CometPattern loops a comet with a smooth tail.
+	CometPattern PatternKind = "comet"
+	// LarsonPattern bounces an eye back and forth.
+	LarsonPattern PatternKind = "larson"
+	// PlasmaPattern draws overlapping sine waves.
+	PlasmaPattern PatternKind = "plasma"
+)
+
+// patternKinds lists all pattern kinds.
+var patternKinds = []PatternKind{
+	RainbowPattern,
+	WipePattern,
+	TheaterChasePattern,
+	TwinklePattern,
+	FirePattern,
+	MeteorPattern,
+	CometPattern,
+	LarsonPattern,
+	PlasmaPattern,
+}
+
 // VisualizerConfig is the configuration for the visualizer.
 type VisualizerConfig struct {
 	Kind    VisualizerKind `toml:"kind"`
@@ -99,12 +460,84 @@ type VisualizerConfig struct {
 	Backend string         `toml:"backend"`
 	Device  string         `toml:"device"`
 	Smooth  float64        `toml:"smooth"`
+	// MinFreq and MaxFreq are the frequency cut-offs of the bins in Hz. The
+	// defaults are 60 and 8000.
+	MinFreq float64 `toml:"min_freq"`
+	MaxFreq float64 `toml:"max_freq"`
+	// FreqScale is the scale that the bins are spaced on: "linear", "log",
+	// "mel" or "bark". The default is "log".
+	FreqScale string `toml:"freq_scale"`
+	// Gains is the gain curve in dB from the lowest to the highest bin,
+	// interpolated over the bins.
+	Gains []float64 `toml:"gains"`
+	// AGC enables automatic gain control with the given attack and release
+	// times, instead of scaling by the recent peaks.
+	AGC        bool         `toml:"agc"`
+	AGCAttack  TOMLDuration `toml:"agc_attack"`
+	AGCRelease TOMLDuration `toml:"agc_release"`
+	// ChannelStyle is how the audio channels are laid out on the LEDs. The
+	// default is "mono-left".
+	ChannelStyle string `toml:"channel_style"`
+
+	// Format is the format of raw PCM audio for the "file" and "stdin"
+	// backends, written as "encoding:rate:channels". The default is
+	// "s16le:44100:2", which matches MPD's FIFO output.
+	Format string `toml:"format"`
+	// Pace is the playback speed of the "file" backend relative to real
+	// time. The default is 1.
+	Pace float64 `toml:"pace"`
+	// Loop plays the file of the "file" backend again once it ends.
+	Loop bool `toml:"loop"`
 
 	Gradients          []led.RGBColor `toml:"gradients"`
 	GradientMode       GradientMode   `toml:"gradient_mode"`
 	GradientPeakSwitch float64        `toml:"gradient_peak_switch"`
 	GradientPeakBin    int            `toml:"gradient_peak_bin"`
 	GradientDuration   TOMLDuration   `toml:"gradient_duration"`
+	// GradientSource is where the colors of the gradient come from. If
+	// empty, then they are Gradients.
+	GradientSource GradientSource `toml:"gradient_source"`
+
+	// SilenceThreshold is the RMS of the audio below which it is considered
+	// silent. The default is 0.001, or roughly -60 dBFS.
+	SilenceThreshold float64 `toml:"silence_threshold"`
+	// SilenceDuration is how long the audio must be silent before the LEDs
+	// fall back to the idle animation. The default is 5s.
+	SilenceDuration TOMLDuration `toml:"silence_duration"`
+
+	// BeatSensitivity is how far the spectral flux must rise above its
+	// recent mean, in standard deviations, to count as a beat. The default
+	// is 1.5.
+	BeatSensitivity float64 `toml:"beat_sensitivity"`
+	// BeatMinBPM and BeatMaxBPM bound the estimated tempo. The defaults are
+	// 60 and 180.
+	BeatMinBPM float64 `toml:"beat_min_bpm"`
+	BeatMaxBPM float64 `toml:"beat_max_bpm"`
+	// BeatDecay is how long the flash of the beat visualizer takes to fade
+	// out. The default is 250ms.
+	BeatDecay TOMLDuration `toml:"beat_decay"`
+
+	// BassBins is the number of lowest bins that make up the bass energy of
+	// the waterfall visualizer. The default is the lowest quarter.
+	BassBins int `toml:"bass_bins"`
+	// ScrollSpeed is how many LEDs per second the waterfall visualizer
+	// scrolls by. The default is 30.
+	ScrollSpeed float64 `toml:"scroll_speed"`
+	// PulseSoftness is the fraction of the pulse visualizer that fades out
+	// towards its edges. The default is 0.25.
+	PulseSoftness float64 `toml:"pulse_softness"`
+	// PeakHold is how long the peak dots of the VU visualizer stay before
+	// they fall. The default is 1s.
+	PeakHold TOMLDuration `toml:"peak_hold"`
+	// PeakFall is how fast the peak dots of the VU visualizer fall in meter
+	// lengths per second. The default is 0.5.
+	PeakFall float64 `toml:"peak_fall"`
+	// PeakColor is the color of the peak dots of the VU visualizer. The
+	// default is white.
+	PeakColor *led.RGBColor `toml:"peak_color"`
+	// HueRange is the hues within [0, 1] that the spectrum-hue visualizer
+	// maps the lowest and highest bins to. The default is red to violet.
+	HueRange [2]float64 `toml:"hue_range"`
 }
 
 // VisualizerKind is the kind of visualizer to use.
@@ -119,6 +552,31 @@ const (
 	// MeterVisualizer shows a horizontal meter based on the normalized
 	// amplitude.
 	MeterVisualizer VisualizerKind = "meter"
+	// BeatVisualizer flashes the entire strip on each beat and advances to
+	// the next gradient color.
+	BeatVisualizer VisualizerKind = "beat"
+	// WaterfallVisualizer scrolls the history of the bass energy along the
+	// strip.
+	WaterfallVisualizer VisualizerKind = "waterfall"
+	// PulseVisualizer expands from the center proportionally to the
+	// loudness.
+	PulseVisualizer VisualizerKind = "pulse"
+	// VUVisualizer draws a meter for each channel with peak-hold dots. It
+	// uses the "stereo-split" channel style unless another one is set.
+	VUVisualizer VisualizerKind = "vu"
+	// SpectrumHueVisualizer colors the strip with a hue mapped from the
+	// dominant frequency.
+	SpectrumHueVisualizer VisualizerKind = "spectrum-hue"
+)
+
+// GradientSource is where the colors of a gradient come from.
+type GradientSource string
+
+const (
+	// AlbumArtGradientSource takes the colors from the cover art of the
+	// track that the media player plays, which requires MPRIS to be
+	// configured. Gradients are used while there is no cover art.
+	AlbumArtGradientSource GradientSource = "album-art"
 )
 
 // GradientMode is the mode for the gradient.
@@ -129,10 +587,271 @@ const (
 	PeakGradientMode GradientMode = "peak"
 	// DurationGradientMode means the gradient is changed based on the duration.
 	DurationGradientMode GradientMode = "duration"
+	// BeatGradientMode means the gradient is changed on every detected beat.
+	BeatGradientMode GradientMode = "beat"
 	// StaticGradientMode means to only use the first color in the gradient.
 	StaticGradientMode GradientMode = "static"
 )
 
+// E131Config is the configuration for receiving DMX universes over E1.31
+// (sACN), such as from xLights or QLC+. Each LED takes three channels, and
+// LEDs continue into the following universes once a universe is full.
+type E131Config struct {
+	// Universe is the first universe to receive.
+	Universe uint16 `toml:"universe"`
+	// StartChannel is the channel of the first LED in the first universe,
+	// starting from 1. If zero, then 1 is used.
+	StartChannel int `toml:"start_channel,omitempty"`
+	// ChannelsPerUniverse is the number of channels used in each universe.
+	// If zero, then 510 is used, which is 170 LEDs per universe.
+	ChannelsPerUniverse int `toml:"channels_per_universe,omitempty"`
+	// Listen is the UDP address to listen on. If empty, then ":5568" is
+	// used. Ranges listening on the same address share a socket.
+	Listen string `toml:"listen,omitempty"`
+	// Multicast joins the multicast groups of the universes. Unicast is
+	// always received.
+	Multicast bool `toml:"multicast,omitempty"`
+	// Interface is the network interface to join multicast groups on.
+	Interface string `toml:"interface,omitempty"`
+	// Timeout is how long to wait for data before falling back to the idle
+	// animation. If zero, then 2.5s is used.
+	Timeout TOMLDuration `toml:"timeout,omitempty"`
+}
+
+// ArtNetConfig is the configuration for receiving DMX universes over
+// Art-Net. The daemon shows up as an Art-Net node with an output port for each
+// universe. LEDs are mapped onto universes the same way as with E131Config.
+type ArtNetConfig struct {
+	// Universe is the 15-bit port address of the first universe, which is
+	// the Net, Sub-Net and Universe as net*256 + subnet*16 + universe.
+	Universe uint16 `toml:"universe"`
+	// StartChannel is the channel of the first LED in the first universe,
+	// starting from 1. If zero, then 1 is used.
+	StartChannel int `toml:"start_channel,omitempty"`
+	// ChannelsPerUniverse is the number of channels used in each universe.
+	// If zero, then 510 is used, which is 170 LEDs per universe.
+	ChannelsPerUniverse int `toml:"channels_per_universe,omitempty"`
+	// Listen is the UDP address to listen on. If empty, then ":6454" is
+	// used. Ranges listening on the same address are ports of the same node.
+	Listen string `toml:"listen,omitempty"`
+	// ShortName is the name that controllers show for the node. If empty,
+	// then "catglow" is used.
+	ShortName string `toml:"short_name,omitempty"`
+	// LongName is the description that controllers show for the node.
+	LongName string `toml:"long_name,omitempty"`
+	// Timeout is how long to wait for data before falling back to the idle
+	// animation. If zero, then 4s is used.
+	Timeout TOMLDuration `toml:"timeout,omitempty"`
+}
+
+// DDPConfig is the configuration for receiving pixels over the Distributed
+// Display Protocol, such as from xLights or LedFx. Frames are shown once the
+// sender pushes them.
+type DDPConfig struct {
+	// Listen is the UDP address to listen on. If empty, then ":4048" is
+	// used. Ranges listening on the same address share the stream.
+	Listen string `toml:"listen,omitempty"`
+	// Offset is the pixel in the stream that the first LED receives.
+	Offset int `toml:"offset,omitempty"`
+	// Timeout is how long to wait for data before falling back to the idle
+	// animation, or to the scene for the whole strip. If zero, then 2.5s is
+	// used.
+	Timeout TOMLDuration `toml:"timeout,omitempty"`
+}
+
+// AmbilightConfig is the configuration for coloring a strip around a screen
+// after the edges of what is shown on it. Each LED shows the average color of
+// the region of the screen next to it.
+type AmbilightConfig struct {
+	// Source is where the frames of the screen come from.
+	Source AmbilightSource `toml:"source"`
+	// Path is the directory of images for DirAmbilightSource, or the named
+	// pipe or file of raw frames for PipeAmbilightSource.
+	Path string `toml:"path"`
+	// Width and Height are the size of the raw frames in pixels, which are
+	// only used with PipeAmbilightSource.
+	Width  int `toml:"width,omitempty"`
+	Height int `toml:"height,omitempty"`
+	// Interval is how long each image of DirAmbilightSource is shown. If
+	// zero, then 1s is used.
+	Interval TOMLDuration `toml:"interval,omitempty"`
+
+	// Top, Right, Bottom and Left are the number of LEDs along each edge of
+	// the screen. They must add up to the number of LEDs in the range.
+	Top    int `toml:"top,omitempty"`
+	Right  int `toml:"right,omitempty"`
+	Bottom int `toml:"bottom,omitempty"`
+	Left   int `toml:"left,omitempty"`
+	// Start is the corner of the screen where the strip starts as seen from
+	// its front: "top-left", "top-right", "bottom-right" or "bottom-left".
+	// If empty, then "bottom-left" is used.
+	Start string `toml:"start,omitempty"`
+	// Direction is the direction that the strip runs in from its start as
+	// seen from the front of the screen: "clockwise" or "counterclockwise".
+	// If empty, then "clockwise" is used.
+	Direction string `toml:"direction,omitempty"`
+	// Depth is how far each LED's region reaches into the screen, as a
+	// fraction of its height or width. If zero, then 0.1 is used.
+	Depth float64 `toml:"depth,omitempty"`
+
+	// Smoothing is about how long the LEDs take to follow a change of the
+	// picture. If zero, then 200ms is used.
+	Smoothing TOMLDuration `toml:"smoothing,omitempty"`
+	// Timeout is how long to wait for frames before falling back to the idle
+	// animation. If zero, then 2.5s is used.
+	Timeout TOMLDuration `toml:"timeout,omitempty"`
+}
+
+// AmbilightSource is where the frames of the screen come from.
+type AmbilightSource string
+
+const (
+	// DirAmbilightSource shows the PNG and JPEG images in a directory in the
+	// order of their names, over and over, such as to try out the geometry.
+	DirAmbilightSource AmbilightSource = "dir"
+	// PipeAmbilightSource reads raw frames of 8-bit RGB pixels from a named
+	// pipe, such as what FFmpeg writes with -f rawvideo -pix_fmt rgb24.
+	PipeAmbilightSource AmbilightSource = "pipe"
+)
+
+// WLEDConfig is the configuration for the WLED-compatible API, which lets
+// WLED apps and Home Assistant's WLED integration control the daemon.
+type WLEDConfig struct {
+	// Listen is the HTTP address to serve the JSON API on. If empty, then
+	// ":80" is used, which is where WLED clients expect it. If the address
+	// cannot be bound, such as without the privilege to bind port 80, then
+	// the API is disabled with a warning.
+	Listen string `toml:"listen,omitempty"`
+	// Realtime is the UDP address to receive the realtime protocols on. If
+	// empty, then ":21324" is used. Like the API, they are disabled if the
+	// address cannot be bound.
+	Realtime string `toml:"realtime,omitempty"`
+	// Name is the name that clients show. If empty, then "catglow" is used.
+	Name string `toml:"name,omitempty"`
+}
+
+// OpenRGBConfig is the configuration for the OpenRGB SDK server, which makes
+// the strip show up as a device in OpenRGB and other SDK clients.
+type OpenRGBConfig struct {
+	// Listen is the TCP address to listen on. If empty, then
+	// "127.0.0.1:6742" is used, which is where OpenRGB looks for servers.
+	Listen string `toml:"listen,omitempty"`
+	// Name is the name of the device. If empty, then "catglow" is used.
+	Name string `toml:"name,omitempty"`
+}
+
+// MQTTConfig is the configuration for the MQTT client. Each segment is
+// announced to Home Assistant as a light through MQTT discovery.
+type MQTTConfig struct {
+	// Broker is the URL of the broker. If empty, then
+	// "tcp://localhost:1883" is used.
+	Broker string `toml:"broker,omitempty"`
+	// Username and Password authenticate with the broker, if set.
+	Username string `toml:"username,omitempty"`
+	Password string `toml:"password,omitempty"`
+	// ID identifies the daemon in topics and in Home Assistant. It must be
+	// unique per broker. If empty, then "catglow" is used.
+	ID string `toml:"id,omitempty"`
+	// Name is the name of the device in Home Assistant. If empty, then the
+	// ID is used.
+	Name string `toml:"name,omitempty"`
+	// Topic is the topic that everything is published under. If empty, then
+	// "catglow/<id>" is used.
+	Topic string `toml:"topic,omitempty"`
+	// DiscoveryPrefix is Home Assistant's discovery prefix. If empty, then
+	// "homeassistant" is used.
+	DiscoveryPrefix string `toml:"discovery_prefix,omitempty"`
+}
+
+// HTTPConfig is the configuration for the HTTP API and web UI.
+type HTTPConfig struct {
+	// Listen is the TCP address to listen on. If empty, then
+	// "127.0.0.1:8080" is used. The API has no authentication, so it should
+	// only be exposed to trusted networks.
+	Listen string `toml:"listen,omitempty"`
+}
+
+// DBusConfig is the configuration for the D-Bus service.
+type DBusConfig struct {
+	// Name is the well-known name to own on the session bus. If empty, then
+	// "so.libdb.Catglow" is used.
+	Name string `toml:"name,omitempty"`
+}
+
+// MPRISConfig is the configuration for following media players over MPRIS.
+// Visualizers react to the active player, which is the one that started
+// playing last.
+type MPRISConfig struct {
+	// Players limits the players that are followed to the ones with these
+	// names, such as "spotify". If empty, then all players are followed.
+	Players []string `toml:"players,omitempty"`
+	// PauseIdle makes visualizers fall back to their idle animation while
+	// the player is paused or stopped. Visualizers without one are turned
+	// off instead.
+	PauseIdle bool `toml:"pause_idle,omitempty"`
+	// TrackChange is what visualizers do when the track changes. If empty,
+	// then they do nothing.
+	TrackChange TrackChangeAction `toml:"track_change,omitempty"`
+	// FlashColor is the color that visualizers flash on track changes. The
+	// default is white.
+	FlashColor *led.RGBColor `toml:"flash_color,omitempty"`
+	// FlashDuration is how long the flash takes to fade out. The default is
+	// 500ms.
+	FlashDuration TOMLDuration `toml:"flash_duration,omitempty"`
+	// ArtColors is the number of colors that are taken from cover art for
+	// visualizers with album-art gradients. The default is 4.
+	ArtColors int `toml:"art_colors,omitempty"`
+	// FollowPlayer makes visualizers capture from the device named after the
+	// active player, such as "spotify", which is what PipeWire names the
+	// streams of most players. Visualizers go back to their own device once
+	// no player is left.
+	FollowPlayer bool `toml:"follow_player,omitempty"`
+	// Devices maps the names of players to the devices that FollowPlayer
+	// captures from, for players whose streams are named differently.
+	Devices map[string]string `toml:"devices,omitempty"`
+}
+
+// TrackChangeAction is what visualizers do when the track changes.
+type TrackChangeAction string
+
+const (
+	// FlashTrackChange flashes the visualizers.
+	FlashTrackChange TrackChangeAction = "flash"
+	// GradientTrackChange advances the gradients of the visualizers to their
+	// next color.
+	GradientTrackChange TrackChangeAction = "gradient"
+)
+
+// NotificationsConfig is the configuration for flashing the strip when
+// desktop applications show notifications, which are watched on the session
+// bus.
+type NotificationsConfig struct {
+	// Rules decide which notifications flash the strip and how. The first
+	// rule that matches a notification is used, and notifications that match
+	// no rule are ignored.
+	Rules []NotificationRule `toml:"rule"`
+}
+
+// NotificationRule flashes the strip for the matching notifications.
+type NotificationRule struct {
+	// App is the name of the application, such as "Slack", which is matched
+	// regardless of case. If empty, then every application matches.
+	App string `toml:"app,omitempty"`
+	// Summary is text that the summary of the notification must contain,
+	// regardless of case. If empty, then every summary matches.
+	Summary string `toml:"summary,omitempty"`
+	// Color is the color to flash. If nil, then white is used.
+	Color *led.RGBColor `toml:"color,omitempty"`
+	// Count is how many times to flash. If zero, then the strip flashes
+	// once.
+	Count int `toml:"count,omitempty"`
+	// Duration is how long each flash takes. If zero, then 500ms is used.
+	Duration TOMLDuration `toml:"duration,omitempty"`
+	// Priority decides which notification is shown first. Notifications
+	// interrupt those of a lower priority.
+	Priority int `toml:"priority,omitempty"`
+}
+
 // TOMLDuration is a duration that can be parsed from TOML.
 type TOMLDuration time.Duration
 
@@ -0,0 +1,578 @@
+package catglow
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// Effects that segments can show besides the patterns.
+const (
+	// SolidEffect fills a segment with its first color.
+	SolidEffect = "solid"
+	// SceneEffect makes a segment follow the current scene.
+	SceneEffect = "scene"
+)
+
+// Effects returns all effects that segments can show: SolidEffect,
+// SceneEffect and the pattern kinds.
+func Effects() []string {
+	effects := []string{SolidEffect, SceneEffect}
+	for _, kind := range patternKinds {
+		effects = append(effects, string(kind))
+	}
+	return effects
+}
+
+func isEffect(effect string) bool {
+	for _, e := range Effects() {
+		if e == effect {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultSegmentColor is the color of a solid segment without colors.
+var defaultSegmentColor = led.RGBColor{255, 255, 255}
+
+// SegmentState is the runtime state of a segment.
+type SegmentState struct {
+	SegmentConfig
+	// On is false if the segment is turned off.
+	On bool
+	// Brightness is the brightness of the segment within [0, 1], on top of
+	// the brightness of the strip.
+	Brightness float64
+	// Effect is what the segment shows. It is one of Effects.
+	Effect string
+	// Colors are the colors of the effect. Patterns use them as their
+	// palette.
+	Colors []led.RGBColor
+}
+
+// ControllerEventKind is the kind of a ControllerEvent.
+type ControllerEventKind string
+
+const (
+	// LinkUpEvent is sent once the controller is initialized.
+	LinkUpEvent ControllerEventKind = "link-up"
+	// LinkDownEvent is sent once the daemon stops talking to the controller.
+	LinkDownEvent ControllerEventKind = "link-down"
+	// ControllerErrorEvent is sent when the controller reports an error.
+	ControllerErrorEvent ControllerEventKind = "error"
+)
+
+// ControllerEvent is something that happened to the controller.
+type ControllerEvent struct {
+	Kind ControllerEventKind
+	// Message is the error that the controller reported, or why the link
+	// went down, if any.
+	Message string
+}
+
+// control is the state that the daemon is controlled with at runtime, such
+// as over network APIs. It is safe for concurrent use.
+type control struct {
+	mu         sync.Mutex
+	scene      string
+	on         bool
+	brightness float64
+	segments   []SegmentState
+	// version is bumped whenever a segment changes.
+	version  uint64
+	realtime realtimeFrame
+	// direct is the frame that is shown over the scene and segments until it
+	// is stopped, such as in OpenRGB's direct mode. It is nil if there is
+	// none.
+	direct led.LEDs
+	// watchers are signaled whenever the state changes.
+	watchers map[chan struct{}]struct{}
+	// frameWatchers receive the frames sent to the controller.
+	frameWatchers map[*latest[led.LEDs]]struct{}
+	// linked is true while the controller is initialized.
+	linked bool
+	// eventWatchers receive the controller events.
+	eventWatchers map[chan ControllerEvent]struct{}
+	// notifications are the notifications to show, the first of which is
+	// shown since notified. notified is zero until it is first drawn.
+	notifications []Notification
+	notified      time.Time
+}
+
+// realtimeFrame is a frame drawn by an external source over the network.
+// It is shown over everything else until it expires.
+type realtimeFrame struct {
+	leds led.LEDs
+	// set marks the LEDs that were drawn.
+	set   []bool
+	until time.Time
+}
+
+func newControl(cfg *Config) *control {
+	c := &control{
+		scene:      cfg.InitialScene(),
+		on:         true,
+		brightness: 1,
+		realtime: realtimeFrame{
+			leds: led.NewLEDs(cfg.NumLEDs()),
+			set:  make([]bool, cfg.NumLEDs()),
+		},
+	}
+	for _, segment := range cfg.AllSegments() {
+		c.segments = append(c.segments, SegmentState{
+			SegmentConfig: segment,
+			On:            true,
+			Brightness:    1,
+			Effect:        SceneEffect,
+		})
+	}
+	return c
+}
+
+// notify signals the watchers. It must be called with the control locked.
+func (c *control) notify() {
+	for ch := range c.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Watch returns a channel that is signaled whenever the scene, power,
+// brightness, segments or link to the controller change, until the context is
+// canceled. Changes in quick succession may be signaled once. Realtime frames
+// are not signaled.
+func (d *Daemon) Watch(ctx context.Context) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+
+	d.control.mu.Lock()
+	if d.control.watchers == nil {
+		d.control.watchers = make(map[chan struct{}]struct{})
+	}
+	d.control.watchers[ch] = struct{}{}
+	d.control.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		d.control.mu.Lock()
+		delete(d.control.watchers, ch)
+		d.control.mu.Unlock()
+	}()
+
+	return ch
+}
+
+// WatchFrames returns a channel that receives the frames sent to the
+// controller until the context is canceled. Frames that are not received in
+// time are dropped in favor of newer ones.
+func (d *Daemon) WatchFrames(ctx context.Context) <-chan led.LEDs {
+	frames := newLatest[led.LEDs]()
+
+	d.control.mu.Lock()
+	if d.control.frameWatchers == nil {
+		d.control.frameWatchers = make(map[*latest[led.LEDs]]struct{})
+	}
+	d.control.frameWatchers[&frames] = struct{}{}
+	d.control.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		d.control.mu.Lock()
+		delete(d.control.frameWatchers, &frames)
+		d.control.mu.Unlock()
+	}()
+
+	return frames.recv()
+}
+
+// sendFrame sends a copy of the frame to the frame watchers.
+func (c *control) sendFrame(leds led.LEDs) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for frames := range c.frameWatchers {
+		frames.send(append(led.LEDs(nil), leds...))
+	}
+}
+
+// WatchController returns a channel that receives the events of the
+// controller until the context is canceled. Events are dropped if too many
+// of them are not received in time.
+func (d *Daemon) WatchController(ctx context.Context) <-chan ControllerEvent {
+	ch := make(chan ControllerEvent, 16)
+
+	d.control.mu.Lock()
+	if d.control.eventWatchers == nil {
+		d.control.eventWatchers = make(map[chan ControllerEvent]struct{})
+	}
+	d.control.eventWatchers[ch] = struct{}{}
+	d.control.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		d.control.mu.Lock()
+		delete(d.control.eventWatchers, ch)
+		d.control.mu.Unlock()
+	}()
+
+	return ch
+}
+
+// sendEvent sends the event to the event watchers. Link events also change
+// whether the controller is linked, which is signaled to the watchers. The
+// link going down is only sent if it was up.
+func (c *control) sendEvent(ev ControllerEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch ev.Kind {
+	case LinkUpEvent:
+		c.linked = true
+		c.notify()
+	case LinkDownEvent:
+		if !c.linked {
+			return
+		}
+		c.linked = false
+		c.notify()
+	}
+
+	for ch := range c.eventWatchers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Linked returns true if the controller is initialized and the daemon is
+// talking to it.
+func (d *Daemon) Linked() bool {
+	d.control.mu.Lock()
+	defer d.control.mu.Unlock()
+	return d.control.linked
+}
+
+// Scene returns the name of the current scene.
+func (d *Daemon) Scene() string {
+	d.control.mu.Lock()
+	defer d.control.mu.Unlock()
+	return d.control.scene
+}
+
+// Scenes returns the names of all scenes.
+func (d *Daemon) Scenes() []string {
+	scenes := d.cfg.AllScenes()
+	names := make([]string, len(scenes))
+	for i, scene := range scenes {
+		names[i] = scene.Name
+	}
+	return names
+}
+
+// NumLEDs returns the number of LEDs on the strip.
+func (d *Daemon) NumLEDs() int {
+	return d.cfg.NumLEDs()
+}
+
+// Brightness returns the brightness of the strip that was last set, even if
+// the strip is off or still transitioning to it.
+func (d *Daemon) Brightness() float64 {
+	d.control.mu.Lock()
+	defer d.control.mu.Unlock()
+	return d.control.brightness
+}
+
+// On returns true if the strip is on.
+func (d *Daemon) On() bool {
+	d.control.mu.Lock()
+	defer d.control.mu.Unlock()
+	return d.control.on
+}
+
+// SetOn turns the strip on or off, fading over the given duration. The
+// brightness is kept for when the strip is turned back on.
+func (d *Daemon) SetOn(on bool, transition time.Duration) {
+	d.control.mu.Lock()
+	defer d.control.mu.Unlock()
+
+	d.control.on = on
+	d.sendBrightness(transition)
+}
+
+// sendBrightness sends the effective brightness to the main loop. It must be
+// called with the control locked.
+func (d *Daemon) sendBrightness(transition time.Duration) {
+	brightness := d.control.brightness
+	if !d.control.on {
+		brightness = 0
+	}
+	d.brightness.send(brightnessRequest{brightness, transition})
+	d.control.notify()
+}
+
+// Segments returns the state of all segments.
+func (d *Daemon) Segments() []SegmentState {
+	d.control.mu.Lock()
+	defer d.control.mu.Unlock()
+
+	segments := make([]SegmentState, len(d.control.segments))
+	for i, segment := range d.control.segments {
+		segments[i] = segment
+		segments[i].Colors = append([]led.RGBColor(nil), segment.Colors...)
+	}
+	return segments
+}
+
+// UpdateSegment changes the state of the segment with the given name. Its
+// name and range cannot be changed.
+func (d *Daemon) UpdateSegment(name string, update func(*SegmentState)) error {
+	d.control.mu.Lock()
+	defer d.control.mu.Unlock()
+
+	for i := range d.control.segments {
+		segment := &d.control.segments[i]
+		if segment.Name != name {
+			continue
+		}
+
+		updated := *segment
+		updated.Colors = append([]led.RGBColor(nil), segment.Colors...)
+		update(&updated)
+
+		if !isEffect(updated.Effect) {
+			return fmt.Errorf("unknown effect %q", updated.Effect)
+		}
+		updated.SegmentConfig = segment.SegmentConfig
+		updated.Brightness = math.Max(0, math.Min(1, updated.Brightness))
+
+		*segment = updated
+		d.control.version++
+		d.control.notify()
+		return nil
+	}
+
+	return fmt.Errorf("unknown segment %q", name)
+}
+
+// DrawRealtime draws the colors onto the strip starting at the given LED,
+// over whatever the scene and segments show. The LEDs are shown until the
+// timeout passes without anything else being drawn, after which the strip
+// goes back to normal. Colors beyond the end of the strip are ignored.
+func (d *Daemon) DrawRealtime(start int, colors []led.RGBColor, timeout time.Duration) {
+	d.control.mu.Lock()
+	defer d.control.mu.Unlock()
+
+	rt := &d.control.realtime
+	if start < 0 {
+		colors = colors[min(-start, len(colors)):]
+		start = 0
+	}
+	for i, color := range colors {
+		if start+i >= len(rt.leds) {
+			break
+		}
+		rt.leds[start+i] = color
+		rt.set[start+i] = true
+	}
+	rt.until = time.Now().Add(timeout)
+}
+
+// StopRealtime goes back to normal if a realtime frame is shown.
+func (d *Daemon) StopRealtime() {
+	d.control.mu.Lock()
+	defer d.control.mu.Unlock()
+	d.control.realtime.until = time.Time{}
+}
+
+// DrawDirect shows the colors over the scene and segments until StopDirect is
+// called. Unlike realtime frames, they do not expire, and realtime frames are
+// drawn over them without ending them.
+func (d *Daemon) DrawDirect(colors []led.RGBColor) {
+	d.control.mu.Lock()
+	defer d.control.mu.Unlock()
+
+	if d.control.direct == nil {
+		d.control.direct = led.NewLEDs(len(d.control.realtime.leds))
+	}
+	copy(d.control.direct, colors)
+}
+
+// StopDirect goes back to normal if colors are shown by DrawDirect.
+func (d *Daemon) StopDirect() {
+	d.control.mu.Lock()
+	defer d.control.mu.Unlock()
+	d.control.direct = nil
+}
+
+// Realtime returns true if a realtime frame is shown.
+func (d *Daemon) Realtime() bool {
+	d.control.mu.Lock()
+	defer d.control.mu.Unlock()
+	return time.Now().Before(d.control.realtime.until)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// overrides draws the segments, the direct and realtime frames and
+// notifications over the scene. It is only used by the main loop.
+type overrides struct {
+	control *control
+	clock   clock.Clock
+	version uint64
+	// segments is a copy of the segments as of version, along with their
+	// animators.
+	segments  []SegmentState
+	animators []Animator
+}
+
+func newOverrides(control *control, clock clock.Clock) *overrides {
+	return &overrides{
+		control: control,
+		clock:   clock,
+		// Force an update on the first frame.
+		version: math.MaxUint64,
+	}
+}
+
+// draw draws the overrides onto the LEDs.
+func (o *overrides) draw(leds led.LEDs, now time.Time) {
+	o.control.mu.Lock()
+	if o.version != o.control.version {
+		o.update()
+	}
+
+	direct := o.control.direct != nil
+	if direct {
+		copy(leds, o.control.direct)
+	}
+
+	realtime := now.Before(o.control.realtime.until)
+	if realtime {
+		rt := o.control.realtime
+		for i, set := range rt.set {
+			if set {
+				leds[i] = rt.leds[i]
+			}
+		}
+	} else {
+		// Forget the old frame, so that the next realtime frame starts
+		// from the scene.
+		for i := range o.control.realtime.set {
+			o.control.realtime.set[i] = false
+		}
+	}
+	o.control.mu.Unlock()
+
+	if !realtime && !direct {
+		o.drawSegments(leds)
+	}
+}
+
+// drawNotification draws the notification being shown, if any, onto the LEDs.
+// It is drawn after the strip is dimmed, so that notifications are seen even
+// while the strip is dark or off.
+func (o *overrides) drawNotification(leds led.LEDs, now time.Time) {
+	o.control.mu.Lock()
+	notification, elapsed, notifying := o.control.notification(now)
+	o.control.mu.Unlock()
+
+	if notifying {
+		notification.draw(leds, elapsed)
+	}
+}
+
+// drawSegments draws the segments that do not follow the scene, and dims or
+// turns off the segments as set.
+func (o *overrides) drawSegments(leds led.LEDs) {
+	for i, segment := range o.segments {
+		part := leds[segment.Range[0]:segment.Range[1]]
+
+		if a := o.animators[i]; a != nil {
+			a.AcquireFrame(func(frame led.LEDs) { copy(part, frame) })
+		}
+
+		switch {
+		case !segment.On:
+			part.SetRange(0, len(part), led.RGBColor{})
+		case segment.Brightness < 1:
+			part.Scale(segment.Brightness)
+		}
+	}
+}
+
+// update recreates the animators of the segments that changed. It must be
+// called with the control locked.
+func (o *overrides) update() {
+	segments := o.control.segments
+	animators := make([]Animator, len(segments))
+
+	for i, segment := range segments {
+		if i < len(o.segments) && o.animators[i] != nil &&
+			o.segments[i].Effect == segment.Effect && sameColors(o.segments[i].Colors, segment.Colors) {
+			// Keep the animation going if only the brightness changed.
+			animators[i] = o.animators[i]
+			continue
+		}
+		animators[i] = segmentAnimator(segment, o.clock)
+	}
+
+	o.segments = append(o.segments[:0], segments...)
+	for i := range o.segments {
+		o.segments[i].Colors = append([]led.RGBColor(nil), segments[i].Colors...)
+	}
+	o.animators = animators
+	o.version = o.control.version
+}
+
+// segmentAnimator returns the animator of the segment's effect, or nil if it
+// follows the scene.
+func segmentAnimator(segment SegmentState, clock clock.Clock) Animator {
+	numLEDs := segment.Range[1] - segment.Range[0]
+
+	switch segment.Effect {
+	case SceneEffect:
+		return nil
+	case SolidEffect:
+		color := defaultSegmentColor
+		if len(segment.Colors) > 0 {
+			color = segment.Colors[0]
+		}
+		return staticAnimator(numLEDs, color)
+	default:
+		cfg := PatternConfig{
+			Kind:   PatternKind(segment.Effect),
+			Colors: segment.Colors,
+		}
+		a, err := cfg.animator(numLEDs, clock)
+		if err != nil {
+			// The effect was validated when it was set.
+			panic(err)
+		}
+		return a
+	}
+}
+
+func sameColors(a, b []led.RGBColor) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
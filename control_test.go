@@ -0,0 +1,379 @@
+package catglow
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/dbusapi"
+	"libdb.so/catglow/internal/hass"
+	"libdb.so/catglow/internal/led"
+	"libdb.so/catglow/internal/wled"
+)
+
+const controlConfig = `
+[[led]]
+  range = [0, 8]
+  color = [0, 255, 0]
+
+[[segment]]
+  name = "desk"
+  range = [0, 4]
+
+[[segment]]
+  name = "shelf"
+  range = [4, 8]
+`
+
+func newControlDaemon(t *testing.T) *Daemon {
+	t.Helper()
+
+	cfg, err := ParseConfig(strings.NewReader(controlConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewDaemon(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestOverrides(t *testing.T) {
+	d := newControlDaemon(t)
+	overrides := newOverrides(d.control, clock.Real)
+
+	green := led.RGBColor{0, 255, 0}
+	red := led.RGBColor{255, 0, 0}
+	blue := led.RGBColor{0, 0, 255}
+
+	draw := func() led.LEDs {
+		leds := led.NewLEDs(8)
+		leds.SetRange(0, 8, green)
+		overrides.draw(leds, time.Now())
+		return leds
+	}
+
+	if leds := draw(); leds[0] != green || leds[7] != green {
+		t.Errorf("segments should follow the scene by default, got %v", leds)
+	}
+
+	if err := d.UpdateSegment("desk", func(s *SegmentState) {
+		s.Effect = SolidEffect
+		s.Colors = []led.RGBColor{red}
+		s.Brightness = 0.5
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.UpdateSegment("shelf", func(s *SegmentState) { s.On = false }); err != nil {
+		t.Fatal(err)
+	}
+
+	leds := draw()
+	if want := (led.RGBColor{128, 0, 0}); leds[0] != want || leds[3] != want {
+		t.Errorf("desk: got %v, want %v", leds[:4], want)
+	}
+	if (leds[4] != led.RGBColor{}) || (leds[7] != led.RGBColor{}) {
+		t.Errorf("shelf: got %v, want it off", leds[4:])
+	}
+
+	// Realtime colors are drawn over the scene rather than the segments.
+	d.DrawRealtime(2, []led.RGBColor{blue}, time.Minute)
+	if !d.Realtime() {
+		t.Error("realtime is not active")
+	}
+	leds = draw()
+	if leds[2] != blue || leds[0] != green || leds[7] != green {
+		t.Errorf("got %v, want blue at 2 over the scene", leds)
+	}
+
+	d.StopRealtime()
+	if leds := draw(); leds[2] != (led.RGBColor{128, 0, 0}) {
+		t.Errorf("got %v after stopping realtime, want the segments back", leds)
+	}
+
+	if err := d.UpdateSegment("desk", func(s *SegmentState) { s.Effect = "nope" }); err == nil {
+		t.Error("expected an error for an unknown effect")
+	}
+	if err := d.UpdateSegment("nope", func(s *SegmentState) {}); err == nil {
+		t.Error("expected an error for an unknown segment")
+	}
+}
+
+func TestDirect(t *testing.T) {
+	d := newControlDaemon(t)
+	overrides := newOverrides(d.control, clock.Real)
+
+	green := led.RGBColor{0, 255, 0}
+	red := led.RGBColor{255, 0, 0}
+	blue := led.RGBColor{0, 0, 255}
+
+	draw := func() led.LEDs {
+		leds := led.NewLEDs(8)
+		leds.SetRange(0, 8, green)
+		overrides.draw(leds, time.Now())
+		return leds
+	}
+
+	if err := d.UpdateSegment("shelf", func(s *SegmentState) { s.On = false }); err != nil {
+		t.Fatal(err)
+	}
+
+	direct := led.NewLEDs(8)
+	direct.SetRange(0, 8, red)
+	d.DrawDirect(direct)
+	if leds := draw(); leds[0] != red || leds[7] != red {
+		t.Errorf("got %v, want the direct colors over the scene and segments", leds)
+	}
+
+	// Realtime frames are drawn over direct colors, and ending them does not
+	// end direct mode.
+	d.DrawRealtime(2, []led.RGBColor{blue}, time.Minute)
+	if leds := draw(); leds[2] != blue || leds[0] != red {
+		t.Errorf("got %v, want blue at 2 over the direct colors", leds)
+	}
+	d.StopRealtime()
+	if leds := draw(); leds[2] != red {
+		t.Errorf("got %v after stopping realtime, want the direct colors", leds)
+	}
+
+	d.StopDirect()
+	if leds := draw(); leds[0] != green || (leds[7] != led.RGBColor{}) {
+		t.Errorf("got %v after stopping direct mode, want the scene and segments", leds)
+	}
+}
+
+func TestPower(t *testing.T) {
+	d := newControlDaemon(t)
+
+	d.SetBrightness(0.5, 0)
+	d.SetOn(false, 0)
+	if req := <-d.brightness.recv(); req.brightness != 0 {
+		t.Errorf("got brightness %v while off, want 0", req.brightness)
+	}
+
+	d.SetOn(true, time.Second)
+	if req := <-d.brightness.recv(); req.brightness != 0.5 || req.transition != time.Second {
+		t.Errorf("got %+v after turning on, want the brightness back", req)
+	}
+	if !d.On() || d.Brightness() != 0.5 {
+		t.Errorf("got on=%v brightness=%v", d.On(), d.Brightness())
+	}
+}
+
+func TestWLEDSegments(t *testing.T) {
+	c := wledController{newControlDaemon(t)}
+
+	segments := c.Segments()
+	if len(segments) != 2 || segments[1].Name != "shelf" || segments[1].Start != 4 {
+		t.Fatalf("unexpected segments %+v", segments)
+	}
+	if effect := c.Effects()[segments[0].Effect]; effect != SceneEffect {
+		t.Errorf("got effect %q, want %q", effect, SceneEffect)
+	}
+
+	// Picking a color while following the scene switches to solid.
+	if err := c.UpdateSegment(0, func(s *wled.Segment) {
+		s.Colors = []led.RGBColor{{255, 0, 0}}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if effect := c.Daemon.Segments()[0].Effect; effect != SolidEffect {
+		t.Errorf("got effect %q, want %q", effect, SolidEffect)
+	}
+
+	if err := c.UpdateSegment(2, func(*wled.Segment) {}); err == nil {
+		t.Error("expected an error for an unknown segment")
+	}
+}
+
+func TestWLEDListenFailure(t *testing.T) {
+	d := newControlDaemon(t)
+
+	// Take the addresses so that the daemon cannot bind them.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The API is disabled, but the realtime protocols are still received.
+	d.cfg.WLED = &WLEDConfig{Listen: l.Addr().String(), Realtime: "127.0.0.1:0"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := d.runWLED(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got %v, want the WLED server to run until canceled", err)
+	}
+
+	// With neither, there is nothing to run.
+	d.cfg.WLED = &WLEDConfig{Listen: l.Addr().String(), Realtime: conn.LocalAddr().String()}
+	if err := d.runWLED(context.Background()); err != nil {
+		t.Errorf("got %v, want the WLED server to be disabled", err)
+	}
+}
+
+func TestDDPListenFailure(t *testing.T) {
+	d := newControlDaemon(t)
+
+	// Take the address so that the daemon cannot bind it.
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	d.cfg.DDP = &DDPConfig{Listen: conn.LocalAddr().String()}
+	if err := d.runDDP(context.Background()); err != nil {
+		t.Errorf("got %v, want DDP to be disabled", err)
+	}
+}
+
+func TestHTTPListenFailure(t *testing.T) {
+	d := newControlDaemon(t)
+
+	// Take the address so that the daemon cannot bind it.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	d.cfg.HTTP = &HTTPConfig{Listen: l.Addr().String()}
+	if err := d.runHTTP(context.Background()); err != nil {
+		t.Errorf("got %v, want the HTTP API to be disabled", err)
+	}
+}
+
+func TestHassLights(t *testing.T) {
+	c := hassController{newControlDaemon(t)}
+
+	effects := c.Effects()
+	if effects[0] != SolidEffect || effects[1] != "scene:default" {
+		t.Errorf("got effects %v, want solid and the scenes first", effects)
+	}
+
+	lights := c.Lights()
+	if len(lights) != 2 || lights[0].Effect != "scene:default" {
+		t.Fatalf("unexpected lights %+v", lights)
+	}
+
+	color := led.RGBColor{0, 0, 255}
+	if err := c.UpdateLight("shelf", hass.Command{Color: &color}); err != nil {
+		t.Fatal(err)
+	}
+	if light := c.Lights()[1]; light.Effect != SolidEffect || light.Color != color {
+		t.Errorf("got %+v, want a solid blue light", light)
+	}
+
+	effect := "scene:default"
+	if err := c.UpdateLight("shelf", hass.Command{Effect: &effect}); err != nil {
+		t.Fatal(err)
+	}
+	if light := c.Lights()[1]; light.Effect != effect {
+		t.Errorf("got effect %q, want %q", light.Effect, effect)
+	}
+
+	effect = "scene:nope"
+	if err := c.UpdateLight("shelf", hass.Command{Effect: &effect}); err == nil {
+		t.Error("expected an error for an unknown scene")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	d := newControlDaemon(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := d.Watch(ctx)
+	frames := d.WatchFrames(ctx)
+
+	d.SetOn(false, 0)
+	select {
+	case <-changes:
+	default:
+		t.Error("turning off was not signaled")
+	}
+
+	leds := led.LEDs{{1, 2, 3}}
+	d.control.sendFrame(leds)
+	leds[0] = led.RGBColor{}
+	d.control.sendFrame(led.LEDs{{4, 5, 6}})
+
+	select {
+	case frame := <-frames:
+		if frame[0] != (led.RGBColor{4, 5, 6}) {
+			t.Errorf("got frame %v, want the latest one", frame)
+		}
+	default:
+		t.Error("no frame was received")
+	}
+}
+
+func TestControllerEvents(t *testing.T) {
+	c := dbusController{newControlDaemon(t)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := c.WatchEvents(ctx)
+
+	// The link cannot go down before it is up.
+	c.control.sendEvent(ControllerEvent{Kind: LinkDownEvent})
+	c.control.sendEvent(ControllerEvent{Kind: LinkUpEvent})
+	if !c.Linked() {
+		t.Error("the controller is not linked")
+	}
+	c.control.sendEvent(ControllerEvent{ControllerErrorEvent, "oops"})
+	c.control.sendEvent(ControllerEvent{LinkDownEvent, "controller reported error"})
+	if c.Linked() {
+		t.Error("the controller is still linked")
+	}
+
+	want := []dbusapi.Event{
+		{Kind: dbusapi.LinkUp},
+		{Kind: dbusapi.ControllerError, Message: "oops"},
+		{Kind: dbusapi.LinkDown, Message: "controller reported error"},
+	}
+	for _, want := range want {
+		select {
+		case ev := <-events:
+			if ev != want {
+				t.Errorf("got event %+v, want %+v", ev, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no event, want %+v", want)
+		}
+	}
+}
+
+func TestDBusSegments(t *testing.T) {
+	c := dbusController{newControlDaemon(t)}
+
+	// Picking a color while following the scene switches to solid.
+	if err := c.UpdateSegment("desk", func(s *dbusapi.Segment) {
+		s.Colors = []dbusapi.Color{{R: 255}}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	segment := c.Daemon.Segments()[0]
+	if segment.Effect != SolidEffect || segment.Colors[0] != (led.RGBColor{255, 0, 0}) {
+		t.Errorf("got %+v, want a solid red segment", segment)
+	}
+
+	if segments := c.Segments(); segments[1].Start != 4 || segments[1].End != 8 {
+		t.Errorf("got shelf %+v, want LEDs 4 to 8", segments[1])
+	}
+}
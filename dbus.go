@@ -0,0 +1,131 @@
+package catglow
+
+import (
+	"context"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/pkg/errors"
+	"libdb.so/catglow/internal/dbusapi"
+	"libdb.so/catglow/internal/led"
+)
+
+// runDBus serves the D-Bus service on the session bus until the context is
+// canceled.
+func (d *Daemon) runDBus(ctx context.Context) error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to the session bus")
+	}
+	defer conn.Close()
+
+	name := d.cfg.DBus.Name
+	if name == "" {
+		name = dbusapi.DefaultName
+	}
+
+	d.logger.Info("serving on the session bus", "name", name)
+	return dbusapi.Serve(ctx, conn, name, dbusController{d})
+}
+
+// dbusController adapts the daemon to the D-Bus service.
+type dbusController struct {
+	*Daemon
+}
+
+var _ dbusapi.Controller = dbusController{}
+
+func (c dbusController) Segments() []dbusapi.Segment {
+	segments := c.Daemon.Segments()
+	dbusSegments := make([]dbusapi.Segment, len(segments))
+	for i, segment := range segments {
+		dbusSegments[i] = toDBusSegment(segment)
+	}
+	return dbusSegments
+}
+
+func (c dbusController) UpdateSegment(name string, update func(*dbusapi.Segment)) error {
+	return c.Daemon.UpdateSegment(name, func(segment *SegmentState) {
+		old := toDBusSegment(*segment)
+		updated := old
+		updated.Colors = append([]dbusapi.Color(nil), old.Colors...)
+		update(&updated)
+
+		colors := make([]led.RGBColor, len(updated.Colors))
+		for i, color := range updated.Colors {
+			colors[i] = led.RGBColor{color.R, color.G, color.B}
+		}
+
+		// Picking a color for a segment that follows the scene should show
+		// that color.
+		if updated.Effect == SceneEffect && old.Effect == SceneEffect && !sameColors(segment.Colors, colors) {
+			updated.Effect = SolidEffect
+		}
+
+		segment.On = updated.On
+		segment.Brightness = updated.Brightness
+		segment.Effect = updated.Effect
+		segment.Colors = colors
+	})
+}
+
+func (c dbusController) Effects() []string {
+	return Effects()
+}
+
+func (c dbusController) Notify(n dbusapi.Notification) error {
+	return c.Daemon.Notify(Notification{
+		Color:    led.RGBColor{n.Color.R, n.Color.G, n.Color.B},
+		Count:    n.Count,
+		Duration: n.Duration,
+		Priority: n.Priority,
+	})
+}
+
+func (c dbusController) WatchEvents(ctx context.Context) <-chan dbusapi.Event {
+	events := make(chan dbusapi.Event, 16)
+	controllerEvents := c.WatchController(ctx)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev := <-controllerEvents:
+				select {
+				case events <- toDBusEvent(ev):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events
+}
+
+func toDBusSegment(segment SegmentState) dbusapi.Segment {
+	colors := make([]dbusapi.Color, len(segment.Colors))
+	for i, color := range segment.Colors {
+		colors[i] = dbusapi.Color{R: color[0], G: color[1], B: color[2]}
+	}
+	return dbusapi.Segment{
+		Name:       segment.Name,
+		Start:      uint32(segment.Range[0]),
+		End:        uint32(segment.Range[1]),
+		On:         segment.On,
+		Brightness: segment.Brightness,
+		Effect:     segment.Effect,
+		Colors:     colors,
+	}
+}
+
+func toDBusEvent(ev ControllerEvent) dbusapi.Event {
+	var kind dbusapi.EventKind
+	switch ev.Kind {
+	case LinkUpEvent:
+		kind = dbusapi.LinkUp
+	case LinkDownEvent:
+		kind = dbusapi.LinkDown
+	case ControllerErrorEvent:
+		kind = dbusapi.ControllerError
+	}
+	return dbusapi.Event{Kind: kind, Message: ev.Message}
+}
@@ -0,0 +1,77 @@
+package catglow
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/ddp"
+	"libdb.so/catglow/internal/led"
+)
+
+// ddpAnimator draws the frames received over DDP.
+type ddpAnimator struct {
+	*ddp.Output
+	// receive receives frames into the output until the context is canceled.
+	// If nil, then nothing is received.
+	receive func(ctx context.Context, out *ddp.Output) error
+}
+
+var _ silencer = (*ddpAnimator)(nil)
+
+// Run receives frames until the context is canceled.
+func (a *ddpAnimator) Run(ctx context.Context) error {
+	if a.receive == nil {
+		<-ctx.Done()
+		return nil
+	}
+	return a.receive(ctx, a.Output)
+}
+
+func (c *DDPConfig) timeout() time.Duration {
+	if c.Timeout == 0 {
+		return ddp.DataTimeout
+	}
+	return time.Duration(c.Timeout)
+}
+
+func (c *DDPConfig) validate() error {
+	if c.Offset < 0 {
+		return errors.New("offset must not be negative")
+	}
+	return nil
+}
+
+func (c *DDPConfig) animator(numLEDs int, env animatorEnv) (*ddpAnimator, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+
+	a := &ddpAnimator{
+		Output: ddp.NewOutput(numLEDs, c.Offset, c.timeout(), env.clock),
+	}
+	if env.ddp != nil {
+		a.receive = func(ctx context.Context, out *ddp.Output) error {
+			return env.ddp.Run(ctx, c.Listen, out)
+		}
+	}
+
+	return a, nil
+}
+
+// runDDP receives the whole strip over DDP until the context is canceled.
+// Frames are drawn like realtime frames of the WLED API. If the address cannot
+// be bound, then DDP is disabled with a warning.
+func (d *Daemon) runDDP(ctx context.Context) error {
+	cfg := d.cfg.DDP
+	timeout := cfg.timeout()
+
+	out := ddp.NewOutput(d.cfg.NumLEDs(), cfg.Offset, timeout, clock.Real)
+	out.OnCommit(func(leds led.LEDs) {
+		d.DrawRealtime(0, leds, timeout)
+	})
+
+	err := d.ddp.Run(ctx, cfg.Listen, out)
+	return d.optionalServer("DDP", cfg.Listen, err)
+}
@@ -0,0 +1,97 @@
+package catglow
+
+import (
+	"context"
+	"time"
+
+	"libdb.so/catglow/internal/artnet"
+	"libdb.so/catglow/internal/dmx"
+	"libdb.so/catglow/internal/e131"
+)
+
+// dmxAnimator draws the DMX universes received over the network, such as
+// over E1.31 or Art-Net.
+type dmxAnimator struct {
+	*dmx.Output
+	// receive receives universes into the output until the context is
+	// canceled. If nil, then nothing is received.
+	receive func(ctx context.Context, out *dmx.Output) error
+}
+
+var _ silencer = (*dmxAnimator)(nil)
+
+// Run receives universes until the context is canceled.
+func (a *dmxAnimator) Run(ctx context.Context) error {
+	if a.receive == nil {
+		<-ctx.Done()
+		return nil
+	}
+	return a.receive(ctx, a.Output)
+}
+
+func newDMXAnimator(numLEDs int, mapping dmx.Mapping, timeout time.Duration, env animatorEnv) (*dmxAnimator, error) {
+	if err := mapping.Validate(); err != nil {
+		return nil, err
+	}
+	return &dmxAnimator{
+		Output: dmx.NewOutput(numLEDs, mapping, timeout, env.clock),
+	}, nil
+}
+
+func (c *E131Config) animator(numLEDs int, env animatorEnv) (*dmxAnimator, error) {
+	timeout := time.Duration(c.Timeout)
+	if timeout == 0 {
+		timeout = e131.SourceTimeout
+	}
+
+	a, err := newDMXAnimator(numLEDs, dmx.Mapping{
+		Universe:            c.Universe,
+		StartChannel:        c.StartChannel,
+		ChannelsPerUniverse: c.ChannelsPerUniverse,
+	}, timeout, env)
+	if err != nil {
+		return nil, err
+	}
+
+	if env.e131 != nil {
+		listen := e131.ListenConfig{
+			Addr:      c.Listen,
+			Multicast: c.Multicast,
+			Interface: c.Interface,
+		}
+		a.receive = func(ctx context.Context, out *dmx.Output) error {
+			return env.e131.Run(ctx, listen, out)
+		}
+	}
+
+	return a, nil
+}
+
+func (c *ArtNetConfig) animator(numLEDs int, env animatorEnv) (*dmxAnimator, error) {
+	timeout := time.Duration(c.Timeout)
+	if timeout == 0 {
+		timeout = artnet.DataTimeout
+	}
+
+	a, err := newDMXAnimator(numLEDs, dmx.Mapping{
+		Universe:            c.Universe,
+		StartChannel:        c.StartChannel,
+		ChannelsPerUniverse: c.ChannelsPerUniverse,
+	}, timeout, env)
+	if err != nil {
+		return nil, err
+	}
+
+	if env.artnet != nil {
+		node := artnet.NodeConfig{
+			Addr:      c.Listen,
+			ShortName: c.ShortName,
+			LongName:  c.LongName,
+		}
+		a.receive = func(ctx context.Context, out *dmx.Output) error {
+			return env.artnet.Run(ctx, node, out)
+		}
+	}
+
+	return a, nil
+}
@@ -3,16 +3,24 @@ module libdb.so/catglow
 go 1.20
 
 require (
+	github.com/eclipse/paho.mqtt.golang v1.5.0
+	github.com/godbus/dbus/v5 v5.1.0
+	github.com/mewkiz/flac v1.0.12
 	github.com/noriah/catnip v1.8.0
 	github.com/pelletier/go-toml v1.9.5
 	github.com/pkg/errors v0.9.1
 	github.com/spf13/pflag v1.0.5
 	go.bug.st/serial v1.6.0
-	golang.org/x/sync v0.3.0
+	golang.org/x/sync v0.7.0
 )
 
 require (
 	github.com/creack/goselect v0.1.2 // indirect
-	golang.org/x/sys v0.0.0-20220829200755-d48e67d00261 // indirect
+	github.com/gorilla/websocket v1.5.3 // indirect
+	github.com/icza/bitio v1.1.0 // indirect
+	github.com/mewkiz/pkg v0.0.0-20230226050401-4010bf0fec14 // indirect
+	github.com/noisetorch/pulseaudio v0.0.0-20220603053345-9303200c3861 // indirect
+	golang.org/x/net v0.27.0 // indirect
+	golang.org/x/sys v0.22.0 // indirect
 	gonum.org/v1/gonum v0.11.0 // indirect
 )
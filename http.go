@@ -0,0 +1,77 @@
+package catglow
+
+import (
+	"context"
+
+	"libdb.so/catglow/internal/httpapi"
+	"libdb.so/catglow/internal/led"
+)
+
+func (c *HTTPConfig) listen() string {
+	if c.Listen == "" {
+		return "127.0.0.1:8080"
+	}
+	return c.Listen
+}
+
+// runHTTP serves the HTTP API and web UI until the context is canceled. If
+// the address cannot be bound, then the API is disabled with a warning.
+func (d *Daemon) runHTTP(ctx context.Context) error {
+	cfg := d.cfg.HTTP
+	server := httpapi.NewServer(httpController{d})
+
+	d.logger.Info("serving the HTTP API", "listen", cfg.listen())
+	err := server.ListenAndServe(ctx, cfg.listen())
+	return d.optionalServer("HTTP", cfg.listen(), err)
+}
+
+// httpController adapts the daemon to the HTTP API.
+type httpController struct {
+	*Daemon
+}
+
+var _ httpapi.Controller = httpController{}
+
+func (c httpController) Segments() []httpapi.Segment {
+	segments := c.Daemon.Segments()
+	apiSegments := make([]httpapi.Segment, len(segments))
+	for i, segment := range segments {
+		apiSegments[i] = toAPISegment(segment)
+	}
+	return apiSegments
+}
+
+func (c httpController) UpdateSegment(name string, update func(*httpapi.Segment)) error {
+	return c.Daemon.UpdateSegment(name, func(segment *SegmentState) {
+		apiSegment := toAPISegment(*segment)
+		update(&apiSegment)
+
+		segment.On = apiSegment.On
+		segment.Brightness = apiSegment.Brightness
+		segment.Effect = apiSegment.Effect
+		segment.Colors = apiSegment.Colors
+	})
+}
+
+func (c httpController) Effects() []string {
+	return Effects()
+}
+
+func (c httpController) Notify(n httpapi.Notification) error {
+	return c.Daemon.Notify(Notification(n))
+}
+
+func toAPISegment(segment SegmentState) httpapi.Segment {
+	colors := segment.Colors
+	if colors == nil {
+		colors = []led.RGBColor{}
+	}
+	return httpapi.Segment{
+		Name:       segment.Name,
+		Range:      segment.Range,
+		On:         segment.On,
+		Brightness: segment.Brightness,
+		Effect:     segment.Effect,
+		Colors:     colors,
+	}
+}
@@ -0,0 +1,84 @@
+package catglow
+
+import (
+	"context"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// idleAnimator draws an animator such as a visualizer, crossfading to an idle
+// animation while it is silent and back once it resumes.
+type idleAnimator struct {
+	vis   silencer
+	idle  Animator
+	fade  time.Duration
+	clock clock.Clock
+
+	silent     bool
+	transition transition
+
+	visBuf  led.LEDs
+	idleBuf led.LEDs
+}
+
+var _ animatorRunner = (*idleAnimator)(nil)
+
+func newIdleAnimator(vis silencer, idle Animator, fade time.Duration, clock clock.Clock) *idleAnimator {
+	return &idleAnimator{
+		vis:   vis,
+		idle:  idle,
+		fade:  fade,
+		clock: clock,
+	}
+}
+
+// Run runs the wrapped animator.
+func (a *idleAnimator) Run(ctx context.Context) error {
+	return a.vis.Run(ctx)
+}
+
+// AcquireFrame implements Animator.
+func (a *idleAnimator) AcquireFrame(f func(led.LEDs)) {
+	now := a.clock.Now()
+
+	if silent := a.vis.Silent(); silent != a.silent {
+		a.silent = silent
+		// If we are switching in the middle of a fade, then start from where
+		// the fade left off rather than jumping.
+		p := 1 - a.transition.progress(now)
+		a.transition = transition{
+			start:    now.Add(-time.Duration(p * float64(a.fade))),
+			duration: a.fade,
+		}
+	}
+
+	// t is 0 for the wrapped animator and 1 for the idle animation.
+	t := a.transition.progress(now)
+	if !a.silent {
+		t = 1 - t
+	}
+
+	switch t {
+	case 0:
+		a.vis.AcquireFrame(f)
+	case 1:
+		a.idle.AcquireFrame(f)
+	default:
+		a.vis.AcquireFrame(func(leds led.LEDs) { a.visBuf = copyFrame(a.visBuf, leds) })
+		a.idle.AcquireFrame(func(leds led.LEDs) { a.idleBuf = copyFrame(a.idleBuf, leds) })
+		a.visBuf.Blend(a.visBuf, a.idleBuf, t)
+		f(a.visBuf)
+	}
+}
+
+// copyFrame copies src into dst, reallocating dst if needed.
+func copyFrame(dst, src led.LEDs) led.LEDs {
+	if cap(dst) < len(src) {
+		dst = make(led.LEDs, len(src))
+	}
+	dst = dst[:len(src)]
+	copy(dst, src)
+	return dst
+}
@@ -0,0 +1,107 @@
+package catglow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// fakeSilencer is a silencer that draws a single color.
+type fakeSilencer struct {
+	color  led.RGBColor
+	silent bool
+}
+
+func (s *fakeSilencer) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *fakeSilencer) Silent() bool { return s.silent }
+
+func (s *fakeSilencer) AcquireFrame(f func(led.LEDs)) { f(led.LEDs{s.color}) }
+
+func TestIdleAnimator(t *testing.T) {
+	red := led.RGBColor{255, 0, 0}
+	blue := led.RGBColor{0, 0, 255}
+
+	type step struct {
+		at     time.Duration
+		silent bool
+		want   led.RGBColor
+	}
+
+	for _, test := range []struct {
+		name  string
+		fade  time.Duration
+		steps []step
+	}{
+		{
+			name: "to idle",
+			fade: time.Second,
+			steps: []step{
+				{0, false, red},
+				{time.Second, true, red},
+				{1250 * time.Millisecond, true, led.RGBColor{191, 0, 64}},
+				{1500 * time.Millisecond, true, led.RGBColor{128, 0, 128}},
+				{2000 * time.Millisecond, true, blue},
+				{3000 * time.Millisecond, true, blue},
+			},
+		},
+		{
+			name: "back from idle",
+			fade: time.Second,
+			steps: []step{
+				{0, true, red},
+				{time.Second, true, blue},
+				{time.Second, false, blue},
+				{1250 * time.Millisecond, false, led.RGBColor{64, 0, 191}},
+				{1500 * time.Millisecond, false, led.RGBColor{128, 0, 128}},
+				{2000 * time.Millisecond, false, red},
+			},
+		},
+		{
+			name: "reversed mid-fade",
+			fade: time.Second,
+			steps: []step{
+				{0, true, red},
+				{250 * time.Millisecond, true, led.RGBColor{191, 0, 64}},
+				// The fade back starts from where the fade left off.
+				{250 * time.Millisecond, false, led.RGBColor{191, 0, 64}},
+				{375 * time.Millisecond, false, led.RGBColor{223, 0, 32}},
+				{500 * time.Millisecond, false, red},
+			},
+		},
+		{
+			name: "without a fade",
+			steps: []step{
+				{0, false, red},
+				{time.Second, true, blue},
+				{2 * time.Second, false, red},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			clk := clock.NewManual(start)
+
+			vis := &fakeSilencer{color: red}
+			idle := &fakeSilencer{color: blue}
+			a := newIdleAnimator(vis, idle, test.fade, clk)
+
+			for _, step := range test.steps {
+				clk.Set(start.Add(step.at))
+				vis.silent = step.silent
+
+				var got led.RGBColor
+				a.AcquireFrame(func(leds led.LEDs) { got = leds[0] })
+				if got != step.want {
+					t.Errorf("at %v (silent: %v): got %v, want %v", step.at, step.silent, got, step.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package ambilight
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+var (
+	red   = led.RGBColor{255, 0, 0}
+	green = led.RGBColor{0, 255, 0}
+	blue  = led.RGBColor{0, 0, 255}
+	white = led.RGBColor{255, 255, 255}
+)
+
+// screen returns a black image with each edge in a color, as deep as the
+// default depth of the regions. The left and right edges cover the corners.
+func screen(w, h int, top, right, bottom, left led.RGBColor) *image.RGBA {
+	dx, dy := w/10, h/10
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var c led.RGBColor
+			switch {
+			case x < dx:
+				c = left
+			case x >= w-dx:
+				c = right
+			case y < dy:
+				c = top
+			case y >= h-dy:
+				c = bottom
+			}
+			img.Set(x, y, color.RGBA{c[0], c[1], c[2], 255})
+		}
+	}
+	return img
+}
+
+func TestRegions(t *testing.T) {
+	g := Geometry{Top: 4, Right: 2, Bottom: 4, Left: 2}
+	if err := g.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := g.Regions(image.Rect(0, 0, 100, 50))
+	want := []image.Rectangle{
+		// Up the left edge from the bottom left corner.
+		image.Rect(0, 25, 10, 50),
+		image.Rect(0, 0, 10, 25),
+		// Along the top to the right.
+		image.Rect(0, 0, 25, 5),
+		image.Rect(25, 0, 50, 5),
+		image.Rect(50, 0, 75, 5),
+		image.Rect(75, 0, 100, 5),
+		// Down the right edge.
+		image.Rect(90, 0, 100, 25),
+		image.Rect(90, 25, 100, 50),
+		// Back along the bottom.
+		image.Rect(75, 45, 100, 50),
+		image.Rect(50, 45, 75, 50),
+		image.Rect(25, 45, 50, 50),
+		image.Rect(0, 45, 25, 50),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got regions\n%v\nwant\n%v", got, want)
+	}
+
+	g = Geometry{Top: 2, Left: 1, Start: TopRight, Direction: Counterclockwise, Depth: 0.2}
+	got = g.Regions(image.Rect(10, 10, 110, 60))
+	want = []image.Rectangle{
+		// Along the top to the left, then down the left edge.
+		image.Rect(60, 10, 110, 20),
+		image.Rect(10, 10, 60, 20),
+		image.Rect(10, 10, 30, 60),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got regions\n%v\nwant\n%v", got, want)
+	}
+
+	// More LEDs than pixels still gives each LED a pixel.
+	for _, r := range (Geometry{Top: 8}).Regions(image.Rect(0, 0, 4, 4)) {
+		if r.Empty() {
+			t.Errorf("got empty region %v", r)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	invalid := []Geometry{
+		{},
+		{Top: -1, Bottom: 2},
+		{Top: 1, Start: "middle"},
+		{Top: 1, Direction: "up"},
+		{Top: 1, Depth: 2},
+	}
+	for _, g := range invalid {
+		if err := g.Validate(); err == nil {
+			t.Errorf("expected an error for %+v", g)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	img := screen(200, 100, green, blue, white, red)
+	g := Geometry{Top: 2, Right: 1, Bottom: 2, Left: 1, Start: TopLeft}
+
+	got := Sample(img, g.Regions(img.Bounds()))
+	// The top and bottom regions reach into the corners, which belong to the
+	// left and right edges.
+	want := []led.RGBColor{
+		{51, 204, 0}, {0, 204, 51},
+		blue,
+		{204, 204, 255}, {255, 204, 204},
+		red,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Regions outside the image are black.
+	if got := Sample(img, []image.Rectangle{image.Rect(300, 0, 310, 10)}); got[0] != (led.RGBColor{}) {
+		t.Errorf("got %v outside the image, want black", got[0])
+	}
+}
+
+func frame(o *Output) led.LEDs {
+	var leds led.LEDs
+	o.AcquireFrame(func(l led.LEDs) { leds = append(leds, l...) })
+	return leds
+}
+
+func TestOutput(t *testing.T) {
+	clock := clock.NewManual(time.Unix(0, 0))
+	g := Geometry{Left: 1, Right: 1}
+	out := NewOutput(g, time.Second, time.Second, clock)
+
+	if !out.Silent() {
+		t.Error("output is not silent before receiving")
+	}
+
+	// The first frame is shown right away.
+	out.Update(screen(100, 100, white, blue, white, red))
+	if got, want := frame(out), (led.LEDs{red, blue}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if out.Silent() {
+		t.Error("output is silent while receiving")
+	}
+
+	// Later frames are eased into.
+	out.Update(screen(100, 100, white, red, white, blue))
+	clock.Advance(time.Second)
+	// 1 - 1/e of the way there.
+	want := led.LEDs{{94, 0, 161}, {161, 0, 94}}
+	if got := frame(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v after a second, want %v", got, want)
+	}
+
+	clock.Advance(time.Minute)
+	if !out.Silent() {
+		t.Error("output is not silent after the timeout")
+	}
+	if got, want := frame(out), (led.LEDs{blue, red}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v after a minute, want %v", got, want)
+	}
+}
+
+func TestDirSource(t *testing.T) {
+	dir := t.TempDir()
+	for name, c := range map[string]led.RGBColor{"1.png": red, "2.png": green} {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := png.Encode(f, screen(20, 20, c, c, c, c)); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	// Other files are ignored.
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hi"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := clock.NewManual(time.Unix(0, 0))
+	src := DirSource{Dir: dir, Interval: time.Second, Clock: clock}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	frames := make(chan led.RGBColor)
+	go src.Run(ctx, func(img image.Image) {
+		frames <- Sample(img, []image.Rectangle{image.Rect(0, 0, 2, 20)})[0]
+	})
+
+	for i, want := range []led.RGBColor{red, green, red} {
+		if got := <-frames; got != want {
+			t.Errorf("frame %d: got %v, want %v", i, got, want)
+		}
+		for clock.Waiters() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		clock.Advance(time.Second)
+	}
+
+	empty := DirSource{Dir: t.TempDir(), Interval: time.Second, Clock: clock}
+	if err := empty.Run(ctx, func(image.Image) {}); err == nil {
+		t.Error("expected an error for a directory without images")
+	}
+}
+
+func TestReadFrames(t *testing.T) {
+	raw := []byte{
+		255, 0, 0, 0, 255, 0,
+		0, 0, 255, 255, 255, 255,
+		1, 2, 3, 4, 5, 6,
+		7, 8, 9, 10, 11, 12,
+	}
+
+	var got []led.RGBColor
+	err := readFrames(context.Background(), bytes.NewReader(raw), 2, 2, func(img image.Image) {
+		for y := 0; y < 2; y++ {
+			for x := 0; x < 2; x++ {
+				r, g, b, _ := img.At(x, y).RGBA()
+				got = append(got, led.RGBColor{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)})
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []led.RGBColor{red, green, blue, white, {1, 2, 3}, {4, 5, 6}, {7, 8, 9}, {10, 11, 12}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// A partial frame at the end is an error.
+	err = readFrames(context.Background(), bytes.NewReader(raw[:15]), 2, 2, func(image.Image) {})
+	if err == nil {
+		t.Error("expected an error for a partial frame")
+	}
+}
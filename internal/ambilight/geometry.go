@@ -0,0 +1,196 @@
+// Package ambilight colors a strip around a screen after the edges of what
+// is shown on it.
+package ambilight
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+)
+
+// Corner is a corner of the screen as seen from its front.
+type Corner string
+
+const (
+	TopLeft     Corner = "top-left"
+	TopRight    Corner = "top-right"
+	BottomRight Corner = "bottom-right"
+	BottomLeft  Corner = "bottom-left"
+)
+
+// Direction is the direction that the strip runs around the screen as seen
+// from its front.
+type Direction string
+
+const (
+	Clockwise        Direction = "clockwise"
+	Counterclockwise Direction = "counterclockwise"
+)
+
+// DefaultDepth is the default depth of the sampled regions.
+const DefaultDepth = 0.1
+
+// Geometry is how the LEDs are laid out around the screen.
+type Geometry struct {
+	// Top, Right, Bottom and Left are the number of LEDs along each edge.
+	// Edges without LEDs are skipped.
+	Top, Right, Bottom, Left int
+	// Start is the corner where the strip starts. If empty, then it starts
+	// at the bottom left.
+	Start Corner
+	// Direction is the direction the strip runs in from its start. If empty,
+	// then it runs clockwise.
+	Direction Direction
+	// Depth is how far each LED's region reaches into the screen, as a
+	// fraction of the screen's height for the top and bottom edges and of its
+	// width for the left and right edges. If zero, then DefaultDepth is used.
+	Depth float64
+}
+
+// Validate returns an error if the geometry is invalid.
+func (g Geometry) Validate() error {
+	if g.Top < 0 || g.Right < 0 || g.Bottom < 0 || g.Left < 0 {
+		return errors.New("the number of LEDs along an edge must not be negative")
+	}
+	if g.NumLEDs() == 0 {
+		return errors.New("no LEDs along any edge")
+	}
+	if g.startEdge() == -1 {
+		return fmt.Errorf("unknown corner %q", g.Start)
+	}
+	switch g.Direction {
+	case "", Clockwise, Counterclockwise:
+	default:
+		return fmt.Errorf("unknown direction %q", g.Direction)
+	}
+	if g.Depth < 0 || g.Depth > 1 {
+		return errors.New("depth must be within [0, 1]")
+	}
+	return nil
+}
+
+// NumLEDs returns the number of LEDs around the screen.
+func (g Geometry) NumLEDs() int {
+	return g.Top + g.Right + g.Bottom + g.Left
+}
+
+// edge is an edge of the screen.
+type edge int
+
+// The edges in clockwise order from the top left corner. Each edge starts at
+// the corner that comes first going clockwise, so the top edge runs from left
+// to right and the left edge from the bottom up.
+const (
+	topEdge edge = iota
+	rightEdge
+	bottomEdge
+	leftEdge
+)
+
+// startEdge returns the first edge going clockwise from the start corner, or
+// -1 if the corner is unknown.
+func (g Geometry) startEdge() edge {
+	switch g.Start {
+	case TopLeft:
+		return topEdge
+	case TopRight:
+		return rightEdge
+	case BottomRight:
+		return bottomEdge
+	case BottomLeft, "":
+		return leftEdge
+	default:
+		return -1
+	}
+}
+
+func (g Geometry) count(e edge) int {
+	switch e {
+	case topEdge:
+		return g.Top
+	case rightEdge:
+		return g.Right
+	case bottomEdge:
+		return g.Bottom
+	default:
+		return g.Left
+	}
+}
+
+// Regions returns the region of the screen that each LED samples in the
+// order of the strip, for a screen with the given bounds. The geometry must
+// be valid.
+func (g Geometry) Regions(bounds image.Rectangle) []image.Rectangle {
+	depth := g.Depth
+	if depth == 0 {
+		depth = DefaultDepth
+	}
+	w, h := bounds.Dx(), bounds.Dy()
+	dx := clamp(int(math.Round(depth*float64(w))), 1, w)
+	dy := clamp(int(math.Round(depth*float64(h))), 1, h)
+
+	// Go around clockwise from the start corner, then turn it around if the
+	// strip runs the other way.
+	regions := make([]image.Rectangle, 0, g.NumLEDs())
+	start := g.startEdge()
+	for i := edge(0); i < 4; i++ {
+		e := (start + i) % 4
+		n := g.count(e)
+		for j := 0; j < n; j++ {
+			var r image.Rectangle
+			switch e {
+			case topEdge:
+				x0, x1 := span(j, n, w)
+				r = image.Rect(x0, 0, x1, dy)
+			case rightEdge:
+				y0, y1 := span(j, n, h)
+				r = image.Rect(w-dx, y0, w, y1)
+			case bottomEdge:
+				x0, x1 := span(n-1-j, n, w)
+				r = image.Rect(x0, h-dy, x1, h)
+			case leftEdge:
+				y0, y1 := span(n-1-j, n, h)
+				r = image.Rect(0, y0, dx, y1)
+			}
+			regions = append(regions, r.Add(bounds.Min))
+		}
+	}
+
+	if g.Direction == Counterclockwise {
+		for i, j := 0, len(regions)-1; i < j; i, j = i+1, j-1 {
+			regions[i], regions[j] = regions[j], regions[i]
+		}
+	}
+	return regions
+}
+
+// span returns the range of the ith of n equal parts of the given length.
+// Every part is at least one pixel long if the length allows it.
+func span(i, n, length int) (lo, hi int) {
+	lo = i * length / n
+	hi = (i + 1) * length / n
+	if hi == lo {
+		hi = min(lo+1, length)
+		lo = hi - 1
+	}
+	return lo, hi
+}
+
+func clamp(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
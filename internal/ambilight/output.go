@@ -0,0 +1,120 @@
+package ambilight
+
+import (
+	"image"
+	"math"
+	"sync"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// DataTimeout is how long an output is considered to be receiving after its
+// last frame.
+const DataTimeout = 2500 * time.Millisecond
+
+// Output is an animator that draws the edges of the frames it receives. Its
+// LEDs ease towards the colors of the latest frame, so that they do not
+// flicker with the picture. It is safe for concurrent use.
+type Output struct {
+	geometry  Geometry
+	smoothing time.Duration
+	timeout   time.Duration
+	clock     clock.Clock
+
+	mu sync.Mutex
+	// bounds are the bounds that regions were computed for.
+	bounds  image.Rectangle
+	regions []image.Rectangle
+	target  []led.RGBColor
+	current [][3]float64
+	leds    led.LEDs
+	// last is when the last frame was received.
+	last time.Time
+	// drawn is when the LEDs were last eased towards the target.
+	drawn time.Time
+}
+
+// NewOutput creates a new output for the LEDs of the geometry, which must be
+// valid. The LEDs take about the smoothing duration to follow a change of the
+// picture, or follow it right away if it is zero. The output is silent once
+// it has received nothing for the given timeout.
+func NewOutput(geometry Geometry, smoothing, timeout time.Duration, clock clock.Clock) *Output {
+	numLEDs := geometry.NumLEDs()
+	return &Output{
+		geometry:  geometry,
+		smoothing: smoothing,
+		timeout:   timeout,
+		clock:     clock,
+		target:    make([]led.RGBColor, numLEDs),
+		current:   make([][3]float64, numLEDs),
+		leds:      led.NewLEDs(numLEDs),
+	}
+}
+
+// Update samples the edges of the frame. The image is not kept.
+func (o *Output) Update(img image.Image) {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return
+	}
+
+	o.mu.Lock()
+	if bounds != o.bounds {
+		o.bounds = bounds
+		o.regions = o.geometry.Regions(bounds)
+	}
+	regions := o.regions
+	o.mu.Unlock()
+
+	// Sample outside the lock, since it takes a while for large frames.
+	colors := Sample(img, regions)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	copy(o.target, colors)
+	if o.silent() {
+		// Start from the picture rather than fading in from black or from
+		// whatever was shown long ago.
+		for i, c := range colors {
+			o.current[i] = [3]float64{float64(c[0]), float64(c[1]), float64(c[2])}
+		}
+	}
+	o.last = o.clock.Now()
+}
+
+// AcquireFrame implements Animator.
+func (o *Output) AcquireFrame(f func(led.LEDs)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := o.clock.Now()
+	alpha := 1.0
+	if o.smoothing > 0 && !o.drawn.IsZero() {
+		alpha = 1 - math.Exp(-float64(now.Sub(o.drawn))/float64(o.smoothing))
+	}
+	o.drawn = now
+
+	for i, c := range o.target {
+		for ch := range c {
+			o.current[i][ch] += (float64(c[ch]) - o.current[i][ch]) * alpha
+			o.leds[i][ch] = uint8(math.Round(o.current[i][ch]))
+		}
+	}
+	f(o.leds)
+}
+
+// Silent returns true if nothing was received for the timeout.
+func (o *Output) Silent() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.silent()
+}
+
+// silent returns true if nothing was received for the timeout. It must be
+// called with the output locked.
+func (o *Output) silent() bool {
+	return o.last.IsZero() || o.clock.Now().Sub(o.last) >= o.timeout
+}
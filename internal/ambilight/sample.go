@@ -0,0 +1,54 @@
+package ambilight
+
+import (
+	"image"
+
+	"libdb.so/catglow/internal/led"
+)
+
+// maxSamples is about the most pixels that are sampled per region. Larger
+// regions are sampled evenly, which is plenty for their average color.
+const maxSamples = 32 * 32
+
+// Sample returns the average color of each region of the image. Regions
+// outside the image are black.
+func Sample(img image.Image, regions []image.Rectangle) []led.RGBColor {
+	colors := make([]led.RGBColor, len(regions))
+	bounds := img.Bounds()
+	for i, r := range regions {
+		colors[i] = average(img, r.Intersect(bounds))
+	}
+	return colors
+}
+
+// average returns the average color of the region of the image.
+func average(img image.Image, r image.Rectangle) led.RGBColor {
+	if r.Empty() {
+		return led.RGBColor{}
+	}
+
+	step := 1
+	for (r.Dx()/step)*(r.Dy()/step) > maxSamples {
+		step++
+	}
+
+	var sum [3]uint64
+	var n uint64
+	for y := r.Min.Y; y < r.Max.Y; y += step {
+		for x := r.Min.X; x < r.Max.X; x += step {
+			// Transparent pixels count as black, since the screen shows
+			// nothing there.
+			red, green, blue, _ := img.At(x, y).RGBA()
+			sum[0] += uint64(red >> 8)
+			sum[1] += uint64(green >> 8)
+			sum[2] += uint64(blue >> 8)
+			n++
+		}
+	}
+
+	var avg led.RGBColor
+	for ch := range avg {
+		avg[ch] = uint8((sum[ch] + n/2) / n)
+	}
+	return avg
+}
@@ -0,0 +1,169 @@
+package ambilight
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"libdb.so/catglow/internal/clock"
+
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// Source is where the frames of the screen come from.
+type Source interface {
+	// Run calls the given function with every frame until the context is
+	// canceled or the source ends. The function must not keep the image.
+	Run(ctx context.Context, frame func(image.Image)) error
+}
+
+// DirSource shows the PNG and JPEG images in a directory in the order of
+// their names, over and over. It is mostly useful to try out a geometry.
+type DirSource struct {
+	// Dir is the directory of images. It is read again every time the images
+	// are shown, so images can be added while it runs.
+	Dir string
+	// Interval is how long each image is shown.
+	Interval time.Duration
+	// Clock is the clock to show the images by.
+	Clock clock.Clock
+}
+
+var _ Source = DirSource{}
+
+// Run implements Source.
+func (s DirSource) Run(ctx context.Context, frame func(image.Image)) error {
+	for {
+		paths, err := s.images()
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			return fmt.Errorf("no images in %s", s.Dir)
+		}
+
+		for _, path := range paths {
+			img, err := loadImage(path)
+			if err != nil {
+				return err
+			}
+			frame(img)
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-s.Clock.After(s.Interval):
+			}
+		}
+	}
+}
+
+// images returns the paths of the images in the directory, sorted by name.
+func (s DirSource) images() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".png", ".jpg", ".jpeg":
+			if !entry.IsDir() {
+				paths = append(paths, filepath.Join(s.Dir, entry.Name()))
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func loadImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s", path)
+	}
+	return img, nil
+}
+
+// PipeSource reads raw frames of 8-bit RGB pixels, row by row, from a named
+// pipe or file, such as what FFmpeg writes with -f rawvideo -pix_fmt rgb24.
+//
+// The named pipe is opened for both reading and writing, so that it never
+// reaches the end when its writer goes away. A file ends the source at its
+// end.
+type PipeSource struct {
+	// Path is the path of the named pipe or file.
+	Path string
+	// Width and Height are the size of the frames in pixels.
+	Width, Height int
+}
+
+var _ Source = PipeSource{}
+
+// Run implements Source.
+func (s PipeSource) Run(ctx context.Context, frame func(image.Image)) error {
+	if s.Width <= 0 || s.Height <= 0 {
+		return errors.New("frame size must be positive")
+	}
+
+	f, err := os.OpenFile(s.Path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Closing the pipe unblocks the pending read once the context is done.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			f.Close()
+		case <-done:
+		}
+	}()
+
+	return readFrames(ctx, f, s.Width, s.Height, frame)
+}
+
+// readFrames reads raw RGB frames of the given size from r until it ends or
+// the context is canceled.
+func readFrames(ctx context.Context, r io.Reader, width, height int, frame func(image.Image)) error {
+	buf := make([]byte, width*height*3)
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return errors.Wrap(err, "failed to read a frame")
+		}
+
+		for i, j := 0, 0; i < len(buf); i, j = i+3, j+4 {
+			img.Pix[j+0] = buf[i+0]
+			img.Pix[j+1] = buf[i+1]
+			img.Pix[j+2] = buf[i+2]
+			img.Pix[j+3] = 0xFF
+		}
+		frame(img)
+	}
+}
@@ -0,0 +1,245 @@
+package artnet
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/dmx"
+	"libdb.so/catglow/internal/led"
+)
+
+// artDmx is an ArtDmx packet for port address 1:2:3 with sequence 5 and
+// four channels.
+var artDmx = []byte{
+	'A', 'r', 't', '-', 'N', 'e', 't', 0,
+	0x00, 0x50, // OpDmx, little-endian
+	0x00, 0x0e, // protocol version 14
+	0x05,       // sequence
+	0x00,       // physical
+	0x23,       // SubUni: sub-net 2, universe 3
+	0x01,       // Net
+	0x00, 0x04, // length, big-endian
+	0xff, 0x80, 0x00, 0x10,
+}
+
+// artPoll is an ArtPoll packet that asks for replies on changes.
+var artPoll = []byte{
+	'A', 'r', 't', '-', 'N', 'e', 't', 0,
+	0x00, 0x20, // OpPoll
+	0x00, 0x0e, // protocol version 14
+	0x02, // flags
+	0x00, // diagnostics priority
+}
+
+func TestParseDmxPacket(t *testing.T) {
+	p, err := ParseDmxPacket(artDmx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Address != NewPortAddress(1, 2, 3) {
+		t.Errorf("address = %v, want 1:2:3", p.Address)
+	}
+	if p.Address != 0x123 {
+		t.Errorf("address = %#x, want 0x123", uint16(p.Address))
+	}
+	if p.Sequence != 5 {
+		t.Errorf("sequence = %d, want 5", p.Sequence)
+	}
+	if string(p.Data) != "\xff\x80\x00\x10" {
+		t.Errorf("data = %v", p.Data)
+	}
+
+	b, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != string(artDmx) {
+		t.Errorf("marshaled %v, want %v", b, artDmx)
+	}
+
+	if _, err := ParseDmxPacket(artDmx[:20]); err == nil {
+		t.Error("expected an error for a truncated packet")
+	}
+	if _, err := ParseDmxPacket([]byte("not Art-Net at all")); err != ErrNotArtNet {
+		t.Errorf("expected ErrNotArtNet, got %v", err)
+	}
+}
+
+func TestParsePollPacket(t *testing.T) {
+	p, err := ParsePollPacket(artPoll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Flags != 0x02 {
+		t.Errorf("flags = %#x, want 0x02", p.Flags)
+	}
+
+	if _, err := ParsePollPacket(artDmx); err == nil {
+		t.Error("expected an error for an ArtDmx packet")
+	}
+}
+
+func TestPollReplyPacket(t *testing.T) {
+	reply := PollReplyPacket{
+		IP:        net.IPv4(192, 168, 1, 20),
+		ShortName: "catglow",
+		LongName:  "catglow LED strip",
+		Ports:     []PortAddress{NewPortAddress(1, 2, 3), NewPortAddress(1, 2, 4)},
+		Active:    []bool{true, false},
+		BindIndex: 1,
+	}
+
+	b, err := reply.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 239 {
+		t.Errorf("reply is %d bytes, want 239", len(b))
+	}
+
+	// Check the fields that controllers care about by their offsets.
+	fields := []struct {
+		name   string
+		offset int
+		want   []byte
+	}{
+		{"OpCode", 8, []byte{0x00, 0x21}},
+		{"IP", 10, []byte{192, 168, 1, 20}},
+		{"Port", 14, []byte{0x36, 0x19}},
+		{"NetSwitch", 18, []byte{1}},
+		{"SubSwitch", 19, []byte{2}},
+		{"ShortName", 26, []byte("catglow\x00")},
+		{"NumPorts", 172, []byte{0, 2}},
+		{"PortTypes", 174, []byte{0x80, 0x80, 0, 0}},
+		{"GoodOutput", 182, []byte{0x80, 0, 0, 0}},
+		{"SwOut", 190, []byte{3, 4, 0, 0}},
+		{"BindIndex", 211, []byte{1}},
+	}
+	for _, f := range fields {
+		if got := b[f.offset : f.offset+len(f.want)]; string(got) != string(f.want) {
+			t.Errorf("%s = %v, want %v", f.name, got, f.want)
+		}
+	}
+
+	parsed, err := ParsePollReplyPacket(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.ShortName != reply.ShortName || parsed.LongName != reply.LongName ||
+		len(parsed.Ports) != 2 || parsed.Ports[1] != reply.Ports[1] || !parsed.Active[0] {
+		t.Errorf("parsed %+v, want %+v", parsed, reply)
+	}
+
+	reply.Ports = []PortAddress{NewPortAddress(1, 2, 3), NewPortAddress(1, 3, 3)}
+	if _, err := reply.MarshalBinary(); err == nil {
+		t.Error("expected an error for ports in different sub-nets")
+	}
+}
+
+func TestNode(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	addr := freeAddr(t)
+	nodeAddr, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two LEDs on 1:2:3, and one more on 1:2:4.
+	out := dmx.NewOutput(3, dmx.Mapping{
+		Universe:            uint16(NewPortAddress(1, 2, 3)),
+		ChannelsPerUniverse: 6,
+	}, time.Second, clock.Real)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	nodes := NewNodes()
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- nodes.Run(ctx, NodeConfig{Addr: addr, LongName: "test strip"}, out)
+	}()
+
+	// Keep polling until the node is up and replies.
+	var reply PollReplyPacket
+	buf := make([]byte, 1500)
+	for {
+		if _, err := conn.WriteToUDP(artPoll, nodeAddr); err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+		n, _, err := conn.ReadFromUDP(buf)
+		if err == nil {
+			reply, err = ParsePollReplyPacket(buf[:n])
+			if err != nil {
+				t.Fatal(err)
+			}
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatal("timed out waiting for ArtPollReply")
+		}
+	}
+
+	if reply.ShortName != "catglow" || reply.LongName != "test strip" {
+		t.Errorf("names = %q, %q", reply.ShortName, reply.LongName)
+	}
+	if len(reply.Ports) != 2 || reply.Ports[0] != 0x123 || reply.Ports[1] != 0x124 {
+		t.Errorf("ports = %v, want 1:2:3 and 1:2:4", reply.Ports)
+	}
+	if !reply.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("IP = %v, want 127.0.0.1", reply.IP)
+	}
+
+	if _, err := conn.WriteToUDP(artDmx, nodeAddr); err != nil {
+		t.Fatal(err)
+	}
+	// The same sequence number again is dropped.
+	stale := append([]byte(nil), artDmx...)
+	stale[18] = 0
+	if _, err := conn.WriteToUDP(stale, nodeAddr); err != nil {
+		t.Fatal(err)
+	}
+	next := append([]byte(nil), artDmx...)
+	next[12] = 6
+	next[14] = 0x24
+	if _, err := conn.WriteToUDP(next, nodeAddr); err != nil {
+		t.Fatal(err)
+	}
+
+	want := led.LEDs{{0xff, 0x80, 0x00}, {0x10, 0, 0}, {0xff, 0x80, 0x00}}
+	for {
+		var got led.LEDs
+		out.AcquireFrame(func(leds led.LEDs) { got = append(got, leds...) })
+		if got[0] == want[0] && got[1] == want[1] && got[2] == want[2] {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("LEDs = %v, want %v", got, want)
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("Run failed: %v", err)
+	}
+}
+
+func freeAddr(t *testing.T) string {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().String()
+}
@@ -0,0 +1,295 @@
+package artnet
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"libdb.so/catglow/internal/dmx"
+)
+
+// DataTimeout is how long a port is reported as receiving data after its last
+// packet. Controllers resend universes at least every few seconds even if
+// nothing changed.
+const DataTimeout = 4 * time.Second
+
+// NodeConfig configures a node.
+type NodeConfig struct {
+	// Addr is the UDP address to listen on. If empty, then all interfaces are
+	// listened on at Port.
+	Addr string
+	// ShortName is the name that controllers show for the node. If empty,
+	// then "catglow" is used.
+	ShortName string
+	// LongName is the description that controllers show for the node.
+	LongName string
+}
+
+func (c NodeConfig) addr() string {
+	if c.Addr == "" {
+		return fmt.Sprintf(":%d", Port)
+	}
+	return c.Addr
+}
+
+// Nodes shares nodes between outputs that listen on the same address, so
+// that they show up as one node with a port for each universe. The zero
+// value is ready to use.
+type Nodes struct {
+	mu    sync.Mutex
+	nodes map[string]*node
+}
+
+// NewNodes creates a new set of nodes.
+func NewNodes() *Nodes {
+	return &Nodes{}
+}
+
+// Run receives universes into the output until the context is canceled or
+// the socket fails. The node is started when the first output listening on
+// its address attaches, which also sets the node's names, and stopped once
+// the last one detaches.
+func (ns *Nodes) Run(ctx context.Context, cfg NodeConfig, out *dmx.Output) error {
+	ns.mu.Lock()
+	if ns.nodes == nil {
+		ns.nodes = make(map[string]*node)
+	}
+	n, ok := ns.nodes[cfg.addr()]
+	if !ok {
+		n = &node{outputs: make(map[*dmx.Output]struct{})}
+		ns.nodes[cfg.addr()] = n
+	}
+	ns.mu.Unlock()
+
+	return n.run(ctx, cfg, out)
+}
+
+type node struct {
+	mu      sync.Mutex
+	cfg     NodeConfig
+	conn    *net.UDPConn
+	outputs map[*dmx.Output]struct{}
+	// sequences is the last sequence number of each sender and port.
+	sequences map[sequenceKey]uint8
+	// received is when each port last received data.
+	received map[PortAddress]time.Time
+	// done is closed once the socket fails, after which err is set.
+	done chan struct{}
+	err  error
+}
+
+type sequenceKey struct {
+	sender string
+	port   PortAddress
+}
+
+func (n *node) run(ctx context.Context, cfg NodeConfig, out *dmx.Output) error {
+	n.mu.Lock()
+	if n.conn == nil {
+		if err := n.open(cfg); err != nil {
+			n.mu.Unlock()
+			return err
+		}
+	}
+	conn := n.conn
+	done := n.done
+	n.outputs[out] = struct{}{}
+	n.mu.Unlock()
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case <-done:
+		n.mu.Lock()
+		err = n.err
+		n.mu.Unlock()
+	}
+
+	n.mu.Lock()
+	if n.conn == conn {
+		delete(n.outputs, out)
+		if len(n.outputs) == 0 {
+			n.conn.Close()
+			n.conn = nil
+		}
+	}
+	n.mu.Unlock()
+
+	return err
+}
+
+// open opens the socket and starts reading from it.
+func (n *node) open(cfg NodeConfig) error {
+	addr, err := net.ResolveUDPAddr("udp4", cfg.addr())
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenUDP("udp4", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for Art-Net: %w", err)
+	}
+
+	if cfg.ShortName == "" {
+		cfg.ShortName = "catglow"
+	}
+
+	n.cfg = cfg
+	n.conn = conn
+	n.sequences = make(map[sequenceKey]uint8)
+	n.received = make(map[PortAddress]time.Time)
+	n.done = make(chan struct{})
+	n.err = nil
+
+	go n.read(conn, n.done)
+	return nil
+}
+
+func (n *node) read(conn *net.UDPConn, done chan struct{}) {
+	buf := make([]byte, 1500)
+	for {
+		size, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			n.mu.Lock()
+			if n.conn == conn {
+				// The socket failed rather than being closed by us. Let the
+				// next output that attaches reopen it.
+				n.err = fmt.Errorf("failed to receive Art-Net: %w", err)
+				n.conn = nil
+				n.outputs = make(map[*dmx.Output]struct{})
+				conn.Close()
+			}
+			n.mu.Unlock()
+			close(done)
+			return
+		}
+
+		b := buf[:size]
+
+		op, err := ReadOpCode(b)
+		if err != nil {
+			continue
+		}
+
+		switch op {
+		case OpDmx:
+			p, err := ParseDmxPacket(b)
+			if err != nil {
+				continue
+			}
+			n.mu.Lock()
+			n.handleDmx(p, from.String(), time.Now())
+			n.mu.Unlock()
+
+		case OpPoll:
+			if _, err := ParsePollPacket(b); err != nil {
+				continue
+			}
+			n.mu.Lock()
+			replies := n.pollReplies(localIP(conn, from), time.Now())
+			n.mu.Unlock()
+			for _, reply := range replies {
+				// Failing to reply only means that the node is not
+				// discovered.
+				conn.WriteToUDP(reply, from)
+			}
+		}
+	}
+}
+
+// handleDmx draws the universe of an ArtDmx packet. If several controllers
+// send the same universe, then the latest packet wins.
+func (n *node) handleDmx(p DmxPacket, sender string, now time.Time) {
+	var covered bool
+	for out := range n.outputs {
+		covered = covered || out.Covers(uint16(p.Address))
+	}
+	if !covered {
+		return
+	}
+
+	if p.Sequence != 0 {
+		key := sequenceKey{sender, p.Address}
+		// Drop packets that arrive out of order, unless the sequence jumped
+		// far enough that the controller probably restarted.
+		if last, ok := n.sequences[key]; ok {
+			if d := int8(p.Sequence - last); d <= 0 && d > -20 {
+				return
+			}
+		}
+		n.sequences[key] = p.Sequence
+	}
+
+	n.received[p.Address] = now
+
+	for out := range n.outputs {
+		out.Update(uint16(p.Address), p.Data)
+	}
+}
+
+// pollReplies returns the encoded ArtPollReply packets that describe all
+// ports of the node.
+func (n *node) pollReplies(ip net.IP, now time.Time) [][]byte {
+	var ports []PortAddress
+	seen := make(map[PortAddress]bool)
+	for out := range n.outputs {
+		for _, universe := range out.Universes() {
+			port := PortAddress(universe & 0x7fff)
+			if !seen[port] {
+				seen[port] = true
+				ports = append(ports, port)
+			}
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+
+	var replies [][]byte
+	for len(ports) > 0 {
+		// Ports in a reply share their Net and Sub-Net.
+		count := 1
+		for count < len(ports) && count < MaxPorts && ports[count]&^0xf == ports[0]&^0xf {
+			count++
+		}
+
+		reply := PollReplyPacket{
+			IP:         ip,
+			ShortName:  n.cfg.ShortName,
+			LongName:   n.cfg.LongName,
+			NodeReport: "#0001 [0000] catglow is running",
+			Ports:      ports[:count],
+			BindIndex:  uint8(len(replies) + 1),
+		}
+		for _, port := range reply.Ports {
+			reply.Active = append(reply.Active, now.Sub(n.received[port]) < DataTimeout)
+		}
+
+		b, err := reply.MarshalBinary()
+		if err == nil {
+			replies = append(replies, b)
+		}
+
+		ports = ports[count:]
+	}
+
+	return replies
+}
+
+// localIP returns the IP address of the node as seen from the given
+// address.
+func localIP(conn *net.UDPConn, to *net.UDPAddr) net.IP {
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok && !addr.IP.IsUnspecified() {
+		return addr.IP
+	}
+
+	// Find the interface that packets to the address go out of. No packets
+	// are sent.
+	c, err := net.DialUDP("udp4", nil, to)
+	if err != nil {
+		return nil
+	}
+	defer c.Close()
+	return c.LocalAddr().(*net.UDPAddr).IP
+}
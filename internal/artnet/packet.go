@@ -0,0 +1,296 @@
+// Package artnet implements an Art-Net node that receives DMX512 universes
+// and answers polls from controllers so that they can discover it.
+package artnet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Port is the UDP port that Art-Net is sent to.
+const Port = 6454
+
+// ProtocolVersion is the version of the Art-Net protocol that is spoken.
+const ProtocolVersion = 14
+
+// OpCode is the type of an Art-Net packet.
+type OpCode uint16
+
+const (
+	// OpPoll is the OpCode of ArtPoll, which discovers nodes.
+	OpPoll OpCode = 0x2000
+	// OpPollReply is the OpCode of ArtPollReply, which nodes answer ArtPoll
+	// with.
+	OpPollReply OpCode = 0x2100
+	// OpDmx is the OpCode of ArtDmx, which carries a universe.
+	OpDmx OpCode = 0x5000
+)
+
+const id = "Art-Net\x00"
+
+// ErrNotArtNet is returned when parsing something that is not an Art-Net
+// packet.
+var ErrNotArtNet = errors.New("not an Art-Net packet")
+
+// ReadOpCode returns the OpCode of a packet.
+func ReadOpCode(b []byte) (OpCode, error) {
+	if len(b) < 10 || string(b[:8]) != id {
+		return 0, ErrNotArtNet
+	}
+	return OpCode(binary.LittleEndian.Uint16(b[8:])), nil
+}
+
+// PortAddress is the 15-bit address of a universe, made up of the Net, the
+// Sub-Net and the Universe.
+type PortAddress uint16
+
+// NewPortAddress returns the port address of the given net, sub-net and
+// universe.
+func NewPortAddress(net, subNet, universe uint8) PortAddress {
+	return PortAddress(net&0x7f)<<8 | PortAddress(subNet&0xf)<<4 | PortAddress(universe&0xf)
+}
+
+// Net returns the Net of the port address.
+func (a PortAddress) Net() uint8 { return uint8(a>>8) & 0x7f }
+
+// SubNet returns the Sub-Net of the port address.
+func (a PortAddress) SubNet() uint8 { return uint8(a>>4) & 0xf }
+
+// Universe returns the Universe within the Sub-Net of the port address.
+func (a PortAddress) Universe() uint8 { return uint8(a) & 0xf }
+
+// String formats the port address as net:subnet:universe.
+func (a PortAddress) String() string {
+	return fmt.Sprintf("%d:%d:%d", a.Net(), a.SubNet(), a.Universe())
+}
+
+// DmxPacket is an ArtDmx packet, which carries a universe.
+type DmxPacket struct {
+	// Sequence is the sequence number of the packet, or 0 if the sender does
+	// not sequence its packets.
+	Sequence uint8
+	// Physical is the physical input port that the data came from.
+	Physical uint8
+	// Address is the port address of the universe.
+	Address PortAddress
+	// Data is the channels of the universe.
+	Data []byte
+}
+
+const dmxHeaderSize = 18
+
+// ParseDmxPacket parses an ArtDmx packet. The data of the returned packet
+// refers to b.
+func ParseDmxPacket(b []byte) (DmxPacket, error) {
+	op, err := ReadOpCode(b)
+	if err != nil {
+		return DmxPacket{}, err
+	}
+	if op != OpDmx {
+		return DmxPacket{}, fmt.Errorf("unexpected opcode %#04x", op)
+	}
+	if len(b) < dmxHeaderSize {
+		return DmxPacket{}, fmt.Errorf("ArtDmx packet too short (%d bytes)", len(b))
+	}
+
+	length := int(binary.BigEndian.Uint16(b[16:]))
+	if length < 2 || length > 512 || len(b) < dmxHeaderSize+length {
+		return DmxPacket{}, fmt.Errorf("invalid ArtDmx length %d", length)
+	}
+
+	return DmxPacket{
+		Sequence: b[12],
+		Physical: b[13],
+		Address:  PortAddress(binary.LittleEndian.Uint16(b[14:]) & 0x7fff),
+		Data:     b[dmxHeaderSize : dmxHeaderSize+length],
+	}, nil
+}
+
+// MarshalBinary encodes the ArtDmx packet. Data of an odd length is padded
+// with a zero channel.
+func (p DmxPacket) MarshalBinary() ([]byte, error) {
+	if len(p.Data) > 512 {
+		return nil, fmt.Errorf("too many channels (%d)", len(p.Data))
+	}
+
+	length := len(p.Data) + len(p.Data)%2
+	if length < 2 {
+		length = 2
+	}
+
+	b := make([]byte, dmxHeaderSize+length)
+	copy(b, id)
+	binary.LittleEndian.PutUint16(b[8:], uint16(OpDmx))
+	binary.BigEndian.PutUint16(b[10:], ProtocolVersion)
+	b[12] = p.Sequence
+	b[13] = p.Physical
+	binary.LittleEndian.PutUint16(b[14:], uint16(p.Address))
+	binary.BigEndian.PutUint16(b[16:], uint16(length))
+	copy(b[dmxHeaderSize:], p.Data)
+
+	return b, nil
+}
+
+// PollPacket is an ArtPoll packet, which controllers broadcast to discover
+// nodes.
+type PollPacket struct {
+	// Flags configures how nodes reply.
+	Flags uint8
+	// DiagPriority is the lowest priority of diagnostics to send.
+	DiagPriority uint8
+}
+
+// ParsePollPacket parses an ArtPoll packet.
+func ParsePollPacket(b []byte) (PollPacket, error) {
+	op, err := ReadOpCode(b)
+	if err != nil {
+		return PollPacket{}, err
+	}
+	if op != OpPoll {
+		return PollPacket{}, fmt.Errorf("unexpected opcode %#04x", op)
+	}
+	if len(b) < 14 {
+		return PollPacket{}, fmt.Errorf("ArtPoll packet too short (%d bytes)", len(b))
+	}
+	return PollPacket{
+		Flags:        b[12],
+		DiagPriority: b[13],
+	}, nil
+}
+
+// MarshalBinary encodes the ArtPoll packet.
+func (p PollPacket) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 14)
+	copy(b, id)
+	binary.LittleEndian.PutUint16(b[8:], uint16(OpPoll))
+	binary.BigEndian.PutUint16(b[10:], ProtocolVersion)
+	b[12] = p.Flags
+	b[13] = p.DiagPriority
+	return b, nil
+}
+
+// MaxPorts is the number of ports that an ArtPollReply can describe. Nodes
+// with more ports send a reply for every few of them.
+const MaxPorts = 4
+
+// PollReplyPacket is an ArtPollReply packet, which describes a node and up
+// to MaxPorts of its output ports. All ports share the same Net and Sub-Net.
+type PollReplyPacket struct {
+	// IP is the IPv4 address of the node.
+	IP net.IP
+	// ShortName is the name of the node, up to 17 characters.
+	ShortName string
+	// LongName is the description of the node, up to 63 characters.
+	LongName string
+	// NodeReport is the status of the node, up to 63 characters.
+	NodeReport string
+	// Ports are the output ports. Their Net and Sub-Net must be the same.
+	Ports []PortAddress
+	// Active is true for the ports that are receiving data.
+	Active []bool
+	// MAC is the MAC address of the node, if known.
+	MAC net.HardwareAddr
+	// BindIndex numbers the replies of a node, starting from 1.
+	BindIndex uint8
+}
+
+const pollReplySize = 239
+
+// MarshalBinary encodes the ArtPollReply packet.
+func (p PollReplyPacket) MarshalBinary() ([]byte, error) {
+	if len(p.Ports) > MaxPorts {
+		return nil, fmt.Errorf("too many ports (%d)", len(p.Ports))
+	}
+	for _, port := range p.Ports {
+		if port&^0xf != p.Ports[0]&^0xf {
+			return nil, fmt.Errorf("port %v is not in the same sub-net as %v", port, p.Ports[0])
+		}
+	}
+
+	b := make([]byte, pollReplySize)
+	copy(b, id)
+	binary.LittleEndian.PutUint16(b[8:], uint16(OpPollReply))
+	if ip := p.IP.To4(); ip != nil {
+		copy(b[10:14], ip)
+	}
+	binary.LittleEndian.PutUint16(b[14:], Port)
+	// VersInfo, Oem and ESTA codes stay zero.
+	if len(p.Ports) > 0 {
+		b[18] = p.Ports[0].Net()
+		b[19] = p.Ports[0].SubNet()
+	}
+	// Status1: indicators normal, port addresses set by the network.
+	b[23] = 0b1110_0000
+	putString(b[26:44], p.ShortName)
+	putString(b[44:108], p.LongName)
+	putString(b[108:172], p.NodeReport)
+	binary.BigEndian.PutUint16(b[172:], uint16(len(p.Ports)))
+	for i, port := range p.Ports {
+		// The port outputs DMX512.
+		b[174+i] = 0x80
+		if i < len(p.Active) && p.Active[i] {
+			b[182+i] = 0x80
+		}
+		b[190+i] = port.Universe()
+	}
+	// Style: a DMX to or from Art-Net device.
+	b[200] = 0x00
+	copy(b[201:207], p.MAC)
+	if ip := p.IP.To4(); ip != nil {
+		copy(b[207:211], ip)
+	}
+	b[211] = p.BindIndex
+	// Status2: supports 15-bit port addresses.
+	b[212] = 0b0000_1000
+
+	return b, nil
+}
+
+// ParsePollReplyPacket parses an ArtPollReply packet.
+func ParsePollReplyPacket(b []byte) (PollReplyPacket, error) {
+	op, err := ReadOpCode(b)
+	if err != nil {
+		return PollReplyPacket{}, err
+	}
+	if op != OpPollReply {
+		return PollReplyPacket{}, fmt.Errorf("unexpected opcode %#04x", op)
+	}
+	if len(b) < 212 {
+		return PollReplyPacket{}, fmt.Errorf("ArtPollReply packet too short (%d bytes)", len(b))
+	}
+
+	p := PollReplyPacket{
+		IP:         net.IP(append([]byte(nil), b[10:14]...)),
+		ShortName:  getString(b[26:44]),
+		LongName:   getString(b[44:108]),
+		NodeReport: getString(b[108:172]),
+		MAC:        net.HardwareAddr(append([]byte(nil), b[201:207]...)),
+		BindIndex:  b[211],
+	}
+
+	numPorts := int(binary.BigEndian.Uint16(b[172:]))
+	if numPorts > MaxPorts {
+		numPorts = MaxPorts
+	}
+	for i := 0; i < numPorts; i++ {
+		p.Ports = append(p.Ports, NewPortAddress(b[18], b[19], b[190+i]))
+		p.Active = append(p.Active, b[182+i]&0x80 != 0)
+	}
+
+	return p, nil
+}
+
+// putString writes a null-terminated string into b, truncating it if needed.
+func putString(b []byte, s string) {
+	copy(b[:len(b)-1], s)
+}
+
+func getString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
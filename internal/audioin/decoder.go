@@ -0,0 +1,252 @@
+package audioin
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mewkiz/flac"
+)
+
+// Decoder decodes PCM audio.
+type Decoder interface {
+	// Format returns the format of the decoded audio. The encoding is only
+	// informational.
+	Format() Format
+	// ReadFrame reads one frame of samples, one per channel, into dst. The
+	// samples are within [-1, 1]. It returns io.EOF at the end of the audio.
+	ReadFrame(dst []float64) error
+}
+
+// OpenFile opens an audio file. WAV and FLAC files are detected by their
+// extension. Anything else is read as raw PCM in the given format.
+func OpenFile(path string, raw Format) (Decoder, io.Closer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var dec Decoder
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav", ".wave":
+		dec, err = NewWAVDecoder(bufio.NewReader(f))
+	case ".flac":
+		dec, err = NewFLACDecoder(f)
+	default:
+		dec = NewRawDecoder(bufio.NewReader(f), raw)
+	}
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+
+	return dec, f, nil
+}
+
+type rawDecoder struct {
+	r      io.Reader
+	format Format
+	buf    []byte
+}
+
+// NewRawDecoder creates a decoder for raw interleaved PCM in the given format.
+func NewRawDecoder(r io.Reader, format Format) Decoder {
+	return &rawDecoder{
+		r:      r,
+		format: format,
+		buf:    make([]byte, format.Encoding.Size()*format.Channels),
+	}
+}
+
+func (d *rawDecoder) Format() Format { return d.format }
+
+func (d *rawDecoder) ReadFrame(dst []float64) error {
+	if _, err := io.ReadFull(d.r, d.buf); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return err
+	}
+
+	size := d.format.Encoding.Size()
+	for ch := range dst[:d.format.Channels] {
+		dst[ch] = decodeSample(d.format.Encoding, d.buf[ch*size:])
+	}
+	return nil
+}
+
+func decodeSample(enc Encoding, b []byte) float64 {
+	switch enc {
+	case U8:
+		return (float64(b[0]) - 128) / 128
+	case S16LE:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+	case S16BE:
+		return float64(int16(binary.BigEndian.Uint16(b))) / (1 << 15)
+	case S24LE:
+		v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+		return float64(v) / (1 << 23)
+	case S32LE:
+		return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+	case F32LE:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	case F64LE:
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	default:
+		return 0
+	}
+}
+
+// NewWAVDecoder creates a decoder for a RIFF WAVE stream. Integer PCM of 8,
+// 16, 24 and 32 bits and IEEE float of 32 and 64 bits are supported.
+func NewWAVDecoder(r io.Reader) (Decoder, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("failed to read RIFF header: %w", err)
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("not a WAVE file")
+	}
+
+	var format *Format
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil, fmt.Errorf("failed to read chunk header: %w", err)
+		}
+
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+
+		switch id {
+		case "fmt ":
+			f, err := readWAVFormat(io.LimitReader(r, size), size)
+			if err != nil {
+				return nil, err
+			}
+			format = &f
+
+		case "data":
+			if format == nil {
+				return nil, fmt.Errorf("data chunk before fmt chunk")
+			}
+			return NewRawDecoder(io.LimitReader(r, size), *format), nil
+
+		default:
+			// Chunks are padded to an even size.
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return nil, fmt.Errorf("failed to skip %q chunk: %w", id, err)
+			}
+		}
+	}
+}
+
+func readWAVFormat(r io.Reader, size int64) (Format, error) {
+	var fmtChunk struct {
+		AudioFormat   uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+	}
+	if err := binary.Read(r, binary.LittleEndian, &fmtChunk); err != nil {
+		return Format{}, fmt.Errorf("failed to read fmt chunk: %w", err)
+	}
+	// Skip the extension, including the pad byte.
+	if _, err := io.CopyN(io.Discard, r, size-16+size%2); err != nil && err != io.EOF {
+		return Format{}, fmt.Errorf("failed to skip fmt extension: %w", err)
+	}
+
+	const (
+		wavePCM        = 1
+		waveFloat      = 3
+		waveExtensible = 0xFFFE
+	)
+
+	var enc Encoding
+	switch {
+	case fmtChunk.AudioFormat == waveFloat && fmtChunk.BitsPerSample == 32:
+		enc = F32LE
+	case fmtChunk.AudioFormat == waveFloat && fmtChunk.BitsPerSample == 64:
+		enc = F64LE
+	case fmtChunk.AudioFormat == wavePCM || fmtChunk.AudioFormat == waveExtensible:
+		switch fmtChunk.BitsPerSample {
+		case 8:
+			enc = U8
+		case 16:
+			enc = S16LE
+		case 24:
+			enc = S24LE
+		case 32:
+			enc = S32LE
+		}
+	}
+	if enc == "" {
+		return Format{}, fmt.Errorf(
+			"unsupported WAVE format %d with %d bits per sample",
+			fmtChunk.AudioFormat, fmtChunk.BitsPerSample)
+	}
+
+	return Format{
+		Encoding:   enc,
+		SampleRate: int(fmtChunk.SampleRate),
+		Channels:   int(fmtChunk.Channels),
+	}, nil
+}
+
+type flacDecoder struct {
+	stream *flac.Stream
+	format Format
+	scale  float64
+	// samples is the current FLAC frame's samples per channel, and pos is the
+	// position within them.
+	samples [][]int32
+	pos     int
+}
+
+// NewFLACDecoder creates a decoder for a FLAC stream.
+func NewFLACDecoder(r io.Reader) (Decoder, error) {
+	stream, err := flac.New(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &flacDecoder{
+		stream: stream,
+		format: Format{
+			Encoding:   "flac",
+			SampleRate: int(stream.Info.SampleRate),
+			Channels:   int(stream.Info.NChannels),
+		},
+		scale: float64(int64(1) << (stream.Info.BitsPerSample - 1)),
+	}, nil
+}
+
+func (d *flacDecoder) Format() Format { return d.format }
+
+func (d *flacDecoder) ReadFrame(dst []float64) error {
+	for len(d.samples) == 0 || d.pos >= len(d.samples[0]) {
+		frame, err := d.stream.ParseNext()
+		if err != nil {
+			return err
+		}
+
+		d.samples = d.samples[:0]
+		for _, subframe := range frame.Subframes {
+			d.samples = append(d.samples, subframe.Samples)
+		}
+		d.pos = 0
+	}
+
+	for ch := range dst[:d.format.Channels] {
+		dst[ch] = float64(d.samples[ch][d.pos]) / d.scale
+	}
+	d.pos++
+	return nil
+}
@@ -0,0 +1,217 @@
+package audioin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+)
+
+// testSamples are stereo s16 frames with the left channel counting up and the
+// right channel counting down.
+var testSamples = [][2]int16{
+	{0, 0},
+	{8192, -8192},
+	{16384, -16384},
+	{-32768, 32767},
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Format
+		err  bool
+	}{
+		{"s16le:44100:2", Format{S16LE, 44100, 2}, false},
+		{"F32LE:48000:1", Format{F32LE, 48000, 1}, false},
+		{"s24le", Format{S24LE, 44100, 2}, false},
+		{"u8:8000", Format{U8, 8000, 2}, false},
+		{"s12le:44100:2", Format{}, true},
+		{"s16le:fast:2", Format{}, true},
+		{"s16le:44100:0", Format{}, true},
+		{"s16le:44100:2:1", Format{}, true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseFormat(test.in)
+		if test.err {
+			if err == nil {
+				t.Errorf("ParseFormat(%q) = %v, want error", test.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseFormat(%q) failed: %v", test.in, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseFormat(%q) = %v, want %v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestRawDecoder(t *testing.T) {
+	var buf bytes.Buffer
+	for _, frame := range testSamples {
+		binary.Write(&buf, binary.LittleEndian, frame)
+	}
+
+	dec := NewRawDecoder(&buf, Format{S16LE, 44100, 2})
+	expectSamples(t, dec)
+}
+
+func TestWAVDecoder(t *testing.T) {
+	dec, err := NewWAVDecoder(bytes.NewReader(makeWAV(44100, testSamples)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f := dec.Format(); f != (Format{S16LE, 44100, 2}) {
+		t.Fatalf("unexpected format %v", f)
+	}
+
+	expectSamples(t, dec)
+}
+
+func TestFLACDecoder(t *testing.T) {
+	var buf bytes.Buffer
+	writeFLAC(t, &buf, testSamples)
+
+	dec, err := NewFLACDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f := dec.Format(); f.SampleRate != 44100 || f.Channels != 2 {
+		t.Fatalf("unexpected format %v", f)
+	}
+
+	expectSamples(t, dec)
+}
+
+func TestOpenFile(t *testing.T) {
+	dir := t.TempDir()
+
+	wav := filepath.Join(dir, "test.wav")
+	if err := os.WriteFile(wav, makeWAV(44100, testSamples), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dec, closer, err := OpenFile(wav, DefaultFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+
+	expectSamples(t, dec)
+}
+
+func expectSamples(t *testing.T, dec Decoder) {
+	t.Helper()
+
+	frame := make([]float64, 2)
+	for i, want := range testSamples {
+		if err := dec.ReadFrame(frame); err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		for ch := range frame {
+			if w := float64(want[ch]) / 32768; math.Abs(frame[ch]-w) > 1e-9 {
+				t.Errorf("frame %d channel %d: got %f, want %f", i, ch, frame[ch], w)
+			}
+		}
+	}
+
+	if err := dec.ReadFrame(frame); err != io.EOF {
+		t.Errorf("expected io.EOF after the last frame, got %v", err)
+	}
+}
+
+// makeWAV encodes stereo s16 frames as a WAVE file. An extra chunk is
+// inserted before the data to test that unknown chunks are skipped.
+func makeWAV(rate int, frames [][2]int16) []byte {
+	var data bytes.Buffer
+	for _, frame := range frames {
+		binary.Write(&data, binary.LittleEndian, frame)
+	}
+
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	buf.WriteString("RIFF")
+	binary.Write(&buf, le, uint32(4+(8+16)+(8+4)+(8+data.Len())))
+	buf.WriteString("WAVE")
+
+	buf.WriteString("fmt ")
+	binary.Write(&buf, le, uint32(16))
+	binary.Write(&buf, le, uint16(1)) // PCM
+	binary.Write(&buf, le, uint16(2))
+	binary.Write(&buf, le, uint32(rate))
+	binary.Write(&buf, le, uint32(rate*4))
+	binary.Write(&buf, le, uint16(4))
+	binary.Write(&buf, le, uint16(16))
+
+	buf.WriteString("LIST")
+	binary.Write(&buf, le, uint32(4))
+	buf.WriteString("INFO")
+
+	buf.WriteString("data")
+	binary.Write(&buf, le, uint32(data.Len()))
+	buf.Write(data.Bytes())
+
+	return buf.Bytes()
+}
+
+func writeFLAC(t *testing.T, w io.Writer, frames [][2]int16) {
+	t.Helper()
+
+	info := &meta.StreamInfo{
+		BlockSizeMin:  16,
+		BlockSizeMax:  16,
+		SampleRate:    44100,
+		NChannels:     2,
+		BitsPerSample: 16,
+		NSamples:      uint64(len(frames)),
+	}
+
+	enc, err := flac.NewEncoder(w, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subframes := make([]*frame.Subframe, 2)
+	for ch := range subframes {
+		samples := make([]int32, len(frames))
+		for i, f := range frames {
+			samples[i] = int32(f[ch])
+		}
+		subframes[ch] = &frame.Subframe{
+			SubHeader: frame.SubHeader{Pred: frame.PredVerbatim},
+			Samples:   samples,
+			NSamples:  len(samples),
+		}
+	}
+
+	err = enc.WriteFrame(&frame.Frame{
+		Header: frame.Header{
+			HasFixedBlockSize: true,
+			BlockSize:         uint16(len(frames)),
+			SampleRate:        44100,
+			Channels:          frame.ChannelsLR,
+			BitsPerSample:     16,
+		},
+		Subframes: subframes,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
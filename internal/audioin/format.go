@@ -0,0 +1,95 @@
+// Package audioin implements catnip audio input sessions that read PCM audio
+// from files and pipes instead of a live system audio backend.
+package audioin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Encoding is the encoding of raw PCM samples.
+type Encoding string
+
+const (
+	U8    Encoding = "u8"
+	S16LE Encoding = "s16le"
+	S16BE Encoding = "s16be"
+	S24LE Encoding = "s24le"
+	S32LE Encoding = "s32le"
+	F32LE Encoding = "f32le"
+	F64LE Encoding = "f64le"
+)
+
+// Size returns the size of one sample in bytes, or 0 if the encoding is
+// unknown.
+func (e Encoding) Size() int {
+	switch e {
+	case U8:
+		return 1
+	case S16LE, S16BE:
+		return 2
+	case S24LE:
+		return 3
+	case S32LE, F32LE:
+		return 4
+	case F64LE:
+		return 8
+	default:
+		return 0
+	}
+}
+
+// Format describes raw PCM audio.
+type Format struct {
+	Encoding   Encoding
+	SampleRate int
+	Channels   int
+}
+
+// DefaultFormat is the format of MPD's FIFO output by default, which is
+// "44100:16:2" in MPD's notation.
+var DefaultFormat = Format{
+	Encoding:   S16LE,
+	SampleRate: 44100,
+	Channels:   2,
+}
+
+// ParseFormat parses a format in the "encoding:rate:channels" notation, e.g.
+// "s16le:44100:2". Trailing parts may be omitted, in which case they are taken
+// from DefaultFormat.
+func ParseFormat(s string) (Format, error) {
+	f := DefaultFormat
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return f, fmt.Errorf("invalid format %q, must be encoding:rate:channels", s)
+	}
+
+	f.Encoding = Encoding(strings.ToLower(parts[0]))
+	if f.Encoding.Size() == 0 {
+		return f, fmt.Errorf("unknown encoding %q", parts[0])
+	}
+
+	if len(parts) > 1 {
+		rate, err := strconv.Atoi(parts[1])
+		if err != nil || rate <= 0 {
+			return f, fmt.Errorf("invalid sample rate %q", parts[1])
+		}
+		f.SampleRate = rate
+	}
+
+	if len(parts) > 2 {
+		channels, err := strconv.Atoi(parts[2])
+		if err != nil || channels <= 0 {
+			return f, fmt.Errorf("invalid channel count %q", parts[2])
+		}
+		f.Channels = channels
+	}
+
+	return f, nil
+}
+
+// String returns the format in the notation accepted by ParseFormat.
+func (f Format) String() string {
+	return fmt.Sprintf("%s:%d:%d", f.Encoding, f.SampleRate, f.Channels)
+}
@@ -0,0 +1,396 @@
+package audioin
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/noriah/catnip/input"
+)
+
+// Source opens a decoder for the audio to read.
+type Source struct {
+	// Open opens a new decoder. The returned io.Closer is closed once the
+	// decoder is done or the session is stopped, which must unblock any
+	// pending read.
+	Open func() (Decoder, io.Closer, error)
+	// Live is true if the source produces audio in real time on its own, such
+	// as a pipe. Live sources are not paced, and the session writes silence if
+	// they stall.
+	Live bool
+	// Reopen is true if the source should be opened again once it ends.
+	Reopen bool
+}
+
+// FileSource returns a source that reads the audio file at the given path.
+// Raw PCM files are read in the given format. If loop is true, then the file
+// is played again once it ends.
+func FileSource(path string, raw Format, loop bool) Source {
+	return Source{
+		Open:   func() (Decoder, io.Closer, error) { return OpenFile(path, raw) },
+		Reopen: loop,
+	}
+}
+
+// PipeSource returns a source that reads raw PCM in the given format from
+// the named pipe at the given path, such as MPD's FIFO output. If path is
+// empty, then standard input is read instead.
+//
+// The named pipe is opened for both reading and writing, so that it never
+// reaches the end when its writer goes away. Standard input ends the
+// source when it is closed.
+func PipeSource(path string, format Format) Source {
+	if path == "" {
+		return Source{
+			Open: func() (Decoder, io.Closer, error) {
+				return NewRawDecoder(bufio.NewReader(os.Stdin), format), io.NopCloser(nil), nil
+			},
+			Live: true,
+		}
+	}
+
+	return Source{
+		Open: func() (Decoder, io.Closer, error) {
+			f, err := os.OpenFile(path, os.O_RDWR, 0)
+			if err != nil {
+				return nil, nil, err
+			}
+			return NewRawDecoder(bufio.NewReader(f), format), f, nil
+		},
+		Live: true,
+	}
+}
+
+// Options are options for a session.
+type Options struct {
+	// Pace is the playback speed relative to real time for sources that are
+	// not live. Values above 1 replay the audio faster. If zero, then the
+	// audio is played in real time.
+	Pace float64
+}
+
+// Session is an input.Session that reads audio from a Source. The audio is
+// resampled and remixed to the session's sample rate and channel count.
+//
+// Once the source ends, the session keeps writing silence until it is
+// stopped, so the visualizer behaves as if the music had stopped.
+type Session struct {
+	src  Source
+	opts Options
+	cfg  input.SessionConfig
+}
+
+var _ input.Session = (*Session)(nil)
+
+// NewSession creates a new session.
+func NewSession(src Source, opts Options, cfg input.SessionConfig) *Session {
+	if opts.Pace <= 0 {
+		opts.Pace = 1
+	}
+	return &Session{
+		src:  src,
+		opts: opts,
+		cfg:  cfg,
+	}
+}
+
+// Start implements input.Session.
+func (s *Session) Start(ctx context.Context, dst [][]input.Sample, kickChan chan bool, mu *sync.Mutex) error {
+	if !input.EnsureBufferLen(s.cfg, dst) {
+		return errors.New("invalid dst length given")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	chunks := make(chan [][]input.Sample)
+	free := make(chan [][]input.Sample, 2)
+	for i := 0; i < cap(free); i++ {
+		free <- input.MakeBuffers(s.cfg.FrameSize, s.cfg.SampleSize)
+	}
+
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(chunks)
+		readErr <- s.read(ctx, chunks, free)
+	}()
+
+	chunkDuration := time.Duration(float64(s.cfg.SampleSize) / s.cfg.SampleRate * float64(time.Second))
+	if !s.src.Live {
+		chunkDuration = time.Duration(float64(chunkDuration) / s.opts.Pace)
+	}
+
+	timer := time.NewTimer(chunkDuration)
+	defer timer.Stop()
+
+	next := time.Now()
+	ended := false
+
+	for {
+		var chunk [][]input.Sample
+
+		switch {
+		case ended:
+			// Keep the visualizer fed with silence.
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timer.C:
+			}
+
+		case s.src.Live:
+			// Write silence if the source stalls, e.g. because the music was
+			// paused.
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case chunk = <-chunks:
+			case <-timer.C:
+			}
+
+		default:
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case chunk = <-chunks:
+			}
+
+			// Pace the audio. If we fall behind, then catch up rather than
+			// trying to make up for the lost time.
+			if wait := time.Until(next); wait > 0 {
+				resetTimer(timer, wait)
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-timer.C:
+				}
+			} else {
+				next = time.Now()
+			}
+			next = next.Add(chunkDuration)
+		}
+
+		if chunk == nil && !ended && (!s.src.Live || len(readErr) > 0) {
+			// The reader is done.
+			if err := <-readErr; err != nil {
+				return err
+			}
+			ended = true
+		}
+
+		mu.Lock()
+		if chunk != nil {
+			input.CopyBuffers(dst, chunk)
+		} else {
+			for _, buf := range dst {
+				for i := range buf {
+					buf[i] = 0
+				}
+			}
+		}
+		mu.Unlock()
+
+		if chunk != nil {
+			free <- chunk
+		}
+
+		switch {
+		case ended:
+			resetTimer(timer, chunkDuration)
+		case s.src.Live:
+			resetTimer(timer, chunkDuration*2)
+		}
+
+		// Signal that we've written to dst.
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case kickChan <- true:
+		}
+	}
+}
+
+// read reads chunks from the source until it ends or the context is
+// canceled. It returns nil if the source ended normally.
+func (s *Session) read(ctx context.Context, chunks chan<- [][]input.Sample, free <-chan [][]input.Sample) error {
+	var r *resampler
+
+	for {
+		dec, closer, err := s.src.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open audio: %w", err)
+		}
+
+		// Close the source once the context is canceled to unblock any
+		// pending read.
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				closer.Close()
+			case <-done:
+			}
+		}()
+
+		if r == nil {
+			r = newResampler(dec, s.cfg.FrameSize, s.cfg.SampleRate)
+		} else {
+			r.reset(dec)
+		}
+
+		err = s.readChunks(ctx, r, chunks, free)
+		close(done)
+		closer.Close()
+
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err != io.EOF:
+			return err
+		case !s.src.Reopen:
+			return nil
+		}
+	}
+}
+
+func (s *Session) readChunks(ctx context.Context, r *resampler, chunks chan<- [][]input.Sample, free <-chan [][]input.Sample) error {
+	frame := make([]float64, s.cfg.FrameSize)
+
+	for {
+		var chunk [][]input.Sample
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case chunk = <-free:
+		}
+
+		for i := 0; i < s.cfg.SampleSize; i++ {
+			if err := r.read(frame); err != nil {
+				if errors.Is(err, os.ErrClosed) {
+					return ctx.Err()
+				}
+				return err
+			}
+			for ch, sample := range frame {
+				chunk[ch][i] = sample
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case chunks <- chunk:
+		}
+	}
+}
+
+// resampler converts the decoded audio to a sample rate and channel count
+// using linear interpolation.
+type resampler struct {
+	dec  Decoder
+	rate float64
+	// step is the number of source frames per output frame.
+	step float64
+	// pos is the position between prev and next.
+	pos        float64
+	prev, next []float64
+	raw        []float64
+}
+
+func newResampler(dec Decoder, channels int, sampleRate float64) *resampler {
+	r := &resampler{
+		rate: sampleRate,
+		prev: make([]float64, channels),
+		next: make([]float64, channels),
+		// Start two frames behind, so that the first output frame is the
+		// first source frame.
+		pos: 2,
+	}
+	r.reset(dec)
+	return r
+}
+
+// reset switches to a new decoder, continuing smoothly from the old one.
+func (r *resampler) reset(dec Decoder) {
+	r.dec = dec
+	r.raw = make([]float64, dec.Format().Channels)
+	r.step = float64(dec.Format().SampleRate) / r.rate
+}
+
+// Resample returns a decoder that converts the audio of dec to the given
+// sample rate and number of channels. Its samples are always decoded as
+// F64LE.
+func Resample(dec Decoder, sampleRate float64, channels int) Decoder {
+	return newResampler(dec, channels, sampleRate)
+}
+
+// Format implements Decoder.
+func (r *resampler) Format() Format {
+	return Format{
+		Encoding:   F64LE,
+		SampleRate: int(r.rate),
+		Channels:   len(r.prev),
+	}
+}
+
+// ReadFrame implements Decoder.
+func (r *resampler) ReadFrame(dst []float64) error { return r.read(dst) }
+
+// read reads the next output frame into dst.
+func (r *resampler) read(dst []float64) error {
+	for r.pos >= 1 {
+		r.prev, r.next = r.next, r.prev
+		if err := r.readMixed(r.next); err != nil {
+			return err
+		}
+		r.pos--
+	}
+
+	for ch := range dst {
+		dst[ch] = r.prev[ch] + (r.next[ch]-r.prev[ch])*r.pos
+	}
+
+	r.pos += r.step
+	return nil
+}
+
+// readMixed reads a source frame and mixes it to the output channel count.
+func (r *resampler) readMixed(dst []float64) error {
+	if err := r.dec.ReadFrame(r.raw); err != nil {
+		return err
+	}
+
+	switch {
+	case len(dst) == len(r.raw):
+		copy(dst, r.raw)
+	case len(dst) == 1:
+		// Downmix to mono.
+		var sum float64
+		for _, sample := range r.raw {
+			sum += sample
+		}
+		dst[0] = sum / float64(len(r.raw))
+	default:
+		for ch := range dst {
+			dst[ch] = r.raw[ch%len(r.raw)]
+		}
+	}
+
+	return nil
+}
+
+// resetTimer resets the timer, draining it if it fired in the meantime.
+func resetTimer(t *time.Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	t.Reset(d)
+}
@@ -0,0 +1,97 @@
+package audioin
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/noriah/catnip/input"
+)
+
+func TestSession(t *testing.T) {
+	// One second of a 441 Hz tone at 22050 Hz that is only in the left
+	// channel. The session resamples it to 44100 Hz and downmixes it to mono.
+	const srcRate = 22050
+	frames := make([][2]int16, srcRate)
+	for i := range frames {
+		frames[i][0] = int16(16384 * math.Sin(2*math.Pi*441*float64(i)/srcRate))
+	}
+
+	path := filepath.Join(t.TempDir(), "tone.wav")
+	if err := os.WriteFile(path, makeWAV(srcRate, frames), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := input.SessionConfig{
+		FrameSize:  1,
+		SampleSize: 1024,
+		SampleRate: 44100,
+	}
+
+	// Replay the track at 50 times the speed, so it takes 20ms.
+	session := NewSession(FileSource(path, DefaultFormat, false), Options{Pace: 50}, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	buffers := input.MakeBuffers(cfg.FrameSize, cfg.SampleSize)
+	kickChan := make(chan bool)
+	mu := &sync.Mutex{}
+
+	sessionErr := make(chan error, 1)
+	go func() { sessionErr <- session.Start(ctx, buffers, kickChan, mu) }()
+
+	start := time.Now()
+
+	// The first buffer is the start of the tone.
+	<-kickChan
+	mu.Lock()
+	for i, sample := range buffers[0][:100] {
+		want := 0.25 * math.Sin(2*math.Pi*441*float64(i)/44100)
+		if math.Abs(sample-want) > 0.001 {
+			t.Errorf("sample %d: got %f, want %f", i, sample, want)
+		}
+	}
+	mu.Unlock()
+
+	// The rest of the track takes 43 buffers, after which the session writes
+	// silence.
+	for i := 0; ; i++ {
+		<-kickChan
+		if i < 42 {
+			continue
+		}
+
+		mu.Lock()
+		silent := rms(buffers[0]) == 0
+		mu.Unlock()
+
+		if silent {
+			break
+		}
+		if i > 50 {
+			t.Fatal("session did not end")
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("replay took %v, expected the session to be paced", elapsed)
+	}
+
+	cancel()
+	if err := <-sessionErr; err != context.Canceled {
+		t.Errorf("unexpected session error: %v", err)
+	}
+}
+
+func rms(samples []float64) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += s * s
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
@@ -0,0 +1,100 @@
+// Package clock provides an injectable clock so that time-dependent code can
+// be tested deterministically.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the interface for types that tell the time.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After returns a channel that receives the current time once the given
+	// duration has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// Real is the Clock that uses the system time.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Manual is a Clock that only moves when told to. It is safe for concurrent
+// use.
+type Manual struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []manualWaiter
+}
+
+type manualWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+var _ Clock = (*Manual)(nil)
+
+// NewManual creates a new Manual clock starting at the given time.
+func NewManual(now time.Time) *Manual {
+	return &Manual{now: now}
+}
+
+// Now implements Clock.
+func (c *Manual) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After implements Clock.
+func (c *Manual) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+
+	c.waiters = append(c.waiters, manualWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by the given duration, firing all channels
+// returned by After that are due.
+func (c *Manual) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set sets the clock to the given time, firing all channels returned by After
+// that are due.
+func (c *Manual) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = now
+
+	waiters := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(now) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.ch <- now
+	}
+	c.waiters = waiters
+}
+
+// Waiters returns the number of pending After channels. It is useful for
+// waiting until a goroutine has started waiting on the clock.
+func (c *Manual) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
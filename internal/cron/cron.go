@@ -0,0 +1,279 @@
+// Package cron implements parsing and evaluation of cron-like schedules.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron schedule with minute resolution.
+type Schedule struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	// domAny and dowAny are true if the day-of-month or day-of-week fields
+	// are wildcards. Following cron semantics, if both are restricted, then a
+	// day matches if either matches.
+	domAny bool
+	dowAny bool
+}
+
+// searchLimit is how far Next and Prev search before giving up. Schedules such
+// as "0 0 30 2 *" never match.
+const searchLimit = 5 * 366 * 24 * time.Hour
+
+var aliases = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = []string{
+	"jan", "feb", "mar", "apr", "may", "jun",
+	"jul", "aug", "sep", "oct", "nov", "dec",
+}
+
+var dowNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+type field struct {
+	name     string
+	min, max int
+	names    []string
+	namesMin int
+}
+
+var fields = [5]field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: monthNames, namesMin: 1},
+	// 7 is also Sunday.
+	{name: "day of week", min: 0, max: 7, names: dowNames, namesMin: 0},
+}
+
+// Parse parses a standard 5-field cron expression ("minute hour dom month
+// dow"). Each field may be a wildcard, a number, a range, a list or a step,
+// e.g. "*/15", "1-5" or "mon,wed,fri". The aliases @hourly, @daily, @midnight,
+// @weekly, @monthly, @yearly and @annually are also accepted.
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if alias, ok := aliases[strings.ToLower(expr)]; ok {
+		expr = alias
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("cron expression %q must have %d fields", expr, len(fields))
+	}
+
+	var bits [5]uint64
+	for i, part := range parts {
+		b, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s field %q: %w", fields[i].name, part, err)
+		}
+		bits[i] = b
+	}
+
+	// Fold Sunday (7) into 0.
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = (bits[4] | 1) &^ (1 << 7)
+	}
+
+	return &Schedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: parts[2] == "*",
+		dowAny: parts[4] == "*",
+	}, nil
+}
+
+// Daily returns a schedule that fires every day at the given hour and minute.
+func Daily(hour, minute int) *Schedule {
+	return &Schedule{
+		minute: 1 << minute,
+		hour:   1 << hour,
+		dom:    rangeBits(1, 31, 1),
+		month:  rangeBits(1, 12, 1),
+		dow:    rangeBits(0, 6, 1),
+		domAny: true,
+		dowAny: true,
+	}
+}
+
+func parseField(s string, f field) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(s, ",") {
+		b, err := parseItem(item, f)
+		if err != nil {
+			return 0, err
+		}
+		bits |= b
+	}
+	return bits, nil
+}
+
+func parseItem(s string, f field) (uint64, error) {
+	rangePart, stepPart, hasStep := strings.Cut(s, "/")
+
+	step := 1
+	if hasStep {
+		n, err := strconv.Atoi(stepPart)
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid step %q", stepPart)
+		}
+		step = n
+	}
+
+	lo, hi := f.min, f.max
+	switch {
+	case rangePart == "*":
+	case strings.Contains(rangePart, "-"):
+		loPart, hiPart, _ := strings.Cut(rangePart, "-")
+		var err error
+		if lo, err = parseValue(loPart, f); err != nil {
+			return 0, err
+		}
+		if hi, err = parseValue(hiPart, f); err != nil {
+			return 0, err
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("range %d-%d is backwards", lo, hi)
+		}
+	default:
+		v, err := parseValue(rangePart, f)
+		if err != nil {
+			return 0, err
+		}
+		lo = v
+		if hasStep {
+			// "5/15" means "5-max/15".
+			hi = f.max
+		} else {
+			hi = v
+		}
+	}
+
+	return rangeBits(lo, hi, step), nil
+}
+
+func parseValue(s string, f field) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return i + f.namesMin, nil
+		}
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+func rangeBits(lo, hi, step int) uint64 {
+	var bits uint64
+	for i := lo; i <= hi; i += step {
+		bits |= 1 << i
+	}
+	return bits
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<v) != 0
+}
+
+func (s *Schedule) matchesDay(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// Matches returns true if the schedule fires at the minute of the given time.
+func (s *Schedule) Matches(t time.Time) bool {
+	return has(s.month, int(t.Month())) &&
+		s.matchesDay(t) &&
+		has(s.hour, t.Hour()) &&
+		has(s.minute, t.Minute())
+}
+
+// Next returns the first time strictly after t that the schedule fires at. It
+// returns the zero time if the schedule never fires.
+func (s *Schedule) Next(t time.Time) time.Time {
+	limit := t.Add(searchLimit)
+	t = truncateMinute(t).Add(time.Minute)
+
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		switch {
+		case !has(s.month, int(m)):
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+		case !has(s.hour, t.Hour()):
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, t.Location())
+		case !has(s.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// Prev returns the last time at or before t that the schedule fired at. It
+// returns the zero time if the schedule never fires.
+func (s *Schedule) Prev(t time.Time) time.Time {
+	limit := t.Add(-searchLimit)
+	t = truncateMinute(t)
+
+	for t.After(limit) {
+		y, m, d := t.Date()
+		switch {
+		case !has(s.month, int(m)):
+			t = time.Date(y, m, 1, 0, 0, 0, 0, t.Location()).Add(-time.Minute)
+		case !s.matchesDay(t):
+			t = time.Date(y, m, d, 0, 0, 0, 0, t.Location()).Add(-time.Minute)
+		case !has(s.hour, t.Hour()):
+			t = time.Date(y, m, d, t.Hour(), 0, 0, 0, t.Location()).Add(-time.Minute)
+		case !has(s.minute, t.Minute()):
+			t = t.Add(-time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// truncateMinute truncates t to the minute in its own location. Unlike
+// t.Truncate, it is correct for locations with non-whole-minute offsets.
+func truncateMinute(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, t.Location())
+}
@@ -0,0 +1,62 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	at := func(s string) time.Time {
+		t, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+
+	tests := []struct {
+		expr string
+		now  string
+		next string
+		prev string
+	}{
+		{"30 21 * * *", "2023-06-01 12:00", "2023-06-01 21:30", "2023-05-31 21:30"},
+		{"30 21 * * *", "2023-06-01 21:30", "2023-06-02 21:30", "2023-06-01 21:30"},
+		{"*/15 * * * *", "2023-06-01 12:07", "2023-06-01 12:15", "2023-06-01 12:00"},
+		{"0 9 * * mon-fri", "2023-06-02 10:00", "2023-06-05 09:00", "2023-06-02 09:00"},
+		{"0 0 1 jan *", "2023-06-01 00:00", "2024-01-01 00:00", "2023-01-01 00:00"},
+		{"0 0 * * 7", "2023-06-01 00:00", "2023-06-04 00:00", "2023-05-28 00:00"},
+		{"@hourly", "2023-06-01 12:07", "2023-06-01 13:00", "2023-06-01 12:00"},
+	}
+
+	for _, test := range tests {
+		s, err := Parse(test.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.expr, err)
+			continue
+		}
+
+		now := at(test.now)
+		if next := s.Next(now); !next.Equal(at(test.next)) {
+			t.Errorf("%q.Next(%s) = %s, want %s", test.expr, test.now, next, test.next)
+		}
+		if prev := s.Prev(now); !prev.Equal(at(test.prev)) {
+			t.Errorf("%q.Prev(%s) = %s, want %s", test.expr, test.now, prev, test.prev)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 5-1 * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", expr)
+		}
+	}
+}
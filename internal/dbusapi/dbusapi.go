@@ -0,0 +1,367 @@
+// Package dbusapi exposes the daemon as a service on D-Bus, so that it can be
+// controlled from desktop shells and scripts, such as with busctl:
+//
+//	busctl --user call so.libdb.Catglow /so/libdb/Catglow so.libdb.Catglow1 SetScene s music
+//	busctl --user get-property so.libdb.Catglow /so/libdb/Catglow so.libdb.Catglow1 Brightness
+package dbusapi
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/pkg/errors"
+)
+
+// Names of the service.
+const (
+	// DefaultName is the well-known name that the service owns by default.
+	DefaultName = "so.libdb.Catglow"
+	// Path is the path of the object that the service exports.
+	Path dbus.ObjectPath = "/so/libdb/Catglow"
+	// Interface is the interface of the object.
+	Interface = "so.libdb.Catglow1"
+)
+
+// Controller is what the service controls.
+type Controller interface {
+	// Scene returns the name of the current scene.
+	Scene() string
+	// Scenes returns the names of all scenes.
+	Scenes() []string
+	// SetScene switches to the scene with the given name.
+	SetScene(name string) error
+
+	// On returns true if the strip is on.
+	On() bool
+	// SetOn turns the strip on or off.
+	SetOn(on bool, transition time.Duration)
+	// Brightness returns the brightness of the strip within [0, 1].
+	Brightness() float64
+	// SetBrightness sets the brightness of the strip within [0, 1].
+	SetBrightness(brightness float64, transition time.Duration)
+
+	// Segments returns the segments of the strip.
+	Segments() []Segment
+	// UpdateSegment changes the segment with the given name.
+	UpdateSegment(name string, update func(*Segment)) error
+	// Effects returns the effects that segments can show.
+	Effects() []string
+
+	// Notify flashes the strip over whatever it shows.
+	Notify(n Notification) error
+
+	// Linked returns true if the daemon is talking to the LED controller.
+	Linked() bool
+
+	// Watch returns a channel that is signaled whenever the state changes,
+	// until the context is canceled.
+	Watch(ctx context.Context) <-chan struct{}
+	// WatchEvents returns a channel that receives the events of the LED
+	// controller until the context is canceled.
+	WatchEvents(ctx context.Context) <-chan Event
+}
+
+// Segment is a segment of the strip. It is the D-Bus struct (suubdsa(yyy)).
+type Segment struct {
+	Name string
+	// Start and End are the range of LEDs of the segment, excluding End.
+	Start, End uint32
+	On         bool
+	Brightness float64
+	Effect     string
+	Colors     []Color
+}
+
+// Color is an RGB color. It is the D-Bus struct (yyy).
+type Color struct {
+	R, G, B uint8
+}
+
+// Notification flashes the strip over whatever it shows.
+type Notification struct {
+	Color Color
+	// Count is how many times to flash, or zero for the default.
+	Count int
+	// Duration is how long each flash takes, or zero for the default.
+	Duration time.Duration
+	// Priority decides which notification is shown first.
+	Priority int
+}
+
+// EventKind is the kind of an Event.
+type EventKind string
+
+const (
+	// LinkUp is emitted as the LinkUp signal.
+	LinkUp EventKind = "link-up"
+	// LinkDown is emitted as the LinkDown signal with the reason.
+	LinkDown EventKind = "link-down"
+	// ControllerError is emitted as the ControllerError signal with the
+	// message.
+	ControllerError EventKind = "error"
+)
+
+// Event is an event of the LED controller, which is emitted as a signal.
+type Event struct {
+	Kind    EventKind
+	Message string
+}
+
+// Serve exports the service on the connection and owns the given name, then
+// keeps the properties up to date and emits the events as signals until the
+// context is canceled. The connection is not closed.
+func Serve(ctx context.Context, conn *dbus.Conn, name string, ctrl Controller) error {
+	if name == "" {
+		name = DefaultName
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Watch before exporting, so that no change is missed.
+	changes := ctrl.Watch(ctx)
+	events := ctrl.WatchEvents(ctx)
+
+	s := &service{ctrl: ctrl}
+	if err := conn.Export(s, Path, Interface); err != nil {
+		return errors.Wrap(err, "failed to export the service")
+	}
+	defer conn.Export(nil, Path, Interface)
+
+	props, err := prop.Export(conn, Path, prop.Map{Interface: s.properties()})
+	if err != nil {
+		return errors.Wrap(err, "failed to export the properties")
+	}
+	defer conn.Export(nil, Path, "org.freedesktop.DBus.Properties")
+
+	node := introspect.Node{
+		Name: string(Path),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       Interface,
+				Methods:    methods,
+				Signals:    signals,
+				Properties: props.Introspection(Interface),
+			},
+		},
+	}
+	if err := conn.Export(introspect.NewIntrospectable(&node), Path, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return errors.Wrap(err, "failed to export the introspection data")
+	}
+	defer conn.Export(nil, Path, "org.freedesktop.DBus.Introspectable")
+
+	reply, err := conn.RequestName(name, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return errors.Wrapf(err, "failed to request name %q", name)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return errors.Errorf("name %q is already taken", name)
+	}
+	defer conn.ReleaseName(name)
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Emit the events that came in while stopping, such as the link
+			// going down.
+			for {
+				select {
+				case ev := <-events:
+					s.emit(conn, ev)
+				default:
+					return ctx.Err()
+				}
+			}
+		case <-changes:
+			s.update(props)
+		case ev := <-events:
+			if err := s.emit(conn, ev); err != nil {
+				return errors.Wrap(err, "failed to emit signal")
+			}
+		}
+	}
+}
+
+// methods describes the methods for introspection, since their arguments
+// cannot be named through reflection.
+var methods = []introspect.Method{
+	{Name: "SetScene", Args: []introspect.Arg{
+		{Name: "name", Type: "s", Direction: "in"},
+	}},
+	{Name: "SetOn", Args: []introspect.Arg{
+		{Name: "on", Type: "b", Direction: "in"},
+		{Name: "transition_ms", Type: "u", Direction: "in"},
+	}},
+	{Name: "SetBrightness", Args: []introspect.Arg{
+		{Name: "brightness", Type: "d", Direction: "in"},
+		{Name: "transition_ms", Type: "u", Direction: "in"},
+	}},
+	{Name: "SetColor", Args: []introspect.Arg{
+		{Name: "segment", Type: "s", Direction: "in"},
+		{Name: "color", Type: "(yyy)", Direction: "in"},
+	}},
+	{Name: "SetEffect", Args: []introspect.Arg{
+		{Name: "segment", Type: "s", Direction: "in"},
+		{Name: "effect", Type: "s", Direction: "in"},
+	}},
+	{Name: "Notify", Args: []introspect.Arg{
+		{Name: "color", Type: "(yyy)", Direction: "in"},
+		{Name: "count", Type: "u", Direction: "in"},
+		{Name: "duration_ms", Type: "u", Direction: "in"},
+		{Name: "priority", Type: "i", Direction: "in"},
+	}},
+}
+
+var signals = []introspect.Signal{
+	{Name: "LinkUp"},
+	{Name: "LinkDown", Args: []introspect.Arg{
+		{Name: "reason", Type: "s"},
+	}},
+	{Name: "ControllerError", Args: []introspect.Arg{
+		{Name: "message", Type: "s"},
+	}},
+}
+
+// service is the exported object. Its exported methods are the D-Bus methods.
+type service struct {
+	ctrl Controller
+}
+
+// errInvalidArgs is the error returned for arguments that the controller
+// rejects, such as unknown scenes.
+const errInvalidArgs = "org.freedesktop.DBus.Error.InvalidArgs"
+
+func invalidArgs(err error) *dbus.Error {
+	return dbus.NewError(errInvalidArgs, []interface{}{err.Error()})
+}
+
+func (s *service) SetScene(name string) *dbus.Error {
+	if err := s.ctrl.SetScene(name); err != nil {
+		return invalidArgs(err)
+	}
+	return nil
+}
+
+func (s *service) SetOn(on bool, transitionMs uint32) *dbus.Error {
+	s.ctrl.SetOn(on, time.Duration(transitionMs)*time.Millisecond)
+	return nil
+}
+
+func (s *service) SetBrightness(brightness float64, transitionMs uint32) *dbus.Error {
+	if brightness < 0 || brightness > 1 {
+		return invalidArgs(errors.Errorf("brightness %v is not within [0, 1]", brightness))
+	}
+	s.ctrl.SetBrightness(brightness, time.Duration(transitionMs)*time.Millisecond)
+	return nil
+}
+
+// SetColor sets the first color of the segment.
+func (s *service) SetColor(segment string, color Color) *dbus.Error {
+	err := s.ctrl.UpdateSegment(segment, func(seg *Segment) {
+		if len(seg.Colors) == 0 {
+			seg.Colors = []Color{color}
+		} else {
+			seg.Colors[0] = color
+		}
+	})
+	if err != nil {
+		return invalidArgs(err)
+	}
+	return nil
+}
+
+func (s *service) SetEffect(segment, effect string) *dbus.Error {
+	err := s.ctrl.UpdateSegment(segment, func(seg *Segment) {
+		seg.Effect = effect
+	})
+	if err != nil {
+		return invalidArgs(err)
+	}
+	return nil
+}
+
+// Notify flashes the strip. A count or duration of zero means the default.
+func (s *service) Notify(color Color, count, durationMs uint32, priority int32) *dbus.Error {
+	err := s.ctrl.Notify(Notification{
+		Color:    color,
+		Count:    int(count),
+		Duration: time.Duration(durationMs) * time.Millisecond,
+		Priority: int(priority),
+	})
+	if err != nil {
+		return invalidArgs(err)
+	}
+	return nil
+}
+
+// state returns the values of the properties that change.
+func (s *service) state() map[string]interface{} {
+	segments := s.ctrl.Segments()
+	for i := range segments {
+		// Empty arrays are fine on D-Bus, but keep nil and empty colors
+		// comparable.
+		if segments[i].Colors == nil {
+			segments[i].Colors = []Color{}
+		}
+	}
+	return map[string]interface{}{
+		"Scene":      s.ctrl.Scene(),
+		"On":         s.ctrl.On(),
+		"Brightness": s.ctrl.Brightness(),
+		"Segments":   segments,
+		"Linked":     s.ctrl.Linked(),
+	}
+}
+
+func (s *service) properties() map[string]*prop.Prop {
+	props := map[string]*prop.Prop{
+		"Scenes":  {Value: s.ctrl.Scenes(), Emit: prop.EmitConst},
+		"Effects": {Value: s.ctrl.Effects(), Emit: prop.EmitConst},
+	}
+	for name, value := range s.state() {
+		props[name] = &prop.Prop{Value: value, Emit: prop.EmitTrue}
+	}
+	return props
+}
+
+// update sets the properties that changed, which emits PropertiesChanged.
+func (s *service) update(props *prop.Properties) {
+	for name, value := range s.state() {
+		if !reflect.DeepEqual(props.GetMust(Interface, name), value) {
+			props.SetMust(Interface, name, value)
+		}
+	}
+}
+
+func (s *service) emit(conn *dbus.Conn, ev Event) error {
+	switch ev.Kind {
+	case LinkUp:
+		return conn.Emit(Path, Interface+".LinkUp")
+	case LinkDown:
+		return conn.Emit(Path, Interface+".LinkDown", ev.Message)
+	case ControllerError:
+		return conn.Emit(Path, Interface+".ControllerError", ev.Message)
+	default:
+		return nil
+	}
+}
+
+// Notify calls Notify on the service that owns the given name, or
+// DefaultName if empty.
+func Notify(ctx context.Context, conn *dbus.Conn, name string, n Notification) error {
+	if name == "" {
+		name = DefaultName
+	}
+	return conn.Object(name, Path).CallWithContext(ctx, Interface+".Notify", 0,
+		n.Color,
+		uint32(n.Count),
+		uint32(n.Duration/time.Millisecond),
+		int32(n.Priority),
+	).Err
+}
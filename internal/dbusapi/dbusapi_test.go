@@ -0,0 +1,370 @@
+package dbusapi
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+type fakeController struct {
+	mu         sync.Mutex
+	scene      string
+	on         bool
+	brightness float64
+	transition time.Duration
+	segments   []Segment
+	linked     bool
+	notified   []Notification
+	changed    chan struct{}
+	events     chan Event
+}
+
+func newFakeController() *fakeController {
+	return &fakeController{
+		scene:      "music",
+		on:         true,
+		brightness: 1,
+		segments: []Segment{
+			{Name: "desk", Start: 0, End: 4, On: true, Brightness: 1, Effect: "scene"},
+		},
+		changed: make(chan struct{}, 1),
+		events:  make(chan Event, 4),
+	}
+}
+
+func (c *fakeController) Scenes() []string    { return []string{"music", "off"} }
+func (c *fakeController) Effects() []string   { return []string{"solid", "scene"} }
+func (c *fakeController) Scene() string       { c.mu.Lock(); defer c.mu.Unlock(); return c.scene }
+func (c *fakeController) On() bool            { c.mu.Lock(); defer c.mu.Unlock(); return c.on }
+func (c *fakeController) Brightness() float64 { c.mu.Lock(); defer c.mu.Unlock(); return c.brightness }
+func (c *fakeController) Linked() bool        { c.mu.Lock(); defer c.mu.Unlock(); return c.linked }
+
+func (c *fakeController) SetScene(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if name != "music" && name != "off" {
+		return fmt.Errorf("unknown scene %q", name)
+	}
+	c.scene = name
+	c.notify()
+	return nil
+}
+
+func (c *fakeController) SetOn(on bool, transition time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.on = on
+	c.transition = transition
+	c.notify()
+}
+
+func (c *fakeController) SetBrightness(brightness float64, transition time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.brightness = brightness
+	c.transition = transition
+	c.notify()
+}
+
+func (c *fakeController) Segments() []Segment {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	segments := make([]Segment, len(c.segments))
+	for i, segment := range c.segments {
+		segments[i] = segment
+		segments[i].Colors = append([]Color(nil), segment.Colors...)
+	}
+	return segments
+}
+
+func (c *fakeController) UpdateSegment(name string, update func(*Segment)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.segments {
+		if c.segments[i].Name == name {
+			update(&c.segments[i])
+			c.notify()
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown segment %q", name)
+}
+
+func (c *fakeController) Notify(n Notification) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n.Count > 10 {
+		return fmt.Errorf("too many flashes")
+	}
+	c.notified = append(c.notified, n)
+	return nil
+}
+
+func (c *fakeController) Watch(ctx context.Context) <-chan struct{} { return c.changed }
+
+func (c *fakeController) WatchEvents(ctx context.Context) <-chan Event { return c.events }
+
+func (c *fakeController) send(ev Event) {
+	c.mu.Lock()
+	switch ev.Kind {
+	case LinkUp:
+		c.linked = true
+	case LinkDown:
+		c.linked = false
+	}
+	c.notify()
+	c.mu.Unlock()
+
+	c.events <- ev
+}
+
+func (c *fakeController) notify() {
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+// startBus starts a private session bus for the test and returns its
+// address. The test is skipped if dbus-daemon is not installed.
+func startBus(t *testing.T) string {
+	t.Helper()
+
+	path, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+
+	cmd := exec.Command(path, "--session", "--nofork", "--nopidfile", "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatal("failed to read the bus address:", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+func connect(t *testing.T, address string) *dbus.Conn {
+	t.Helper()
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestService(t *testing.T) {
+	address := startBus(t)
+	ctrl := newFakeController()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	conn := connect(t, address)
+	served := make(chan error, 1)
+	go func() { served <- Serve(ctx, conn, "", ctrl) }()
+
+	client := connect(t, address)
+	if err := client.AddMatchSignal(dbus.WithMatchObjectPath(Path)); err != nil {
+		t.Fatal(err)
+	}
+	signals := make(chan *dbus.Signal, 16)
+	client.Signal(signals)
+
+	obj := client.Object(DefaultName, Path)
+
+	// Wait for the service to own its name.
+	for i := 0; ; i++ {
+		var owned bool
+		err := client.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, DefaultName).Store(&owned)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if owned {
+			break
+		}
+		if i == 100 {
+			t.Fatal("the service did not own its name")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	waitSignal := func(name string) *dbus.Signal {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case sig := <-signals:
+				if sig.Name == name {
+					return sig
+				}
+			case <-timeout:
+				t.Fatalf("no %s signal", name)
+				return nil
+			}
+		}
+	}
+
+	t.Run("methods", func(t *testing.T) {
+		if call := obj.Call(Interface+".SetScene", 0, "off"); call.Err != nil {
+			t.Fatal(call.Err)
+		}
+		if ctrl.Scene() != "off" {
+			t.Errorf("got scene %q, want off", ctrl.Scene())
+		}
+
+		call := obj.Call(Interface+".SetScene", 0, "nope")
+		if err, ok := call.Err.(dbus.Error); !ok || err.Name != errInvalidArgs {
+			t.Errorf("got error %v for an unknown scene, want %s", call.Err, errInvalidArgs)
+		}
+
+		if call := obj.Call(Interface+".SetBrightness", 0, 0.25, uint32(500)); call.Err != nil {
+			t.Fatal(call.Err)
+		}
+		ctrl.mu.Lock()
+		brightness, transition := ctrl.brightness, ctrl.transition
+		ctrl.mu.Unlock()
+		if brightness != 0.25 || transition != 500*time.Millisecond {
+			t.Errorf("got brightness %v over %v", brightness, transition)
+		}
+		if call := obj.Call(Interface+".SetBrightness", 0, 2.0, uint32(0)); call.Err == nil {
+			t.Error("expected an error for a brightness above 1")
+		}
+
+		if call := obj.Call(Interface+".SetOn", 0, false, uint32(0)); call.Err != nil {
+			t.Fatal(call.Err)
+		}
+		if ctrl.On() {
+			t.Error("the strip is still on")
+		}
+
+		red := Color{255, 0, 0}
+		if call := obj.Call(Interface+".SetColor", 0, "desk", red); call.Err != nil {
+			t.Fatal(call.Err)
+		}
+		if call := obj.Call(Interface+".SetEffect", 0, "desk", "solid"); call.Err != nil {
+			t.Fatal(call.Err)
+		}
+		if segment := ctrl.Segments()[0]; len(segment.Colors) != 1 || segment.Colors[0] != red || segment.Effect != "solid" {
+			t.Errorf("got segment %+v, want a solid red one", segment)
+		}
+		if call := obj.Call(Interface+".SetColor", 0, "nope", red); call.Err == nil {
+			t.Error("expected an error for an unknown segment")
+		}
+
+		n := Notification{Color: red, Count: 3, Duration: 250 * time.Millisecond, Priority: -1}
+		if err := Notify(ctx, client, "", n); err != nil {
+			t.Fatal(err)
+		}
+		ctrl.mu.Lock()
+		notified := ctrl.notified
+		ctrl.mu.Unlock()
+		if len(notified) != 1 || notified[0] != n {
+			t.Errorf("got notifications %+v, want %+v", notified, n)
+		}
+		if err := Notify(ctx, client, DefaultName, Notification{Count: 11}); err == nil {
+			t.Error("expected an error for too many flashes")
+		}
+	})
+
+	t.Run("properties", func(t *testing.T) {
+		ctrl.SetScene("music")
+		for {
+			sig := waitSignal("org.freedesktop.DBus.Properties.PropertiesChanged")
+			changed := sig.Body[1].(map[string]dbus.Variant)
+			if scene, ok := changed["Scene"]; ok && scene.Value() == "music" {
+				break
+			}
+		}
+
+		scenes, err := obj.GetProperty(Interface + ".Scenes")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := scenes.Value().([]string); len(got) != 2 || got[1] != "off" {
+			t.Errorf("got scenes %v", got)
+		}
+
+		segments, err := obj.GetProperty(Interface + ".Segments")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sig := segments.Signature().String(); sig != "a(suubdsa(yyy))" {
+			t.Errorf("got segments of type %s", sig)
+		}
+	})
+
+	t.Run("signals", func(t *testing.T) {
+		ctrl.send(Event{Kind: LinkUp})
+		waitSignal(Interface + ".LinkUp")
+
+		linked, err := obj.GetProperty(Interface + ".Linked")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if linked.Value() != true {
+			t.Error("the controller is not linked")
+		}
+
+		ctrl.send(Event{Kind: ControllerError, Message: "out of memory"})
+		if sig := waitSignal(Interface + ".ControllerError"); sig.Body[0] != "out of memory" {
+			t.Errorf("got error message %v", sig.Body)
+		}
+
+		ctrl.send(Event{Kind: LinkDown, Message: "controller reported error"})
+		if sig := waitSignal(Interface + ".LinkDown"); sig.Body[0] != "controller reported error" {
+			t.Errorf("got reason %v", sig.Body)
+		}
+	})
+
+	t.Run("introspection", func(t *testing.T) {
+		var xml string
+		if err := obj.Call("org.freedesktop.DBus.Introspectable.Introspect", 0).Store(&xml); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{`name="SetColor"`, `name="LinkDown"`, `name="Brightness"`} {
+			if !strings.Contains(xml, want) {
+				t.Errorf("introspection data is missing %s", want)
+			}
+		}
+	})
+
+	cancel()
+	if err := <-served; err != context.Canceled {
+		t.Errorf("Serve returned %v, want context.Canceled", err)
+	}
+}
+
+func TestNameTaken(t *testing.T) {
+	address := startBus(t)
+
+	taken := connect(t, address)
+	if _, err := taken.RequestName(DefaultName, dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Serve(context.Background(), connect(t, address), DefaultName, newFakeController())
+	if err == nil || !strings.Contains(err.Error(), "already taken") {
+		t.Errorf("got error %v, want the name to be taken", err)
+	}
+}
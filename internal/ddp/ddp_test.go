@@ -0,0 +1,212 @@
+package ddp
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// Packets as sent by xLights, split into two packets per frame with the
+// second one pushing the frame.
+var (
+	firstHalf = []byte{
+		0x40, 0x01, 0x0b, 0x01, // version 1, sequence 1, RGB8, display
+		0x00, 0x00, 0x00, 0x00, // offset 0
+		0x00, 0x06, // 6 bytes
+		0xff, 0x00, 0x00,
+		0x00, 0xff, 0x00,
+	}
+	secondHalf = []byte{
+		0x41, 0x02, 0x0b, 0x01, // version 1, push, sequence 2, RGB8, display
+		0x00, 0x00, 0x00, 0x06, // offset 6
+		0x00, 0x06, // 6 bytes
+		0x00, 0x00, 0xff,
+		0x10, 0x20, 0x30,
+	}
+)
+
+func TestParsePacket(t *testing.T) {
+	p, err := ParsePacket(secondHalf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Packet{
+		Flags:    Version1 | Push,
+		Sequence: 2,
+		DataType: RGB8,
+		ID:       IDDisplay,
+		Offset:   6,
+		Data:     []byte{0x00, 0x00, 0xff, 0x10, 0x20, 0x30},
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("got %+v, want %+v", p, want)
+	}
+
+	b, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, secondHalf) {
+		t.Errorf("marshaled to %x, want %x", b, secondHalf)
+	}
+
+	// The timecode extends the header.
+	timecoded := Packet{Flags: Timecode, ID: IDAll, Timecode: 0x01020304, Data: []byte{1, 2, 3}}
+	b, err = timecoded.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != timecodeHeaderSize+3 {
+		t.Errorf("got %d bytes, want %d", len(b), timecodeHeaderSize+3)
+	}
+	p, err = ParsePacket(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timecoded.Flags |= Version1
+	if !reflect.DeepEqual(p, timecoded) {
+		t.Errorf("got %+v, want %+v", p, timecoded)
+	}
+
+	for _, b := range [][]byte{
+		secondHalf[:9],
+		secondHalf[:14],                         // data shorter than the length
+		append([]byte{0x81}, secondHalf[1:]...), // version 2
+	} {
+		if _, err := ParsePacket(b); err == nil {
+			t.Errorf("%x: expected error", b)
+		}
+	}
+}
+
+func frame(out *Output) led.LEDs {
+	var leds led.LEDs
+	out.AcquireFrame(func(l led.LEDs) { leds = append(leds, l...) })
+	return leds
+}
+
+func TestOutput(t *testing.T) {
+	clock := clock.NewManual(time.Unix(0, 0))
+	// Draw pixels 1 to 3 of the stream.
+	out := NewOutput(3, 1, time.Second, clock)
+
+	var commits int
+	out.OnCommit(func(led.LEDs) { commits++ })
+
+	if !out.Silent() {
+		t.Error("output is not silent before receiving")
+	}
+
+	first, _ := ParsePacket(firstHalf)
+	second, _ := ParsePacket(secondHalf)
+
+	// Senders that never push have every packet shown.
+	out.Update(first)
+	if got, want := frame(out), (led.LEDs{{0, 255, 0}, {}, {}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Once the sender pushes, packets are only shown when pushed.
+	out.Update(second)
+	want := led.LEDs{{0, 255, 0}, {0, 0, 255}, {0x10, 0x20, 0x30}}
+	if got := frame(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	first.Data = []byte{0, 0, 0, 1, 1, 1}
+	out.Update(first)
+	if got := frame(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v before the push, want %v", got, want)
+	}
+
+	out.Update(Packet{Flags: Push, ID: IDDisplay})
+	want[0] = led.RGBColor{1, 1, 1}
+	if got := frame(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v after the push, want %v", got, want)
+	}
+
+	if commits != 3 {
+		t.Errorf("got %d commits, want 3", commits)
+	}
+
+	// RGBW pixels have their white dropped, and queries are ignored.
+	out.Update(Packet{Flags: Push, DataType: TypeRGBW<<3 | 3, ID: IDAll, Offset: 4, Data: []byte{9, 8, 7, 6}})
+	out.Update(Packet{Flags: Push | Query, ID: IDDisplay, Data: []byte{0, 0, 0, 0, 0, 0}})
+	want[0] = led.RGBColor{9, 8, 7}
+	if got := frame(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if out.Silent() {
+		t.Error("output is silent after receiving")
+	}
+	clock.Advance(time.Second)
+	if !out.Silent() {
+		t.Error("output is not silent after the timeout")
+	}
+}
+
+func freeAddr(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().String()
+}
+
+func TestReceivers(t *testing.T) {
+	listen := freeAddr(t)
+
+	// Two outputs share the stream, each drawing two pixels of it.
+	left := NewOutput(2, 0, time.Second, clock.Real)
+	right := NewOutput(2, 2, time.Second, clock.Real)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	receivers := NewReceivers()
+	runErrs := make(chan error, 2)
+	for _, out := range []*Output{left, right} {
+		out := out
+		go func() { runErrs <- receivers.Run(ctx, listen, out) }()
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	dst, err := net.ResolveUDPAddr("udp", listen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := led.RGBColor{0x10, 0x20, 0x30}
+	for frame(right)[1] != want || frame(left)[0] != (led.RGBColor{0xff, 0, 0}) {
+		// Keep sending until both outputs are attached.
+		conn.WriteTo(firstHalf, dst)
+		conn.WriteTo(secondHalf, dst)
+
+		select {
+		case <-ctx.Done():
+			t.Fatalf("timed out, got %v and %v", frame(left), frame(right))
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	for i := 0; i < 2; i++ {
+		if err := <-runErrs; err != nil {
+			t.Errorf("Run failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package ddp
+
+import (
+	"sync"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// DataTimeout is how long an output is considered to be receiving after its
+// last frame.
+const DataTimeout = 2500 * time.Millisecond
+
+// Output is an animator that draws the frames it receives. It draws a range
+// of the pixels in the stream, starting at an offset. It is safe for
+// concurrent use.
+type Output struct {
+	offset  int
+	timeout time.Duration
+	clock   clock.Clock
+	commit  func(led.LEDs)
+
+	mu sync.Mutex
+	// pending is the frame that is being received.
+	pending led.LEDs
+	leds    led.LEDs
+	last    time.Time
+	// pushes is true once the sender has pushed a frame. Until then, every
+	// packet is shown as it comes in, since not all senders push.
+	pushes bool
+}
+
+// NewOutput creates a new output for the given number of LEDs, which draws
+// the pixels of the stream from the given offset in pixels. The output is
+// silent once it has received nothing for the given timeout.
+func NewOutput(numLEDs, offset int, timeout time.Duration, clock clock.Clock) *Output {
+	return &Output{
+		offset:  offset,
+		timeout: timeout,
+		clock:   clock,
+		pending: led.NewLEDs(numLEDs),
+		leds:    led.NewLEDs(numLEDs),
+	}
+}
+
+// OnCommit sets a function that is called with every frame that is shown,
+// which must not be kept after it returns. It must be called before the
+// output receives anything.
+func (o *Output) OnCommit(f func(led.LEDs)) {
+	o.commit = f
+}
+
+// Update draws the pixels of the packet. The frame is shown once the packet
+// is pushed. Pixels outside the output are ignored.
+func (o *Output) Update(p Packet) {
+	if p.Flags&(Query|Reply|Storage) != 0 || (p.ID != IDDisplay && p.ID != IDAll) {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	size := p.DataType.PixelSize()
+	for j, v := range p.Data {
+		offset := int(p.Offset) + j
+		channel := offset % size
+		if channel >= 3 {
+			// White channels are dropped.
+			continue
+		}
+		i := offset/size - o.offset
+		if i < 0 {
+			continue
+		}
+		if i >= len(o.pending) {
+			break
+		}
+		o.pending[i][channel] = v
+	}
+
+	if p.Flags&Push != 0 {
+		o.pushes = true
+	} else if o.pushes {
+		return
+	}
+
+	copy(o.leds, o.pending)
+	o.last = o.clock.Now()
+	if o.commit != nil {
+		o.commit(o.leds)
+	}
+}
+
+// AcquireFrame acquires the last shown frame.
+func (o *Output) AcquireFrame(f func(led.LEDs)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	f(o.leds)
+}
+
+// Silent returns true if nothing was shown for the timeout.
+func (o *Output) Silent() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.last.IsZero() || o.clock.Now().Sub(o.last) >= o.timeout
+}
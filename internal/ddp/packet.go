@@ -0,0 +1,155 @@
+// Package ddp implements a receiver for the Distributed Display Protocol,
+// which xLights, LedFx and others use to send pixel data over the network.
+package ddp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Port is the UDP port that DDP is sent to.
+const Port = 4048
+
+// Flags are the flags in the first byte of a packet, which also hold the
+// protocol version.
+type Flags uint8
+
+const (
+	// VersionMask masks the protocol version.
+	VersionMask Flags = 0xc0
+	// Version1 is the only protocol version.
+	Version1 Flags = 0x40
+	// Timecode marks that the header carries a timecode.
+	Timecode Flags = 0x10
+	// Storage marks that the data is for the storage of the device.
+	Storage Flags = 0x08
+	// Reply marks a reply to a query.
+	Reply Flags = 0x04
+	// Query marks a query, which asks the device for data.
+	Query Flags = 0x02
+	// Push marks the last packet of a frame, after which the frame is shown.
+	Push Flags = 0x01
+)
+
+// DataType is the type of the pixel data.
+type DataType uint8
+
+// Types of pixel data, which are bits 3 to 5 of DataType.
+const (
+	TypeUndefined = 0
+	TypeRGB       = 1
+	TypeHSL       = 2
+	TypeRGBW      = 3
+	TypeGrayscale = 4
+)
+
+// RGB8 is the data type of 8-bit RGB pixels.
+const RGB8 DataType = TypeRGB<<3 | 3
+
+// PixelSize returns the number of bytes per pixel. Only 8-bit channels are
+// supported. Senders often leave the type undefined, in which case RGB is
+// assumed.
+func (t DataType) PixelSize() int {
+	if (t>>3)&7 == TypeRGBW {
+		return 4
+	}
+	return 3
+}
+
+// Destination IDs.
+const (
+	// IDDisplay is the default output device.
+	IDDisplay = 1
+	// IDAll addresses all devices.
+	IDAll = 255
+)
+
+// Packet is a DDP packet.
+type Packet struct {
+	// Flags are the flags of the packet. They always include Version1 once
+	// marshaled.
+	Flags Flags
+	// Sequence is the sequence number within [1, 15], or 0 if unused.
+	Sequence uint8
+	// DataType is the type of the pixel data.
+	DataType DataType
+	// ID is the destination of the packet.
+	ID uint8
+	// Offset is the offset of the data in bytes.
+	Offset uint32
+	// Timecode is the time that the data should be shown at. It is only
+	// used if Flags has Timecode.
+	Timecode uint32
+	// Data is the pixel data.
+	Data []byte
+}
+
+const (
+	headerSize         = 10
+	timecodeHeaderSize = 14
+)
+
+// ErrInvalid is returned when parsing something that is not a DDP packet.
+var ErrInvalid = errors.New("not a DDP packet")
+
+// ParsePacket parses a DDP packet.
+func ParsePacket(b []byte) (Packet, error) {
+	if len(b) < headerSize {
+		return Packet{}, ErrInvalid
+	}
+
+	p := Packet{
+		Flags:    Flags(b[0]),
+		Sequence: b[1] & 0x0f,
+		DataType: DataType(b[2]),
+		ID:       b[3],
+		Offset:   binary.BigEndian.Uint32(b[4:]),
+	}
+	if p.Flags&VersionMask != Version1 {
+		return Packet{}, fmt.Errorf("unsupported DDP version %d", p.Flags>>6)
+	}
+
+	length := int(binary.BigEndian.Uint16(b[8:]))
+	b = b[headerSize:]
+
+	if p.Flags&Timecode != 0 {
+		if len(b) < timecodeHeaderSize-headerSize {
+			return Packet{}, ErrInvalid
+		}
+		p.Timecode = binary.BigEndian.Uint32(b)
+		b = b[timecodeHeaderSize-headerSize:]
+	}
+
+	if len(b) < length {
+		return Packet{}, fmt.Errorf("DDP packet has %d bytes of data, want %d", len(b), length)
+	}
+	p.Data = b[:length]
+
+	return p, nil
+}
+
+// MarshalBinary encodes the packet.
+func (p Packet) MarshalBinary() ([]byte, error) {
+	if len(p.Data) > 0xffff {
+		return nil, fmt.Errorf("DDP data too long: %d bytes", len(p.Data))
+	}
+
+	size := headerSize
+	if p.Flags&Timecode != 0 {
+		size = timecodeHeaderSize
+	}
+
+	b := make([]byte, size, size+len(p.Data))
+	b[0] = byte(p.Flags&^VersionMask | Version1)
+	b[1] = p.Sequence & 0x0f
+	b[2] = byte(p.DataType)
+	b[3] = p.ID
+	binary.BigEndian.PutUint32(b[4:], p.Offset)
+	binary.BigEndian.PutUint16(b[8:], uint16(len(p.Data)))
+	if p.Flags&Timecode != 0 {
+		binary.BigEndian.PutUint32(b[10:], p.Timecode)
+	}
+
+	return append(b, p.Data...), nil
+}
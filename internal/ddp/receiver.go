@@ -0,0 +1,152 @@
+package ddp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+)
+
+func addr(addr string) string {
+	if addr == "" {
+		return fmt.Sprintf(":%d", Port)
+	}
+	return addr
+}
+
+// Receivers shares sockets between outputs that listen on the same address,
+// since only one socket can be bound to it. Every output sees every packet
+// and draws its own range of the stream. The zero value is ready to use.
+type Receivers struct {
+	mu        sync.Mutex
+	receivers map[string]*receiver
+}
+
+// NewReceivers creates a new set of receivers.
+func NewReceivers() *Receivers {
+	return &Receivers{}
+}
+
+// Run receives frames into the output until the context is canceled or the
+// socket fails. If listen is empty, then all interfaces are listened on at
+// Port. The socket is opened when the first output listening on its address
+// attaches and closed once the last one detaches.
+func (rs *Receivers) Run(ctx context.Context, listen string, out *Output) error {
+	listen = addr(listen)
+
+	rs.mu.Lock()
+	if rs.receivers == nil {
+		rs.receivers = make(map[string]*receiver)
+	}
+	r, ok := rs.receivers[listen]
+	if !ok {
+		r = &receiver{
+			addr:    listen,
+			outputs: make(map[*Output]struct{}),
+		}
+		rs.receivers[listen] = r
+	}
+	rs.mu.Unlock()
+
+	return r.run(ctx, out)
+}
+
+// receiver receives DDP on a socket and hands the packets to the attached
+// outputs.
+type receiver struct {
+	addr string
+
+	mu      sync.Mutex
+	conn    *net.UDPConn
+	outputs map[*Output]struct{}
+	// done is closed once the socket fails, after which err is set.
+	done chan struct{}
+	err  error
+}
+
+func (r *receiver) run(ctx context.Context, out *Output) error {
+	r.mu.Lock()
+	if r.conn == nil {
+		if err := r.open(); err != nil {
+			r.mu.Unlock()
+			return err
+		}
+	}
+	conn := r.conn
+	done := r.done
+	r.outputs[out] = struct{}{}
+	r.mu.Unlock()
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case <-done:
+		r.mu.Lock()
+		err = r.err
+		r.mu.Unlock()
+	}
+
+	r.mu.Lock()
+	if r.conn == conn {
+		delete(r.outputs, out)
+		if len(r.outputs) == 0 {
+			r.conn.Close()
+			r.conn = nil
+		}
+	}
+	r.mu.Unlock()
+
+	return err
+}
+
+// open opens the socket and starts reading from it.
+func (r *receiver) open() error {
+	addr, err := net.ResolveUDPAddr("udp", r.addr)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for DDP: %w", err)
+	}
+
+	r.conn = conn
+	r.done = make(chan struct{})
+	r.err = nil
+
+	go r.read(conn, r.done)
+	return nil
+}
+
+func (r *receiver) read(conn *net.UDPConn, done chan struct{}) {
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			r.mu.Lock()
+			if r.conn == conn {
+				// The socket failed rather than being closed by us. Let the
+				// next output that attaches reopen it.
+				r.err = fmt.Errorf("failed to receive DDP: %w", err)
+				r.conn = nil
+				r.outputs = make(map[*Output]struct{})
+				conn.Close()
+			}
+			r.mu.Unlock()
+			close(done)
+			return
+		}
+
+		p, err := ParsePacket(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		r.mu.Lock()
+		for out := range r.outputs {
+			out.Update(p)
+		}
+		r.mu.Unlock()
+	}
+}
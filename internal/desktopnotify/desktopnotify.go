@@ -0,0 +1,85 @@
+// Package desktopnotify watches the notifications that desktop applications
+// show through the freedesktop.org notification service. The notifications
+// are seen by monitoring the session bus, so the notification daemon keeps
+// showing them as usual.
+package desktopnotify
+
+import (
+	"context"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/pkg/errors"
+)
+
+// Names of the notification service.
+const (
+	// Name is the well-known name of the notification daemon.
+	Name = "org.freedesktop.Notifications"
+	// Path is the path of the notification daemon's object.
+	Path dbus.ObjectPath = "/org/freedesktop/Notifications"
+	// Interface is the interface that applications call Notify on.
+	Interface = "org.freedesktop.Notifications"
+)
+
+// Notification is a notification that an application showed.
+type Notification struct {
+	// App is the name of the application, such as "Slack". It may be empty.
+	App string
+	// Summary is the single line that sums up the notification.
+	Summary string
+	// Body is the text of the notification, which may be empty.
+	Body string
+}
+
+// Watch monitors the connection for notifications, calling the given function
+// with each, until the context is canceled or the connection is closed. The
+// connection becomes a monitor and cannot be used for anything else, so it
+// should be a private connection that Watch may close once done.
+func Watch(ctx context.Context, conn *dbus.Conn, onNotify func(Notification)) error {
+	rule := "type='method_call',interface='" + Interface + "',member='Notify'"
+	err := conn.BusObject().CallWithContext(ctx,
+		"org.freedesktop.DBus.Monitoring.BecomeMonitor", 0, []string{rule}, uint32(0)).Err
+	if err != nil {
+		return errors.Wrap(err, "failed to monitor the bus")
+	}
+
+	// Monitors only receive what they monitor, which must not be handled as
+	// calls to this connection.
+	msgs := make(chan *dbus.Message, 16)
+	conn.Eavesdrop(msgs)
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	for msg := range msgs {
+		n, ok := parse(msg)
+		if ok {
+			onNotify(n)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return errors.New("the connection to the bus was closed")
+}
+
+// parse returns the notification of a Notify call, whose arguments are
+// (app_name s, replaces_id u, app_icon s, summary s, body s, actions as,
+// hints a{sv}, expire_timeout i).
+func parse(msg *dbus.Message) (Notification, bool) {
+	if msg.Type != dbus.TypeMethodCall || len(msg.Body) < 5 {
+		return Notification{}, false
+	}
+
+	app, ok1 := msg.Body[0].(string)
+	summary, ok2 := msg.Body[3].(string)
+	body, ok3 := msg.Body[4].(string)
+	if !ok1 || !ok2 || !ok3 {
+		return Notification{}, false
+	}
+
+	return Notification{App: app, Summary: summary, Body: body}, true
+}
@@ -0,0 +1,129 @@
+package desktopnotify
+
+import (
+	"bufio"
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// startBus starts a private session bus for the test and returns its
+// address. The test is skipped if dbus-daemon is not installed.
+func startBus(t *testing.T) string {
+	t.Helper()
+
+	path, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+
+	cmd := exec.Command(path, "--session", "--nofork", "--nopidfile", "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatal("failed to read the bus address:", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+func connect(t *testing.T, address string) *dbus.Conn {
+	t.Helper()
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// mockServer is a notification daemon that accepts every notification.
+type mockServer struct{}
+
+func (mockServer) Notify(
+	app string, replaces uint32, icon, summary, body string,
+	actions []string, hints map[string]dbus.Variant, timeout int32,
+) (uint32, *dbus.Error) {
+	return 1, nil
+}
+
+func TestWatch(t *testing.T) {
+	address := startBus(t)
+
+	server := connect(t, address)
+	if err := server.Export(mockServer{}, Path, Interface); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.RequestName(Name, dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	notifications := make(chan Notification, 16)
+	monitor := connect(t, address)
+	watched := make(chan error, 1)
+	go func() {
+		watched <- Watch(ctx, monitor, func(n Notification) { notifications <- n })
+	}()
+
+	app := connect(t, address)
+	notify := func(summary string) {
+		t.Helper()
+		call := app.Object(Name, Path).Call(Interface+".Notify", 0,
+			"Slack", uint32(0), "", summary, "hello", []string{},
+			map[string]dbus.Variant{}, int32(-1))
+		if call.Err != nil {
+			t.Fatal(call.Err)
+		}
+	}
+
+	// Keep notifying until the monitor is up.
+	var got Notification
+	timeout := time.After(5 * time.Second)
+wait:
+	for {
+		notify("first")
+		select {
+		case got = <-notifications:
+			break wait
+		case <-time.After(50 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("no notification")
+		}
+	}
+	if want := (Notification{App: "Slack", Summary: "first", Body: "hello"}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	notify("second")
+	for got.Summary != "second" {
+		// Skip the notifications sent while waiting.
+		select {
+		case got = <-notifications:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no second notification")
+		}
+	}
+
+	cancel()
+	if err := <-watched; err != context.Canceled {
+		t.Errorf("Watch returned %v, want context.Canceled", err)
+	}
+}
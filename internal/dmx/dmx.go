@@ -0,0 +1,156 @@
+// Package dmx maps DMX512 universes onto LEDs, for lighting protocols that
+// carry them over the network such as E1.31 and Art-Net.
+package dmx
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// UniverseSize is the number of channels in a universe.
+const UniverseSize = 512
+
+// DefaultChannelsPerUniverse is the number of channels that lighting software
+// usually puts into each universe, which is 170 RGB LEDs.
+const DefaultChannelsPerUniverse = 510
+
+// Mapping describes where the RGB channels of a strip of LEDs are in a series
+// of consecutive universes.
+type Mapping struct {
+	// Universe is the first universe.
+	Universe uint16
+	// StartChannel is the channel of the first LED in the first universe,
+	// starting from 1. If zero, then 1 is used.
+	StartChannel int
+	// ChannelsPerUniverse is the number of channels used in each universe
+	// before continuing in the next one. If zero, then
+	// DefaultChannelsPerUniverse is used.
+	ChannelsPerUniverse int
+}
+
+func (m Mapping) withDefaults() Mapping {
+	if m.StartChannel == 0 {
+		m.StartChannel = 1
+	}
+	if m.ChannelsPerUniverse == 0 {
+		m.ChannelsPerUniverse = DefaultChannelsPerUniverse
+	}
+	return m
+}
+
+// Validate validates the mapping.
+func (m Mapping) Validate() error {
+	m = m.withDefaults()
+	if m.ChannelsPerUniverse < 1 || m.ChannelsPerUniverse > UniverseSize {
+		return fmt.Errorf("channels per universe must be within [1, %d]", UniverseSize)
+	}
+	if m.StartChannel < 1 || m.StartChannel > m.ChannelsPerUniverse {
+		return fmt.Errorf("start channel must be within [1, %d]", m.ChannelsPerUniverse)
+	}
+	return nil
+}
+
+// Universes returns the universes that the given number of LEDs span.
+func (m Mapping) Universes(numLEDs int) []uint16 {
+	m = m.withDefaults()
+	if numLEDs == 0 {
+		return nil
+	}
+
+	last := (m.StartChannel - 1 + 3*numLEDs - 1) / m.ChannelsPerUniverse
+	universes := make([]uint16, 0, last+1)
+	for i := 0; i <= last; i++ {
+		universes = append(universes, m.Universe+uint16(i))
+	}
+	return universes
+}
+
+// Draw draws the channels of the given universe onto the LEDs that it
+// covers. Channels missing from data are left unchanged.
+func (m Mapping) Draw(leds led.LEDs, universe uint16, data []byte) {
+	m = m.withDefaults()
+
+	// Work with channel indices across all universes, where 0 is the first
+	// channel of the first universe.
+	index := int(universe - m.Universe)
+	first := index * m.ChannelsPerUniverse
+	start := m.StartChannel - 1
+
+	for ch := 0; ch < m.ChannelsPerUniverse && ch < len(data); ch++ {
+		i := first + ch - start
+		if i < 0 {
+			continue
+		}
+		if i >= 3*len(leds) {
+			break
+		}
+		leds[i/3][i%3] = data[ch]
+	}
+}
+
+// Output is an animator that draws the universes it receives. It is safe
+// for concurrent use.
+type Output struct {
+	mapping   Mapping
+	universes []uint16
+	timeout   time.Duration
+	clock     clock.Clock
+
+	mu   sync.Mutex
+	leds led.LEDs
+	last time.Time
+}
+
+// NewOutput creates a new output for the given number of LEDs. The output is
+// silent once it has received nothing for the given timeout.
+func NewOutput(numLEDs int, m Mapping, timeout time.Duration, clock clock.Clock) *Output {
+	return &Output{
+		mapping:   m,
+		universes: m.Universes(numLEDs),
+		timeout:   timeout,
+		clock:     clock,
+		leds:      led.NewLEDs(numLEDs),
+	}
+}
+
+// Universes returns the universes that the output draws.
+func (o *Output) Universes() []uint16 {
+	return o.universes
+}
+
+// Covers returns true if the output draws the given universe.
+func (o *Output) Covers(universe uint16) bool {
+	return len(o.universes) > 0 && universe-o.universes[0] < uint16(len(o.universes))
+}
+
+// Update draws the channels of the given universe. Universes that the output
+// does not cover are ignored.
+func (o *Output) Update(universe uint16, data []byte) {
+	if !o.Covers(universe) {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.mapping.Draw(o.leds, universe, data)
+	o.last = o.clock.Now()
+}
+
+// AcquireFrame acquires the last drawn frame.
+func (o *Output) AcquireFrame(f func(led.LEDs)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	f(o.leds)
+}
+
+// Silent returns true if nothing was received for the timeout.
+func (o *Output) Silent() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.last.IsZero() || o.clock.Now().Sub(o.last) >= o.timeout
+}
@@ -0,0 +1,85 @@
+package dmx
+
+import (
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+func TestUniverses(t *testing.T) {
+	tests := []struct {
+		mapping Mapping
+		numLEDs int
+		want    []uint16
+	}{
+		{Mapping{Universe: 1}, 170, []uint16{1}},
+		{Mapping{Universe: 1}, 171, []uint16{1, 2}},
+		{Mapping{Universe: 1, StartChannel: 4}, 170, []uint16{1, 2}},
+		{Mapping{Universe: 5, ChannelsPerUniverse: 6}, 5, []uint16{5, 6, 7}},
+		{Mapping{Universe: 1}, 0, nil},
+	}
+
+	for _, test := range tests {
+		got := test.mapping.Universes(test.numLEDs)
+		if len(got) != len(test.want) {
+			t.Errorf("%+v with %d LEDs: got %v, want %v", test.mapping, test.numLEDs, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%+v with %d LEDs: got %v, want %v", test.mapping, test.numLEDs, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestDraw(t *testing.T) {
+	// 4 channels per universe, so the second LED spans both universes.
+	m := Mapping{Universe: 10, StartChannel: 2, ChannelsPerUniverse: 4}
+	leds := led.NewLEDs(3)
+
+	m.Draw(leds, 10, []byte{99, 1, 2, 3})
+	m.Draw(leds, 11, []byte{4, 5, 6, 7})
+	m.Draw(leds, 12, []byte{8, 9})
+
+	want := led.LEDs{{1, 2, 3}, {4, 5, 6}, {7, 8, 9}}
+	for i := range want {
+		if leds[i] != want[i] {
+			t.Errorf("LEDs = %v, want %v", leds, want)
+			break
+		}
+	}
+}
+
+func TestOutput(t *testing.T) {
+	clock := clock.NewManual(time.Unix(0, 0))
+	o := NewOutput(2, Mapping{Universe: 1}, time.Second, clock)
+
+	if !o.Silent() {
+		t.Error("output is not silent before receiving anything")
+	}
+
+	o.Update(2, []byte{1, 2, 3})
+	if !o.Silent() {
+		t.Error("output is not silent after receiving another universe")
+	}
+
+	o.Update(1, []byte{1, 2, 3, 4, 5, 6})
+	if o.Silent() {
+		t.Error("output is silent after receiving data")
+	}
+
+	o.AcquireFrame(func(leds led.LEDs) {
+		if leds[1] != (led.RGBColor{4, 5, 6}) {
+			t.Errorf("LEDs = %v, want the second LED to be 4, 5, 6", leds)
+		}
+	})
+
+	clock.Advance(time.Second)
+	if !o.Silent() {
+		t.Error("output is not silent after the timeout")
+	}
+}
@@ -0,0 +1,190 @@
+package e131
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/dmx"
+	"libdb.so/catglow/internal/led"
+)
+
+func TestPacketRoundTrip(t *testing.T) {
+	p := DataPacket{
+		CID:        [16]byte{1, 2, 3},
+		SourceName: "xLights",
+		Priority:   150,
+		Sequence:   42,
+		Options:    ForceSynchronization,
+		Universe:   7,
+		Data:       []byte{255, 0, 128},
+	}
+
+	b, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 129 {
+		t.Errorf("packet is %d bytes, want 129", len(b))
+	}
+	// The root layer's length covers everything after the preamble.
+	if got := int(b[16]&0x0f)<<8 | int(b[17]); got != len(b)-16 {
+		t.Errorf("root layer length = %d, want %d", got, len(b)-16)
+	}
+
+	got, err := ParseDataPacket(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.CID != p.CID || got.SourceName != p.SourceName || got.Priority != p.Priority ||
+		got.Sequence != p.Sequence || got.Options != p.Options || got.Universe != p.Universe ||
+		string(got.Data) != string(p.Data) {
+		t.Errorf("parsed %+v, want %+v", got, p)
+	}
+
+	if _, err := ParseDataPacket(b[:100]); err == nil {
+		t.Error("expected an error for a truncated packet")
+	}
+
+	// Synchronization packets use another root vector.
+	b[21] = 0x08
+	if _, err := ParseDataPacket(b); err != ErrNotData {
+		t.Errorf("expected ErrNotData, got %v", err)
+	}
+}
+
+func TestMulticastAddr(t *testing.T) {
+	if got := MulticastAddr(0x1234).String(); got != "239.255.18.52:5568" {
+		t.Errorf("got %s", got)
+	}
+}
+
+// sender sends E1.31 to the receiver under test from a local UDP socket.
+type sender struct {
+	t    *testing.T
+	conn net.PacketConn
+	addr net.Addr
+	cid  [16]byte
+	seq  uint8
+}
+
+func newSender(t *testing.T, addr string, cid byte) *sender {
+	udpAddr, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &sender{t: t, conn: conn, addr: udpAddr, cid: [16]byte{cid}}
+}
+
+func (s *sender) send(universe uint16, priority uint8, opts Options, data ...byte) {
+	s.seq++
+	b, err := DataPacket{
+		CID:      s.cid,
+		Priority: priority,
+		Sequence: s.seq,
+		Options:  opts,
+		Universe: universe,
+		Data:     data,
+	}.MarshalBinary()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if _, err := s.conn.WriteTo(b, s.addr); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+func freeAddr(t *testing.T) string {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().String()
+}
+
+func TestReceiver(t *testing.T) {
+	addr := freeAddr(t)
+
+	// Two LEDs in universe 1 and one in universe 2.
+	out := dmx.NewOutput(3, dmx.Mapping{Universe: 1, ChannelsPerUniverse: 6}, time.Second, clock.Real)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	receivers := NewReceivers()
+	runErr := make(chan error, 1)
+	go func() { runErr <- receivers.Run(ctx, ListenConfig{Addr: addr}, out) }()
+
+	low := newSender(t, addr, 1)
+	high := newSender(t, addr, 2)
+
+	// Keep sending until the receiver is up.
+	waitFor(t, ctx, out, 0, led.RGBColor{1, 2, 3}, func() {
+		low.send(1, DefaultPriority, 0, 1, 2, 3, 4, 5, 6)
+	})
+
+	low.send(2, DefaultPriority, 0, 7, 8, 9)
+	waitFor(t, ctx, out, 2, led.RGBColor{7, 8, 9}, nil)
+
+	// A source with a higher priority takes over...
+	high.send(1, 150, 0, 10, 20, 30)
+	waitFor(t, ctx, out, 0, led.RGBColor{10, 20, 30}, nil)
+
+	// ...and the lower priority is ignored while it is around.
+	low.send(1, DefaultPriority, 0, 0, 0, 0)
+	// Preview data is not drawn.
+	high.send(1, 150, PreviewData, 0, 0, 0)
+	// Packets that arrive out of order are dropped.
+	high.seq -= 2
+	high.send(1, 150, 0, 0, 0, 0)
+	high.seq++
+
+	// Packets are handled in order, so once this one is drawn, the ones
+	// before it were handled.
+	high.send(2, 150, 0, 70, 80, 90)
+	waitFor(t, ctx, out, 2, led.RGBColor{70, 80, 90}, nil)
+	waitFor(t, ctx, out, 0, led.RGBColor{10, 20, 30}, nil)
+
+	// Once the high priority source terminates, the lower one is drawn again.
+	high.send(1, 150, StreamTerminated)
+	waitFor(t, ctx, out, 0, led.RGBColor{13, 23, 33}, func() {
+		low.send(1, DefaultPriority, 0, 13, 23, 33)
+	})
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("Run failed: %v", err)
+	}
+}
+
+// waitFor waits until the LED at i has the given color, calling send while
+// waiting if it is not nil.
+func waitFor(t *testing.T, ctx context.Context, out *dmx.Output, i int, want led.RGBColor, send func()) {
+	t.Helper()
+
+	for {
+		if send != nil {
+			send()
+		}
+
+		var got led.RGBColor
+		out.AcquireFrame(func(leds led.LEDs) { got = leds[i] })
+		if got == want {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for LED %d to be %v, got %v", i, want, got)
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package e131
+
+import (
+	"errors"
+	"net"
+)
+
+var errMulticastUnsupported = errors.New("multicast is not supported on this platform")
+
+func joinGroup(conn *net.UDPConn, ifname string, group net.IP) error {
+	return errMulticastUnsupported
+}
+
+func leaveGroup(conn *net.UDPConn, group net.IP) error {
+	return errMulticastUnsupported
+}
@@ -0,0 +1,68 @@
+//go:build unix
+
+package e131
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+func joinGroup(conn *net.UDPConn, ifname string, group net.IP) error {
+	mreq := &syscall.IPMreq{}
+	copy(mreq.Multiaddr[:], group.To4())
+
+	if ifname != "" {
+		ip, err := interfaceAddr(ifname)
+		if err != nil {
+			return err
+		}
+		copy(mreq.Interface[:], ip)
+	}
+
+	return setMembership(conn, syscall.IP_ADD_MEMBERSHIP, mreq)
+}
+
+func leaveGroup(conn *net.UDPConn, group net.IP) error {
+	mreq := &syscall.IPMreq{}
+	copy(mreq.Multiaddr[:], group.To4())
+	return setMembership(conn, syscall.IP_DROP_MEMBERSHIP, mreq)
+}
+
+func setMembership(conn *net.UDPConn, opt int, mreq *syscall.IPMreq) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptIPMreq(int(fd), syscall.IPPROTO_IP, opt, mreq)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// interfaceAddr returns the IPv4 address of the named network interface.
+func interfaceAddr(name string) (net.IP, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok {
+			if ip := ipnet.IP.To4(); ip != nil {
+				return ip, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("interface %s has no IPv4 address", name)
+}
@@ -0,0 +1,168 @@
+// Package e131 implements a receiver for E1.31 (Streaming ACN, or sACN),
+// which lighting software uses to send DMX512 universes over the network.
+package e131
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Port is the UDP port that E1.31 is sent to.
+const Port = 5568
+
+// Options are the option flags of a data packet.
+type Options uint8
+
+const (
+	// PreviewData marks data that is meant for visualizers rather than
+	// lights.
+	PreviewData Options = 1 << 7
+	// StreamTerminated marks the last packet of a source for a universe.
+	StreamTerminated Options = 1 << 6
+	// ForceSynchronization marks that synchronization must be kept even if
+	// the sync source stops.
+	ForceSynchronization Options = 1 << 5
+)
+
+// DefaultPriority is the priority of sources that do not set one.
+const DefaultPriority = 100
+
+// DataPacket is an E1.31 data packet, which carries a universe.
+type DataPacket struct {
+	// CID identifies the source.
+	CID [16]byte
+	// SourceName is the user-assigned name of the source.
+	SourceName string
+	// Priority is the priority of the data within [0, 200]. Receivers only
+	// use the data of the sources with the highest priority.
+	Priority uint8
+	// SyncAddress is the universe that synchronization packets are sent on,
+	// or 0 if the data is not synchronized.
+	SyncAddress uint16
+	// Sequence is the sequence number of the packet, which increases by one
+	// per packet of a source and universe.
+	Sequence uint8
+	// Options are the option flags.
+	Options Options
+	// Universe is the universe that the data is for.
+	Universe uint16
+	// StartCode is the DMX512 start code. Levels are sent with start code 0.
+	StartCode uint8
+	// Data is the channels of the universe.
+	Data []byte
+}
+
+const (
+	vectorRootData     = 0x00000004
+	vectorFramingData  = 0x00000002
+	vectorDMPSetProp   = 0x02
+	dmpAddressType     = 0xa1
+	dataHeaderSize     = 126
+	sourceNameSize     = 64
+	acnPacketID        = "ASC-E1.17\x00\x00\x00"
+	flagsAndLengthMask = 0x0fff
+)
+
+// ErrNotData is returned when parsing a valid E1.31 packet that is not a data
+// packet, such as a synchronization or discovery packet.
+var ErrNotData = errors.New("not an E1.31 data packet")
+
+// ParseDataPacket parses a data packet. The data of the returned packet
+// refers to b.
+func ParseDataPacket(b []byte) (DataPacket, error) {
+	if len(b) < 22 ||
+		binary.BigEndian.Uint16(b[0:]) != 0x0010 ||
+		binary.BigEndian.Uint16(b[2:]) != 0x0000 ||
+		string(b[4:16]) != acnPacketID {
+		return DataPacket{}, errors.New("not an E1.31 packet")
+	}
+
+	if binary.BigEndian.Uint32(b[18:]) != vectorRootData {
+		return DataPacket{}, ErrNotData
+	}
+
+	if len(b) < dataHeaderSize {
+		return DataPacket{}, fmt.Errorf("data packet too short (%d bytes)", len(b))
+	}
+	if binary.BigEndian.Uint32(b[40:]) != vectorFramingData {
+		return DataPacket{}, ErrNotData
+	}
+	if b[117] != vectorDMPSetProp || b[118] != dmpAddressType {
+		return DataPacket{}, errors.New("invalid DMP layer")
+	}
+
+	// The property values are the start code followed by the channels.
+	count := int(binary.BigEndian.Uint16(b[123:]))
+	if count < 1 || count > 513 || len(b) < dataHeaderSize-1+count {
+		return DataPacket{}, fmt.Errorf("invalid property value count %d", count)
+	}
+
+	p := DataPacket{
+		SourceName:  string(bytes.TrimRight(b[44:44+sourceNameSize], "\x00")),
+		Priority:    b[108],
+		SyncAddress: binary.BigEndian.Uint16(b[109:]),
+		Sequence:    b[111],
+		Options:     Options(b[112]),
+		Universe:    binary.BigEndian.Uint16(b[113:]),
+		StartCode:   b[125],
+		Data:        b[dataHeaderSize : dataHeaderSize-1+count],
+	}
+	copy(p.CID[:], b[22:38])
+
+	return p, nil
+}
+
+// MarshalBinary encodes the data packet.
+func (p DataPacket) MarshalBinary() ([]byte, error) {
+	if len(p.Data) > 512 {
+		return nil, fmt.Errorf("too many channels (%d)", len(p.Data))
+	}
+	if len(p.SourceName) >= sourceNameSize {
+		return nil, errors.New("source name too long")
+	}
+
+	b := make([]byte, dataHeaderSize+len(p.Data))
+	flagsAndLength := func(offset int) {
+		binary.BigEndian.PutUint16(b[offset:], 0x7000|uint16(len(b)-offset)&flagsAndLengthMask)
+	}
+
+	// Root layer.
+	binary.BigEndian.PutUint16(b[0:], 0x0010)
+	copy(b[4:], acnPacketID)
+	flagsAndLength(16)
+	binary.BigEndian.PutUint32(b[18:], vectorRootData)
+	copy(b[22:], p.CID[:])
+
+	// Framing layer.
+	flagsAndLength(38)
+	binary.BigEndian.PutUint32(b[40:], vectorFramingData)
+	copy(b[44:], p.SourceName)
+	b[108] = p.Priority
+	binary.BigEndian.PutUint16(b[109:], p.SyncAddress)
+	b[111] = p.Sequence
+	b[112] = uint8(p.Options)
+	binary.BigEndian.PutUint16(b[113:], p.Universe)
+
+	// DMP layer.
+	flagsAndLength(115)
+	b[117] = vectorDMPSetProp
+	b[118] = dmpAddressType
+	binary.BigEndian.PutUint16(b[121:], 1) // address increment
+	binary.BigEndian.PutUint16(b[123:], uint16(1+len(p.Data)))
+	b[125] = p.StartCode
+	copy(b[dataHeaderSize:], p.Data)
+
+	return b, nil
+}
+
+// MulticastAddr returns the multicast address that the given universe is sent
+// to.
+func MulticastAddr(universe uint16) *net.UDPAddr {
+	return &net.UDPAddr{
+		IP:   net.IPv4(239, 255, byte(universe>>8), byte(universe)),
+		Port: Port,
+	}
+}
@@ -0,0 +1,283 @@
+package e131
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"libdb.so/catglow/internal/dmx"
+)
+
+// SourceTimeout is how long a source is considered alive after its last
+// packet, which the standard calls the network data loss timeout.
+const SourceTimeout = 2500 * time.Millisecond
+
+// ListenConfig configures how an output receives E1.31.
+type ListenConfig struct {
+	// Addr is the UDP address to listen on. If empty, then all interfaces are
+	// listened on at Port.
+	Addr string
+	// Multicast joins the multicast groups of the output's universes. Unicast
+	// packets are always received.
+	Multicast bool
+	// Interface is the name of the network interface to join multicast
+	// groups on. If empty, then the system picks one.
+	Interface string
+}
+
+func (c ListenConfig) addr() string {
+	if c.Addr == "" {
+		return fmt.Sprintf(":%d", Port)
+	}
+	return c.Addr
+}
+
+// Receivers shares sockets between outputs that listen on the same address,
+// since only one socket can be bound to it. The zero value is ready to use.
+type Receivers struct {
+	mu        sync.Mutex
+	receivers map[string]*receiver
+}
+
+// NewReceivers creates a new set of receivers.
+func NewReceivers() *Receivers {
+	return &Receivers{}
+}
+
+// Run receives universes into the output until the context is canceled or
+// the socket fails. The socket is opened when the first output listening on
+// its address attaches and closed once the last one detaches.
+func (rs *Receivers) Run(ctx context.Context, cfg ListenConfig, out *dmx.Output) error {
+	rs.mu.Lock()
+	if rs.receivers == nil {
+		rs.receivers = make(map[string]*receiver)
+	}
+	r, ok := rs.receivers[cfg.addr()]
+	if !ok {
+		r = &receiver{
+			addr:      cfg.addr(),
+			outputs:   make(map[*dmx.Output]struct{}),
+			groups:    make(map[uint16]int),
+			universes: make(map[uint16]*universeState),
+		}
+		rs.receivers[cfg.addr()] = r
+	}
+	rs.mu.Unlock()
+
+	return r.run(ctx, cfg, out)
+}
+
+// receiver receives E1.31 on a socket and hands the universes to the
+// attached outputs.
+type receiver struct {
+	addr string
+
+	mu      sync.Mutex
+	conn    *net.UDPConn
+	outputs map[*dmx.Output]struct{}
+	// groups counts the outputs that joined the multicast group of each
+	// universe.
+	groups    map[uint16]int
+	universes map[uint16]*universeState
+	// done is closed once the socket fails, after which err is set.
+	done chan struct{}
+	err  error
+}
+
+func (r *receiver) run(ctx context.Context, cfg ListenConfig, out *dmx.Output) error {
+	r.mu.Lock()
+	if r.conn == nil {
+		if err := r.open(); err != nil {
+			r.mu.Unlock()
+			return err
+		}
+	}
+	conn := r.conn
+	done := r.done
+
+	r.outputs[out] = struct{}{}
+
+	var joined []uint16
+	if cfg.Multicast {
+		for _, universe := range out.Universes() {
+			if r.groups[universe] == 0 {
+				if err := joinGroup(conn, cfg.Interface, MulticastAddr(universe).IP); err != nil {
+					r.detach(out, joined)
+					r.mu.Unlock()
+					return fmt.Errorf("failed to join multicast group of universe %d: %w", universe, err)
+				}
+			}
+			r.groups[universe]++
+			joined = append(joined, universe)
+		}
+	}
+	r.mu.Unlock()
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case <-done:
+		r.mu.Lock()
+		err = r.err
+		r.mu.Unlock()
+	}
+
+	r.mu.Lock()
+	if r.conn == conn {
+		r.detach(out, joined)
+	}
+	r.mu.Unlock()
+
+	return err
+}
+
+// open opens the socket and starts reading from it.
+func (r *receiver) open() error {
+	addr, err := net.ResolveUDPAddr("udp4", r.addr)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenUDP("udp4", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for E1.31: %w", err)
+	}
+
+	r.conn = conn
+	r.done = make(chan struct{})
+	r.err = nil
+
+	go r.read(conn, r.done)
+	return nil
+}
+
+// detach detaches the output, leaving the given multicast groups and closing
+// the socket if it was the last output.
+func (r *receiver) detach(out *dmx.Output, joined []uint16) {
+	delete(r.outputs, out)
+
+	for _, universe := range joined {
+		r.groups[universe]--
+		if r.groups[universe] == 0 {
+			delete(r.groups, universe)
+			leaveGroup(r.conn, MulticastAddr(universe).IP)
+		}
+	}
+
+	if len(r.outputs) == 0 {
+		r.conn.Close()
+		r.conn = nil
+		r.universes = make(map[uint16]*universeState)
+	}
+}
+
+func (r *receiver) read(conn *net.UDPConn, done chan struct{}) {
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			r.mu.Lock()
+			if r.conn == conn {
+				// The socket failed rather than being closed by us. Let the
+				// next output that attaches reopen it.
+				r.err = fmt.Errorf("failed to receive E1.31: %w", err)
+				r.conn = nil
+				r.outputs = make(map[*dmx.Output]struct{})
+				r.groups = make(map[uint16]int)
+				r.universes = make(map[uint16]*universeState)
+				conn.Close()
+			}
+			r.mu.Unlock()
+			close(done)
+			return
+		}
+
+		p, err := ParseDataPacket(buf[:n])
+		if err != nil {
+			// Not everything sent to the port is for us, and
+			// synchronization and discovery packets are not used.
+			continue
+		}
+
+		r.mu.Lock()
+		r.handle(p, time.Now())
+		r.mu.Unlock()
+	}
+}
+
+// universeState tracks the sources sending a universe.
+type universeState struct {
+	sources map[[16]byte]*sourceState
+}
+
+type sourceState struct {
+	priority uint8
+	sequence uint8
+	seen     time.Time
+}
+
+// handle merges a data packet into its universe. Only the sources with the
+// highest priority are drawn. If several sources share it, then the latest
+// packet wins.
+func (r *receiver) handle(p DataPacket, now time.Time) {
+	if p.StartCode != 0 || p.Options&PreviewData != 0 {
+		return
+	}
+
+	var covered bool
+	for out := range r.outputs {
+		covered = covered || out.Covers(p.Universe)
+	}
+	if !covered {
+		return
+	}
+
+	u, ok := r.universes[p.Universe]
+	if !ok {
+		u = &universeState{sources: make(map[[16]byte]*sourceState)}
+		r.universes[p.Universe] = u
+	}
+
+	src, ok := u.sources[p.CID]
+	if ok {
+		// Drop packets that arrive out of order, unless the sequence jumped
+		// far enough that the source probably restarted.
+		if d := int8(p.Sequence - src.sequence); d <= 0 && d > -20 {
+			return
+		}
+	}
+
+	if p.Options&StreamTerminated != 0 {
+		delete(u.sources, p.CID)
+		return
+	}
+
+	if !ok {
+		src = &sourceState{}
+		u.sources[p.CID] = src
+	}
+	src.priority = p.Priority
+	src.sequence = p.Sequence
+	src.seen = now
+
+	var highest uint8
+	for cid, other := range u.sources {
+		if now.Sub(other.seen) >= SourceTimeout {
+			delete(u.sources, cid)
+			continue
+		}
+		if other.priority > highest {
+			highest = other.priority
+		}
+	}
+
+	if p.Priority < highest {
+		return
+	}
+
+	for out := range r.outputs {
+		out.Update(p.Universe, p.Data)
+	}
+}
@@ -0,0 +1,256 @@
+package hass
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// MQTT 3.1.1 packet types.
+const (
+	packetConnect    = 1
+	packetConnAck    = 2
+	packetPublish    = 3
+	packetPubAck     = 4
+	packetSubscribe  = 8
+	packetSubAck     = 9
+	packetPingReq    = 12
+	packetPingResp   = 13
+	packetDisconnect = 14
+)
+
+// broker is a stand-in for an MQTT broker. It speaks just enough MQTT 3.1.1
+// for the client: retained messages, wills and QoS 1 publishes from clients.
+// Messages are delivered to subscribers with QoS 0.
+type broker struct {
+	t        *testing.T
+	listener net.Listener
+
+	mu       sync.Mutex
+	retained map[string][]byte
+	subs     map[*brokerConn][]string
+	changed  chan struct{}
+}
+
+type brokerConn struct {
+	net.Conn
+	mu sync.Mutex
+}
+
+type message struct {
+	topic   string
+	payload []byte
+	retain  bool
+}
+
+func newBroker(t *testing.T) *broker {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &broker{
+		t:        t,
+		listener: l,
+		retained: make(map[string][]byte),
+		subs:     make(map[*brokerConn][]string),
+		changed:  make(chan struct{}, 1),
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(&brokerConn{Conn: conn})
+		}
+	}()
+
+	return b
+}
+
+// URL returns the URL of the broker.
+func (b *broker) URL() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+// Retained returns the retained message of the topic, if any.
+func (b *broker) Retained(topic string) ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	payload, ok := b.retained[topic]
+	return payload, ok
+}
+
+// Publish publishes a message to the subscribers.
+func (b *broker) Publish(topic string, payload []byte) {
+	b.publish(message{topic: topic, payload: payload})
+}
+
+func (b *broker) publish(msg message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if msg.retain {
+		if len(msg.payload) == 0 {
+			delete(b.retained, msg.topic)
+		} else {
+			b.retained[msg.topic] = msg.payload
+		}
+	}
+
+	for conn, filters := range b.subs {
+		for _, filter := range filters {
+			if topicMatches(filter, msg.topic) {
+				conn.send(msg)
+				break
+			}
+		}
+	}
+
+	select {
+	case b.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (b *broker) serve(conn *brokerConn) {
+	defer conn.Close()
+
+	var will *message
+	defer func() {
+		b.mu.Lock()
+		delete(b.subs, conn)
+		b.mu.Unlock()
+		if will != nil {
+			b.publish(*will)
+		}
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		header, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case packetConnect:
+			will = parseConnect(body)
+			conn.write(packetConnAck<<4, []byte{0, 0})
+
+		case packetPublish:
+			qos := header >> 1 & 3
+			topic, rest := readString(body)
+			if qos > 0 {
+				conn.write(packetPubAck<<4, rest[:2])
+				rest = rest[2:]
+			}
+			b.publish(message{topic: topic, payload: rest, retain: header&1 != 0})
+
+		case packetSubscribe:
+			id, rest := body[:2], body[2:]
+			var filters []string
+			ack := append([]byte(nil), id...)
+			for len(rest) > 0 {
+				var filter string
+				filter, rest = readString(rest)
+				rest = rest[1:] // QoS
+				filters = append(filters, filter)
+				ack = append(ack, 0)
+			}
+			conn.write(packetSubAck<<4, ack)
+
+			b.mu.Lock()
+			b.subs[conn] = append(b.subs[conn], filters...)
+			for topic, payload := range b.retained {
+				for _, filter := range filters {
+					if topicMatches(filter, topic) {
+						conn.send(message{topic: topic, payload: payload, retain: true})
+						break
+					}
+				}
+			}
+			b.mu.Unlock()
+
+		case packetPingReq:
+			conn.write(packetPingResp<<4, nil)
+
+		case packetDisconnect:
+			will = nil
+			return
+		}
+	}
+}
+
+// parseConnect returns the will of a CONNECT packet, if any.
+func parseConnect(body []byte) *message {
+	_, rest := readString(body) // protocol name
+	level, flags := rest[0], rest[1]
+	_ = level
+	rest = rest[4:]            // level, flags and keep alive
+	_, rest = readString(rest) // client ID
+	if flags&0x04 == 0 {
+		return nil
+	}
+	topic, rest := readString(rest)
+	payload, _ := readString(rest)
+	return &message{topic: topic, payload: []byte(payload), retain: flags&0x20 != 0}
+}
+
+func readString(b []byte) (string, []byte) {
+	n := int(binary.BigEndian.Uint16(b))
+	return string(b[2 : 2+n]), b[2+n:]
+}
+
+func (c *brokerConn) send(msg message) {
+	var body []byte
+	body = binary.BigEndian.AppendUint16(body, uint16(len(msg.topic)))
+	body = append(body, msg.topic...)
+	body = append(body, msg.payload...)
+
+	header := byte(packetPublish << 4)
+	if msg.retain {
+		header |= 1
+	}
+	c.write(header, body)
+}
+
+func (c *brokerConn) write(header byte, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := []byte{header}
+	b = binary.AppendUvarint(b, uint64(len(body)))
+	c.Write(append(b, body...))
+}
+
+// topicMatches returns true if the topic matches the filter, which may have
+// wildcards.
+func topicMatches(filter, topic string) bool {
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+	for i, part := range f {
+		if part == "#" {
+			return true
+		}
+		if i >= len(t) || (part != "+" && part != t[i]) {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
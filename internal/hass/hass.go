@@ -0,0 +1,263 @@
+// Package hass integrates with Home Assistant over MQTT. Lights are announced
+// through MQTT discovery, take commands on their command topics and publish
+// their state.
+package hass
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/pkg/errors"
+)
+
+// Controller is what the lights control.
+type Controller interface {
+	// Lights returns the state of the lights.
+	Lights() []Light
+	// Effects returns the effects that lights can show.
+	Effects() []string
+	// UpdateLight applies a command to the light with the given name.
+	UpdateLight(name string, cmd Command) error
+	// Watch returns a channel that is signaled whenever the state of the
+	// lights changes, until the context is canceled.
+	Watch(ctx context.Context) <-chan struct{}
+}
+
+// Config configures the MQTT client.
+type Config struct {
+	// Broker is the URL of the broker, such as "tcp://localhost:1883".
+	Broker string
+	// Username and Password authenticate with the broker, if set.
+	Username string
+	Password string
+	// ID identifies the device in topics and unique IDs. It is also the
+	// client ID.
+	ID string
+	// Name is the name of the device in Home Assistant.
+	Name string
+	// Topic is the topic that the topics of the lights are under.
+	Topic string
+	// DiscoveryPrefix is the prefix of Home Assistant's discovery topics.
+	DiscoveryPrefix string
+	// OnError is called with errors that don't stop the client, such as
+	// invalid commands or a lost connection. It may be nil.
+	OnError func(error)
+}
+
+// Defaults.
+const (
+	DefaultBroker          = "tcp://localhost:1883"
+	DefaultID              = "catglow"
+	DefaultDiscoveryPrefix = "homeassistant"
+)
+
+func (c Config) withDefaults() Config {
+	if c.Broker == "" {
+		c.Broker = DefaultBroker
+	}
+	if c.ID == "" {
+		c.ID = DefaultID
+	}
+	c.ID = objectID(c.ID)
+	if c.Name == "" {
+		c.Name = c.ID
+	}
+	if c.Topic == "" {
+		c.Topic = "catglow/" + c.ID
+	}
+	c.Topic = strings.TrimSuffix(c.Topic, "/")
+	if c.DiscoveryPrefix == "" {
+		c.DiscoveryPrefix = DefaultDiscoveryPrefix
+	}
+	if c.OnError == nil {
+		c.OnError = func(error) {}
+	}
+	return c
+}
+
+const (
+	online  = "online"
+	offline = "offline"
+	// qos is the QoS of everything that is published and subscribed to.
+	qos = 1
+)
+
+// Run connects to the broker and keeps the lights in sync with Home
+// Assistant until the context is canceled. Connecting is retried until it
+// succeeds, and the client reconnects if the connection is lost.
+func Run(ctx context.Context, cfg Config, ctrl Controller) error {
+	cfg = cfg.withDefaults()
+
+	c := &client{
+		cfg:       cfg,
+		ctrl:      ctrl,
+		published: make(map[string]string),
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetWill(c.availabilityTopic(), offline, qos, true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetAutoReconnect(true).
+		SetOrderMatters(false).
+		SetOnConnectHandler(c.onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			cfg.OnError(errors.Wrap(err, "lost connection to the MQTT broker"))
+		})
+
+	c.client = mqtt.NewClient(opts)
+
+	changes := ctrl.Watch(ctx)
+
+	token := c.client.Connect()
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			return errors.Wrap(err, "failed to connect to the MQTT broker")
+		}
+	case <-ctx.Done():
+	}
+
+	defer func() {
+		if c.client.IsConnected() {
+			c.client.Publish(c.availabilityTopic(), qos, true, offline).WaitTimeout(time.Second)
+		}
+		c.client.Disconnect(250)
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changes:
+			c.publishStates(false)
+		}
+	}
+}
+
+type client struct {
+	cfg    Config
+	ctrl   Controller
+	client mqtt.Client
+
+	mu sync.Mutex
+	// published is the last state payload published to each topic.
+	published map[string]string
+}
+
+func (c *client) availabilityTopic() string {
+	return c.cfg.Topic + "/status"
+}
+
+func (c *client) lightTopic(name, suffix string) string {
+	return c.cfg.Topic + "/" + objectID(name) + "/" + suffix
+}
+
+func (c *client) onConnect(mqtt.Client) {
+	c.check("subscribe to commands", c.client.Subscribe(c.cfg.Topic+"/+/set", qos, c.onCommand))
+	// Home Assistant announces when it comes online, after which it needs
+	// the discovery configs again.
+	c.check("subscribe to Home Assistant's status", c.client.Subscribe(c.cfg.DiscoveryPrefix+"/status", qos, c.onStatus))
+
+	c.publishDiscovery()
+	c.check("publish availability", c.client.Publish(c.availabilityTopic(), qos, true, online))
+	c.publishStates(true)
+}
+
+func (c *client) onStatus(_ mqtt.Client, msg mqtt.Message) {
+	if string(msg.Payload()) == online {
+		c.publishDiscovery()
+		c.publishStates(true)
+	}
+}
+
+func (c *client) onCommand(_ mqtt.Client, msg mqtt.Message) {
+	id := strings.TrimSuffix(strings.TrimPrefix(msg.Topic(), c.cfg.Topic+"/"), "/set")
+
+	for _, light := range c.ctrl.Lights() {
+		if objectID(light.Name) != id {
+			continue
+		}
+
+		cmd, err := parseCommand(msg.Payload())
+		if err != nil {
+			c.cfg.OnError(fmt.Errorf("invalid command for light %q: %w", light.Name, err))
+			return
+		}
+		if err := c.ctrl.UpdateLight(light.Name, cmd); err != nil {
+			c.cfg.OnError(fmt.Errorf("failed to update light %q: %w", light.Name, err))
+		}
+		return
+	}
+
+	c.cfg.OnError(fmt.Errorf("command for unknown light %q", id))
+}
+
+// publishDiscovery publishes the discovery config of each light.
+func (c *client) publishDiscovery() {
+	effects := c.ctrl.Effects()
+	device := deviceConfig{
+		Identifiers:  []string{"catglow_" + c.cfg.ID},
+		Name:         c.cfg.Name,
+		Manufacturer: "catglow",
+		Model:        "LED strip",
+	}
+
+	for _, light := range c.ctrl.Lights() {
+		id := objectID(light.Name)
+		config, _ := json.Marshal(lightConfig{
+			Name:                light.Name,
+			UniqueID:            "catglow_" + c.cfg.ID + "_" + id,
+			ObjectID:            c.cfg.ID + "_" + id,
+			Schema:              "json",
+			CommandTopic:        c.lightTopic(light.Name, "set"),
+			StateTopic:          c.lightTopic(light.Name, "state"),
+			AvailabilityTopic:   c.availabilityTopic(),
+			Brightness:          true,
+			BrightnessScale:     255,
+			SupportedColorModes: []string{"rgb"},
+			Effect:              true,
+			EffectList:          effects,
+			Device:              device,
+		})
+
+		topic := fmt.Sprintf("%s/light/%s/%s/config", c.cfg.DiscoveryPrefix, c.cfg.ID, id)
+		c.check("publish discovery", c.client.Publish(topic, qos, true, config))
+	}
+}
+
+// publishStates publishes the state of the lights that changed since they
+// were last published, or of all lights if force is true.
+func (c *client) publishStates(force bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, light := range c.ctrl.Lights() {
+		topic := c.lightTopic(light.Name, "state")
+		state := string(marshalState(light))
+		if !force && c.published[topic] == state {
+			continue
+		}
+		c.published[topic] = state
+		c.check("publish state", c.client.Publish(topic, qos, true, state))
+	}
+}
+
+// check reports the error of the token once it completes, without blocking.
+func (c *client) check(what string, token mqtt.Token) {
+	go func() {
+		<-token.Done()
+		if err := token.Error(); err != nil {
+			c.cfg.OnError(fmt.Errorf("failed to %s: %w", what, err))
+		}
+	}()
+}
@@ -0,0 +1,195 @@
+package hass
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/led"
+)
+
+type fakeController struct {
+	mu      sync.Mutex
+	lights  []Light
+	changed chan struct{}
+}
+
+func newFakeController() *fakeController {
+	return &fakeController{
+		lights: []Light{
+			{Name: "desk", On: true, Brightness: 1, Color: led.RGBColor{255, 255, 255}, Effect: "solid"},
+			{Name: "Shelf Top", On: true, Brightness: 0.5, Effect: "rainbow"},
+		},
+		changed: make(chan struct{}, 1),
+	}
+}
+
+func (c *fakeController) Lights() []Light {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Light(nil), c.lights...)
+}
+
+func (c *fakeController) Effects() []string { return []string{"solid", "rainbow"} }
+
+func (c *fakeController) UpdateLight(name string, cmd Command) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.lights {
+		l := &c.lights[i]
+		if l.Name != name {
+			continue
+		}
+		if cmd.On != nil {
+			l.On = *cmd.On
+		}
+		if cmd.Brightness != nil {
+			l.Brightness = *cmd.Brightness
+		}
+		if cmd.Color != nil {
+			l.Color = *cmd.Color
+		}
+		if cmd.Effect != nil {
+			l.Effect = *cmd.Effect
+		}
+	}
+
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (c *fakeController) Watch(ctx context.Context) <-chan struct{} {
+	return c.changed
+}
+
+// waitRetained waits until the retained message of the topic satisfies f.
+func waitRetained(t *testing.T, ctx context.Context, b *broker, topic string, f func([]byte) bool) {
+	t.Helper()
+
+	for {
+		payload, ok := b.Retained(topic)
+		if ok && f(payload) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for %s, last got %q", topic, payload)
+		case <-b.changed:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func stateIs(want map[string]any) func([]byte) bool {
+	return func(payload []byte) bool {
+		var got map[string]any
+		if err := json.Unmarshal(payload, &got); err != nil {
+			return false
+		}
+		for k, v := range want {
+			if !reflect.DeepEqual(got[k], v) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func TestRun(t *testing.T) {
+	b := newBroker(t)
+	ctrl := newFakeController()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	runCtx, stop := context.WithCancel(ctx)
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- Run(runCtx, Config{
+			Broker:  b.URL(),
+			ID:      "test",
+			OnError: func(err error) { t.Log(err) },
+		}, ctrl)
+	}()
+
+	waitRetained(t, ctx, b, "catglow/test/status", func(p []byte) bool { return string(p) == online })
+
+	var config lightConfig
+	waitRetained(t, ctx, b, "homeassistant/light/test/shelf_top/config", func(p []byte) bool {
+		return json.Unmarshal(p, &config) == nil
+	})
+	if config.CommandTopic != "catglow/test/shelf_top/set" || config.StateTopic != "catglow/test/shelf_top/state" {
+		t.Errorf("got topics %q and %q", config.CommandTopic, config.StateTopic)
+	}
+	if config.Schema != "json" || config.UniqueID != "catglow_test_shelf_top" || config.Name != "Shelf Top" {
+		t.Errorf("unexpected config %+v", config)
+	}
+	if !reflect.DeepEqual(config.EffectList, ctrl.Effects()) {
+		t.Errorf("got effects %v", config.EffectList)
+	}
+
+	waitRetained(t, ctx, b, "catglow/test/shelf_top/state", stateIs(map[string]any{
+		"state":      "ON",
+		"brightness": 128.0,
+		"effect":     "rainbow",
+	}))
+
+	b.Publish("catglow/test/desk/set", []byte(`{"state": "OFF"}`))
+	waitRetained(t, ctx, b, "catglow/test/desk/state", stateIs(map[string]any{"state": "OFF"}))
+
+	b.Publish("catglow/test/desk/set", []byte(`{
+		"state": "ON",
+		"brightness": 51,
+		"color": {"r": 255, "g": 0, "b": 128},
+		"effect": "rainbow"
+	}`))
+	waitRetained(t, ctx, b, "catglow/test/desk/state", stateIs(map[string]any{
+		"state":      "ON",
+		"brightness": 51.0,
+		"color":      map[string]any{"r": 255.0, "g": 0.0, "b": 128.0},
+		"effect":     "rainbow",
+	}))
+
+	// Once Home Assistant restarts, the configs are published again.
+	b.mu.Lock()
+	delete(b.retained, "homeassistant/light/test/desk/config")
+	b.mu.Unlock()
+	b.Publish("homeassistant/status", []byte(online))
+	waitRetained(t, ctx, b, "homeassistant/light/test/desk/config", func([]byte) bool { return true })
+
+	stop()
+	if err := <-runErr; err != nil {
+		t.Errorf("Run failed: %v", err)
+	}
+	waitRetained(t, ctx, b, "catglow/test/status", func(p []byte) bool { return string(p) == offline })
+}
+
+func TestParseCommand(t *testing.T) {
+	cmd, err := parseCommand([]byte(`{"state": "on", "brightness": 300, "color": {"r": 1, "g": 2, "b": 3}, "transition": 2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.On == nil || !*cmd.On {
+		t.Error("state is not on")
+	}
+	if cmd.Brightness == nil || *cmd.Brightness != 1 {
+		t.Errorf("got brightness %v, want 1", cmd.Brightness)
+	}
+	if cmd.Color == nil || *cmd.Color != (led.RGBColor{1, 2, 3}) {
+		t.Errorf("got color %v", cmd.Color)
+	}
+	if cmd.Transition == nil || *cmd.Transition != 2 || cmd.Effect != nil {
+		t.Errorf("got transition %v and effect %v", cmd.Transition, cmd.Effect)
+	}
+
+	if _, err := parseCommand([]byte(`{"state": "maybe"}`)); err == nil {
+		t.Error("expected an error for an invalid state")
+	}
+}
@@ -0,0 +1,150 @@
+package hass
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"libdb.so/catglow/internal/led"
+)
+
+// Light is the state of a light entity.
+type Light struct {
+	// Name is the name of the light, which must be unique.
+	Name string
+	// On is true if the light is on.
+	On bool
+	// Brightness is the brightness of the light within [0, 1].
+	Brightness float64
+	// Color is the color of the light.
+	Color led.RGBColor
+	// Effect is the effect that the light shows, which is one of the effects
+	// of the controller.
+	Effect string
+}
+
+// Command is a command sent to a light. Fields that are nil are unchanged.
+type Command struct {
+	On         *bool
+	Brightness *float64
+	Color      *led.RGBColor
+	Effect     *string
+	// Transition is the transition in seconds, if any.
+	Transition *float64
+}
+
+// lightConfig is the discovery config of a light using the JSON schema.
+type lightConfig struct {
+	Name                string       `json:"name"`
+	UniqueID            string       `json:"unique_id"`
+	ObjectID            string       `json:"object_id"`
+	Schema              string       `json:"schema"`
+	CommandTopic        string       `json:"command_topic"`
+	StateTopic          string       `json:"state_topic"`
+	AvailabilityTopic   string       `json:"availability_topic"`
+	Brightness          bool         `json:"brightness"`
+	BrightnessScale     int          `json:"brightness_scale"`
+	SupportedColorModes []string     `json:"supported_color_modes"`
+	Effect              bool         `json:"effect"`
+	EffectList          []string     `json:"effect_list"`
+	Device              deviceConfig `json:"device"`
+}
+
+type deviceConfig struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+type rgb struct {
+	R int `json:"r"`
+	G int `json:"g"`
+	B int `json:"b"`
+}
+
+const (
+	stateOn  = "ON"
+	stateOff = "OFF"
+)
+
+// lightState is the state payload of a light, which is also the payload of
+// commands.
+type lightState struct {
+	State      string   `json:"state"`
+	Brightness *int     `json:"brightness,omitempty"`
+	ColorMode  string   `json:"color_mode,omitempty"`
+	Color      *rgb     `json:"color,omitempty"`
+	Effect     *string  `json:"effect,omitempty"`
+	Transition *float64 `json:"transition,omitempty"`
+}
+
+func marshalState(l Light) []byte {
+	state := stateOff
+	if l.On {
+		state = stateOn
+	}
+	brightness := int(math.Round(math.Max(0, math.Min(1, l.Brightness)) * 255))
+	effect := l.Effect
+
+	b, _ := json.Marshal(lightState{
+		State:      state,
+		Brightness: &brightness,
+		ColorMode:  "rgb",
+		Color:      &rgb{int(l.Color[0]), int(l.Color[1]), int(l.Color[2])},
+		Effect:     &effect,
+	})
+	return b
+}
+
+func parseCommand(b []byte) (Command, error) {
+	var s lightState
+	if err := json.Unmarshal(b, &s); err != nil {
+		return Command{}, err
+	}
+
+	var cmd Command
+	switch strings.ToUpper(s.State) {
+	case "":
+	case stateOn:
+		on := true
+		cmd.On = &on
+	case stateOff:
+		on := false
+		cmd.On = &on
+	default:
+		return Command{}, fmt.Errorf("invalid state %q", s.State)
+	}
+
+	if s.Brightness != nil {
+		brightness := math.Max(0, math.Min(255, float64(*s.Brightness))) / 255
+		cmd.Brightness = &brightness
+	}
+	if s.Color != nil {
+		color := led.RGBColor{clampByte(s.Color.R), clampByte(s.Color.G), clampByte(s.Color.B)}
+		cmd.Color = &color
+	}
+	cmd.Effect = s.Effect
+	cmd.Transition = s.Transition
+
+	return cmd, nil
+}
+
+func clampByte(v int) uint8 {
+	return uint8(math.Max(0, math.Min(255, float64(v))))
+}
+
+// objectID turns a name into something that can be used in topics and IDs.
+func objectID(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', '0' <= r && r <= '9', r == '_', r == '-':
+			return r
+		case 'A' <= r && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '_'
+		}
+	}, name)
+}
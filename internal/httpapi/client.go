@@ -0,0 +1,54 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Notify sends the notification to the API served at the given base URL,
+// such as "http://127.0.0.1:8080".
+func Notify(ctx context.Context, baseURL string, n Notification) error {
+	req := notificationJSON{
+		Color:    n.Color,
+		Count:    n.Count,
+		Priority: n.Priority,
+	}
+	if n.Duration != 0 {
+		req.Duration = n.Duration.String()
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	url := strings.TrimSuffix(baseURL, "/") + "/api/notify"
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return errors.New(apiErr.Error)
+		}
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
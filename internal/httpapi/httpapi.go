@@ -0,0 +1,497 @@
+// Package httpapi serves a JSON API over HTTP for controlling the daemon,
+// along with a stream of the live frames and a small web UI.
+package httpapi
+
+import (
+	"context"
+	"embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"libdb.so/catglow/internal/led"
+)
+
+// Controller is what the API controls.
+type Controller interface {
+	// NumLEDs returns the number of LEDs on the strip.
+	NumLEDs() int
+
+	// Scene returns the name of the current scene.
+	Scene() string
+	// Scenes returns the names of all scenes.
+	Scenes() []string
+	// SetScene switches to the scene with the given name.
+	SetScene(name string) error
+
+	// On returns true if the strip is on.
+	On() bool
+	// SetOn turns the strip on or off.
+	SetOn(on bool, transition time.Duration)
+	// Brightness returns the brightness of the strip within [0, 1].
+	Brightness() float64
+	// SetBrightness sets the brightness of the strip within [0, 1].
+	SetBrightness(brightness float64, transition time.Duration)
+	// Realtime returns true if an external source draws the strip.
+	Realtime() bool
+
+	// Segments returns the segments of the strip.
+	Segments() []Segment
+	// UpdateSegment changes the segment with the given name.
+	UpdateSegment(name string, update func(*Segment)) error
+	// Effects returns the effects that segments can show.
+	Effects() []string
+
+	// Notify flashes the strip over whatever it shows.
+	Notify(n Notification) error
+
+	// Watch returns a channel that is signaled whenever the state changes,
+	// until the context is canceled.
+	Watch(ctx context.Context) <-chan struct{}
+	// WatchFrames returns a channel that receives the frames shown on the
+	// strip until the context is canceled.
+	WatchFrames(ctx context.Context) <-chan led.LEDs
+}
+
+// Segment is a segment of the strip.
+type Segment struct {
+	Name       string         `json:"name"`
+	Range      [2]int         `json:"range"`
+	On         bool           `json:"on"`
+	Brightness float64        `json:"brightness"`
+	Effect     string         `json:"effect"`
+	Colors     []led.RGBColor `json:"colors"`
+}
+
+// Notification flashes the strip over whatever it shows.
+type Notification struct {
+	Color led.RGBColor
+	// Count is how many times to flash, or zero for the default.
+	Count int
+	// Duration is how long each flash takes, or zero for the default.
+	Duration time.Duration
+	// Priority decides which notification is shown first.
+	Priority int
+}
+
+// notificationJSON is a Notification in the API.
+type notificationJSON struct {
+	Color led.RGBColor `json:"color"`
+	Count int          `json:"count,omitempty"`
+	// Duration is a duration such as "500ms".
+	Duration string `json:"duration,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+// Status is the state of the daemon.
+type Status struct {
+	NumLEDs    int       `json:"num_leds"`
+	Scene      string    `json:"scene"`
+	Scenes     []string  `json:"scenes"`
+	On         bool      `json:"on"`
+	Brightness float64   `json:"brightness"`
+	Realtime   bool      `json:"realtime"`
+	Segments   []Segment `json:"segments"`
+	Effects    []string  `json:"effects"`
+}
+
+// DefaultFrameRate is the rate that frames are streamed at unless the client
+// asks for another one.
+const DefaultFrameRate = 30
+
+//go:embed static
+var static embed.FS
+
+// Server serves the API.
+type Server struct {
+	ctrl Controller
+	mux  *http.ServeMux
+}
+
+var _ http.Handler = (*Server)(nil)
+
+// NewServer creates a new server for the controller.
+func NewServer(ctrl Controller) *Server {
+	s := &Server{
+		ctrl: ctrl,
+		mux:  http.NewServeMux(),
+	}
+
+	staticFS, _ := fs.Sub(static, "static")
+	s.mux.Handle("/", http.FileServer(http.FS(staticFS)))
+	s.mux.HandleFunc("/api/status", s.handleStatus)
+	s.mux.HandleFunc("/api/scene", s.handleScene)
+	s.mux.HandleFunc("/api/brightness", s.handleBrightness)
+	s.mux.HandleFunc("/api/segments", s.handleSegments)
+	s.mux.HandleFunc("/api/segments/", s.handleSegment)
+	s.mux.HandleFunc("/api/notify", s.handleNotify)
+	s.mux.HandleFunc("/api/events", s.handleEvents)
+
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The API has no authentication, so requests that change anything must
+	// not come from other sites that the user happens to visit.
+	if !safeMethod(r.Method) && !sameOrigin(r) {
+		writeError(w, http.StatusForbidden, errors.New("cross-origin requests are not allowed"))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// sameOrigin returns true if the request has no Origin header, such as from
+// curl, or if it comes from a page served by the API itself.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return u.Host == r.Host
+}
+
+// ListenAndServe serves the API on the given address until the context is
+// canceled.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen")
+	}
+
+	server := &http.Server{
+		Handler:     s,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	if err := server.Serve(l); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// Status returns the current state.
+func (s *Server) Status() Status {
+	return Status{
+		NumLEDs:    s.ctrl.NumLEDs(),
+		Scene:      s.ctrl.Scene(),
+		Scenes:     s.ctrl.Scenes(),
+		On:         s.ctrl.On(),
+		Brightness: s.ctrl.Brightness(),
+		Realtime:   s.ctrl.Realtime(),
+		Segments:   s.ctrl.Segments(),
+		Effects:    s.ctrl.Effects(),
+	}
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, s.Status())
+}
+
+type sceneJSON struct {
+	Scene  string   `json:"scene"`
+	Scenes []string `json:"scenes,omitempty"`
+}
+
+func (s *Server) handleScene(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var req sceneJSON
+		if !readJSON(w, r, &req) {
+			return
+		}
+		if err := s.ctrl.SetScene(req.Scene); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	writeJSON(w, sceneJSON{s.ctrl.Scene(), s.ctrl.Scenes()})
+}
+
+type brightnessJSON struct {
+	On         bool    `json:"on"`
+	Brightness float64 `json:"brightness"`
+}
+
+type brightnessRequest struct {
+	On         *bool    `json:"on"`
+	Brightness *float64 `json:"brightness"`
+	// Transition is a duration such as "500ms".
+	Transition string `json:"transition"`
+}
+
+func (s *Server) handleBrightness(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var req brightnessRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+
+		var transition time.Duration
+		if req.Transition != "" {
+			var err error
+			transition, err = time.ParseDuration(req.Transition)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		if req.Brightness != nil {
+			if *req.Brightness < 0 || *req.Brightness > 1 {
+				writeError(w, http.StatusBadRequest, errors.New("brightness must be within [0, 1]"))
+				return
+			}
+			s.ctrl.SetBrightness(*req.Brightness, transition)
+		}
+		if req.On != nil {
+			s.ctrl.SetOn(*req.On, transition)
+		}
+	}
+
+	writeJSON(w, brightnessJSON{s.ctrl.On(), s.ctrl.Brightness()})
+}
+
+func (s *Server) handleSegments(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, s.ctrl.Segments())
+}
+
+// segmentRequest changes a segment. Fields that are not set are unchanged.
+type segmentRequest struct {
+	On         *bool           `json:"on"`
+	Brightness *float64        `json:"brightness"`
+	Effect     *string         `json:"effect"`
+	Colors     *[]led.RGBColor `json:"colors"`
+}
+
+func (s *Server) handleSegment(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/api/segments/")
+
+	if r.Method == http.MethodPut {
+		var req segmentRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		if req.Brightness != nil && (*req.Brightness < 0 || *req.Brightness > 1) {
+			writeError(w, http.StatusBadRequest, errors.New("brightness must be within [0, 1]"))
+			return
+		}
+
+		err := s.ctrl.UpdateSegment(name, func(segment *Segment) {
+			if req.On != nil {
+				segment.On = *req.On
+			}
+			if req.Brightness != nil {
+				segment.Brightness = *req.Brightness
+			}
+			if req.Effect != nil {
+				segment.Effect = *req.Effect
+			}
+			if req.Colors != nil {
+				segment.Colors = *req.Colors
+			}
+		})
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	for _, segment := range s.ctrl.Segments() {
+		if segment.Name == name {
+			writeJSON(w, segment)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, fmt.Errorf("unknown segment %q", name))
+}
+
+func (s *Server) handleNotify(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	var req notificationJSON
+	if !readJSON(w, r, &req) {
+		return
+	}
+
+	n := Notification{
+		Color:    req.Color,
+		Count:    req.Count,
+		Priority: req.Priority,
+	}
+	if req.Duration != "" {
+		var err error
+		n.Duration, err = time.ParseDuration(req.Duration)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if err := s.ctrl.Notify(n); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleEvents streams Server-Sent Events: "status" events with the Status
+// whenever it changes, and "frame" events with the base64 RGB bytes of the
+// strip at the rate given by the fps query parameter.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	fps := DefaultFrameRate
+	if v := r.URL.Query().Get("fps"); v != "" {
+		var err error
+		fps, err = strconv.Atoi(v)
+		if err != nil || fps <= 0 {
+			writeError(w, http.StatusBadRequest, errors.New("invalid fps"))
+			return
+		}
+	}
+	interval := time.Second / time.Duration(fps)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	ctx := r.Context()
+	changes := s.ctrl.Watch(ctx)
+	frames := s.ctrl.WatchFrames(ctx)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	writeEvent := func(event string, data []byte) bool {
+		_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		flusher.Flush()
+		return err == nil
+	}
+
+	status, _ := json.Marshal(s.Status())
+	if !writeEvent("status", status) {
+		return
+	}
+
+	var lastFrame time.Time
+	buf := make([]byte, 0, 3*s.ctrl.NumLEDs())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-changes:
+			status, _ := json.Marshal(s.Status())
+			if !writeEvent("status", status) {
+				return
+			}
+
+		case leds := <-frames:
+			now := time.Now()
+			if now.Sub(lastFrame) < interval {
+				continue
+			}
+			lastFrame = now
+
+			buf = buf[:0]
+			for _, c := range leds {
+				buf = append(buf, c[0], c[1], c[2])
+			}
+			if !writeEvent("frame", []byte(base64.StdEncoding.EncodeToString(buf))) {
+				return
+			}
+		}
+	}
+}
+
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	return false
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	// Browsers send requests of other types, such as text/plain, to other
+	// sites without asking first.
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, errors.New("content type must be application/json"))
+		return false
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, errors.Wrap(err, "invalid JSON"))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,327 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/led"
+)
+
+type fakeController struct {
+	mu         sync.Mutex
+	scene      string
+	on         bool
+	brightness float64
+	transition time.Duration
+	segments   []Segment
+	notified   []Notification
+	changed    chan struct{}
+	frames     chan led.LEDs
+}
+
+func newFakeController() *fakeController {
+	return &fakeController{
+		scene:      "music",
+		on:         true,
+		brightness: 1,
+		segments: []Segment{
+			{Name: "desk", Range: [2]int{0, 2}, On: true, Brightness: 1, Effect: "scene"},
+		},
+		changed: make(chan struct{}, 1),
+		frames:  make(chan led.LEDs, 1),
+	}
+}
+
+func (c *fakeController) NumLEDs() int        { return 2 }
+func (c *fakeController) Scenes() []string    { return []string{"music", "off"} }
+func (c *fakeController) Effects() []string   { return []string{"solid", "scene"} }
+func (c *fakeController) Realtime() bool      { return false }
+func (c *fakeController) Scene() string       { c.mu.Lock(); defer c.mu.Unlock(); return c.scene }
+func (c *fakeController) On() bool            { c.mu.Lock(); defer c.mu.Unlock(); return c.on }
+func (c *fakeController) Brightness() float64 { c.mu.Lock(); defer c.mu.Unlock(); return c.brightness }
+
+func (c *fakeController) SetScene(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if name != "music" && name != "off" {
+		return fmt.Errorf("unknown scene %q", name)
+	}
+	c.scene = name
+	c.notify()
+	return nil
+}
+
+func (c *fakeController) SetOn(on bool, transition time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.on = on
+	c.transition = transition
+	c.notify()
+}
+
+func (c *fakeController) SetBrightness(brightness float64, transition time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.brightness = brightness
+	c.transition = transition
+	c.notify()
+}
+
+func (c *fakeController) Segments() []Segment {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Segment(nil), c.segments...)
+}
+
+func (c *fakeController) UpdateSegment(name string, update func(*Segment)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.segments {
+		if c.segments[i].Name == name {
+			update(&c.segments[i])
+			c.notify()
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown segment %q", name)
+}
+
+func (c *fakeController) Notify(n Notification) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n.Count > 10 {
+		return fmt.Errorf("too many flashes")
+	}
+	c.notified = append(c.notified, n)
+	return nil
+}
+
+func (c *fakeController) notify() {
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (c *fakeController) Watch(ctx context.Context) <-chan struct{}       { return c.changed }
+func (c *fakeController) WatchFrames(ctx context.Context) <-chan led.LEDs { return c.frames }
+
+func do(t *testing.T, s *Server, method, path, body string, wantCode int, v any) {
+	t.Helper()
+
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	if w.Code != wantCode {
+		t.Fatalf("%s %s: got status %d, want %d: %s", method, path, w.Code, wantCode, w.Body)
+	}
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: invalid JSON: %v", method, path, err)
+		}
+	}
+}
+
+func TestAPI(t *testing.T) {
+	ctrl := newFakeController()
+	s := NewServer(ctrl)
+
+	var status Status
+	do(t, s, "GET", "/api/status", "", 200, &status)
+	if status.Scene != "music" || !status.On || status.NumLEDs != 2 || len(status.Segments) != 1 {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	var scene sceneJSON
+	do(t, s, "PUT", "/api/scene", `{"scene": "off"}`, 200, &scene)
+	if scene.Scene != "off" || ctrl.Scene() != "off" {
+		t.Errorf("got scene %q, want off", scene.Scene)
+	}
+	do(t, s, "PUT", "/api/scene", `{"scene": "nope"}`, 400, nil)
+
+	var brightness brightnessJSON
+	do(t, s, "PUT", "/api/brightness", `{"brightness": 0.25, "on": false, "transition": "2s"}`, 200, &brightness)
+	if brightness.Brightness != 0.25 || brightness.On || ctrl.transition != 2*time.Second {
+		t.Errorf("got %+v with transition %v", brightness, ctrl.transition)
+	}
+	do(t, s, "PUT", "/api/brightness", `{"brightness": 2}`, 400, nil)
+	do(t, s, "PUT", "/api/brightness", `{"transition": "soon"}`, 400, nil)
+
+	var segment Segment
+	do(t, s, "PUT", "/api/segments/desk", `{"effect": "solid", "colors": [[255, 0, 0]], "brightness": 0.5}`, 200, &segment)
+	want := Segment{
+		Name: "desk", Range: [2]int{0, 2}, On: true, Brightness: 0.5,
+		Effect: "solid", Colors: []led.RGBColor{{255, 0, 0}},
+	}
+	if !reflect.DeepEqual(segment, want) {
+		t.Errorf("got segment %+v, want %+v", segment, want)
+	}
+
+	var segments []Segment
+	do(t, s, "GET", "/api/segments", "", 200, &segments)
+	if !reflect.DeepEqual(segments, []Segment{want}) {
+		t.Errorf("got segments %+v", segments)
+	}
+
+	do(t, s, "GET", "/api/segments/nope", "", 404, nil)
+	do(t, s, "PUT", "/api/segments/desk", `{"bogus": true}`, 400, nil)
+	do(t, s, "DELETE", "/api/segments/desk", "", 405, nil)
+}
+
+func TestUI(t *testing.T) {
+	s := NewServer(newFakeController())
+
+	r := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	if w.Code != 200 || !strings.Contains(w.Body.String(), `new EventSource("/api/events")`) {
+		t.Errorf("got status %d and an unexpected page", w.Code)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	ctrl := newFakeController()
+	server := httptest.NewServer(NewServer(ctrl))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("got content type %q", ct)
+	}
+
+	r := bufio.NewReader(resp.Body)
+	readEvent := func() (string, string) {
+		t.Helper()
+		var event, data string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "":
+				return event, data
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}
+
+	if event, _ := readEvent(); event != "status" {
+		t.Fatalf("got event %q first, want status", event)
+	}
+
+	ctrl.frames <- led.LEDs{{1, 2, 3}, {4, 5, 6}}
+	event, data := readEvent()
+	if event != "frame" {
+		t.Fatalf("got event %q, want frame", event)
+	}
+	if b, _ := base64.StdEncoding.DecodeString(data); !reflect.DeepEqual(b, []byte{1, 2, 3, 4, 5, 6}) {
+		t.Errorf("got frame %v", b)
+	}
+
+	ctrl.SetScene("off")
+	event, data = readEvent()
+	var status Status
+	if err := json.Unmarshal([]byte(data), &status); event != "status" || err != nil || status.Scene != "off" {
+		t.Errorf("got event %q with %s, want the new status", event, data)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	ctrl := newFakeController()
+	server := httptest.NewServer(NewServer(ctrl))
+	defer server.Close()
+
+	ctx := context.Background()
+	n := Notification{Color: led.RGBColor{255, 0, 0}, Count: 3, Duration: 250 * time.Millisecond, Priority: 1}
+	if err := Notify(ctx, server.URL, n); err != nil {
+		t.Fatal(err)
+	}
+	if err := Notify(ctx, server.URL+"/", Notification{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Notification{n, {}}
+	if !reflect.DeepEqual(ctrl.notified, want) {
+		t.Errorf("got notifications %+v, want %+v", ctrl.notified, want)
+	}
+
+	if err := Notify(ctx, server.URL, Notification{Count: 11}); err == nil || err.Error() != "too many flashes" {
+		t.Errorf("got error %v, want the controller's", err)
+	}
+
+	s := NewServer(ctrl)
+	do(t, s, "POST", "/api/notify", `{"duration": "soon"}`, 400, nil)
+	do(t, s, "GET", "/api/notify", "", 405, nil)
+}
+
+func TestCrossOrigin(t *testing.T) {
+	ctrl := newFakeController()
+	s := NewServer(ctrl)
+
+	for _, test := range []struct {
+		name        string
+		method      string
+		contentType string
+		origin      string
+		wantCode    int
+	}{
+		{"json", "PUT", "application/json", "", 200},
+		{"json with charset", "PUT", "application/json; charset=utf-8", "", 200},
+		{"same origin", "PUT", "application/json", "http://example.com", 200},
+		{"plain text", "PUT", "text/plain", "", 415},
+		{"form", "PUT", "application/x-www-form-urlencoded", "", 415},
+		{"no content type", "PUT", "", "", 415},
+		{"other origin", "PUT", "application/json", "http://evil.example", 403},
+		{"null origin", "PUT", "application/json", "null", 403},
+		{"other origin reading", "GET", "", "http://evil.example", 200},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			body := ""
+			if test.method == "PUT" {
+				body = `{"scene": "music"}`
+			}
+			r := httptest.NewRequest(test.method, "http://example.com/api/scene", strings.NewReader(body))
+			if test.contentType != "" {
+				r.Header.Set("Content-Type", test.contentType)
+			}
+			if test.origin != "" {
+				r.Header.Set("Origin", test.origin)
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+
+			if w.Code != test.wantCode {
+				t.Errorf("got status %d, want %d: %s", w.Code, test.wantCode, w.Body)
+			}
+		})
+	}
+}
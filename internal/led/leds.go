@@ -2,6 +2,7 @@ package led
 
 import (
 	"io"
+	"math"
 	"unsafe"
 )
 
@@ -61,3 +62,87 @@ func (l LEDs) Draw(start int, other LEDs) int {
 	}
 	return len(other)
 }
+
+// Mix linearly interpolates between c and other. A t of 0 returns c, and a t
+// of 1 returns other.
+func (c RGBColor) Mix(other RGBColor, t float64) RGBColor {
+	switch {
+	case t <= 0:
+		return c
+	case t >= 1:
+		return other
+	}
+	return RGBColor{
+		mixChannel(c[0], other[0], t),
+		mixChannel(c[1], other[1], t),
+		mixChannel(c[2], other[2], t),
+	}
+}
+
+func mixChannel(a, b uint8, t float64) uint8 {
+	return uint8(float64(a) + (float64(b)-float64(a))*t + 0.5)
+}
+
+// Blend blends from and to into l by t, where a t of 0 means from and a t of
+// 1 means to. All three strips must have the same length. l may be the same
+// slice as from or to.
+func (l LEDs) Blend(from, to LEDs, t float64) {
+	for i := range l {
+		l[i] = from[i].Mix(to[i], t)
+	}
+}
+
+// Scale scales the brightness of all LEDs by the given factor within [0, 1].
+func (l LEDs) Scale(factor float64) {
+	if factor >= 1 {
+		return
+	}
+	for i := range l {
+		l[i] = RGBColor{}.Mix(l[i], factor)
+	}
+}
+
+// Reverse reverses the order of the LEDs in place.
+func (l LEDs) Reverse() {
+	for i, j := 0, len(l)-1; i < j; i, j = i+1, j-1 {
+		l[i], l[j] = l[j], l[i]
+	}
+}
+
+// HSV returns the color for the given hue, saturation and value. All
+// arguments are within [0, 1]; the hue wraps around.
+func HSV(h, s, v float64) RGBColor {
+	h = math.Mod(h, 1)
+	if h < 0 {
+		h++
+	}
+
+	h *= 6
+	i := math.Floor(h)
+	f := h - i
+	p := v * (1 - s)
+	q := v * (1 - s*f)
+	t := v * (1 - s*(1-f))
+
+	var r, g, b float64
+	switch int(i) {
+	case 0:
+		r, g, b = v, t, p
+	case 1:
+		r, g, b = q, v, p
+	case 2:
+		r, g, b = p, v, t
+	case 3:
+		r, g, b = p, q, v
+	case 4:
+		r, g, b = t, p, v
+	default:
+		r, g, b = v, p, q
+	}
+
+	return RGBColor{toChannel(r), toChannel(g), toChannel(b)}
+}
+
+func toChannel(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(1, v))*255 + 0.5)
+}
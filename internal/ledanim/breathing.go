@@ -0,0 +1,79 @@
+package ledanim
+
+import (
+	"math"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// BreathingFunction is the function that a breathing animation follows.
+type BreathingFunction string
+
+const (
+	// BreathingLinear fades in and out linearly.
+	BreathingLinear BreathingFunction = "linear"
+	// BreathingSine fades in and out following a cosine wave.
+	BreathingSine BreathingFunction = "sine"
+)
+
+// BreathingConfig is the configuration for a breathing animation.
+type BreathingConfig struct {
+	// Color is the color at full brightness.
+	Color led.RGBColor
+	// Frame is the frame at full brightness. If set, then it is used instead
+	// of Color. It must have the same length as the animation.
+	Frame led.LEDs
+	// Period is the duration of one full breath.
+	Period time.Duration
+	// Function is the breathing function. If empty, then BreathingSine is
+	// used.
+	Function BreathingFunction
+}
+
+// Breathing is an animation that slowly fades the strip in and out.
+type Breathing struct {
+	base
+	cfg BreathingConfig
+}
+
+// NewBreathing creates a new breathing animation.
+func NewBreathing(numLEDs int, cfg BreathingConfig, clock clock.Clock) *Breathing {
+	if cfg.Function == "" {
+		cfg.Function = BreathingSine
+	}
+	return &Breathing{
+		base: newBase(numLEDs, clock),
+		cfg:  cfg,
+	}
+}
+
+// AcquireFrame draws the current frame.
+func (b *Breathing) AcquireFrame(f func(led.LEDs)) {
+	if b.cfg.Frame != nil {
+		copy(b.leds, b.cfg.Frame)
+	} else {
+		b.leds.SetRange(0, len(b.leds), b.cfg.Color)
+	}
+	b.leds.Scale(b.intensity(b.elapsed()))
+	f(b.leds)
+}
+
+// intensity returns the brightness after the given elapsed time. The returned
+// value is in the range [0, 1], starting at full brightness.
+func (b *Breathing) intensity(elapsed time.Duration) float64 {
+	if b.cfg.Period <= 0 {
+		return 1
+	}
+
+	halfPeriod := float64(b.cfg.Period / 2)
+
+	switch b.cfg.Function {
+	case BreathingLinear:
+		elapsed %= b.cfg.Period
+		return math.Abs(1 - (float64(elapsed) / halfPeriod))
+	default:
+		return (1 + math.Cos(float64(elapsed)/halfPeriod*math.Pi)) / 2
+	}
+}
@@ -0,0 +1,45 @@
+package ledanim
+
+import (
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// TheaterChase is a pattern of every few LEDs lit, crawling along the strip
+// like theater marquee lights. It uses Speed as the time to move by one LED,
+// Size as the spacing between lit LEDs and Colors as the colors to cycle
+// through every time the lights have moved by one spacing.
+type TheaterChase struct {
+	base
+	cfg PatternConfig
+}
+
+// NewTheaterChase creates a new theater chase pattern.
+func NewTheaterChase(numLEDs int, cfg PatternConfig, clock clock.Clock) *TheaterChase {
+	return &TheaterChase{
+		base: newBase(numLEDs, clock),
+		cfg: cfg.withDefaults(PatternConfig{
+			Speed:  100 * time.Millisecond,
+			Colors: []led.RGBColor{{255, 255, 255}},
+			Size:   3,
+		}),
+	}
+}
+
+// AcquireFrame draws the current frame.
+func (c *TheaterChase) AcquireFrame(f func(led.LEDs)) {
+	step := int(c.steps(c.cfg.Speed))
+	offset := step % c.cfg.Size
+	color := c.cfg.Colors[(step/c.cfg.Size)%len(c.cfg.Colors)]
+
+	for i := range c.leds {
+		if i%c.cfg.Size == offset {
+			c.leds[i] = color
+		} else {
+			c.leds[i] = led.RGBColor{}
+		}
+	}
+	f(c.leds)
+}
@@ -0,0 +1,53 @@
+package ledanim
+
+import (
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// Comet is a pattern of a comet with a smoothly fading tail looping around the
+// strip. It uses Speed as the time to move by one LED, Size as the length of
+// the tail and Colors[0] as the color of the comet.
+type Comet struct {
+	base
+	cfg PatternConfig
+}
+
+// NewComet creates a new comet pattern.
+func NewComet(numLEDs int, cfg PatternConfig, clock clock.Clock) *Comet {
+	return &Comet{
+		base: newBase(numLEDs, clock),
+		cfg: cfg.withDefaults(PatternConfig{
+			Speed:  20 * time.Millisecond,
+			Colors: []led.RGBColor{{255, 255, 255}},
+			Size:   10,
+		}),
+	}
+}
+
+// AcquireFrame draws the current frame.
+func (c *Comet) AcquireFrame(f func(led.LEDs)) {
+	n := float64(len(c.leds))
+	head := c.steps(c.cfg.Speed)
+
+	for i := range c.leds {
+		// Distance behind the head, wrapping around the strip.
+		d := head - float64(i)
+		for d < 0 {
+			d += n
+		}
+		for d >= n {
+			d -= n
+		}
+
+		brightness := 0.0
+		if d < float64(c.cfg.Size) {
+			b := 1 - d/float64(c.cfg.Size)
+			brightness = b * b
+		}
+		c.leds[i] = dim(c.cfg.Colors[0], brightness)
+	}
+	f(c.leds)
+}
@@ -0,0 +1,64 @@
+package ledanim
+
+import (
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// CycleConfig is the configuration for a cycle animation.
+type CycleConfig struct {
+	// Colors is the list of colors to cycle through.
+	Colors []led.RGBColor
+	// Interval is how long each color is shown for.
+	Interval time.Duration
+	// Fade is how long to fade into the next color at the end of each
+	// interval. If zero, then colors are switched instantly.
+	Fade time.Duration
+}
+
+// Cycle is an animation that cycles the whole strip through a list of colors.
+type Cycle struct {
+	base
+	cfg CycleConfig
+}
+
+// NewCycle creates a new cycle animation.
+func NewCycle(numLEDs int, cfg CycleConfig, clock clock.Clock) *Cycle {
+	if cfg.Fade > cfg.Interval {
+		cfg.Fade = cfg.Interval
+	}
+	return &Cycle{
+		base: newBase(numLEDs, clock),
+		cfg:  cfg,
+	}
+}
+
+// AcquireFrame draws the current frame.
+func (c *Cycle) AcquireFrame(f func(led.LEDs)) {
+	c.leds.SetRange(0, len(c.leds), c.color(c.elapsed()))
+	f(c.leds)
+}
+
+func (c *Cycle) color(elapsed time.Duration) led.RGBColor {
+	n := len(c.cfg.Colors)
+	switch {
+	case n == 0:
+		return led.RGBColor{}
+	case n == 1 || c.cfg.Interval <= 0:
+		return c.cfg.Colors[0]
+	}
+
+	i := int(elapsed/c.cfg.Interval) % n
+	current := c.cfg.Colors[i]
+
+	// Fade into the next color during the last part of the interval.
+	fadeStart := c.cfg.Interval - c.cfg.Fade
+	if into := elapsed % c.cfg.Interval; c.cfg.Fade > 0 && into > fadeStart {
+		next := c.cfg.Colors[(i+1)%n]
+		return current.Mix(next, float64(into-fadeStart)/float64(c.cfg.Fade))
+	}
+
+	return current
+}
@@ -0,0 +1,96 @@
+package ledanim
+
+import (
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// Fire is a fire simulation based on Mark Kriegsman's Fire2012. Heat rises
+// from the start of the strip towards the end. It uses Speed as the duration
+// of one simulation step, Density as the chance of a new spark per step and
+// Decay as how much the fire cools down per step.
+type Fire struct {
+	stepped
+	cfg  PatternConfig
+	heat []uint8
+}
+
+// NewFire creates a new fire pattern.
+func NewFire(numLEDs int, cfg PatternConfig, clock clock.Clock) *Fire {
+	cfg = cfg.withDefaults(PatternConfig{
+		Speed:   16 * time.Millisecond,
+		Density: ratio(120.0 / 255),
+		Decay:   ratio(55.0 / 255),
+	})
+	return &Fire{
+		stepped: newStepped(numLEDs, cfg.Speed, cfg.Seed, clock),
+		cfg:     cfg,
+		heat:    make([]uint8, numLEDs),
+	}
+}
+
+// AcquireFrame draws the current frame.
+func (f *Fire) AcquireFrame(fn func(led.LEDs)) {
+	f.advance(f.step)
+	for i, heat := range f.heat {
+		f.leds[i] = heatColor(heat)
+	}
+	fn(f.leds)
+}
+
+func (f *Fire) step() {
+	n := len(f.heat)
+	if n == 0 {
+		return
+	}
+
+	// Cool down every cell a little.
+	cooling := int(*f.cfg.Decay * 255)
+	for i := range f.heat {
+		cooldown := f.rand.Intn((cooling*10)/n + 2)
+		f.heat[i] = uint8(max0(int(f.heat[i]) - cooldown))
+	}
+
+	// Heat from each cell drifts up and diffuses a little.
+	for k := n - 1; k >= 2; k-- {
+		f.heat[k] = uint8((int(f.heat[k-1]) + 2*int(f.heat[k-2])) / 3)
+	}
+
+	// Randomly ignite new sparks near the bottom.
+	if f.rand.Float64() < *f.cfg.Density {
+		y := f.rand.Intn(min(7, n))
+		f.heat[y] = uint8(min(255, int(f.heat[y])+160+f.rand.Intn(96)))
+	}
+}
+
+// heatColor maps a heat value to a black body radiation color.
+func heatColor(heat uint8) led.RGBColor {
+	// Scale heat down to [0, 191] for the three color ramps.
+	t192 := uint8(int(heat) * 191 / 255)
+	ramp := (t192 & 0x3F) << 2
+
+	switch {
+	case t192&0x80 != 0:
+		return led.RGBColor{255, 255, ramp}
+	case t192&0x40 != 0:
+		return led.RGBColor{255, ramp, 0}
+	default:
+		return led.RGBColor{ramp, 0, 0}
+	}
+}
+
+func max0(v int) int {
+	if v < 0 {
+		return 0
+	}
+	return v
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
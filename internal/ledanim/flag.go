@@ -0,0 +1,94 @@
+package ledanim
+
+import (
+	"sort"
+
+	"libdb.so/catglow/internal/led"
+)
+
+// Flags is a list of flag presets by name. Each flag is a list of horizontal
+// stripes from top to bottom.
+var Flags = map[string][]led.RGBColor{
+	"trans": {
+		{10, 150, 204},
+		{255, 94, 155},
+		{255, 255, 255},
+		{255, 94, 155},
+		{10, 150, 204},
+	},
+	"pride": {
+		{228, 3, 3},
+		{255, 140, 0},
+		{255, 237, 0},
+		{0, 128, 38},
+		{36, 64, 142},
+		{115, 41, 130},
+	},
+	"bi": {
+		{214, 2, 112},
+		{214, 2, 112},
+		{155, 79, 150},
+		{0, 56, 168},
+		{0, 56, 168},
+	},
+	"lesbian": {
+		{213, 45, 0},
+		{255, 154, 86},
+		{255, 255, 255},
+		{211, 98, 164},
+		{163, 2, 98},
+	},
+	"nonbinary": {
+		{252, 244, 52},
+		{255, 255, 255},
+		{156, 89, 209},
+		{44, 44, 44},
+	},
+	"pan": {
+		{255, 33, 140},
+		{255, 216, 0},
+		{33, 177, 255},
+	},
+	"ace": {
+		{0, 0, 0},
+		{163, 163, 163},
+		{255, 255, 255},
+		{128, 0, 128},
+	},
+	"genderfluid": {
+		{255, 118, 164},
+		{255, 255, 255},
+		{192, 17, 215},
+		{0, 0, 0},
+		{47, 60, 190},
+	},
+}
+
+// FlagNames returns the sorted names of all flag presets.
+func FlagNames() []string {
+	names := make([]string, 0, len(Flags))
+	for name := range Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DrawStripes draws the given stripes evenly across the LEDs.
+func DrawStripes(leds led.LEDs, stripes []led.RGBColor) {
+	if len(stripes) == 0 {
+		return
+	}
+	for i := range leds {
+		leds[i] = stripes[i*len(stripes)/len(leds)]
+	}
+}
+
+// NewFlag creates a frame with the given stripes drawn across it. A flag does
+// not move, so the frame can be drawn as-is or used as the frame of another
+// animation, such as Breathing.
+func NewFlag(numLEDs int, stripes []led.RGBColor) led.LEDs {
+	leds := led.NewLEDs(numLEDs)
+	DrawStripes(leds, stripes)
+	return leds
+}
@@ -0,0 +1,46 @@
+package ledanim
+
+import (
+	"math"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// Larson is a Larson scanner: an eye bouncing back and forth along the strip.
+// It uses Speed as the time to move by one LED, Size as the width of the eye
+// and Colors[0] as the color of the eye.
+type Larson struct {
+	base
+	cfg PatternConfig
+}
+
+// NewLarson creates a new Larson scanner pattern.
+func NewLarson(numLEDs int, cfg PatternConfig, clock clock.Clock) *Larson {
+	return &Larson{
+		base: newBase(numLEDs, clock),
+		cfg: cfg.withDefaults(PatternConfig{
+			Speed:  30 * time.Millisecond,
+			Colors: []led.RGBColor{{255, 0, 0}},
+			Size:   5,
+		}),
+	}
+}
+
+// AcquireFrame draws the current frame.
+func (l *Larson) AcquireFrame(f func(led.LEDs)) {
+	span := float64(len(l.leds) - 1)
+	center := 0.0
+	if span > 0 {
+		// Triangle wave between 0 and span.
+		center = span - math.Abs(math.Mod(l.steps(l.cfg.Speed), 2*span)-span)
+	}
+
+	radius := float64(l.cfg.Size) / 2
+	for i := range l.leds {
+		d := math.Abs(float64(i) - center)
+		l.leds[i] = dim(l.cfg.Colors[0], math.Max(0, 1-d/(radius+0.5)))
+	}
+	f(l.leds)
+}
@@ -0,0 +1,31 @@
+// Package ledanim implements procedural animations that are drawn onto LEDs.
+// Animations are functions of time, so they draw their frame lazily when one
+// is acquired.
+package ledanim
+
+import (
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// base is the common state of all animations.
+type base struct {
+	clock clock.Clock
+	start time.Time
+	leds  led.LEDs
+}
+
+func newBase(numLEDs int, clock clock.Clock) base {
+	return base{
+		clock: clock,
+		start: clock.Now(),
+		leds:  led.NewLEDs(numLEDs),
+	}
+}
+
+// elapsed returns the time elapsed since the animation started.
+func (b *base) elapsed() time.Duration {
+	return b.clock.Now().Sub(b.start)
+}
@@ -0,0 +1,59 @@
+package ledanim
+
+import (
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// Meteor is a meteor rain pattern: a meteor flies along the strip, leaving a
+// randomly decaying trail behind. It uses Speed as the time to move by one
+// LED, Size as the size of the meteor, Decay as how much the trail fades per
+// step and Colors[0] as the color of the meteor.
+type Meteor struct {
+	stepped
+	cfg PatternConfig
+	pos int
+}
+
+// NewMeteor creates a new meteor rain pattern.
+func NewMeteor(numLEDs int, cfg PatternConfig, clock clock.Clock) *Meteor {
+	cfg = cfg.withDefaults(PatternConfig{
+		Speed:  30 * time.Millisecond,
+		Colors: []led.RGBColor{{255, 255, 255}},
+		Size:   5,
+		Decay:  ratio(0.25),
+	})
+	return &Meteor{
+		stepped: newStepped(numLEDs, cfg.Speed, cfg.Seed, clock),
+		cfg:     cfg,
+	}
+}
+
+// AcquireFrame draws the current frame.
+func (m *Meteor) AcquireFrame(f func(led.LEDs)) {
+	m.advance(m.step)
+	f(m.leds)
+}
+
+func (m *Meteor) step() {
+	n := len(m.leds)
+
+	// Randomly fade the trail so that it sparkles.
+	for i := range m.leds {
+		if m.rand.Intn(2) == 0 {
+			m.leds[i] = fade(m.leds[i], *m.cfg.Decay)
+		}
+	}
+
+	for i := 0; i < m.cfg.Size; i++ {
+		if j := m.pos - i; j >= 0 && j < n {
+			m.leds[j] = m.cfg.Colors[0]
+		}
+	}
+
+	// The meteor flies off the strip before coming back, so that its trail
+	// can fade out.
+	m.pos = (m.pos + 1) % (2 * n)
+}
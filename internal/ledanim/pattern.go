@@ -0,0 +1,117 @@
+package ledanim
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// PatternConfig is the configuration shared by all procedural patterns. Each
+// pattern documents which fields it uses; zero values and nil pointers mean the
+// pattern's defaults.
+type PatternConfig struct {
+	// Speed is the duration of one step of the pattern. What a step is depends
+	// on the pattern, but it is usually moving by one LED.
+	Speed time.Duration
+	// Colors is the palette of the pattern.
+	Colors []led.RGBColor
+	// Size is the size of the moving part of the pattern, such as the length
+	// of a comet's tail.
+	Size int
+	// Density is the chance of something appearing per step within [0, 1],
+	// such as a twinkle or a spark. It is a pointer because 0 is a valid
+	// density.
+	Density *float64
+	// Decay is how quickly trails fade per step within [0, 1]. It is a
+	// pointer because 0 is a valid decay.
+	Decay *float64
+	// Seed is the seed for the random number generator. Patterns with the
+	// same seed and clock draw the same frames.
+	Seed int64
+}
+
+func (c PatternConfig) withDefaults(defaults PatternConfig) PatternConfig {
+	if c.Speed <= 0 {
+		c.Speed = defaults.Speed
+	}
+	if len(c.Colors) == 0 {
+		c.Colors = defaults.Colors
+	}
+	if c.Size <= 0 {
+		c.Size = defaults.Size
+	}
+	if c.Density == nil {
+		c.Density = defaults.Density
+	}
+	if c.Decay == nil {
+		c.Decay = defaults.Decay
+	}
+	return c
+}
+
+// ratio returns a pointer to v for the defaults of Density and Decay.
+func ratio(v float64) *float64 { return &v }
+
+// maxCatchUpSteps is the most steps a stepped pattern simulates at once. If
+// frames were not acquired for a long time, then the older steps are skipped.
+const maxCatchUpSteps = 1024
+
+// stepped is the common state of patterns that are simulated step by step
+// rather than computed from the time.
+type stepped struct {
+	base
+	speed time.Duration
+	// done is the number of steps simulated so far.
+	done int64
+	rand *rand.Rand
+}
+
+func newStepped(numLEDs int, speed time.Duration, seed int64, clock clock.Clock) stepped {
+	return stepped{
+		base:  newBase(numLEDs, clock),
+		speed: speed,
+		rand:  rand.New(rand.NewSource(seed)),
+	}
+}
+
+// advance calls f once for every step that has elapsed since the last call.
+func (s *stepped) advance(f func()) {
+	target := int64(s.elapsed() / s.speed)
+	if target-s.done > maxCatchUpSteps {
+		s.done = target - maxCatchUpSteps
+	}
+	for ; s.done < target; s.done++ {
+		f()
+	}
+}
+
+// steps returns the number of steps elapsed, for patterns that are computed
+// from the time.
+func (b *base) steps(speed time.Duration) float64 {
+	return float64(b.elapsed()) / float64(speed)
+}
+
+// paletteAt returns the color at the position t within [0, 1) of the palette,
+// blending between adjacent colors. The palette wraps around.
+func paletteAt(colors []led.RGBColor, t float64) led.RGBColor {
+	t = math.Mod(t, 1)
+	if t < 0 {
+		t++
+	}
+	pos := t * float64(len(colors))
+	i := int(pos)
+	return colors[i%len(colors)].Mix(colors[(i+1)%len(colors)], pos-float64(i))
+}
+
+// fade fades the color towards black by the given amount within [0, 1].
+func fade(c led.RGBColor, amount float64) led.RGBColor {
+	return c.Mix(led.RGBColor{}, amount)
+}
+
+// dim returns the color at the given brightness within [0, 1].
+func dim(c led.RGBColor, brightness float64) led.RGBColor {
+	return led.RGBColor{}.Mix(c, brightness)
+}
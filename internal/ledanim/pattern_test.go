@@ -0,0 +1,175 @@
+package ledanim
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+type animation interface {
+	AcquireFrame(func(led.LEDs))
+}
+
+func TestPatternGolden(t *testing.T) {
+	const numLEDs = 16
+
+	cfg := PatternConfig{
+		Colors: []led.RGBColor{{255, 0, 0}, {0, 0, 255}},
+		Seed:   1,
+	}
+
+	patterns := map[string]func(clock.Clock) animation{
+		"rainbow": func(c clock.Clock) animation { return NewRainbow(numLEDs, cfg, c) },
+		"wipe":    func(c clock.Clock) animation { return NewWipe(numLEDs, cfg, c) },
+		"chase":   func(c clock.Clock) animation { return NewTheaterChase(numLEDs, cfg, c) },
+		"twinkle": func(c clock.Clock) animation { return NewTwinkle(numLEDs, cfg, c) },
+		"fire":    func(c clock.Clock) animation { return NewFire(numLEDs, cfg, c) },
+		"meteor":  func(c clock.Clock) animation { return NewMeteor(numLEDs, cfg, c) },
+		"comet":   func(c clock.Clock) animation { return NewComet(numLEDs, cfg, c) },
+		"larson":  func(c clock.Clock) animation { return NewLarson(numLEDs, cfg, c) },
+		"plasma":  func(c clock.Clock) animation { return NewPlasma(numLEDs, cfg, c) },
+	}
+
+	times := []time.Duration{
+		0,
+		50 * time.Millisecond,
+		120 * time.Millisecond,
+		400 * time.Millisecond,
+		time.Second,
+		3 * time.Second,
+	}
+
+	for name, newPattern := range patterns {
+		t.Run(name, func(t *testing.T) {
+			expectGolden(t, name, newPattern, times)
+		})
+	}
+}
+
+func TestPatternZeroDensity(t *testing.T) {
+	clk := clock.NewManual(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	density := 0.0
+	twinkle := NewTwinkle(16, PatternConfig{Density: &density, Seed: 1}, clk)
+
+	// A density of 0 is not the default, so nothing ever lights up.
+	for i := 0; i < 10; i++ {
+		clk.Advance(time.Second)
+		twinkle.AcquireFrame(func(leds led.LEDs) {
+			for j, c := range leds {
+				if c != (led.RGBColor{}) {
+					t.Fatalf("LED %d got %v, want it off", j, c)
+				}
+			}
+		})
+	}
+}
+
+func TestAnimationGolden(t *testing.T) {
+	const numLEDs = 12
+
+	red := led.RGBColor{255, 0, 0}
+	flag := NewFlag(numLEDs, Flags["trans"])
+
+	animations := map[string]func(clock.Clock) animation{
+		"breathing": func(c clock.Clock) animation {
+			return NewBreathing(numLEDs, BreathingConfig{Color: red, Period: 2 * time.Second}, c)
+		},
+		"breathing-linear": func(c clock.Clock) animation {
+			return NewBreathing(numLEDs, BreathingConfig{
+				Color:    red,
+				Period:   2 * time.Second,
+				Function: BreathingLinear,
+			}, c)
+		},
+		"breathing-flag": func(c clock.Clock) animation {
+			return NewBreathing(numLEDs, BreathingConfig{Frame: flag, Period: 2 * time.Second}, c)
+		},
+		"cycle": func(c clock.Clock) animation {
+			return NewCycle(numLEDs, CycleConfig{
+				Colors:   []led.RGBColor{red, {0, 255, 0}, {0, 0, 255}},
+				Interval: time.Second,
+			}, c)
+		},
+		"cycle-fade": func(c clock.Clock) animation {
+			return NewCycle(numLEDs, CycleConfig{
+				Colors:   []led.RGBColor{red, {0, 255, 0}, {0, 0, 255}},
+				Interval: time.Second,
+				Fade:     500 * time.Millisecond,
+			}, c)
+		},
+	}
+
+	times := []time.Duration{
+		0,
+		250 * time.Millisecond,
+		500 * time.Millisecond,
+		750 * time.Millisecond,
+		time.Second,
+		1500 * time.Millisecond,
+		2 * time.Second,
+		3250 * time.Millisecond,
+	}
+
+	for name, newAnimation := range animations {
+		t.Run(name, func(t *testing.T) {
+			expectGolden(t, name, newAnimation, times)
+		})
+	}
+}
+
+// expectGolden renders the animation at the given times and compares the
+// frames to testdata/<name>.golden, or writes them there with -update.
+func expectGolden(t *testing.T, name string, newAnimation func(clock.Clock) animation, times []time.Duration) {
+	t.Helper()
+
+	got := renderGolden(newAnimation, times)
+
+	// Rendering twice must give the same frames.
+	if again := renderGolden(newAnimation, times); again != got {
+		t.Fatal("animation is not deterministic")
+	}
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update?): %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("frames differ from %s:\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func renderGolden(newPattern func(clock.Clock) animation, times []time.Duration) string {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewManual(start)
+	pattern := newPattern(clk)
+
+	var b strings.Builder
+	for _, at := range times {
+		clk.Set(start.Add(at))
+		pattern.AcquireFrame(func(leds led.LEDs) {
+			fmt.Fprintf(&b, "%6s:", at)
+			for _, c := range leds {
+				fmt.Fprintf(&b, " %02x%02x%02x", c[0], c[1], c[2])
+			}
+			b.WriteByte('\n')
+		})
+	}
+	return b.String()
+}
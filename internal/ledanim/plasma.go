@@ -0,0 +1,48 @@
+package ledanim
+
+import (
+	"math"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// Plasma is a plasma effect made of overlapping sine waves. It uses Speed as
+// the duration of one step, where the waves move by one radian every step, and
+// Colors as the palette. If there are less than two colors, then the whole hue
+// range is used.
+type Plasma struct {
+	base
+	cfg PatternConfig
+}
+
+// NewPlasma creates a new plasma pattern.
+func NewPlasma(numLEDs int, cfg PatternConfig, clock clock.Clock) *Plasma {
+	return &Plasma{
+		base: newBase(numLEDs, clock),
+		cfg: cfg.withDefaults(PatternConfig{
+			Speed: time.Second,
+		}),
+	}
+}
+
+// AcquireFrame draws the current frame.
+func (p *Plasma) AcquireFrame(f func(led.LEDs)) {
+	t := p.steps(p.cfg.Speed)
+	for i := range p.leds {
+		x := float64(i)
+		v := math.Sin(x*0.11+t) +
+			math.Sin(x*0.07-t*1.3) +
+			math.Sin((x*0.05+t*0.7)+math.Sin(t*0.4)*2)
+		// v is within [-3, 3]; map it to [0, 1].
+		v = (v + 3) / 6
+
+		if len(p.cfg.Colors) < 2 {
+			p.leds[i] = led.HSV(v, 1, 1)
+		} else {
+			p.leds[i] = paletteAt(p.cfg.Colors, v)
+		}
+	}
+	f(p.leds)
+}
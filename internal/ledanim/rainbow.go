@@ -0,0 +1,36 @@
+package ledanim
+
+import (
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// Rainbow is a pattern that scrolls a rainbow along the strip. It uses Speed
+// as the time to scroll by one LED and Size as the number of LEDs that one
+// full rainbow spans, which defaults to the whole strip.
+type Rainbow struct {
+	base
+	cfg PatternConfig
+}
+
+// NewRainbow creates a new rainbow pattern.
+func NewRainbow(numLEDs int, cfg PatternConfig, clock clock.Clock) *Rainbow {
+	return &Rainbow{
+		base: newBase(numLEDs, clock),
+		cfg: cfg.withDefaults(PatternConfig{
+			Speed: 20 * time.Millisecond,
+			Size:  numLEDs,
+		}),
+	}
+}
+
+// AcquireFrame draws the current frame.
+func (r *Rainbow) AcquireFrame(f func(led.LEDs)) {
+	offset := r.steps(r.cfg.Speed)
+	for i := range r.leds {
+		r.leds[i] = led.HSV((float64(i)+offset)/float64(r.cfg.Size), 1, 1)
+	}
+	f(r.leds)
+}
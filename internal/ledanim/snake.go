@@ -0,0 +1,53 @@
+package ledanim
+
+import (
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// SnakeConfig is the configuration for a snake animation.
+type SnakeConfig struct {
+	// Colors is the list of colors of the chunks. The strip is evenly divided
+	// into chunks.
+	Colors []led.RGBColor
+	// Speed is the duration for the snake to move by one LED. If zero, then
+	// the snake does not move.
+	Speed time.Duration
+}
+
+// Snake is an animation that moves chunks of colors along the strip.
+type Snake struct {
+	base
+	cfg SnakeConfig
+}
+
+// NewSnake creates a new snake animation.
+func NewSnake(numLEDs int, cfg SnakeConfig, clock clock.Clock) *Snake {
+	return &Snake{
+		base: newBase(numLEDs, clock),
+		cfg:  cfg,
+	}
+}
+
+// AcquireFrame draws the current frame.
+func (s *Snake) AcquireFrame(f func(led.LEDs)) {
+	n := len(s.leds)
+	if n == 0 || len(s.cfg.Colors) == 0 {
+		f(s.leds)
+		return
+	}
+
+	var offset int
+	if s.cfg.Speed > 0 {
+		offset = int(s.elapsed()/s.cfg.Speed) % n
+	}
+
+	for i := range s.leds {
+		pos := (i - offset + n) % n
+		s.leds[i] = s.cfg.Colors[pos*len(s.cfg.Colors)/n]
+	}
+
+	f(s.leds)
+}
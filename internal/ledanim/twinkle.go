@@ -0,0 +1,47 @@
+package ledanim
+
+import (
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// Twinkle is a pattern of random LEDs lighting up and fading out. It uses
+// Speed as the duration of one step, Density as the chance of each LED
+// lighting up per step, Decay as how much lit LEDs fade per step and Colors as
+// the colors to randomly pick from.
+type Twinkle struct {
+	stepped
+	cfg PatternConfig
+}
+
+// NewTwinkle creates a new twinkle pattern.
+func NewTwinkle(numLEDs int, cfg PatternConfig, clock clock.Clock) *Twinkle {
+	cfg = cfg.withDefaults(PatternConfig{
+		Speed:   50 * time.Millisecond,
+		Colors:  []led.RGBColor{{255, 255, 255}},
+		Density: ratio(0.02),
+		Decay:   ratio(0.1),
+	})
+	return &Twinkle{
+		stepped: newStepped(numLEDs, cfg.Speed, cfg.Seed, clock),
+		cfg:     cfg,
+	}
+}
+
+// AcquireFrame draws the current frame.
+func (t *Twinkle) AcquireFrame(f func(led.LEDs)) {
+	t.advance(t.step)
+	f(t.leds)
+}
+
+func (t *Twinkle) step() {
+	for i := range t.leds {
+		if t.rand.Float64() < *t.cfg.Density {
+			t.leds[i] = t.cfg.Colors[t.rand.Intn(len(t.cfg.Colors))]
+		} else {
+			t.leds[i] = fade(t.leds[i], *t.cfg.Decay)
+		}
+	}
+}
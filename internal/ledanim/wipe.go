@@ -0,0 +1,52 @@
+package ledanim
+
+import (
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// Wipe is a pattern that fills the strip one LED at a time with each color in
+// turn. It uses Speed as the time to fill one LED and Colors as the colors to
+// fill with.
+type Wipe struct {
+	base
+	cfg PatternConfig
+}
+
+// NewWipe creates a new color wipe pattern.
+func NewWipe(numLEDs int, cfg PatternConfig, clock clock.Clock) *Wipe {
+	return &Wipe{
+		base: newBase(numLEDs, clock),
+		cfg: cfg.withDefaults(PatternConfig{
+			Speed:  20 * time.Millisecond,
+			Colors: []led.RGBColor{{255, 0, 0}, {0, 255, 0}, {0, 0, 255}},
+		}),
+	}
+}
+
+// AcquireFrame draws the current frame.
+func (w *Wipe) AcquireFrame(f func(led.LEDs)) {
+	n := int64(len(w.leds))
+	if n == 0 {
+		f(w.leds)
+		return
+	}
+
+	step := int64(w.steps(w.cfg.Speed))
+	colors := int64(len(w.cfg.Colors))
+
+	// The current color wipes over the previous one.
+	wipe := step / n
+	current := w.cfg.Colors[wipe%colors]
+	previous := w.cfg.Colors[(wipe+colors-1)%colors]
+	if wipe == 0 {
+		previous = led.RGBColor{}
+	}
+
+	filled := int(step % n)
+	w.leds.SetRange(0, filled, current)
+	w.leds.SetRange(filled, len(w.leds), previous)
+	f(w.leds)
+}
@@ -0,0 +1,145 @@
+package ledimg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image/png"
+	"io"
+
+	"libdb.so/catglow/internal/led"
+)
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// EncodeAPNG writes the frames as a looping animated PNG.
+func (s Strip) EncodeAPNG(w io.Writer, frames []led.LEDs) error {
+	if len(frames) == 0 {
+		return errors.New("no frames to encode")
+	}
+	if s.Rate > 0xFFFF {
+		return fmt.Errorf("frame rate %d is too high for APNG", s.Rate)
+	}
+
+	bounds := s.Bounds(len(frames[0]))
+	aw := apngWriter{w: w}
+
+	aw.write([]byte(pngSignature))
+
+	var seq uint32
+	for i, leds := range frames {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, s.Frame(leds)); err != nil {
+			return err
+		}
+
+		chunks, err := readChunks(buf.Bytes())
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			for _, c := range chunks {
+				if c.typ == "IHDR" {
+					aw.chunk("IHDR", c.data)
+				}
+			}
+
+			actl := make([]byte, 8)
+			binary.BigEndian.PutUint32(actl[0:], uint32(len(frames)))
+			binary.BigEndian.PutUint32(actl[4:], 0) // loop forever
+			aw.chunk("acTL", actl)
+		}
+
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:], seq)
+		binary.BigEndian.PutUint32(fctl[4:], uint32(bounds.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(bounds.Dy()))
+		// The offsets stay zero.
+		binary.BigEndian.PutUint16(fctl[20:], 1)
+		binary.BigEndian.PutUint16(fctl[22:], uint16(s.Rate))
+		// Neither dispose nor blend, since every frame covers the image.
+		aw.chunk("fcTL", fctl)
+		seq++
+
+		for _, c := range chunks {
+			if c.typ != "IDAT" {
+				continue
+			}
+			if i == 0 {
+				aw.chunk("IDAT", c.data)
+				continue
+			}
+			fdat := make([]byte, 4+len(c.data))
+			binary.BigEndian.PutUint32(fdat, seq)
+			copy(fdat[4:], c.data)
+			aw.chunk("fdAT", fdat)
+			seq++
+		}
+	}
+
+	aw.chunk("IEND", nil)
+	return aw.err
+}
+
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+// readChunks splits an encoded PNG into its chunks.
+func readChunks(b []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(b, []byte(pngSignature)) {
+		return nil, errors.New("missing PNG signature")
+	}
+	b = b[len(pngSignature):]
+
+	var chunks []pngChunk
+	for len(b) > 0 {
+		if len(b) < 12 {
+			return nil, errors.New("truncated PNG chunk")
+		}
+		n := binary.BigEndian.Uint32(b)
+		if uint32(len(b)-12) < n {
+			return nil, errors.New("truncated PNG chunk")
+		}
+		chunks = append(chunks, pngChunk{
+			typ:  string(b[4:8]),
+			data: b[8 : 8+n],
+		})
+		b = b[12+n:]
+	}
+
+	return chunks, nil
+}
+
+// apngWriter writes PNG chunks, keeping the first error.
+type apngWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (w *apngWriter) write(b []byte) {
+	if w.err == nil {
+		_, w.err = w.w.Write(b)
+	}
+}
+
+func (w *apngWriter) chunk(typ string, data []byte) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], typ)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+
+	var footer [4]byte
+	binary.BigEndian.PutUint32(footer[:], crc.Sum32())
+
+	w.write(header[:])
+	w.write(data)
+	w.write(footer[:])
+}
@@ -0,0 +1,129 @@
+// Package ledimg draws LED frames as images, such as to share what a
+// configuration looks like without the hardware.
+package ledimg
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+
+	"libdb.so/catglow/internal/led"
+)
+
+// Background is the color around the LEDs.
+var Background = color.RGBA{20, 20, 20, 255}
+
+// Strip draws frames as a row of circles, one per LED, each in a square cell
+// of Size pixels.
+type Strip struct {
+	// Size is the size of each LED's cell in pixels.
+	Size int
+	// Rate is the frame rate of the frames drawn for animations.
+	Rate int
+}
+
+// Bounds returns the bounds of a frame of the given number of LEDs.
+func (s Strip) Bounds(numLEDs int) image.Rectangle {
+	return image.Rect(0, 0, numLEDs*s.Size, s.Size)
+}
+
+// Draw draws the LEDs into dst at the given point.
+func (s Strip) Draw(dst draw.Image, at image.Point, leds led.LEDs) {
+	// Leave a gap of about a tenth between the circles.
+	r := float64(s.Size) * 0.45
+	c := float64(s.Size) / 2
+
+	for i, l := range leds {
+		ledColor := color.RGBA{l[0], l[1], l[2], 255}
+		for y := 0; y < s.Size; y++ {
+			for x := 0; x < s.Size; x++ {
+				px := at.Add(image.Pt(i*s.Size+x, y))
+				if math.Hypot(float64(x)+0.5-c, float64(y)+0.5-c) <= r {
+					dst.Set(px.X, px.Y, ledColor)
+				} else {
+					dst.Set(px.X, px.Y, Background)
+				}
+			}
+		}
+	}
+}
+
+// Frame draws the LEDs into a new image.
+func (s Strip) Frame(leds led.LEDs) *image.RGBA {
+	img := image.NewRGBA(s.Bounds(len(leds)))
+	s.Draw(img, image.Point{}, leds)
+	return img
+}
+
+// SpriteSheet draws all frames into one image, from the top to the bottom.
+func (s Strip) SpriteSheet(frames []led.LEDs) *image.RGBA {
+	if len(frames) == 0 {
+		return image.NewRGBA(image.Rectangle{})
+	}
+
+	bounds := s.Bounds(len(frames[0]))
+	img := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()*len(frames)))
+	for i, leds := range frames {
+		s.Draw(img, image.Pt(0, i*bounds.Dy()), leds)
+	}
+	return img
+}
+
+// EncodeSpriteSheet writes all frames as a PNG sprite sheet.
+func (s Strip) EncodeSpriteSheet(w io.Writer, frames []led.LEDs) error {
+	return png.Encode(w, s.SpriteSheet(frames))
+}
+
+// EncodeGIF writes the frames as a looping animated GIF. GIF delays are in
+// hundredths of a second, so frames are shown as close to their time as
+// possible.
+func (s Strip) EncodeGIF(w io.Writer, frames []led.LEDs) error {
+	if len(frames) == 0 {
+		return errors.New("no frames to encode")
+	}
+
+	anim := &gif.GIF{
+		Image: make([]*image.Paletted, len(frames)),
+		Delay: make([]int, len(frames)),
+	}
+
+	for i, leds := range frames {
+		img := image.NewPaletted(s.Bounds(len(leds)), framePalette(leds))
+		s.Draw(img, image.Point{}, leds)
+		anim.Image[i] = img
+		anim.Delay[i] = s.centiseconds(i+1) - s.centiseconds(i)
+	}
+
+	return gif.EncodeAll(w, anim)
+}
+
+// centiseconds returns the time of the nth frame in hundredths of a second.
+func (s Strip) centiseconds(n int) int {
+	return int(math.Round(float64(n) * 100 / float64(s.Rate)))
+}
+
+// framePalette returns a palette with the exact colors of the frame, or a
+// generic palette if there are too many.
+func framePalette(leds led.LEDs) color.Palette {
+	p := color.Palette{Background}
+	seen := map[led.RGBColor]bool{{Background.R, Background.G, Background.B}: true}
+
+	for _, l := range leds {
+		if seen[l] {
+			continue
+		}
+		if len(p) == 256 {
+			return palette.Plan9
+		}
+		seen[l] = true
+		p = append(p, color.RGBA{l[0], l[1], l[2], 255})
+	}
+
+	return p
+}
@@ -0,0 +1,150 @@
+package ledimg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+
+	"libdb.so/catglow/internal/led"
+)
+
+var (
+	red  = led.RGBColor{255, 0, 0}
+	blue = led.RGBColor{0, 0, 255}
+)
+
+func testFrames() []led.LEDs {
+	return []led.LEDs{
+		{red, blue},
+		{blue, red},
+		{red, red},
+	}
+}
+
+func TestFrame(t *testing.T) {
+	s := Strip{Size: 10}
+	img := s.Frame(led.LEDs{red, blue})
+
+	if got := img.Bounds().Size(); got.X != 20 || got.Y != 10 {
+		t.Fatalf("size = %v, want 20x10", got)
+	}
+
+	tests := []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{5, 5, color.RGBA{255, 0, 0, 255}},
+		{15, 5, color.RGBA{0, 0, 255, 255}},
+		{0, 0, Background},
+		{10, 9, Background},
+	}
+	for _, test := range tests {
+		if got := img.RGBAAt(test.x, test.y); got != test.want {
+			t.Errorf("pixel (%d, %d) = %v, want %v", test.x, test.y, got, test.want)
+		}
+	}
+}
+
+func TestSpriteSheet(t *testing.T) {
+	s := Strip{Size: 4}
+
+	var buf bytes.Buffer
+	if err := s.EncodeSpriteSheet(&buf, testFrames()); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.Bounds().Size(); got.X != 8 || got.Y != 12 {
+		t.Errorf("size = %v, want 8x12", got)
+	}
+	if r, g, b, _ := img.At(6, 6).RGBA(); r>>8 != 255 || g != 0 || b != 0 {
+		t.Errorf("second frame's second LED is not red")
+	}
+}
+
+func TestGIF(t *testing.T) {
+	s := Strip{Size: 4, Rate: 30}
+
+	var buf bytes.Buffer
+	if err := s.EncodeGIF(&buf, testFrames()); err != nil {
+		t.Fatal(err)
+	}
+
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 3 {
+		t.Fatalf("got %d frames, want 3", len(anim.Image))
+	}
+
+	// 30 fps is 3⅓ hundredths of a second per frame.
+	want := []int{3, 4, 3}
+	for i, delay := range anim.Delay {
+		if delay != want[i] {
+			t.Errorf("frame %d: delay = %d, want %d", i, delay, want[i])
+		}
+	}
+}
+
+func TestAPNG(t *testing.T) {
+	s := Strip{Size: 4, Rate: 30}
+
+	var buf bytes.Buffer
+	if err := s.EncodeAPNG(&buf, testFrames()); err != nil {
+		t.Fatal(err)
+	}
+
+	chunks, err := readChunks(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var types []string
+	var seqs []uint32
+	for _, c := range chunks {
+		switch c.typ {
+		case "acTL":
+			if n := binary.BigEndian.Uint32(c.data); n != 3 {
+				t.Errorf("acTL has %d frames, want 3", n)
+			}
+		case "fcTL", "fdAT":
+			seqs = append(seqs, binary.BigEndian.Uint32(c.data))
+		}
+		if len(types) == 0 || types[len(types)-1] != c.typ {
+			types = append(types, c.typ)
+		}
+	}
+
+	wantTypes := []string{"IHDR", "acTL", "fcTL", "IDAT", "fcTL", "fdAT", "fcTL", "fdAT", "IEND"}
+	if len(types) != len(wantTypes) {
+		t.Fatalf("chunks = %v, want %v", types, wantTypes)
+	}
+	for i := range types {
+		if types[i] != wantTypes[i] {
+			t.Fatalf("chunks = %v, want %v", types, wantTypes)
+		}
+	}
+
+	for i, seq := range seqs {
+		if seq != uint32(i) {
+			t.Errorf("sequence numbers = %v, want them to count up from 0", seqs)
+			break
+		}
+	}
+
+	// Decoders without APNG support show the first frame.
+	img, err := png.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, b, _ := img.At(6, 2).RGBA(); r != 0 || b>>8 != 255 {
+		t.Errorf("first frame's second LED is not blue")
+	}
+}
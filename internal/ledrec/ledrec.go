@@ -0,0 +1,191 @@
+// Package ledrec implements a compressed recording format for LED frames.
+//
+// A recording is a gzip stream that starts with a header followed by frames.
+// The header is the magic string "catglow-rec", a version byte, the number of
+// LEDs as a uvarint and the start time in Unix nanoseconds as a varint. Each
+// frame is its offset from the start time in nanoseconds as a uvarint
+// followed by the RGB values of all LEDs.
+package ledrec
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"libdb.so/catglow/internal/led"
+)
+
+const (
+	magic   = "catglow-rec"
+	version = 1
+)
+
+// MaxLEDs is the most LEDs that a recording can have. It guards against
+// allocating huge frames for a corrupt header.
+const MaxLEDs = 1 << 16
+
+// ErrInvalid is returned when a recording is malformed.
+var ErrInvalid = errors.New("invalid recording")
+
+// Frame is a recorded frame.
+type Frame struct {
+	// Offset is the time of the frame since the start of the recording.
+	Offset time.Duration
+	// LEDs is the frame.
+	LEDs led.LEDs
+}
+
+// Writer writes a recording.
+type Writer struct {
+	gz      *gzip.Writer
+	buf     *bufio.Writer
+	numLEDs int
+	start   time.Time
+	last    time.Duration
+}
+
+// NewWriter creates a new recording of the given number of LEDs that starts
+// at the given time.
+func NewWriter(w io.Writer, numLEDs int, start time.Time) (*Writer, error) {
+	if numLEDs < 1 || numLEDs > MaxLEDs {
+		return nil, fmt.Errorf("LED count must be within [1, %d]", MaxLEDs)
+	}
+
+	gz := gzip.NewWriter(w)
+	buf := bufio.NewWriter(gz)
+
+	header := []byte(magic)
+	header = append(header, version)
+	header = binary.AppendUvarint(header, uint64(numLEDs))
+	header = binary.AppendVarint(header, start.UnixNano())
+
+	if _, err := buf.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		gz:      gz,
+		buf:     buf,
+		numLEDs: numLEDs,
+		start:   start,
+	}, nil
+}
+
+// WriteFrame writes a frame shown at the given time. Frames must be written
+// in order; a frame that is earlier than the last one is recorded at the same
+// time as it.
+func (w *Writer) WriteFrame(t time.Time, leds led.LEDs) error {
+	if len(leds) != w.numLEDs {
+		return fmt.Errorf("frame has %d LEDs, recording has %d", len(leds), w.numLEDs)
+	}
+
+	offset := t.Sub(w.start)
+	if offset < w.last {
+		offset = w.last
+	}
+	w.last = offset
+
+	var header [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(header[:], uint64(offset))
+	if _, err := w.buf.Write(header[:n]); err != nil {
+		return err
+	}
+
+	_, err := leds.WriteTo(w.buf)
+	return err
+}
+
+// Flush flushes the buffered frames to the underlying writer, so that the
+// recording is readable up to this point.
+func (w *Writer) Flush() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	return w.gz.Flush()
+}
+
+// Close flushes the recording and finishes the gzip stream. It does not close
+// the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	return w.gz.Close()
+}
+
+// Reader reads a recording.
+type Reader struct {
+	r *bufio.Reader
+	// NumLEDs is the number of LEDs in each frame.
+	NumLEDs int
+	// Start is the time that the recording started at.
+	Start time.Time
+}
+
+// NewReader reads the header of a recording.
+func NewReader(r io.Reader) (*Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	br := bufio.NewReader(gz)
+
+	header := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[:len(magic)]) != magic {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalid)
+	}
+	if v := header[len(magic)]; v != version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalid, v)
+	}
+
+	numLEDs, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing LED count", ErrInvalid)
+	}
+	if numLEDs < 1 || numLEDs > MaxLEDs {
+		return nil, fmt.Errorf("%w: LED count %d is not within [1, %d]", ErrInvalid, numLEDs, MaxLEDs)
+	}
+
+	start, err := binary.ReadVarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing start time", ErrInvalid)
+	}
+
+	return &Reader{
+		r:       br,
+		NumLEDs: int(numLEDs),
+		Start:   time.Unix(0, start),
+	}, nil
+}
+
+// ReadFrame reads the next frame. It returns io.EOF at the end of the
+// recording. A recording that was cut off in the middle of a frame, e.g.
+// because the daemon was killed, ends at the last complete frame.
+func (r *Reader) ReadFrame() (Frame, error) {
+	offset, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return Frame{}, err
+	}
+
+	leds := led.NewLEDs(r.NumLEDs)
+	pix := leds.AsPixels()
+	if _, err := io.ReadFull(r.r, pix); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return Frame{}, err
+	}
+
+	return Frame{
+		Offset: time.Duration(offset),
+		LEDs:   leds,
+	}, nil
+}
@@ -0,0 +1,238 @@
+package ledrec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/led"
+)
+
+var start = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func record(t *testing.T, offsets ...time.Duration) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, 2, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, offset := range offsets {
+		frame := led.LEDs{{uint8(i), 0, 0}, {0, 0, uint8(i)}}
+		if err := w.WriteFrame(start.Add(offset), frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	offsets := []time.Duration{0, 16 * time.Millisecond, 33 * time.Millisecond}
+	data := record(t, offsets...)
+
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.NumLEDs != 2 {
+		t.Errorf("NumLEDs = %d, want 2", r.NumLEDs)
+	}
+	if !r.Start.Equal(start) {
+		t.Errorf("Start = %v, want %v", r.Start, start)
+	}
+
+	for i, offset := range offsets {
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if f.Offset != offset {
+			t.Errorf("frame %d: offset = %v, want %v", i, f.Offset, offset)
+		}
+		want := led.LEDs{{uint8(i), 0, 0}, {0, 0, uint8(i)}}
+		if !bytes.Equal(f.LEDs.AsPixels(), want.AsPixels()) {
+			t.Errorf("frame %d: LEDs = %v, want %v", i, f.LEDs, want)
+		}
+	}
+
+	if _, err := r.ReadFrame(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, 2, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		w.WriteFrame(start.Add(time.Duration(i)*time.Second), led.NewLEDs(2))
+	}
+	// Flush without closing, as if the daemon was killed.
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	for {
+		if _, err := r.ReadFrame(); err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Fatal(err)
+			}
+			break
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("read %d frames, want 3", n)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	if _, err := NewReader(bytes.NewReader([]byte("not a recording"))); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid, got %v", err)
+	}
+
+	// A header with no or a huge LED count must not make readers panic or
+	// allocate huge frames.
+	for _, numLEDs := range []uint64{0, MaxLEDs + 1, 1 << 40} {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		header := append([]byte(magic), version)
+		header = binary.AppendUvarint(header, numLEDs)
+		header = binary.AppendVarint(header, start.UnixNano())
+		gz.Write(header)
+		gz.Close()
+		if _, err := NewReader(&buf); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%d LEDs: expected ErrInvalid, got %v", numLEDs, err)
+		}
+	}
+}
+
+func TestLEDCount(t *testing.T) {
+	for _, numLEDs := range []int{1, MaxLEDs} {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, numLEDs, start)
+		if err != nil {
+			t.Fatalf("%d LEDs: %v", numLEDs, err)
+		}
+		if err := w.WriteFrame(start, led.NewLEDs(numLEDs)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := NewReader(&buf)
+		if err != nil {
+			t.Fatalf("%d LEDs: %v", numLEDs, err)
+		}
+		frame, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("%d LEDs: %v", numLEDs, err)
+		}
+		if len(frame.LEDs) != numLEDs {
+			t.Errorf("got %d LEDs, want %d", len(frame.LEDs), numLEDs)
+		}
+	}
+
+	for _, numLEDs := range []int{-1, 0, MaxLEDs + 1} {
+		if _, err := NewWriter(io.Discard, numLEDs, start); err == nil {
+			t.Errorf("expected an error for %d LEDs", numLEDs)
+		}
+	}
+}
+
+// stepClock is a clock that jumps forward whenever it is waited on.
+type stepClock struct{ now time.Time }
+
+func (c *stepClock) Now() time.Time { return c.now }
+
+func (c *stepClock) After(d time.Duration) <-chan time.Time {
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func TestPlay(t *testing.T) {
+	data := record(t, 0, time.Second, 2*time.Second, 3*time.Second)
+
+	tests := []struct {
+		name  string
+		opts  PlayOptions
+		want  []uint8         // frame indices
+		times []time.Duration // times since the start of playback
+	}{
+		{
+			name:  "original",
+			want:  []uint8{0, 1, 2, 3},
+			times: []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second},
+		},
+		{
+			name:  "speed",
+			opts:  PlayOptions{Speed: 2},
+			want:  []uint8{0, 1, 2, 3},
+			times: []time.Duration{0, 500 * time.Millisecond, time.Second, 1500 * time.Millisecond},
+		},
+		{
+			name:  "seek",
+			opts:  PlayOptions{Seek: 1500 * time.Millisecond},
+			want:  []uint8{1, 2, 3},
+			times: []time.Duration{0, 500 * time.Millisecond, 1500 * time.Millisecond},
+		},
+		{
+			name:  "seek past end",
+			opts:  PlayOptions{Seek: time.Minute},
+			want:  []uint8{3},
+			times: []time.Duration{0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			clock := &stepClock{now: start}
+			test.opts.Clock = clock
+
+			var got []uint8
+			var times []time.Duration
+			err = Play(context.Background(), r, test.opts, func(f Frame) error {
+				got = append(got, f.LEDs[0][0])
+				times = append(times, clock.now.Sub(start))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, test.want) {
+				t.Errorf("frames = %v, want %v", got, test.want)
+			}
+			for i := range times {
+				if i < len(test.times) && times[i] != test.times[i] {
+					t.Errorf("frame %d drawn at %v, want %v", i, times[i], test.times[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package ledrec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+)
+
+// PlayOptions are options for playing a recording.
+type PlayOptions struct {
+	// Seek skips the recording up to this offset. The last frame before it is
+	// drawn right away, so that playback starts from the state at that point.
+	Seek time.Duration
+	// Speed is the playback speed relative to the original timing. If zero,
+	// then the recording is played at its original speed.
+	Speed float64
+	// Clock is the clock to time the frames with. If nil, then the real clock
+	// is used.
+	Clock clock.Clock
+}
+
+// Play plays the recording, calling draw with each frame at its original
+// time. It returns nil once the recording ends.
+func Play(ctx context.Context, r *Reader, opts PlayOptions, draw func(Frame) error) error {
+	if opts.Speed <= 0 {
+		opts.Speed = 1
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.Real
+	}
+
+	var skipped *Frame
+	var start time.Time
+
+	for {
+		f, err := r.ReadFrame()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			if err == nil && skipped != nil {
+				// The recording ends before the seek offset, so just show
+				// where it ended.
+				err = draw(*skipped)
+			}
+			return err
+		}
+
+		if f.Offset < opts.Seek {
+			skipped = &f
+			continue
+		}
+
+		if start.IsZero() {
+			start = opts.Clock.Now()
+			if skipped != nil {
+				if err := draw(*skipped); err != nil {
+					return err
+				}
+				skipped = nil
+			}
+		}
+
+		at := start.Add(time.Duration(float64(f.Offset-opts.Seek) / opts.Speed))
+		if wait := at.Sub(opts.Clock.Now()); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-opts.Clock.After(wait):
+			}
+		}
+
+		if err := draw(f); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package ledvis
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Beat is a beat detected in the audio.
+type Beat struct {
+	// Count is the number of beats detected so far, including this one. It is
+	// zero if no beat has been detected yet.
+	Count int
+	// Time is the time of the beat.
+	Time time.Time
+	// Strength is how far the onset exceeded the adaptive threshold. It is
+	// at least 1.
+	Strength float64
+}
+
+// BeatSource is implemented by all visualizers. It allows animators to sync
+// to the music.
+type BeatSource interface {
+	// LastBeat returns the most recently detected beat.
+	LastBeat() Beat
+	// Tempo returns the estimated tempo in beats per minute, or 0 if it is
+	// not known.
+	Tempo() float64
+}
+
+// BeatConfig is the configuration for beat detection.
+type BeatConfig struct {
+	// Sensitivity is the number of standard deviations that the spectral flux
+	// must rise above its recent mean to count as an onset. Lower values
+	// detect more beats. If zero, then DefaultBeatSensitivity is used.
+	Sensitivity float64
+	// MinBPM and MaxBPM bound the estimated tempo. MaxBPM also limits how
+	// often beats can be detected. If zero, then DefaultMinBPM and
+	// DefaultMaxBPM are used.
+	MinBPM, MaxBPM float64
+	// Decay is how long a flash of the beat visualizer takes to fade out. If
+	// zero, then DefaultBeatDecay is used.
+	Decay time.Duration
+}
+
+const (
+	// DefaultBeatSensitivity is the default sensitivity of beat detection.
+	DefaultBeatSensitivity = 1.5
+	// DefaultMinBPM is the default lower bound of the estimated tempo.
+	DefaultMinBPM = 60
+	// DefaultMaxBPM is the default upper bound of the estimated tempo.
+	DefaultMaxBPM = 180
+	// DefaultBeatDecay is the default fade out duration of a beat flash.
+	DefaultBeatDecay = 250 * time.Millisecond
+)
+
+const (
+	// fluxWindow is the window of spectral flux values that the adaptive
+	// threshold is computed over.
+	fluxWindow = 500 * time.Millisecond
+	// minFlux is the spectral flux below which no onset is detected, so that
+	// noise in near silence is not picked up.
+	minFlux = 0.01
+	// tempoWindow is how far back beats are used for estimating the tempo.
+	tempoWindow = 8 * time.Second
+	// minTempoIntervals is the number of intervals between beats needed to
+	// estimate the tempo.
+	minTempoIntervals = 3
+)
+
+// beatDetector detects beats using the spectral flux of the bins with an
+// adaptive threshold, and estimates the tempo from the intervals between
+// them.
+type beatDetector struct {
+	cfg  BeatConfig
+	prev []float64
+	// flux is a ring buffer of recent spectral flux values.
+	flux    []float64
+	fluxPos int
+	fluxLen int
+
+	last Beat
+	// times are the times of recent beats, oldest first.
+	times []time.Time
+}
+
+func newBeatDetector(cfg BeatConfig, frameRate int) beatDetector {
+	if cfg.Sensitivity == 0 {
+		cfg.Sensitivity = DefaultBeatSensitivity
+	}
+	if cfg.MinBPM == 0 {
+		cfg.MinBPM = DefaultMinBPM
+	}
+	if cfg.MaxBPM == 0 {
+		cfg.MaxBPM = DefaultMaxBPM
+	}
+	if cfg.Decay == 0 {
+		cfg.Decay = DefaultBeatDecay
+	}
+
+	n := int(fluxWindow.Seconds() * float64(frameRate))
+	if n < 2 {
+		n = 2
+	}
+
+	return beatDetector{
+		cfg:  cfg,
+		flux: make([]float64, n),
+	}
+}
+
+// update updates the detector with the raw bins of a frame. It returns true
+// if a beat was detected.
+func (d *beatDetector) update(bins [][]float64, now time.Time) bool {
+	flux := d.spectralFlux(bins)
+	mean, sd := d.stats()
+	d.pushFlux(flux)
+
+	// Wait for the window to fill up before trusting the threshold.
+	if d.fluxLen < len(d.flux) || flux < minFlux {
+		return false
+	}
+
+	threshold := mean + d.cfg.Sensitivity*sd
+	if flux <= threshold {
+		return false
+	}
+
+	minInterval := time.Duration(float64(time.Minute) / d.cfg.MaxBPM)
+	if d.last.Count > 0 && now.Sub(d.last.Time) < minInterval {
+		return false
+	}
+
+	strength := math.Inf(1)
+	if threshold > 0 {
+		strength = flux / threshold
+	}
+
+	d.last = Beat{
+		Count:    d.last.Count + 1,
+		Time:     now,
+		Strength: strength,
+	}
+
+	d.times = append(d.times, now)
+	for len(d.times) > 0 && now.Sub(d.times[0]) > tempoWindow {
+		d.times = d.times[1:]
+	}
+
+	return true
+}
+
+// spectralFlux returns the sum of the increases in log magnitude of all bins
+// since the last frame.
+func (d *beatDetector) spectralFlux(bins [][]float64) float64 {
+	n := 0
+	for _, channel := range bins {
+		n += len(channel)
+	}
+	if len(d.prev) != n {
+		d.prev = make([]float64, n)
+	}
+
+	var flux float64
+	i := 0
+	for _, channel := range bins {
+		for _, v := range channel {
+			mag := math.Log1p(math.Max(v, 0))
+			if diff := mag - d.prev[i]; diff > 0 {
+				flux += diff
+			}
+			d.prev[i] = mag
+			i++
+		}
+	}
+
+	return flux
+}
+
+func (d *beatDetector) pushFlux(flux float64) {
+	d.flux[d.fluxPos] = flux
+	d.fluxPos = (d.fluxPos + 1) % len(d.flux)
+	if d.fluxLen < len(d.flux) {
+		d.fluxLen++
+	}
+}
+
+// stats returns the mean and standard deviation of the recent flux values.
+func (d *beatDetector) stats() (mean, sd float64) {
+	if d.fluxLen == 0 {
+		return 0, 0
+	}
+
+	values := d.flux[:d.fluxLen]
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	for _, v := range values {
+		sd += (v - mean) * (v - mean)
+	}
+	sd = math.Sqrt(sd / float64(len(values)))
+
+	return mean, sd
+}
+
+// tempo estimates the tempo in beats per minute from the recent beats. The
+// intervals between beats are folded into the configured BPM range by
+// doubling or halving them, so that skipped or extra beats still count, and
+// the median is taken.
+func (d *beatDetector) tempo(now time.Time) float64 {
+	if len(d.times) == 0 || now.Sub(d.times[len(d.times)-1]) > tempoWindow/2 {
+		return 0
+	}
+	if len(d.times) <= minTempoIntervals {
+		return 0
+	}
+
+	minInterval := 60 / d.cfg.MaxBPM
+	maxInterval := 60 / d.cfg.MinBPM
+
+	intervals := make([]float64, 0, len(d.times)-1)
+	for i := 1; i < len(d.times); i++ {
+		interval := d.times[i].Sub(d.times[i-1]).Seconds()
+		for interval > maxInterval {
+			interval /= 2
+		}
+		for interval < minInterval {
+			interval *= 2
+		}
+		intervals = append(intervals, interval)
+	}
+
+	sort.Float64s(intervals)
+	median := intervals[len(intervals)/2]
+	if len(intervals)%2 == 0 {
+		median = (median + intervals[len(intervals)/2-1]) / 2
+	}
+
+	return 60 / median
+}
@@ -0,0 +1,77 @@
+package ledvis
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestBeatDetector(t *testing.T) {
+	const (
+		frameRate = 60
+		bpm       = 120
+		seconds   = 10
+	)
+
+	d := newBeatDetector(BeatConfig{}, frameRate)
+	rand := rand.New(rand.NewSource(1))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	framesPerBeat := frameRate * 60 / bpm
+	var beats []int
+
+	for frame := 0; frame < frameRate*seconds; frame++ {
+		// Kicks decay over a few frames on top of some background noise.
+		kick := math.Pow(0.5, float64(frame%framesPerBeat)) * 10
+		bins := [][]float64{make([]float64, 16)}
+		for i := range bins[0] {
+			bins[0][i] = rand.Float64() * 0.5
+			if i < 4 {
+				bins[0][i] += kick
+			}
+		}
+
+		now := start.Add(time.Duration(frame) * time.Second / frameRate)
+		if d.update(bins, now) {
+			beats = append(beats, frame)
+		}
+	}
+
+	// The first beat happens before the threshold is primed.
+	if want := seconds*bpm/60 - 1; len(beats) != want {
+		t.Fatalf("detected %d beats, want %d: %v", len(beats), want, beats)
+	}
+	for _, frame := range beats {
+		if frame%framesPerBeat != 0 {
+			t.Errorf("beat at frame %d is not on a kick", frame)
+		}
+	}
+
+	if d.last.Count != len(beats) {
+		t.Errorf("last beat count is %d, want %d", d.last.Count, len(beats))
+	}
+
+	now := start.Add(seconds * time.Second)
+	if tempo := d.tempo(now); math.Abs(tempo-bpm) > 1 {
+		t.Errorf("estimated tempo is %.1f BPM, want %d", tempo, bpm)
+	}
+
+	if tempo := d.tempo(now.Add(time.Minute)); tempo != 0 {
+		t.Errorf("estimated tempo is %.1f BPM long after the last beat, want 0", tempo)
+	}
+}
+
+func TestBeatDetectorTempoFolding(t *testing.T) {
+	d := newBeatDetector(BeatConfig{}, 60)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Beats at 100 BPM with one skipped and one at double time.
+	for _, beat := range []float64{0, 0.6, 1.2, 2.4, 2.7, 3.0, 3.6} {
+		d.times = append(d.times, start.Add(time.Duration(beat*float64(time.Second))))
+	}
+
+	if tempo := d.tempo(start.Add(4 * time.Second)); math.Abs(tempo-100) > 1 {
+		t.Errorf("estimated tempo is %.1f BPM, want 100", tempo)
+	}
+}
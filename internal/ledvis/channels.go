@@ -0,0 +1,138 @@
+package ledvis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChannelStyle is the style to draw the channels in.
+type ChannelStyle uint8
+
+const (
+	// MonoLeft means that a single mono channel is drawn from the left.
+	MonoLeft ChannelStyle = iota
+	// MonoRight means that a single mono channel is drawn from the right.
+	MonoRight
+	// StereoTypeSymmetricMiddle means that the left and right channels are
+	// drawn symmetrically from the middle and outwards.
+	StereoTypeSymmetricMiddle
+	// MonoSymmetricMiddle means that a single mono channel is mirrored from
+	// the middle and outwards.
+	MonoSymmetricMiddle
+	// StereoSplit means that the left channel is drawn on the left half and
+	// the right channel on the right half, both from the ends inwards.
+	StereoSplit
+	// StereoInterleaved means that the left and right channels are drawn
+	// over the whole strip from the left, alternating between LEDs.
+	StereoInterleaved
+
+	maxChannelStyle
+)
+
+// ParseChannelStyle parses the name of a channel style as returned by String.
+func ParseChannelStyle(name string) (ChannelStyle, error) {
+	for s := MonoLeft; s < maxChannelStyle; s++ {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+
+	names := make([]string, maxChannelStyle)
+	for s := MonoLeft; s < maxChannelStyle; s++ {
+		names[s] = s.String()
+	}
+
+	return 0, fmt.Errorf(
+		"unknown channel style %q, must be one of %s",
+		name, strings.Join(names, ", "))
+}
+
+// NumChannels returns the number of channels for the given channel style. It
+// returns 0 for unknown styles.
+func (s ChannelStyle) NumChannels() int {
+	switch s {
+	case MonoLeft, MonoRight, MonoSymmetricMiddle:
+		return 1
+	case StereoTypeSymmetricMiddle, StereoSplit, StereoInterleaved:
+		return 2
+	default:
+		return 0
+	}
+}
+
+func (s ChannelStyle) String() string {
+	switch s {
+	case MonoLeft:
+		return "mono-left"
+	case MonoRight:
+		return "mono-right"
+	case StereoTypeSymmetricMiddle:
+		return "stereo-symmetric-middle"
+	case MonoSymmetricMiddle:
+		return "mono-symmetric-middle"
+	case StereoSplit:
+		return "stereo-split"
+	case StereoInterleaved:
+		return "stereo-interleaved"
+	default:
+		return fmt.Sprintf("ChannelStyle(%d)", uint8(s))
+	}
+}
+
+// segment is a part of the LEDs that one channel is drawn onto.
+type segment struct {
+	channel int
+	// leds maps positions in the segment, starting from where the channel is
+	// drawn from, to LED indices.
+	leds []int
+}
+
+// layout returns the segments of the given number of LEDs for the channel
+// style.
+func (s ChannelStyle) layout(numLEDs int) []segment {
+	half := numLEDs / 2
+
+	switch s {
+	case MonoLeft:
+		return []segment{{0, span(0, numLEDs)}}
+	case MonoRight:
+		return []segment{{0, span(numLEDs-1, -1)}}
+	case MonoSymmetricMiddle:
+		return []segment{
+			{0, span(half-1, -1)},
+			{0, span(half, numLEDs)},
+		}
+	case StereoTypeSymmetricMiddle:
+		return []segment{
+			{0, span(half-1, -1)},
+			{1, span(half, numLEDs)},
+		}
+	case StereoSplit:
+		return []segment{
+			{0, span(0, half)},
+			{1, span(numLEDs-1, half-1)},
+		}
+	case StereoInterleaved:
+		segments := []segment{{channel: 0}, {channel: 1}}
+		for i := 0; i < numLEDs; i++ {
+			segments[i%2].leds = append(segments[i%2].leds, i)
+		}
+		return segments
+	default:
+		return nil
+	}
+}
+
+// span returns the indices from start towards end, excluding end.
+func span(start, end int) []int {
+	step := 1
+	if end < start {
+		step = -1
+	}
+
+	indices := make([]int, 0, (end-start)*step)
+	for i := start; i != end; i += step {
+		indices = append(indices, i)
+	}
+	return indices
+}
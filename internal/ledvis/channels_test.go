@@ -0,0 +1,71 @@
+package ledvis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChannelStyleLayout(t *testing.T) {
+	tests := []struct {
+		style ChannelStyle
+		leds  int
+		want  []segment
+	}{
+		{MonoLeft, 4, []segment{{0, []int{0, 1, 2, 3}}}},
+		{MonoRight, 4, []segment{{0, []int{3, 2, 1, 0}}}},
+		{MonoSymmetricMiddle, 5, []segment{
+			{0, []int{1, 0}},
+			{0, []int{2, 3, 4}},
+		}},
+		{StereoTypeSymmetricMiddle, 6, []segment{
+			{0, []int{2, 1, 0}},
+			{1, []int{3, 4, 5}},
+		}},
+		{StereoSplit, 6, []segment{
+			{0, []int{0, 1, 2}},
+			{1, []int{5, 4, 3}},
+		}},
+		{StereoInterleaved, 5, []segment{
+			{0, []int{0, 2, 4}},
+			{1, []int{1, 3}},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.style.String(), func(t *testing.T) {
+			got := test.style.layout(test.leds)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("layout(%d) = %v, want %v", test.leds, got, test.want)
+			}
+
+			// Every LED must be drawn exactly once by a channel that exists.
+			drawn := make([]int, test.leds)
+			for _, seg := range got {
+				if seg.channel >= test.style.NumChannels() {
+					t.Errorf("segment uses channel %d of %d", seg.channel, test.style.NumChannels())
+				}
+				for _, i := range seg.leds {
+					drawn[i]++
+				}
+			}
+			for i, n := range drawn {
+				if n != 1 {
+					t.Errorf("LED %d is drawn %d times", i, n)
+				}
+			}
+		})
+	}
+}
+
+func TestParseChannelStyle(t *testing.T) {
+	for s := MonoLeft; s < maxChannelStyle; s++ {
+		got, err := ParseChannelStyle(s.String())
+		if err != nil || got != s {
+			t.Errorf("ParseChannelStyle(%q) = %v, %v", s.String(), got, err)
+		}
+	}
+
+	if _, err := ParseChannelStyle("surround"); err == nil {
+		t.Error("expected error for unknown channel style")
+	}
+}
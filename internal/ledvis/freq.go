@@ -0,0 +1,324 @@
+package ledvis
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/noriah/catnip/dsp"
+)
+
+// FrequencyScale is the scale that the frequency bands are evenly spaced on.
+type FrequencyScale string
+
+const (
+	// LinearScale spaces bands evenly in Hz.
+	LinearScale FrequencyScale = "linear"
+	// LogScale spaces bands evenly in octaves.
+	LogScale FrequencyScale = "log"
+	// MelScale spaces bands evenly in pitch as perceived by listeners.
+	MelScale FrequencyScale = "mel"
+	// BarkScale spaces bands evenly in critical bands of hearing.
+	BarkScale FrequencyScale = "bark"
+)
+
+// FrequencyConfig configures how the frequency spectrum is divided into bins.
+// If it is zero, then catnip's default analyzer is used.
+type FrequencyConfig struct {
+	// MinFreq and MaxFreq are the frequency cut-offs in Hz. If zero, then
+	// DefaultMinFreq and DefaultMaxFreq are used.
+	MinFreq, MaxFreq float64
+	// Scale is the scale to space the bins on. If empty, then LogScale is
+	// used.
+	Scale FrequencyScale
+	// Gains is the gain curve in dB from the lowest to the highest bin. The
+	// gains are spread evenly over the bins and interpolated in between, so a
+	// single value applies to all bins.
+	Gains []float64
+}
+
+const (
+	// DefaultMinFreq is the default lower frequency cut-off.
+	DefaultMinFreq = 60
+	// DefaultMaxFreq is the default upper frequency cut-off.
+	DefaultMaxFreq = 8000
+)
+
+// IsZero returns true if no frequency mapping is configured.
+func (c FrequencyConfig) IsZero() bool {
+	return c.MinFreq == 0 && c.MaxFreq == 0 && c.Scale == "" && len(c.Gains) == 0
+}
+
+func (c FrequencyConfig) validate() error {
+	if c.IsZero() {
+		return nil
+	}
+
+	switch c.Scale {
+	case "", LinearScale, LogScale, MelScale, BarkScale:
+	default:
+		return fmt.Errorf("unknown frequency scale %q", c.Scale)
+	}
+
+	min, max := c.bounds()
+	switch {
+	case min <= 0:
+		return fmt.Errorf("minimum frequency %v must be positive", min)
+	case max <= min:
+		return fmt.Errorf("maximum frequency %v must be above the minimum %v", max, min)
+	case max > sampleRate/2:
+		return fmt.Errorf("maximum frequency %v must be at most %v", max, sampleRate/2)
+	}
+
+	return nil
+}
+
+func (c FrequencyConfig) bounds() (min, max float64) {
+	min, max = c.MinFreq, c.MaxFreq
+	if min == 0 {
+		min = DefaultMinFreq
+	}
+	if max == 0 {
+		max = DefaultMaxFreq
+	}
+	return min, max
+}
+
+// analyzer returns the analyzer that divides the spectrum into bins.
+func (c FrequencyConfig) analyzer() dsp.Analyzer {
+	if !c.IsZero() {
+		return newBandAnalyzer(c)
+	}
+
+	return dsp.NewAnalyzer(dsp.AnalyzerConfig{
+		SampleRate: sampleRate,
+		SampleSize: sampleSize,
+		SquashLow:  true,
+		BinMethod:  dsp.MaxSampleValue(),
+	})
+}
+
+// toScale converts a frequency in Hz to the scale.
+func (s FrequencyScale) toScale(f float64) float64 {
+	switch s {
+	case LinearScale:
+		return f
+	case MelScale:
+		return 2595 * math.Log10(1+f/700)
+	case BarkScale:
+		// Traunmüller's formula.
+		return 26.81*f/(1960+f) - 0.53
+	default:
+		return math.Log2(f)
+	}
+}
+
+// fromScale converts a value on the scale back to a frequency in Hz.
+func (s FrequencyScale) fromScale(v float64) float64 {
+	switch s {
+	case LinearScale:
+		return v
+	case MelScale:
+		return 700 * (math.Pow(10, v/2595) - 1)
+	case BarkScale:
+		return 1960 * (v + 0.53) / (26.28 - v)
+	default:
+		return math.Exp2(v)
+	}
+}
+
+// band is a range of FFT bins [floor, ceil) that make up one output bin.
+type band struct {
+	floor, ceil int
+	// gain is the linear gain of the band.
+	gain float64
+}
+
+// bandAnalyzer implements dsp.Analyzer with configurable frequency bands.
+type bandAnalyzer struct {
+	cfg     FrequencyConfig
+	fftSize int
+	bands   []band
+}
+
+var _ dsp.Analyzer = (*bandAnalyzer)(nil)
+
+func newBandAnalyzer(cfg FrequencyConfig) *bandAnalyzer {
+	return &bandAnalyzer{
+		cfg:     cfg,
+		fftSize: sampleSize/2 + 1,
+	}
+}
+
+func (a *bandAnalyzer) BinCount() int {
+	return len(a.bands)
+}
+
+// ProcessBin returns the log magnitude of the loudest FFT bin in the band,
+// the same as catnip's analyzer does by default.
+func (a *bandAnalyzer) ProcessBin(idx int, src []complex128) float64 {
+	band := a.bands[idx]
+
+	var mag float64
+	for _, c := range src[band.floor:band.ceil] {
+		mag = math.Max(mag, math.Hypot(real(c), imag(c)))
+	}
+
+	mag *= band.gain
+	if mag <= 1 {
+		return 0
+	}
+	return math.Log(mag)
+}
+
+func (a *bandAnalyzer) Recalculate(n int) int {
+	if n >= a.fftSize {
+		n = a.fftSize - 1
+	}
+	if n == len(a.bands) {
+		return n
+	}
+
+	a.bands = frequencyBands(a.cfg, n, a.fftSize)
+	return n
+}
+
+// frequencyBands divides the FFT bins into n bands evenly spaced on the
+// configured scale. Every band has at least one FFT bin, so bands at the low
+// end may be wider than the scale asks for if the FFT is too coarse.
+func frequencyBands(cfg FrequencyConfig, n, fftSize int) []band {
+	min, max := cfg.bounds()
+	lo := cfg.Scale.toScale(min)
+	hi := cfg.Scale.toScale(max)
+
+	// binWidth is the width of each FFT bin in Hz.
+	const binWidth = float64(sampleRate) / sampleSize
+
+	freqToIndex := func(f float64) int {
+		i := int(math.Round(f / binWidth))
+		if i >= fftSize {
+			i = fftSize - 1
+		}
+		return i
+	}
+
+	bands := make([]band, n)
+	floor := freqToIndex(min)
+
+	for i := range bands {
+		// Leave room for the remaining bands to have one FFT bin each.
+		limit := fftSize - (n - i)
+		if floor > limit {
+			floor = limit
+		}
+
+		edge := cfg.Scale.fromScale(lo + (hi-lo)*float64(i+1)/float64(n))
+		ceil := freqToIndex(edge)
+		if ceil <= floor {
+			ceil = floor + 1
+		}
+
+		bands[i] = band{
+			floor: floor,
+			ceil:  ceil,
+			gain:  math.Pow(10, gainAt(cfg.Gains, i, n)/20),
+		}
+		floor = ceil
+	}
+
+	return bands
+}
+
+// gainAt returns the gain in dB of bin i out of n by interpolating the gain
+// curve.
+func gainAt(gains []float64, i, n int) float64 {
+	switch {
+	case len(gains) == 0:
+		return 0
+	case len(gains) == 1 || n == 1:
+		return gains[0]
+	}
+
+	pos := float64(i) / float64(n-1) * float64(len(gains)-1)
+	j := int(pos)
+	if j >= len(gains)-1 {
+		return gains[len(gains)-1]
+	}
+
+	frac := pos - float64(j)
+	return gains[j] + (gains[j+1]-gains[j])*frac
+}
+
+// AGCConfig configures automatic gain control, which normalizes the bins by
+// following their peak level. If it is not enabled, then the bins are
+// normalized using the mean and deviation of recent peaks instead.
+type AGCConfig struct {
+	// Enabled enables automatic gain control.
+	Enabled bool
+	// Attack is how quickly the gain drops when the audio gets louder. If
+	// zero, then DefaultAGCAttack is used.
+	Attack time.Duration
+	// Release is how quickly the gain recovers when the audio gets quieter.
+	// If zero, then DefaultAGCRelease is used.
+	Release time.Duration
+}
+
+const (
+	// DefaultAGCAttack is the default attack time of the AGC.
+	DefaultAGCAttack = 50 * time.Millisecond
+	// DefaultAGCRelease is the default release time of the AGC.
+	DefaultAGCRelease = 2 * time.Second
+)
+
+// agcFloor is the lowest level that the AGC normalizes to, so that it does
+// not blow up noise during silence.
+const agcFloor = 0.5
+
+// agc is an automatic gain control. It follows the peak level of the bins
+// with separate attack and release times, and normalizes the bins by it.
+type agc struct {
+	cfg   AGCConfig
+	level float64
+	last  time.Time
+}
+
+func newAGC(cfg AGCConfig) agc {
+	if cfg.Attack == 0 {
+		cfg.Attack = DefaultAGCAttack
+	}
+	if cfg.Release == 0 {
+		cfg.Release = DefaultAGCRelease
+	}
+	return agc{cfg: cfg, level: agcFloor}
+}
+
+// scale normalizes the bins in place.
+func (g *agc) scale(bins [][]float64, now time.Time) {
+	var peak float64
+	for _, channel := range bins {
+		for _, v := range channel {
+			peak = math.Max(peak, v)
+		}
+	}
+
+	dt := now.Sub(g.last)
+	if g.last.IsZero() {
+		dt = 0
+	}
+	g.last = now
+
+	tau := g.cfg.Release
+	if peak > g.level {
+		tau = g.cfg.Attack
+	}
+
+	// Move towards the peak exponentially with the time constant tau.
+	alpha := 1 - math.Exp(-float64(dt)/float64(tau))
+	g.level = math.Max(agcFloor, g.level+(peak-g.level)*alpha)
+
+	for _, channel := range bins {
+		for i, v := range channel {
+			channel[i] = math.Min(v/g.level, 1)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package ledvis
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFrequencyScaleRoundTrip(t *testing.T) {
+	for _, scale := range []FrequencyScale{LinearScale, LogScale, MelScale, BarkScale} {
+		for _, f := range []float64{20, 60, 440, 1000, 8000, 20000} {
+			if got := scale.fromScale(scale.toScale(f)); math.Abs(got-f) > 1e-6*f {
+				t.Errorf("%s: round trip of %v Hz gave %v Hz", scale, f, got)
+			}
+		}
+	}
+}
+
+func TestFrequencyBands(t *testing.T) {
+	const fftSize = sampleSize/2 + 1
+	const binWidth = float64(sampleRate) / sampleSize
+
+	for _, scale := range []FrequencyScale{LinearScale, LogScale, MelScale, BarkScale} {
+		t.Run(string(scale), func(t *testing.T) {
+			cfg := FrequencyConfig{MinFreq: 100, MaxFreq: 10000, Scale: scale}
+			bands := frequencyBands(cfg, 32, fftSize)
+
+			if got := float64(bands[0].floor) * binWidth; math.Abs(got-100) > binWidth {
+				t.Errorf("first band starts at %v Hz, want 100 Hz", got)
+			}
+			if got := float64(bands[len(bands)-1].ceil) * binWidth; math.Abs(got-10000) > binWidth {
+				t.Errorf("last band ends at %v Hz, want 10000 Hz", got)
+			}
+
+			for i, b := range bands {
+				if b.ceil <= b.floor {
+					t.Errorf("band %d is empty: %+v", i, b)
+				}
+				if i > 0 && b.floor != bands[i-1].ceil {
+					t.Errorf("band %d does not continue band %d: %+v, %+v", i, i-1, bands[i-1], b)
+				}
+				if b.gain != 1 {
+					t.Errorf("band %d has gain %v without a gain curve", i, b.gain)
+				}
+			}
+		})
+	}
+
+	t.Run("narrow", func(t *testing.T) {
+		// There are fewer FFT bins in the range than bands, so each band gets
+		// a single FFT bin.
+		cfg := FrequencyConfig{MinFreq: 50, MaxFreq: 200, Scale: LogScale}
+		bands := frequencyBands(cfg, 16, fftSize)
+		for i, b := range bands {
+			if b.ceil-b.floor != 1 {
+				t.Errorf("band %d has %d FFT bins, want 1", i, b.ceil-b.floor)
+			}
+		}
+	})
+}
+
+func TestGainAt(t *testing.T) {
+	tests := []struct {
+		gains []float64
+		i, n  int
+		want  float64
+	}{
+		{nil, 3, 10, 0},
+		{[]float64{6}, 3, 10, 6},
+		{[]float64{0, 10}, 0, 11, 0},
+		{[]float64{0, 10}, 5, 11, 5},
+		{[]float64{0, 10}, 10, 11, 10},
+		{[]float64{-6, 0, 6}, 2, 5, 0},
+		{[]float64{-6, 0, 6}, 3, 5, 3},
+	}
+
+	for _, test := range tests {
+		if got := gainAt(test.gains, test.i, test.n); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("gainAt(%v, %d, %d) = %v, want %v", test.gains, test.i, test.n, got, test.want)
+		}
+	}
+}
+
+func TestAGC(t *testing.T) {
+	g := newAGC(AGCConfig{
+		Enabled: true,
+		Attack:  10 * time.Millisecond,
+		Release: time.Second,
+	})
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	frame := time.Second / 60
+
+	update := func(peak float64) float64 {
+		bins := [][]float64{{peak / 2, peak}}
+		g.scale(bins, now)
+		now = now.Add(frame)
+		return bins[0][1]
+	}
+
+	// Loud audio quickly pulls the level up.
+	for i := 0; i < 10; i++ {
+		update(4)
+	}
+	if math.Abs(g.level-4) > 0.01 {
+		t.Errorf("level after attack is %v, want 4", g.level)
+	}
+	if v := update(4); math.Abs(v-1) > 0.01 {
+		t.Errorf("peak is normalized to %v, want 1", v)
+	}
+
+	// Quieter audio releases slowly.
+	update(2)
+	if g.level < 3.8 {
+		t.Errorf("level dropped to %v right after the audio got quieter", g.level)
+	}
+	for i := 0; i < 60*5; i++ {
+		update(2)
+	}
+	if math.Abs(g.level-2) > 0.05 {
+		t.Errorf("level after release is %v, want 2", g.level)
+	}
+
+	// Silence does not drop the level below the floor.
+	for i := 0; i < 60*10; i++ {
+		update(0)
+	}
+	if g.level != agcFloor {
+		t.Errorf("level during silence is %v, want %v", g.level, agcFloor)
+	}
+}
@@ -0,0 +1,81 @@
+package ledvis
+
+import (
+	"time"
+
+	"libdb.so/catglow/internal/led"
+)
+
+var white = led.RGBColor{255, 255, 255}
+
+// gradient keeps track of the current color of a visualizer.
+type gradient struct {
+	cfg   GradientConfig
+	start time.Time
+	index int
+	color led.RGBColor
+	// peaked is true if the peak bin was above the switch threshold on the
+	// last update. The color only switches when the peak rises above it.
+	peaked bool
+}
+
+func newGradient(cfg GradientConfig) gradient {
+	if len(cfg.Colors) == 0 {
+		cfg.Colors = []led.RGBColor{white}
+	}
+	return gradient{
+		cfg:   cfg,
+		color: cfg.Colors[0],
+	}
+}
+
+// update updates the current color using the given normalized bins. beat is
+// true if a beat was detected in this frame.
+func (g *gradient) update(bins [][]float64, beat bool, now time.Time) {
+	if len(g.cfg.Colors) < 2 {
+		return
+	}
+
+	switch g.cfg.Mode {
+	case PeakGradientMode:
+		if len(bins) == 0 || g.cfg.PeakBin >= len(bins[0]) {
+			return
+		}
+
+		peaked := bins[0][g.cfg.PeakBin] >= g.cfg.PeakSwitch
+		if peaked && !g.peaked {
+			g.next()
+		}
+		g.peaked = peaked
+
+	case BeatGradientMode:
+		if beat {
+			g.next()
+		}
+
+	case DurationGradientMode:
+		if g.cfg.Duration <= 0 {
+			return
+		}
+		if g.start.IsZero() {
+			g.start = now
+		}
+
+		// Blend from the current color into the next one over the duration.
+		elapsed := now.Sub(g.start)
+		for elapsed >= g.cfg.Duration {
+			elapsed -= g.cfg.Duration
+			g.start = g.start.Add(g.cfg.Duration)
+			g.index = (g.index + 1) % len(g.cfg.Colors)
+		}
+
+		from := g.cfg.Colors[g.index]
+		to := g.cfg.Colors[(g.index+1)%len(g.cfg.Colors)]
+		g.color = from.Mix(to, float64(elapsed)/float64(g.cfg.Duration))
+	}
+}
+
+func (g *gradient) next() {
+	g.index = (g.index + 1) % len(g.cfg.Colors)
+	g.color = g.cfg.Colors[g.index]
+}
@@ -0,0 +1,127 @@
+package ledvis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/noriah/catnip/input"
+	"libdb.so/catglow/internal/audioin"
+)
+
+// offline feeds all visualizers of a Sources the same audio, one frame at a
+// time.
+type offline struct {
+	src      *source
+	analysis *analysis
+	// dec is the audio to feed. It is nil once the audio has ended.
+	dec     audioin.Decoder
+	buffers [][]input.Sample
+	frame   []float64
+	// hop is the number of samples per frame.
+	hop int
+}
+
+// NewOfflineSources creates sources for rendering frames faster than real
+// time. Instead of capturing audio, all visualizers hear dec, which advances
+// by one frame on each call to Step, regardless of their backend. If dec is
+// nil, then the visualizers hear silence.
+func NewOfflineSources(dec audioin.Decoder, frameRate int) *Sources {
+	src := newSource(SourceConfig{}, frameRate)
+	src.offline = true
+	src.attached = make(chan struct{}, 1)
+
+	if dec != nil {
+		dec = audioin.Resample(dec, sampleRate, sourceChannels)
+	}
+
+	return &Sources{
+		sources: make(map[SourceConfig]*source),
+		offline: &offline{
+			src:      src,
+			analysis: newAnalysis(),
+			dec:      dec,
+			buffers:  input.MakeBuffers(sourceChannels, sampleSize),
+			frame:    make([]float64, sourceChannels),
+			hop:      sampleRate / src.frameRate,
+		},
+	}
+}
+
+// WaitOutputs waits until n visualizers are attached to the offline sources,
+// so that none of them misses the first frame.
+func (s *Sources) WaitOutputs(ctx context.Context, n int) error {
+	if s.offline == nil {
+		return errors.New("sources are not offline")
+	}
+
+	src := s.offline.src
+	for {
+		src.mu.Lock()
+		attached := len(src.outputs)
+		src.mu.Unlock()
+
+		if attached >= n {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-src.attached:
+		}
+	}
+}
+
+// Step feeds the next frame of audio to the visualizers attached to the
+// offline sources. The visualizers have drawn the frame once Step returns.
+func (s *Sources) Step() error {
+	if s.offline == nil {
+		return errors.New("sources are not offline")
+	}
+	return s.offline.step()
+}
+
+func (o *offline) step() error {
+	// Shift the buffers and append the next frame's samples. At low frame
+	// rates, a frame has more samples than the buffers hold, so only the
+	// latest ones are kept.
+	start := sampleSize - o.hop
+	if start > 0 {
+		for _, buf := range o.buffers {
+			copy(buf, buf[o.hop:])
+		}
+	}
+
+	for i := start; i < sampleSize; i++ {
+		if o.dec != nil {
+			if err := o.dec.ReadFrame(o.frame); err != nil {
+				if !errors.Is(err, io.EOF) {
+					return err
+				}
+				o.dec = nil
+			}
+		}
+		if i < 0 {
+			continue
+		}
+		for ch, buf := range o.buffers {
+			if o.dec != nil {
+				buf[i] = o.frame[ch]
+			} else {
+				buf[i] = 0
+			}
+		}
+	}
+
+	o.analysis.process(o.src, o.buffers, noopLocker{})
+	return nil
+}
+
+type noopLocker struct{}
+
+var _ sync.Locker = noopLocker{}
+
+func (noopLocker) Lock()   {}
+func (noopLocker) Unlock() {}
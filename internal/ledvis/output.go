@@ -1,18 +1,267 @@
 package ledvis
 
 import (
+	"context"
+	"fmt"
 	"sync"
+	"time"
 
+	"github.com/noriah/catnip/dsp"
+	"libdb.so/catglow/internal/clock"
 	"libdb.so/catglow/internal/led"
 )
 
+const (
+	sampleRate = 44100
+	sampleSize = 1024
+)
+
+// drawFunc draws the normalized bins of a channel onto the LEDs of its
+// segment, starting from where the channel is drawn from. Bins are within
+// [0, 1].
+type drawFunc func(leds led.LEDs, channel int, bins []float64)
+
+// updateFunc is called once per frame with the normalized bins of all
+// channels before drawing, for visualizers that keep state across frames.
+type updateFunc func(bins [][]float64, now time.Time)
+
 type baseOutput struct {
 	mu   sync.Mutex
 	leds led.LEDs
+	// device overrides the device of the config if set. switched is closed
+	// once it changes.
+	device   string
+	switched chan struct{}
+
+	cfg      VisualizerConfig
+	draw     drawFunc
+	update   updateFunc
+	segments []segment
+	segBuf   led.LEDs
+	scaler   scaler
+	agc      agc
+	silence  silenceDetector
+	beat     beatDetector
+	gradient gradient
+
+	// analyzer and smoother turn the spectrum into bins. binBufs holds the
+	// bins of each channel, of which nbins are in use. They are only used by
+	// the source that the visualizer is attached to.
+	analyzer dsp.Analyzer
+	smoother dsp.Smoother
+	binBufs  [][]float64
+	nbins    int
 }
 
+func newBaseOutput(cfg VisualizerConfig, draw drawFunc) (*baseOutput, error) {
+	if cfg.FrameRate <= 0 {
+		cfg.FrameRate = 60
+	}
+
+	if cfg.Clock == nil {
+		cfg.Clock = clock.Real
+	}
+
+	if cfg.ChannelStyle.NumChannels() == 0 {
+		return nil, fmt.Errorf("invalid channel style %v", cfg.ChannelStyle)
+	}
+
+	if err := cfg.Frequency.validate(); err != nil {
+		return nil, err
+	}
+
+	nchannels := cfg.ChannelStyle.NumChannels()
+
+	o := &baseOutput{
+		leds:     led.NewLEDs(cfg.NumLEDs),
+		cfg:      cfg,
+		draw:     draw,
+		segments: cfg.ChannelStyle.layout(cfg.NumLEDs),
+		segBuf:   led.NewLEDs(cfg.NumLEDs),
+		scaler:   newScaler(cfg.FrameRate),
+		agc:      newAGC(cfg.AGC),
+		silence:  newSilenceDetector(cfg.Silence, cfg.Clock.Now()),
+		beat:     newBeatDetector(cfg.Beat, cfg.FrameRate),
+		gradient: newGradient(cfg.Gradient),
+		analyzer: cfg.Frequency.analyzer(),
+		smoother: dsp.NewSmoother(dsp.SmootherConfig{
+			SampleSize:      sampleSize,
+			SampleRate:      sampleRate,
+			ChannelCount:    nchannels,
+			SmoothingFactor: cfg.SmoothFactor,
+			SmoothingMethod: dsp.SmoothDefault,
+		}),
+		binBufs:  make([][]float64, nchannels),
+		switched: make(chan struct{}),
+	}
+	for ch := range o.binBufs {
+		o.binBufs[ch] = make([]float64, sampleSize)
+	}
+	o.nbins = o.analyzer.Recalculate(o.bins())
+
+	return o, nil
+}
+
+// AcquireFrame acquires the last drawn frame.
 func (o *baseOutput) AcquireFrame(f func(led.LEDs)) {
 	o.mu.Lock()
 	f(o.leds)
 	o.mu.Unlock()
 }
+
+// Silent returns true if the audio has been silent for long enough.
+func (o *baseOutput) Silent() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.silence.silent(o.cfg.Clock.Now())
+}
+
+// LastBeat returns the most recently detected beat.
+func (o *baseOutput) LastBeat() Beat {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.beat.last
+}
+
+// Tempo returns the estimated tempo in beats per minute, or 0 if it is not
+// known.
+func (o *baseOutput) Tempo() float64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.beat.tempo(o.cfg.Clock.Now())
+}
+
+// SetDevice switches the visualizer to capture from the given device of its
+// backend while it runs. If device is empty, then it goes back to the device
+// it was configured with.
+func (o *baseOutput) SetDevice(device string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if device != o.device {
+		o.device = device
+		close(o.switched)
+		o.switched = make(chan struct{})
+	}
+}
+
+// NextColor advances the gradient to its next color.
+func (o *baseOutput) NextColor() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.gradient.cfg.Colors) > 1 {
+		o.gradient.next()
+	}
+}
+
+// SetGradient replaces the colors of the gradient, starting over from the
+// first one. If colors is empty, then the configured colors are used again.
+func (o *baseOutput) SetGradient(colors []led.RGBColor) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	cfg := o.cfg.Gradient
+	if len(colors) > 0 {
+		cfg.Colors = append([]led.RGBColor(nil), colors...)
+	}
+	o.gradient = newGradient(cfg)
+}
+
+// Run captures audio and draws it until the given context is canceled. If
+// the visualizer was configured with Sources, then the capture is shared with
+// other visualizers using the same source.
+func (o *baseOutput) Run(ctx context.Context) error {
+	for {
+		o.mu.Lock()
+		cfg := o.cfg.source()
+		if o.device != "" {
+			cfg.Device = o.device
+		}
+		switched := o.switched
+		o.mu.Unlock()
+
+		src := o.cfg.Sources.get(cfg, o.cfg.FrameRate)
+
+		srcCtx, cancel := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() { done <- src.run(srcCtx, o) }()
+
+		select {
+		case err := <-done:
+			cancel()
+			return err
+		case <-switched:
+			// Detach from the old source before attaching to the new one.
+			cancel()
+			<-done
+		}
+	}
+}
+
+// process turns the spectrum of each channel into bins and draws them. loudness
+// is the RMS of the loudest channel's samples. It is called by the Source that
+// the visualizer is attached to.
+func (o *baseOutput) process(spectra [][]complex128, loudness float64) {
+	o.mu.Lock()
+	o.silence.update(loudness, o.cfg.Clock.Now())
+	o.mu.Unlock()
+
+	bins := o.binBufs[:len(spectra)]
+	for ch, spectrum := range spectra {
+		for i := range bins[ch][:o.nbins] {
+			bins[ch][i] = o.analyzer.ProcessBin(i, spectrum)
+		}
+	}
+
+	o.smoother.SmoothBuffers(bins)
+
+	// The buffers are as large as the most bins possible, so slice them.
+	channels := make([][]float64, len(bins))
+	for ch := range channels {
+		channels[ch] = bins[ch][:o.nbins]
+	}
+
+	o.write(channels)
+}
+
+func (o *baseOutput) bins() int {
+	if o.cfg.Bins > 0 {
+		return o.cfg.Bins
+	}
+	return o.cfg.NumLEDs
+}
+
+func (o *baseOutput) write(bins [][]float64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := o.cfg.Clock.Now()
+
+	// Beats are detected on the raw bins, since scaling changes with the
+	// recent peaks.
+	beat := o.beat.update(bins, now)
+
+	if o.cfg.AGC.Enabled {
+		o.agc.scale(bins, now)
+	} else {
+		o.scaler.scale(bins)
+	}
+	o.gradient.update(bins, beat, now)
+
+	if o.update != nil {
+		o.update(bins, now)
+	}
+
+	for _, seg := range o.segments {
+		buf := o.segBuf[:len(seg.leds)]
+		o.draw(buf, seg.channel, bins[seg.channel])
+		for i, led := range seg.leds {
+			o.leds[led] = buf[i]
+		}
+	}
+
+	if o.cfg.Flip {
+		o.leds.Reverse()
+	}
+}
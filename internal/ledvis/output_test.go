@@ -0,0 +1,275 @@
+package ledvis
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/audioin"
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+// writeTrack writes half a second of a 100 Hz tone as raw PCM and returns its
+// path and format.
+func writeTrack(t *testing.T) (string, audioin.Format) {
+	t.Helper()
+
+	format := audioin.Format{Encoding: audioin.S16LE, SampleRate: 44100, Channels: 2}
+
+	var track bytes.Buffer
+	for i := 0; i < format.SampleRate/2; i++ {
+		v := int16(20000 * math.Sin(2*math.Pi*100*float64(i)/float64(format.SampleRate)))
+		binary.Write(&track, binary.LittleEndian, [2]int16{v, v})
+	}
+
+	path := filepath.Join(t.TempDir(), "track.pcm")
+	if err := os.WriteFile(path, track.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path, format
+}
+
+// TestReplay replays a reference track through a visualizer using the file
+// backend. The track is half a second of a 100 Hz tone, which should light up
+// the LEDs, and the visualizer should report silence once it ends.
+func TestReplay(t *testing.T) {
+	path, format := writeTrack(t)
+
+	vis, err := NewBlinking(VisualizerConfig{
+		Backend: FileBackend,
+		Device:  path,
+		Format:  format,
+		Pace:    5,
+		NumLEDs: 4,
+		Silence: SilenceConfig{Duration: 50 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- vis.Run(ctx) }()
+
+	waitFor(t, ctx, "LEDs to light up", func() bool { return lit(vis) })
+
+	waitFor(t, ctx, "silence after the track", vis.Silent)
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("Run failed: %v", err)
+	}
+}
+
+// TestSharedSource runs two visualizers with different channel styles and
+// bins from the same source, which should be captured only once.
+func TestSharedSource(t *testing.T) {
+	path, format := writeTrack(t)
+	sources := NewSources()
+
+	cfg := VisualizerConfig{
+		Backend: FileBackend,
+		Device:  path,
+		Format:  format,
+		Loop:    true,
+		NumLEDs: 8,
+		Sources: sources,
+	}
+
+	mono, err := NewGlowing(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.ChannelStyle = StereoTypeSymmetricMiddle
+	cfg.Bins = 2
+	stereo, err := NewBlinking(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sources.sources) != 0 {
+		t.Fatalf("sources were created before running: %v", sources.sources)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, vis := range []interface{ Run(context.Context) error }{mono, stereo} {
+		vis := vis
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := vis.Run(ctx); err != nil {
+				t.Errorf("Run failed: %v", err)
+			}
+		}()
+	}
+
+	waitFor(t, ctx, "both visualizers to light up", func() bool { return lit(mono) && lit(stereo) })
+
+	if len(sources.sources) != 1 {
+		t.Errorf("got %d sources, want 1", len(sources.sources))
+	}
+
+	cancel()
+	wg.Wait()
+
+	for _, src := range sources.sources {
+		src.mu.Lock()
+		capturing := src.stop != nil
+		src.mu.Unlock()
+		if capturing {
+			t.Error("source is still capturing after all visualizers stopped")
+		}
+	}
+}
+
+func lit(vis interface{ AcquireFrame(func(led.LEDs)) }) bool {
+	var lit bool
+	vis.AcquireFrame(func(leds led.LEDs) {
+		for _, c := range leds {
+			lit = lit || c != led.RGBColor{}
+		}
+	})
+	return lit
+}
+
+func waitFor(t *testing.T, ctx context.Context, what string, f func() bool) {
+	t.Helper()
+
+	for !f() {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for %s", what)
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+// TestOffline steps a visualizer through the reference track on a simulated
+// clock. It should light up during the track and report silence after it,
+// without waiting in real time.
+func TestOffline(t *testing.T) {
+	path, format := writeTrack(t)
+
+	dec, closer, err := audioin.OpenFile(path, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+
+	const frameRate = 60
+	sources := NewOfflineSources(dec, frameRate)
+	clock := clock.NewManual(time.Unix(0, 0))
+
+	vis, err := NewBlinking(VisualizerConfig{
+		Backend:   "pipewire", // ignored offline
+		Sources:   sources,
+		NumLEDs:   4,
+		FrameRate: frameRate,
+		Clock:     clock,
+		Silence:   SilenceConfig{Duration: 100 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- vis.Run(ctx) }()
+
+	if err := sources.WaitOutputs(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	var wasLit bool
+	for i := 0; i < frameRate; i++ {
+		if err := sources.Step(); err != nil {
+			t.Fatal(err)
+		}
+		if i < frameRate/2 {
+			wasLit = wasLit || lit(vis)
+		}
+		clock.Advance(time.Second / frameRate)
+	}
+
+	if !wasLit {
+		t.Error("LEDs never lit up during the track")
+	}
+	if !vis.Silent() {
+		t.Error("visualizer is not silent after the track")
+	}
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("Run failed: %v", err)
+	}
+}
+
+// TestSetDevice switches a visualizer from a silent file to the reference
+// track while it runs.
+func TestSetDevice(t *testing.T) {
+	path, format := writeTrack(t)
+
+	silence := filepath.Join(t.TempDir(), "silence.pcm")
+	if err := os.WriteFile(silence, make([]byte, 4*format.SampleRate), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sources := NewSources()
+	vis, err := NewBlinking(VisualizerConfig{
+		Backend: FileBackend,
+		Device:  silence,
+		Format:  format,
+		Loop:    true,
+		NumLEDs: 4,
+		Sources: sources,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- vis.Run(ctx) }()
+
+	time.Sleep(100 * time.Millisecond)
+	if lit(vis) {
+		t.Fatal("LEDs lit up during silence")
+	}
+
+	vis.SetDevice(path)
+	waitFor(t, ctx, "LEDs to light up", func() bool { return lit(vis) })
+
+	sources.mu.Lock()
+	for cfg, src := range sources.sources {
+		src.mu.Lock()
+		capturing := src.stop != nil
+		src.mu.Unlock()
+		if capturing != (cfg.Device == path) {
+			t.Errorf("source %q capturing = %v", cfg.Device, capturing)
+		}
+	}
+	sources.mu.Unlock()
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("Run failed: %v", err)
+	}
+}
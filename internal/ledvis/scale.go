@@ -0,0 +1,60 @@
+package ledvis
+
+import (
+	"math"
+
+	"github.com/noriah/catnip/util"
+)
+
+const (
+	// scalingWindow is the window of peaks to scale over in seconds.
+	scalingWindow = 2.5
+	// peakThreshold is the peak below which no scaling is done.
+	peakThreshold = 0.01
+)
+
+// scaler normalizes bins into [0, 1] by keeping track of recent peaks. It uses
+// the same approach as catnip's terminal display.
+type scaler struct {
+	window *util.MovingWindow
+}
+
+func newScaler(frameRate int) scaler {
+	return scaler{
+		window: util.NewMovingWindow(int(scalingWindow * float64(frameRate))),
+	}
+}
+
+// scale normalizes the bins in place.
+func (s *scaler) scale(bins [][]float64) {
+	var peak float64
+	for _, channel := range bins {
+		for _, v := range channel {
+			peak = math.Max(peak, v)
+		}
+	}
+
+	scale := 1.0
+
+	if peak >= peakThreshold {
+		mean, sd := s.window.Update(peak)
+
+		if t := mean + (1.25 * sd); peak > t {
+			mean, sd = s.window.Drop(5)
+		}
+
+		if t := mean - (1.5 * sd); peak < t {
+			mean, sd = s.window.Drop(5)
+		}
+
+		if t := mean + (1.5 * sd); t > 1.0 {
+			scale = t
+		}
+	}
+
+	for _, channel := range bins {
+		for i, v := range channel {
+			channel[i] = math.Min(v/scale, 1)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package ledvis
+
+import (
+	"math"
+	"time"
+)
+
+// silenceDetector detects when the audio has been quiet for a while.
+type silenceDetector struct {
+	cfg SilenceConfig
+	// quietSince is the time since which the audio has been below the
+	// threshold. It is zero if the audio is currently loud.
+	quietSince time.Time
+	// updated is the time of the latest samples, or of the detector's
+	// creation before any arrive.
+	updated time.Time
+}
+
+// newSilenceDetector creates a detector that starts out quiet, so that a
+// capture that never produces any samples still becomes silent.
+func newSilenceDetector(cfg SilenceConfig, now time.Time) silenceDetector {
+	if cfg.Threshold == 0 {
+		cfg.Threshold = DefaultSilenceThreshold
+	}
+	if cfg.Duration == 0 {
+		cfg.Duration = DefaultSilenceDuration
+	}
+	return silenceDetector{cfg: cfg, quietSince: now, updated: now}
+}
+
+// update updates the detector with the RMS of the latest samples.
+func (d *silenceDetector) update(rms float64, now time.Time) {
+	d.updated = now
+	switch {
+	case rms >= d.cfg.Threshold:
+		d.quietSince = time.Time{}
+	case d.quietSince.IsZero():
+		d.quietSince = now
+	}
+}
+
+// silent returns true if the audio has been quiet for long enough. Not
+// receiving any samples counts as quiet.
+func (d *silenceDetector) silent(now time.Time) bool {
+	since := d.quietSince
+	if since.IsZero() {
+		since = d.updated
+	}
+	return now.Sub(since) >= d.cfg.Duration
+}
+
+// rms returns the root mean square of the given samples.
+func rms(samples []float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, s := range samples {
+		sum += s * s
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
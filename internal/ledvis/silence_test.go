@@ -0,0 +1,82 @@
+package ledvis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+)
+
+// noSamples is a level for seconds in which no samples arrive.
+const noSamples = -1
+
+func TestSilenceDetector(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		cfg  SilenceConfig
+		// levels is the RMS of the audio at every second, or noSamples.
+		levels []float64
+		want   []bool
+	}{
+		{
+			name:   "loud",
+			levels: []float64{0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5},
+			want:   []bool{false, false, false, false, false, false, false},
+		},
+		{
+			name:   "quiet for the default duration",
+			levels: []float64{0.5, 0, 0, 0, 0, 0, 0},
+			want:   []bool{false, false, false, false, false, false, true},
+		},
+		{
+			name:   "at the default threshold",
+			levels: []float64{0.001, 0.001, 0.001, 0.001, 0.001, 0.001, 0.001},
+			want:   []bool{false, false, false, false, false, false, false},
+		},
+		{
+			name:   "below the default threshold",
+			levels: []float64{0.0009, 0.0009, 0.0009, 0.0009, 0.0009, 0.0009, 0.0009},
+			want:   []bool{false, false, false, false, false, true, true},
+		},
+		{
+			name:   "custom threshold",
+			cfg:    SilenceConfig{Threshold: 0.1, Duration: 2 * time.Second},
+			levels: []float64{0.2, 0.05, 0.05, 0.05, 0.1, 0.05},
+			want:   []bool{false, false, false, true, false, false},
+		},
+		{
+			name:   "loud again",
+			cfg:    SilenceConfig{Duration: 2 * time.Second},
+			levels: []float64{0, 0, 0, 0.5, 0, 0},
+			want:   []bool{false, false, true, false, false, false},
+		},
+		{
+			name:   "no samples",
+			levels: []float64{noSamples, noSamples, noSamples, noSamples, noSamples, noSamples},
+			want:   []bool{false, false, false, false, false, true},
+		},
+		{
+			name:   "no samples after loud",
+			levels: []float64{0.5, noSamples, noSamples, noSamples, noSamples, noSamples},
+			want:   []bool{false, false, false, false, false, true},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			clk := clock.NewManual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			d := newSilenceDetector(test.cfg, clk.Now())
+
+			got := make([]bool, len(test.levels))
+			for i, level := range test.levels {
+				if level != noSamples {
+					d.update(level, clk.Now())
+				}
+				got[i] = d.silent(clk.Now())
+				clk.Advance(time.Second)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+package ledvis
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/noriah/catnip/dsp/window"
+	"github.com/noriah/catnip/fft"
+	"github.com/noriah/catnip/input"
+	"github.com/pkg/errors"
+	"libdb.so/catglow/internal/audioin"
+
+	_ "github.com/noriah/catnip/input/all"
+)
+
+// sourceChannels is the number of channels that sources capture. Mono
+// visualizers get the mix of both.
+const sourceChannels = 2
+
+// SourceConfig identifies an audio source. Visualizers with the same source
+// configuration share the same capture.
+type SourceConfig struct {
+	Backend string
+	Device  string
+	Format  audioin.Format
+	Pace    float64
+	Loop    bool
+}
+
+func (c VisualizerConfig) source() SourceConfig {
+	cfg := SourceConfig{
+		Backend: c.Backend,
+		Device:  c.Device,
+	}
+	// The input options only matter to our own backends, so leave them out
+	// for catnip's to not split up their sources needlessly.
+	switch c.Backend {
+	case FileBackend:
+		cfg.Format = c.Format
+		cfg.Pace = c.Pace
+		cfg.Loop = c.Loop
+	case StdinBackend:
+		cfg.Format = c.Format
+	}
+	return cfg
+}
+
+// Sources dedupes audio sources, so that visualizers capturing from the same
+// backend and device share a single capture and FFT. A nil *Sources gives
+// every visualizer its own capture.
+type Sources struct {
+	mu      sync.Mutex
+	sources map[SourceConfig]*source
+	// offline is set if the sources are fed frame by frame.
+	offline *offline
+}
+
+// NewSources creates a new set of shared sources.
+func NewSources() *Sources {
+	return &Sources{sources: make(map[SourceConfig]*source)}
+}
+
+func (s *Sources) get(cfg SourceConfig, frameRate int) *source {
+	if s == nil {
+		return newSource(cfg, frameRate)
+	}
+
+	if s.offline != nil {
+		return s.offline.src
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, ok := s.sources[cfg]
+	if !ok {
+		src = newSource(cfg, frameRate)
+		s.sources[cfg] = src
+	}
+	return src
+}
+
+// source captures audio while visualizers are attached to it and fans out
+// the spectrum of each frame to them.
+type source struct {
+	cfg       SourceConfig
+	frameRate int
+	// offline is true if the source is fed by Sources.Step rather than
+	// capturing audio, in which case attached is signaled whenever an
+	// output attaches.
+	offline  bool
+	attached chan struct{}
+
+	mu      sync.Mutex
+	outputs map[*baseOutput]struct{}
+	// stop stops the capture. It is nil if the source is not capturing.
+	stop context.CancelFunc
+	// done is closed once the capture stops, after which err is set.
+	done chan struct{}
+	err  error
+}
+
+func newSource(cfg SourceConfig, frameRate int) *source {
+	if frameRate <= 0 {
+		frameRate = 60
+	}
+	return &source{
+		cfg:       cfg,
+		frameRate: frameRate,
+		outputs:   make(map[*baseOutput]struct{}),
+	}
+}
+
+// run attaches the output to the source until the context is canceled or the
+// capture fails. The capture is started when the first output attaches and
+// stopped once the last one detaches.
+func (s *source) run(ctx context.Context, o *baseOutput) error {
+	s.mu.Lock()
+	s.outputs[o] = struct{}{}
+	if s.attached != nil {
+		select {
+		case s.attached <- struct{}{}:
+		default:
+		}
+	}
+	if s.stop == nil && !s.offline {
+		captureCtx, stop := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		s.stop = stop
+		s.done = done
+		go func() {
+			err := s.capture(captureCtx)
+			s.mu.Lock()
+			s.err = err
+			s.mu.Unlock()
+			close(done)
+		}()
+	}
+	done := s.done
+	s.mu.Unlock()
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case <-done:
+		s.mu.Lock()
+		err = s.err
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	delete(s.outputs, o)
+	if s.stop != nil && s.done == done && (len(s.outputs) == 0 || isClosed(done)) {
+		// Stop the capture if this was the last output, or let the next
+		// output that attaches restart it if it failed.
+		s.stop()
+		s.stop = nil
+	}
+	s.mu.Unlock()
+
+	return err
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// capture captures audio until the context is canceled.
+func (s *source) capture(ctx context.Context) error {
+	sessionCfg := input.SessionConfig{
+		FrameSize:  sourceChannels,
+		SampleSize: sampleSize,
+		SampleRate: sampleRate,
+	}
+
+	session, closeSession, err := s.startSession(sessionCfg)
+	if err != nil {
+		return err
+	}
+	defer closeSession()
+
+	buffers := input.MakeBuffers(sourceChannels, sampleSize)
+	kickChan := make(chan bool, 1)
+	mu := &sync.Mutex{}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go s.process(ctx, buffers, kickChan, mu)
+
+	if err := session.Start(ctx, buffers, kickChan, mu); err != nil && ctx.Err() == nil {
+		return errors.Wrap(err, "failed to start input session")
+	}
+
+	return nil
+}
+
+// startSession starts the input session for the configured backend. The
+// returned function must be called once the session is done.
+func (s *source) startSession(cfg input.SessionConfig) (input.Session, func() error, error) {
+	noop := func() error { return nil }
+
+	format := s.cfg.Format
+	if format == (audioin.Format{}) {
+		format = audioin.DefaultFormat
+	}
+
+	switch s.cfg.Backend {
+	case FileBackend:
+		if s.cfg.Device == "" {
+			return nil, nil, errors.New("file backend requires a device path")
+		}
+		src := audioin.FileSource(s.cfg.Device, format, s.cfg.Loop)
+		return audioin.NewSession(src, audioin.Options{Pace: s.cfg.Pace}, cfg), noop, nil
+
+	case StdinBackend:
+		src := audioin.PipeSource(s.cfg.Device, format)
+		return audioin.NewSession(src, audioin.Options{}, cfg), noop, nil
+	}
+
+	name := s.cfg.Backend
+	if name == "" {
+		name = input.DefaultBackend()
+	}
+
+	backend, err := input.InitBackend(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg.Device, err = input.GetDevice(backend, s.cfg.Device)
+	if err != nil {
+		backend.Close()
+		return nil, nil, err
+	}
+
+	session, err := backend.Start(cfg)
+	if err != nil {
+		backend.Close()
+		return nil, nil, errors.Wrap(err, "failed to start the input backend")
+	}
+
+	return session, backend.Close, nil
+}
+
+// spectrum is the windowed samples of a channel and their FFT.
+type spectrum struct {
+	samples []float64
+	fft     []complex128
+	plan    *fft.Plan
+	rms     float64
+}
+
+func newSpectrum() *spectrum {
+	s := &spectrum{
+		samples: make([]float64, sampleSize),
+		fft:     make([]complex128, sampleSize/2+1),
+	}
+	fft.InitPlan(&s.plan, s.samples, s.fft)
+	return s
+}
+
+// update measures the samples and computes their FFT.
+func (s *spectrum) update(window window.Function) {
+	s.rms = rms(s.samples)
+	window(s.samples)
+	s.plan.Execute()
+}
+
+// process computes the spectrum of the captured audio and hands it to the
+// attached outputs, whenever new audio is captured or at the frame rate,
+// the same way as catnip's processor.
+func (s *source) process(ctx context.Context, buffers [][]input.Sample, kickChan chan bool, mu *sync.Mutex) {
+	a := newAnalysis()
+
+	dur := time.Second / time.Duration(s.frameRate)
+	ticker := time.NewTicker(dur)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-kickChan:
+		case <-ticker.C:
+		}
+		ticker.Reset(dur)
+
+		a.process(s, buffers, mu)
+	}
+}
+
+// analysis computes the spectra of a source's audio for its outputs.
+type analysis struct {
+	window window.Function
+	// Only compute the spectra that the attached outputs need.
+	mono    *spectrum
+	stereo  []*spectrum
+	outputs []*baseOutput
+}
+
+func newAnalysis() *analysis {
+	return &analysis{
+		window: window.Lanczos(),
+		mono:   newSpectrum(),
+		stereo: []*spectrum{newSpectrum(), newSpectrum()},
+	}
+}
+
+// process computes the spectra of the buffers, which are guarded by mu, and
+// hands them to the outputs attached to s.
+func (a *analysis) process(s *source, buffers [][]input.Sample, mu sync.Locker) {
+	mono, stereo := a.mono, a.stereo
+
+	a.outputs = a.outputs[:0]
+	var needMono, needStereo bool
+
+	s.mu.Lock()
+	for o := range s.outputs {
+		a.outputs = append(a.outputs, o)
+		if o.cfg.ChannelStyle.NumChannels() == 1 {
+			needMono = true
+		} else {
+			needStereo = true
+		}
+	}
+	s.mu.Unlock()
+
+	mu.Lock()
+	if needMono {
+		for i := range mono.samples {
+			mono.samples[i] = (buffers[0][i] + buffers[1][i]) / 2
+		}
+	}
+	if needStereo {
+		for ch, spectrum := range stereo {
+			copy(spectrum.samples, buffers[ch])
+		}
+	}
+	mu.Unlock()
+
+	if needMono {
+		mono.update(a.window)
+	}
+	if needStereo {
+		for _, spectrum := range stereo {
+			spectrum.update(a.window)
+		}
+	}
+
+	monoFFT := [][]complex128{mono.fft}
+	stereoFFT := [][]complex128{stereo[0].fft, stereo[1].fft}
+	stereoRMS := math.Max(stereo[0].rms, stereo[1].rms)
+
+	for _, o := range a.outputs {
+		if o.cfg.ChannelStyle.NumChannels() == 1 {
+			o.process(monoFFT, mono.rms)
+		} else {
+			o.process(stereoFFT, stereoRMS)
+		}
+	}
+}
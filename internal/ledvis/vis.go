@@ -1,52 +1,127 @@
+// Package ledvis implements audio visualizations that are drawn onto LEDs.
 package ledvis
 
-// ChannelStyle is the style to draw the channels in.
-type ChannelStyle uint8
+import (
+	"time"
 
-const (
-	// MonoLeft means that a single mono channel is drawn from the left.
-	MonoLeft ChannelStyle = iota
-	// MonoRight means that a single mono channel is drawn from the right.
-	MonoRight
-	// StereoTypeSymmetricMiddle means that the left and right channels are
-	// drawn symmetrically from the middle and outwards.
-	StereoTypeSymmetricMiddle
+	"libdb.so/catglow/internal/audioin"
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
 )
 
-// NumChannels returns the number of channels for the given channel style.
-func (s ChannelStyle) NumChannels() int {
-	switch s {
-	case MonoLeft, MonoRight:
-		return 1
-	case StereoTypeSymmetricMiddle:
-		return 2
-	default:
-		panic("invalid channel style")
-	}
-}
-
-func (s ChannelStyle) String() string {
-	switch s {
-	case MonoLeft:
-		return "mono-left"
-	case MonoRight:
-		return "mono-right"
-	case StereoTypeSymmetricMiddle:
-		return "stereo-symmetric-middle"
-	default:
-		panic("invalid channel style")
-	}
-}
+const (
+	// FileBackend is the backend that reads an audio file. The device is the
+	// path to a WAV, FLAC or raw PCM file.
+	FileBackend = "file"
+	// StdinBackend is the backend that reads raw PCM from standard input. If
+	// a device is given, then it is the path to a named pipe to read from
+	// instead, such as MPD's FIFO output.
+	StdinBackend = "stdin"
+)
 
 // VisualizerConfig is the configuration for the visualizer.
 type VisualizerConfig struct {
+	// Backend is the catnip input backend to capture audio from, or one of
+	// FileBackend and StdinBackend. If empty, then catnip's default backend
+	// is used.
 	Backend string
-	Device  string
+	// Device is the device to capture audio from. Its meaning depends on the
+	// backend.
+	Device string
+	// Format is the format of raw PCM audio read by FileBackend and
+	// StdinBackend. If zero, then audioin.DefaultFormat is used.
+	Format audioin.Format
+	// Pace is the playback speed of FileBackend relative to real time. If
+	// zero, then the file is played in real time.
+	Pace float64
+	// Loop makes FileBackend play the file again once it ends.
+	Loop bool
+	// Sources shares audio captures between visualizers with the same
+	// backend, device and input options. If nil, then the visualizer
+	// captures audio on its own.
+	Sources *Sources
+	// NumLEDs is the number of LEDs to draw onto.
+	NumLEDs int
 	// Bins is the number of bins to use for the visualizer.
 	// If not set, then the number of LEDs is used.
 	Bins int
+	// Frequency configures how the spectrum is divided into bins.
+	Frequency FrequencyConfig
+	// AGC configures automatic gain control.
+	AGC AGCConfig
 	// SmoothFactor is the smooth factor to use for the visualizer.
 	SmoothFactor float64
 	// ChannelStyle is the channel style to use for the visualizer.
 	ChannelStyle ChannelStyle
+	// Flip flips the drawn LEDs.
+	Flip bool
+	// FrameRate is the number of frames to draw per second.
+	FrameRate int
+	// Clock is the clock that frames are drawn at. If nil, then the real
+	// clock is used.
+	Clock clock.Clock
+	// Gradient is the gradient to color the LEDs with.
+	Gradient GradientConfig
+	// Silence is the configuration for detecting silence.
+	Silence SilenceConfig
+	// Beat is the configuration for detecting beats.
+	Beat BeatConfig
+
+	// Waterfall is the configuration for the waterfall visualizer.
+	Waterfall WaterfallConfig
+	// Pulse is the configuration for the pulse visualizer.
+	Pulse PulseConfig
+	// VU is the configuration for the VU visualizer.
+	VU VUConfig
+	// SpectrumHue is the configuration for the spectrum hue visualizer.
+	SpectrumHue SpectrumHueConfig
+}
+
+// GradientMode is the mode for the gradient.
+type GradientMode string
+
+const (
+	// PeakGradientMode means the gradient is changed based on the peak bin.
+	PeakGradientMode GradientMode = "peak"
+	// DurationGradientMode means the gradient is changed based on the duration.
+	DurationGradientMode GradientMode = "duration"
+	// BeatGradientMode means the gradient is changed on every detected beat.
+	BeatGradientMode GradientMode = "beat"
+	// StaticGradientMode means to only use the first color in the gradient.
+	StaticGradientMode GradientMode = "static"
+)
+
+// GradientConfig is the configuration for the gradient of a visualizer.
+type GradientConfig struct {
+	// Colors is the list of colors to cycle through.
+	// If empty, then white is used.
+	Colors []led.RGBColor
+	// Mode is the mode to cycle through the colors in.
+	Mode GradientMode
+	// PeakSwitch is the normalized value of the peak bin that switches to the
+	// next color. It is only used for PeakGradientMode.
+	PeakSwitch float64
+	// PeakBin is the bin to use for PeakGradientMode.
+	PeakBin int
+	// Duration is the duration of each color for DurationGradientMode.
+	Duration time.Duration
 }
+
+// SilenceConfig is the configuration for detecting silence.
+type SilenceConfig struct {
+	// Threshold is the RMS of the audio samples below which the audio is
+	// considered silent. If zero, then DefaultSilenceThreshold is used.
+	Threshold float64
+	// Duration is how long the audio must be below the threshold to be
+	// considered silent. If zero, then DefaultSilenceDuration is used.
+	Duration time.Duration
+}
+
+const (
+	// DefaultSilenceThreshold is the default RMS threshold for silence. It is
+	// roughly -60 dBFS.
+	DefaultSilenceThreshold = 0.001
+	// DefaultSilenceDuration is the default duration of silence before the
+	// visualizer reports being silent.
+	DefaultSilenceDuration = 5 * time.Second
+)
@@ -0,0 +1,43 @@
+package ledvis
+
+import (
+	"math"
+
+	"libdb.so/catglow/internal/led"
+)
+
+// BeatFlash is a visualization that flashes the LEDs on each beat and lets them
+// fade out until the next one. Unless another gradient mode is configured,
+// it advances to the next gradient color on each beat.
+type BeatFlash struct {
+	*baseOutput
+}
+
+// NewBeatFlash creates a new beat visualizer.
+func NewBeatFlash(cfg VisualizerConfig) (*BeatFlash, error) {
+	if cfg.Gradient.Mode == "" {
+		cfg.Gradient.Mode = BeatGradientMode
+	}
+
+	v := &BeatFlash{}
+
+	var err error
+	v.baseOutput, err = newBaseOutput(cfg, v.draw)
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func (v *BeatFlash) draw(leds led.LEDs, _ int, bins []float64) {
+	var intensity float64
+	if v.beat.last.Count > 0 {
+		// Decay exponentially so that the flash is at about 1% at the end.
+		elapsed := v.cfg.Clock.Now().Sub(v.beat.last.Time)
+		intensity = math.Exp(-4.6 * float64(elapsed) / float64(v.beat.cfg.Decay))
+	}
+
+	color := led.RGBColor{}.Mix(v.gradient.color, intensity)
+	leds.SetRange(0, len(leds), color)
+}
@@ -1,27 +1,40 @@
 package ledvis
 
-import "github.com/noriah/catnip/processor"
+import "libdb.so/catglow/internal/led"
 
 // Blinking is a visualization that blinks the LEDs based on the normalized
 // amplitude of the audio.
 type Blinking struct {
-	cfg VisualizerConfig
+	*baseOutput
 }
 
+// NewBlinking creates a new blinking visualizer.
 func NewBlinking(cfg VisualizerConfig) (*Blinking, error) {
+	v := &Blinking{}
 
-}
+	var err error
+	v.baseOutput, err = newBaseOutput(cfg, v.draw)
+	if err != nil {
+		return nil, err
+	}
 
-type blinkingOutput struct {
-	*Blinking
+	return v, nil
 }
 
-var _ processor.Output = (*blinkingOutput)(nil)
-
-func (o blinkingOutput) Bins() int {
-	return o.cfg.Bins
+func (v *Blinking) draw(leds led.LEDs, _ int, bins []float64) {
+	color := led.RGBColor{}.Mix(v.gradient.color, amplitude(bins))
+	leds.SetRange(0, len(leds), color)
 }
 
-func (o blinkingOutput) Write(bins [][]float64, nchannels int) error {
-	return nil
+// amplitude returns the normalized amplitude of the given bins, which is the
+// mean of all bins.
+func amplitude(bins []float64) float64 {
+	if len(bins) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range bins {
+		sum += v
+	}
+	return sum / float64(len(bins))
 }
@@ -0,0 +1,30 @@
+package ledvis
+
+import "libdb.so/catglow/internal/led"
+
+// Glowing is a visualization that glows each LED based on its frequency bin.
+// If there are fewer bins than LEDs, then each bin is drawn over a section of
+// LEDs.
+type Glowing struct {
+	*baseOutput
+}
+
+// NewGlowing creates a new glowing visualizer.
+func NewGlowing(cfg VisualizerConfig) (*Glowing, error) {
+	v := &Glowing{}
+
+	var err error
+	v.baseOutput, err = newBaseOutput(cfg, v.draw)
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func (v *Glowing) draw(leds led.LEDs, _ int, bins []float64) {
+	for i := range leds {
+		bin := bins[i*len(bins)/len(leds)]
+		leds[i] = led.RGBColor{}.Mix(v.gradient.color, bin)
+	}
+}
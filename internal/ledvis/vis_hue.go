@@ -0,0 +1,63 @@
+package ledvis
+
+import "libdb.so/catglow/internal/led"
+
+// SpectrumHueConfig is the configuration for the spectrum hue visualizer.
+type SpectrumHueConfig struct {
+	// Low and High are the hues within [0, 1] of the lowest and highest bins.
+	// If both are zero, then the hues go from red to violet.
+	Low, High float64
+}
+
+// SpectrumHue is a visualization that colors all LEDs with a hue mapped from
+// the dominant frequency, with the brightness of its bin. It ignores the
+// gradient.
+type SpectrumHue struct {
+	*baseOutput
+	low, high float64
+}
+
+// NewSpectrumHue creates a new spectrum hue visualizer.
+func NewSpectrumHue(cfg VisualizerConfig) (*SpectrumHue, error) {
+	v := &SpectrumHue{
+		low:  cfg.SpectrumHue.Low,
+		high: cfg.SpectrumHue.High,
+	}
+	if v.low == 0 && v.high == 0 {
+		v.high = 0.75
+	}
+
+	var err error
+	v.baseOutput, err = newBaseOutput(cfg, v.draw)
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func (v *SpectrumHue) draw(leds led.LEDs, _ int, bins []float64) {
+	var color led.RGBColor
+	if i := dominantBin(bins); i >= 0 {
+		pos := 0.0
+		if len(bins) > 1 {
+			pos = float64(i) / float64(len(bins)-1)
+		}
+		color = led.HSV(v.low+(v.high-v.low)*pos, 1, bins[i])
+	}
+	leds.SetRange(0, len(leds), color)
+}
+
+// dominantBin returns the index of the loudest bin, or -1 if all bins are
+// silent.
+func dominantBin(bins []float64) int {
+	dominant := -1
+	var loudest float64
+	for i, v := range bins {
+		if v > loudest {
+			dominant = i
+			loudest = v
+		}
+	}
+	return dominant
+}
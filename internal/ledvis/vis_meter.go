@@ -0,0 +1,49 @@
+package ledvis
+
+import (
+	"math"
+
+	"libdb.so/catglow/internal/led"
+)
+
+// Meter is a visualization that shows a horizontal meter based on the
+// normalized amplitude of the audio.
+type Meter struct {
+	*baseOutput
+}
+
+// NewMeter creates a new meter visualizer.
+func NewMeter(cfg VisualizerConfig) (*Meter, error) {
+	v := &Meter{}
+
+	var err error
+	v.baseOutput, err = newBaseOutput(cfg, v.draw)
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func (v *Meter) draw(leds led.LEDs, _ int, bins []float64) {
+	drawMeter(leds, amplitude(bins), v.gradient.color)
+}
+
+// drawMeter draws a meter filled to the given level within [0, 1]. The last
+// lit LED is partially lit for a smoother meter.
+func drawMeter(leds led.LEDs, level float64, color led.RGBColor) {
+	level *= float64(len(leds))
+	full := int(level)
+
+	for i := range leds {
+		switch {
+		case i < full:
+			leds[i] = color
+		case i == full:
+			_, frac := math.Modf(level)
+			leds[i] = led.RGBColor{}.Mix(color, frac)
+		default:
+			leds[i] = led.RGBColor{}
+		}
+	}
+}
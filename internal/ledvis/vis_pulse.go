@@ -0,0 +1,56 @@
+package ledvis
+
+import (
+	"math"
+
+	"libdb.so/catglow/internal/led"
+)
+
+// PulseConfig is the configuration for the pulse visualizer.
+type PulseConfig struct {
+	// Softness is the fraction of the pulse that fades out towards its
+	// edges. If zero, then DefaultPulseSoftness is used.
+	Softness float64
+}
+
+// DefaultPulseSoftness is the default softness of the pulse visualizer.
+const DefaultPulseSoftness = 0.25
+
+// Pulse is a visualization that expands from the center of the LEDs
+// proportionally to the loudness of the audio.
+type Pulse struct {
+	*baseOutput
+	softness float64
+}
+
+// NewPulse creates a new pulse visualizer.
+func NewPulse(cfg VisualizerConfig) (*Pulse, error) {
+	v := &Pulse{softness: cfg.Pulse.Softness}
+	if v.softness == 0 {
+		v.softness = DefaultPulseSoftness
+	}
+
+	var err error
+	v.baseOutput, err = newBaseOutput(cfg, v.draw)
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func (v *Pulse) draw(leds led.LEDs, _ int, bins []float64) {
+	center := float64(len(leds)) / 2
+	// radius is how far the pulse reaches from the center, and fade is the
+	// width of its soft edge.
+	radius := amplitude(bins) * center
+	fade := math.Max(1, v.softness*center)
+
+	for i := range leds {
+		// Measure to the edge of the LED that is closest to the center, so
+		// that nothing is lit when the pulse has no radius.
+		d := math.Abs(float64(i)+0.5-center) - 0.5
+		t := math.Max(0, math.Min(1, (radius-d)/fade))
+		leds[i] = led.RGBColor{}.Mix(v.gradient.color, t)
+	}
+}
@@ -0,0 +1,153 @@
+package ledvis
+
+import (
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/led"
+)
+
+var (
+	red  = led.RGBColor{255, 0, 0}
+	blue = led.RGBColor{0, 0, 255}
+	off  = led.RGBColor{}
+)
+
+func testConfig(numLEDs int) VisualizerConfig {
+	return VisualizerConfig{
+		NumLEDs:  numLEDs,
+		Gradient: GradientConfig{Colors: []led.RGBColor{red}},
+	}
+}
+
+func expectLEDs(t *testing.T, got, want led.LEDs) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d LEDs, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("LED %d is %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestWaterfall(t *testing.T) {
+	cfg := testConfig(4)
+	cfg.Waterfall = WaterfallConfig{BassBins: 2, Speed: 10}
+
+	v, err := NewWaterfall(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	leds := led.NewLEDs(4)
+
+	frame := func(bass float64, at time.Duration) {
+		t.Helper()
+		bins := [][]float64{{bass, bass, 0.5, 0.5}}
+		v.update(bins, start.Add(at))
+		v.draw(leds, 0, bins[0])
+	}
+
+	frame(1, 0)
+	expectLEDs(t, leds, led.LEDs{red, off, off, off})
+
+	// The head updates in place until it is time to scroll.
+	frame(0.5, 50*time.Millisecond)
+	expectLEDs(t, leds, led.LEDs{red.Mix(off, 0.5), off, off, off})
+
+	// 10 LEDs per second scroll by one LED every 100ms.
+	frame(0, 100*time.Millisecond)
+	expectLEDs(t, leds, led.LEDs{off, red.Mix(off, 0.5), off, off})
+
+	// A long gap scrolls by several LEDs at once.
+	frame(1, 300*time.Millisecond)
+	expectLEDs(t, leds, led.LEDs{red, off, off, off.Mix(red, 0.5)})
+}
+
+func TestPulse(t *testing.T) {
+	cfg := testConfig(8)
+	cfg.Pulse.Softness = 0.01
+
+	v, err := NewPulse(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leds := led.NewLEDs(8)
+
+	v.draw(leds, 0, []float64{0, 0})
+	expectLEDs(t, leds, led.LEDs{off, off, off, off, off, off, off, off})
+
+	// Half loudness lights the middle half of the LEDs.
+	v.draw(leds, 0, []float64{0.5, 0.5})
+	expectLEDs(t, leds, led.LEDs{off, off, red, red, red, red, off, off})
+
+	v.draw(leds, 0, []float64{1, 1})
+	expectLEDs(t, leds, led.LEDs{red, red, red, red, red, red, red, red})
+}
+
+func TestVU(t *testing.T) {
+	cfg := testConfig(8)
+	cfg.ChannelStyle = StereoSplit
+	cfg.VU = VUConfig{PeakHold: time.Second, PeakFall: 1}
+
+	v, err := NewVU(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	meters := [2]led.LEDs{led.NewLEDs(4), led.NewLEDs(4)}
+
+	frame := func(left, right float64, at time.Duration) {
+		t.Helper()
+		bins := [][]float64{{left}, {right}}
+		v.update(bins, start.Add(at))
+		for ch, leds := range meters {
+			v.draw(leds, ch, bins[ch])
+		}
+	}
+
+	frame(1, 0.5, 0)
+	expectLEDs(t, meters[0], led.LEDs{red, red, red, white})
+	expectLEDs(t, meters[1], led.LEDs{red, red, white, off})
+
+	// The peaks hold while the audio goes quiet.
+	frame(0.25, 0, 500*time.Millisecond)
+	expectLEDs(t, meters[0], led.LEDs{red, off, off, white})
+	expectLEDs(t, meters[1], led.LEDs{off, off, white, off})
+
+	// After the hold time, the peaks fall by one meter length per second.
+	frame(0.25, 0, 1500*time.Millisecond)
+	if got := v.peaks[0].level; got != 0.25 {
+		t.Errorf("left peak is %v, want it to fall onto the level 0.25", got)
+	}
+	if got := v.peaks[1].level; got != 0 {
+		t.Errorf("right peak is %v, want 0", got)
+	}
+	expectLEDs(t, meters[1], led.LEDs{off, off, off, off})
+}
+
+func TestSpectrumHue(t *testing.T) {
+	cfg := testConfig(2)
+	cfg.SpectrumHue = SpectrumHueConfig{Low: 0, High: 2.0 / 3}
+
+	v, err := NewSpectrumHue(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leds := led.NewLEDs(2)
+
+	v.draw(leds, 0, []float64{1, 0.5, 0.2})
+	expectLEDs(t, leds, led.LEDs{red, red})
+
+	v.draw(leds, 0, []float64{0.2, 0.5, 1})
+	expectLEDs(t, leds, led.LEDs{blue, blue})
+
+	v.draw(leds, 0, []float64{0, 0, 0})
+	expectLEDs(t, leds, led.LEDs{off, off})
+}
@@ -0,0 +1,99 @@
+package ledvis
+
+import (
+	"math"
+	"time"
+
+	"libdb.so/catglow/internal/led"
+)
+
+// VUConfig is the configuration for the VU visualizer.
+type VUConfig struct {
+	// PeakHold is how long the peak dots stay before they fall. If zero,
+	// then DefaultPeakHold is used.
+	PeakHold time.Duration
+	// PeakFall is how fast the peak dots fall in meter lengths per second. If
+	// zero, then DefaultPeakFall is used.
+	PeakFall float64
+	// PeakColor is the color of the peak dots. If nil, then white is used.
+	PeakColor *led.RGBColor
+}
+
+const (
+	// DefaultPeakHold is the default hold time of the VU peak dots.
+	DefaultPeakHold = time.Second
+	// DefaultPeakFall is the default fall speed of the VU peak dots.
+	DefaultPeakFall = 0.5
+)
+
+// VU is a visualization that draws a level meter for each channel with a
+// dot that holds the recent peak level and then slowly falls. It is meant to
+// be used with a stereo channel style.
+type VU struct {
+	*baseOutput
+	cfg   VUConfig
+	peaks []vuPeak
+	last  time.Time
+}
+
+type vuPeak struct {
+	level float64
+	at    time.Time
+}
+
+// NewVU creates a new VU visualizer.
+func NewVU(cfg VisualizerConfig) (*VU, error) {
+	if cfg.VU.PeakHold == 0 {
+		cfg.VU.PeakHold = DefaultPeakHold
+	}
+	if cfg.VU.PeakFall == 0 {
+		cfg.VU.PeakFall = DefaultPeakFall
+	}
+	if cfg.VU.PeakColor == nil {
+		color := white
+		cfg.VU.PeakColor = &color
+	}
+
+	v := &VU{cfg: cfg.VU}
+
+	var err error
+	v.baseOutput, err = newBaseOutput(cfg, v.draw)
+	if err != nil {
+		return nil, err
+	}
+
+	v.peaks = make([]vuPeak, cfg.ChannelStyle.NumChannels())
+	v.baseOutput.update = v.update
+
+	return v, nil
+}
+
+func (v *VU) update(bins [][]float64, now time.Time) {
+	var dt time.Duration
+	if !v.last.IsZero() {
+		dt = now.Sub(v.last)
+	}
+	v.last = now
+
+	for ch := range v.peaks {
+		peak := &v.peaks[ch]
+		level := amplitude(bins[ch])
+
+		switch {
+		case level >= peak.level:
+			peak.level = level
+			peak.at = now
+		case now.Sub(peak.at) > v.cfg.PeakHold:
+			peak.level = math.Max(level, peak.level-v.cfg.PeakFall*dt.Seconds())
+		}
+	}
+}
+
+func (v *VU) draw(leds led.LEDs, channel int, bins []float64) {
+	drawMeter(leds, amplitude(bins), v.gradient.color)
+
+	if peak := v.peaks[channel].level; peak > 0 && len(leds) > 0 {
+		i := int(math.Round(peak * float64(len(leds)-1)))
+		leds[i] = *v.cfg.PeakColor
+	}
+}
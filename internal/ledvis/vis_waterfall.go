@@ -0,0 +1,102 @@
+package ledvis
+
+import (
+	"math"
+	"time"
+
+	"libdb.so/catglow/internal/led"
+)
+
+// WaterfallConfig is the configuration for the waterfall visualizer.
+type WaterfallConfig struct {
+	// BassBins is the number of lowest bins whose mean is the bass energy. If
+	// zero, then the lowest quarter of the bins is used.
+	BassBins int
+	// Speed is how many LEDs per second the history scrolls by. If zero, then
+	// DefaultWaterfallSpeed is used.
+	Speed float64
+}
+
+// DefaultWaterfallSpeed is the default scroll speed of the waterfall
+// visualizer in LEDs per second.
+const DefaultWaterfallSpeed = 30
+
+// Waterfall is a visualization that scrolls the history of the bass energy
+// along the LEDs, with the latest energy at the start.
+type Waterfall struct {
+	*baseOutput
+	cfg WaterfallConfig
+	// history is the bass energy history of each channel, latest first.
+	history [][]float64
+	last    time.Time
+	// steps is the fraction of a step that is left over from the last frame.
+	steps float64
+}
+
+// NewWaterfall creates a new waterfall visualizer.
+func NewWaterfall(cfg VisualizerConfig) (*Waterfall, error) {
+	if cfg.Waterfall.Speed == 0 {
+		cfg.Waterfall.Speed = DefaultWaterfallSpeed
+	}
+
+	v := &Waterfall{cfg: cfg.Waterfall}
+
+	var err error
+	v.baseOutput, err = newBaseOutput(cfg, v.draw)
+	if err != nil {
+		return nil, err
+	}
+
+	v.history = make([][]float64, cfg.ChannelStyle.NumChannels())
+	for i := range v.history {
+		v.history[i] = make([]float64, cfg.NumLEDs)
+	}
+	v.baseOutput.update = v.update
+
+	return v, nil
+}
+
+func (v *Waterfall) update(bins [][]float64, now time.Time) {
+	if !v.last.IsZero() {
+		v.steps += now.Sub(v.last).Seconds() * v.cfg.Speed
+	}
+	v.last = now
+
+	steps := int(v.steps)
+	v.steps -= float64(steps)
+
+	for ch, history := range v.history {
+		if steps > 0 {
+			n := len(history)
+			if steps < n {
+				copy(history[steps:], history[:n-steps])
+			}
+			for i := 0; i < steps && i < n; i++ {
+				history[i] = 0
+			}
+		}
+		// The head of the waterfall always shows the latest energy.
+		if len(history) > 0 {
+			history[0] = bassEnergy(bins[ch], v.cfg.BassBins)
+		}
+	}
+}
+
+func (v *Waterfall) draw(leds led.LEDs, channel int, _ []float64) {
+	history := v.history[channel]
+	for i := range leds {
+		leds[i] = led.RGBColor{}.Mix(v.gradient.color, history[i])
+	}
+}
+
+// bassEnergy returns the mean of the lowest n bins. If n is zero, then the
+// lowest quarter of the bins is used.
+func bassEnergy(bins []float64, n int) float64 {
+	if n <= 0 {
+		n = int(math.Ceil(float64(len(bins)) / 4))
+	}
+	if n > len(bins) {
+		n = len(bins)
+	}
+	return amplitude(bins[:n])
+}
@@ -0,0 +1,318 @@
+// Package mpris follows media players on D-Bus through MPRIS, such as whether
+// they are playing and which track.
+package mpris
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/pkg/errors"
+)
+
+// Names of MPRIS on the bus.
+const (
+	// BusPrefix prefixes the bus names of players.
+	BusPrefix = "org.mpris.MediaPlayer2."
+	// Path is the path of the object that players export.
+	Path dbus.ObjectPath = "/org/mpris/MediaPlayer2"
+	// PlayerInterface is the interface with the state of the player.
+	PlayerInterface = "org.mpris.MediaPlayer2.Player"
+)
+
+// Status is the playback status of a player.
+type Status string
+
+// Statuses that players report.
+const (
+	Playing Status = "Playing"
+	Paused  Status = "Paused"
+	Stopped Status = "Stopped"
+)
+
+// Track is the metadata of a track.
+type Track struct {
+	// ID identifies the track within the player. Some players leave it
+	// empty.
+	ID      string
+	Title   string
+	Artists []string
+	Album   string
+	// ArtURL is the URL of the cover art, usually a file:// or http(s)://
+	// URL.
+	ArtURL string
+}
+
+// Same returns true if both are the same track.
+func (t Track) Same(other Track) bool {
+	return t.ID == other.ID && t.Title == other.Title && t.Album == other.Album &&
+		strings.Join(t.Artists, "\x00") == strings.Join(other.Artists, "\x00")
+}
+
+// Player is the state of a player.
+type Player struct {
+	// Name is the name of the player, which is its bus name without
+	// BusPrefix and the instance suffix, such as "spotify" or "firefox".
+	Name   string
+	Status Status
+	Track  Track
+}
+
+// PlayerName returns the name of the player with the given bus name, or
+// false if it is not a player.
+func PlayerName(busName string) (string, bool) {
+	name, ok := strings.CutPrefix(busName, BusPrefix)
+	if !ok || name == "" {
+		return "", false
+	}
+	// Players that can run more than once add an instance suffix, such as
+	// "firefox.instance_1_84".
+	name, _, _ = strings.Cut(name, ".")
+	return name, true
+}
+
+// Config configures the watcher.
+type Config struct {
+	// Players limits the players that are followed to the ones with these
+	// names. If empty, then all players are followed.
+	Players []string
+	// OnChange is called with the active player whenever it changes or its
+	// state does, or with nil once no player is left. The active player is
+	// the one that started playing last, or any player if none did.
+	OnChange func(*Player)
+	// OnError is called with errors that don't stop the watcher, such as
+	// players that cannot be queried. It may be nil.
+	OnError func(error)
+}
+
+// Watch follows the players on the bus until the context is canceled.
+// OnChange is called from the calling goroutine.
+func Watch(ctx context.Context, conn *dbus.Conn, cfg Config) error {
+	if cfg.OnError == nil {
+		cfg.OnError = func(error) {}
+	}
+
+	w := &watcher{
+		cfg:     cfg,
+		conn:    conn,
+		players: make(map[string]*player),
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+
+	if err := conn.AddMatchSignalContext(ctx,
+		dbus.WithMatchObjectPath(Path),
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+		dbus.WithMatchArg(0, PlayerInterface),
+	); err != nil {
+		return errors.Wrap(err, "failed to watch the players")
+	}
+	if err := conn.AddMatchSignalContext(ctx,
+		dbus.WithMatchSender("org.freedesktop.DBus"),
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg0Namespace(strings.TrimSuffix(BusPrefix, ".")),
+	); err != nil {
+		return errors.Wrap(err, "failed to watch for players")
+	}
+
+	var names []string
+	if err := conn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.ListNames", 0).Store(&names); err != nil {
+		return errors.Wrap(err, "failed to list the names on the bus")
+	}
+	for _, name := range names {
+		var owner string
+		if err := conn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.GetNameOwner", 0, name).Store(&owner); err != nil {
+			// The player may have just quit.
+			continue
+		}
+		w.add(ctx, name, owner)
+	}
+	w.changed()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case sig, ok := <-signals:
+			if !ok {
+				return errors.New("connection closed")
+			}
+			w.handle(ctx, sig)
+		}
+	}
+}
+
+type player struct {
+	Player
+	// played is when the player last started playing, counted in changes.
+	played uint64
+}
+
+type watcher struct {
+	cfg  Config
+	conn *dbus.Conn
+	// players maps the unique names of players to them.
+	players map[string]*player
+	// plays counts how often players started playing.
+	plays  uint64
+	active *Player
+}
+
+func (w *watcher) follows(name string) bool {
+	if len(w.cfg.Players) == 0 {
+		return true
+	}
+	for _, n := range w.cfg.Players {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// add adds the player with the bus name if it is one that is followed.
+func (w *watcher) add(ctx context.Context, busName, owner string) {
+	name, ok := PlayerName(busName)
+	if !ok || !w.follows(name) {
+		return
+	}
+
+	p := &player{Player: Player{Name: name, Status: Stopped}}
+	w.players[owner] = p
+
+	var props map[string]dbus.Variant
+	err := w.conn.Object(owner, Path).
+		CallWithContext(ctx, "org.freedesktop.DBus.Properties.GetAll", 0, PlayerInterface).
+		Store(&props)
+	if err != nil {
+		w.cfg.OnError(errors.Wrapf(err, "failed to get the state of %s", name))
+		return
+	}
+	w.update(p, props)
+}
+
+func (w *watcher) handle(ctx context.Context, sig *dbus.Signal) {
+	switch sig.Name {
+	case "org.freedesktop.DBus.NameOwnerChanged":
+		var name, oldOwner, newOwner string
+		if err := dbus.Store(sig.Body, &name, &oldOwner, &newOwner); err != nil {
+			return
+		}
+		if oldOwner != "" {
+			delete(w.players, oldOwner)
+		}
+		if newOwner != "" {
+			w.add(ctx, name, newOwner)
+		}
+
+	case "org.freedesktop.DBus.Properties.PropertiesChanged":
+		p, ok := w.players[sig.Sender]
+		if !ok {
+			return
+		}
+		var iface string
+		var changed map[string]dbus.Variant
+		var invalidated []string
+		if err := dbus.Store(sig.Body, &iface, &changed, &invalidated); err != nil {
+			return
+		}
+		if len(invalidated) > 0 {
+			// Some players only say that their properties changed.
+			err := w.conn.Object(sig.Sender, Path).
+				CallWithContext(ctx, "org.freedesktop.DBus.Properties.GetAll", 0, PlayerInterface).
+				Store(&changed)
+			if err != nil {
+				w.cfg.OnError(errors.Wrapf(err, "failed to get the state of %s", p.Name))
+			}
+		}
+		w.update(p, changed)
+
+	default:
+		return
+	}
+
+	w.changed()
+}
+
+// update updates the player with its changed properties.
+func (w *watcher) update(p *player, props map[string]dbus.Variant) {
+	if v, ok := props["PlaybackStatus"]; ok {
+		status, _ := v.Value().(string)
+		if Status(status) == Playing && p.Status != Playing {
+			w.plays++
+			p.played = w.plays
+		}
+		p.Status = Status(status)
+	}
+	if v, ok := props["Metadata"]; ok {
+		metadata, _ := v.Value().(map[string]dbus.Variant)
+		p.Track = parseTrack(metadata)
+	}
+}
+
+// changed calls OnChange if the active player or its state changed.
+func (w *watcher) changed() {
+	active := w.activePlayer()
+	if active == nil && w.active == nil {
+		return
+	}
+	if active != nil && w.active != nil && active.Name == w.active.Name &&
+		active.Status == w.active.Status && active.Track.Same(w.active.Track) && active.Track.ArtURL == w.active.Track.ArtURL {
+		return
+	}
+	w.active = active
+	w.cfg.OnChange(active)
+}
+
+func (w *watcher) activePlayer() *Player {
+	players := make([]*player, 0, len(w.players))
+	for _, p := range w.players {
+		players = append(players, p)
+	}
+	if len(players) == 0 {
+		return nil
+	}
+
+	// Prefer playing players, then the ones that played last, then any in a
+	// stable order.
+	sort.Slice(players, func(i, j int) bool {
+		a, b := players[i], players[j]
+		if (a.Status == Playing) != (b.Status == Playing) {
+			return a.Status == Playing
+		}
+		if a.played != b.played {
+			return a.played > b.played
+		}
+		return a.Name < b.Name
+	})
+
+	active := players[0].Player
+	active.Track.Artists = append([]string(nil), active.Track.Artists...)
+	return &active
+}
+
+func parseTrack(metadata map[string]dbus.Variant) Track {
+	var track Track
+	switch id := metadata["mpris:trackid"].Value().(type) {
+	case dbus.ObjectPath:
+		track.ID = string(id)
+	case string:
+		track.ID = id
+	}
+	track.Title, _ = metadata["xesam:title"].Value().(string)
+	track.Album, _ = metadata["xesam:album"].Value().(string)
+	track.ArtURL, _ = metadata["mpris:artUrl"].Value().(string)
+	switch artists := metadata["xesam:artist"].Value().(type) {
+	case []string:
+		track.Artists = artists
+	case string:
+		// Some players send a single artist.
+		track.Artists = []string{artists}
+	}
+	return track
+}
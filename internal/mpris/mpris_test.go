@@ -0,0 +1,191 @@
+package mpris
+
+import (
+	"bufio"
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// startBus starts a private session bus for the test and returns its
+// address. The test is skipped if dbus-daemon is not installed.
+func startBus(t *testing.T) string {
+	t.Helper()
+
+	path, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+
+	cmd := exec.Command(path, "--session", "--nofork", "--nopidfile", "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatal("failed to read the bus address:", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+func connect(t *testing.T, address string) *dbus.Conn {
+	t.Helper()
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// mockPlayer is a player on the bus that only has the properties that the
+// watcher reads.
+type mockPlayer struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+func newMockPlayer(t *testing.T, address, name string, status Status, track Track) *mockPlayer {
+	t.Helper()
+
+	conn := connect(t, address)
+	props, err := prop.Export(conn, Path, prop.Map{
+		PlayerInterface: {
+			"PlaybackStatus": {Value: string(status), Emit: prop.EmitTrue},
+			"Metadata":       {Value: metadata(track), Emit: prop.EmitTrue},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := conn.RequestName(BusPrefix+name, dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatal(err)
+	}
+
+	return &mockPlayer{conn: conn, props: props}
+}
+
+func metadata(track Track) map[string]dbus.Variant {
+	return map[string]dbus.Variant{
+		"mpris:trackid": dbus.MakeVariant(dbus.ObjectPath(track.ID)),
+		"xesam:title":   dbus.MakeVariant(track.Title),
+		"xesam:artist":  dbus.MakeVariant(track.Artists),
+		"mpris:artUrl":  dbus.MakeVariant(track.ArtURL),
+	}
+}
+
+func (p *mockPlayer) setStatus(status Status) {
+	p.props.SetMust(PlayerInterface, "PlaybackStatus", string(status))
+}
+
+func (p *mockPlayer) setTrack(track Track) {
+	p.props.SetMust(PlayerInterface, "Metadata", metadata(track))
+}
+
+func TestWatch(t *testing.T) {
+	address := startBus(t)
+
+	song := Track{ID: "/track/1", Title: "Song", Artists: []string{"Band"}, ArtURL: "file:///tmp/cover.png"}
+	spotify := newMockPlayer(t, address, "spotify", Paused, song)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	changes := make(chan *Player, 16)
+	conn := connect(t, address)
+	watched := make(chan error, 1)
+	go func() {
+		watched <- Watch(ctx, conn, Config{
+			Players:  []string{"spotify", "firefox"},
+			OnChange: func(p *Player) { changes <- p },
+			OnError:  func(err error) { t.Error(err) },
+		})
+	}()
+
+	next := func() *Player {
+		t.Helper()
+		select {
+		case p := <-changes:
+			return p
+		case <-time.After(5 * time.Second):
+			t.Fatal("no change")
+			return nil
+		}
+	}
+
+	if p := next(); p == nil || p.Name != "spotify" || p.Status != Paused || p.Track.Title != "Song" || p.Track.ArtURL != song.ArtURL {
+		t.Fatalf("got %+v, want the paused spotify player", p)
+	}
+
+	spotify.setStatus(Playing)
+	if p := next(); p.Status != Playing {
+		t.Errorf("got status %q, want playing", p.Status)
+	}
+
+	song2 := Track{ID: "/track/2", Title: "Other Song", Artists: []string{"Band"}}
+	spotify.setTrack(song2)
+	if p := next(); !p.Track.Same(song2) {
+		t.Errorf("got track %+v, want %+v", p.Track, song2)
+	}
+
+	// Players that are not followed are ignored.
+	newMockPlayer(t, address, "vlc", Playing, song)
+
+	// A player that starts playing takes over.
+	firefox := newMockPlayer(t, address, "firefox.instance_1_84", Paused, song)
+	firefox.setStatus(Playing)
+	if p := next(); p.Name != "firefox" || p.Status != Playing {
+		t.Errorf("got %+v, want the playing firefox player", p)
+	}
+
+	// Once it quits, the other player is active again.
+	firefox.conn.Close()
+	if p := next(); p.Name != "spotify" || p.Status != Playing {
+		t.Errorf("got %+v, want the playing spotify player", p)
+	}
+
+	spotify.conn.Close()
+	if p := next(); p != nil {
+		t.Errorf("got %+v, want no player", p)
+	}
+
+	cancel()
+	if err := <-watched; err != context.Canceled {
+		t.Errorf("Watch returned %v, want context.Canceled", err)
+	}
+}
+
+func TestPlayerName(t *testing.T) {
+	tests := []struct {
+		busName string
+		name    string
+		ok      bool
+	}{
+		{"org.mpris.MediaPlayer2.spotify", "spotify", true},
+		{"org.mpris.MediaPlayer2.firefox.instance_1_84", "firefox", true},
+		{"org.mpris.MediaPlayer2.", "", false},
+		{"org.freedesktop.Notifications", "", false},
+	}
+	for _, test := range tests {
+		name, ok := PlayerName(test.busName)
+		if name != test.name || ok != test.ok {
+			t.Errorf("PlayerName(%q) = %q, %v, want %q, %v", test.busName, name, ok, test.name, test.ok)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package openrgb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/led"
+)
+
+type fakeController struct {
+	mu     sync.Mutex
+	scene  string
+	direct led.LEDs
+}
+
+func (c *fakeController) Scenes() []string { return []string{"music", "off"} }
+
+func (c *fakeController) Scene() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.scene
+}
+
+func (c *fakeController) SetScene(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.scene = name
+	return nil
+}
+
+func (c *fakeController) DrawDirect(colors []led.RGBColor) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.direct = append(led.LEDs(nil), colors...)
+}
+
+func (c *fakeController) StopDirect() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.direct = nil
+}
+
+func (c *fakeController) directColors() led.LEDs {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.direct
+}
+
+// client is a minimal SDK client.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+func (c *client) send(id PacketID, data []byte) {
+	c.t.Helper()
+	if err := WritePacket(c.conn, 0, id, data); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) request(id PacketID, data []byte) []byte {
+	c.t.Helper()
+	c.send(id, data)
+
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	h, reply, err := ReadPacket(c.conn)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if h.ID != id {
+		c.t.Fatalf("got reply %d, want %d", h.ID, id)
+	}
+	return reply
+}
+
+// sync makes sure that the packets sent before it were handled, since the
+// server handles the packets of a client in order.
+func (c *client) sync() {
+	c.t.Helper()
+	c.request(RequestControllerCount, nil)
+}
+
+func u32(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+
+func startServer(t *testing.T) (*fakeController, *client) {
+	ctrl := &fakeController{scene: "music"}
+	s := NewServer(ctrl, Info{
+		Name:  "test",
+		Zones: []Zone{{Name: "desk", NumLEDs: 2}, {Name: "shelf", NumLEDs: 3}},
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- s.Serve(ctx, l) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-serveErr; err != context.Canceled {
+			t.Errorf("Serve failed: %v", err)
+		}
+	})
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return ctrl, &client{t, conn}
+}
+
+func TestControllerData(t *testing.T) {
+	_, c := startServer(t)
+
+	if got := c.request(RequestProtocolVersion, u32(3)); !bytes.Equal(got, u32(ProtocolVersion)) {
+		t.Errorf("got protocol version %x", got)
+	}
+	c.send(SetClientName, []byte("test client\x00"))
+	if got := c.request(RequestControllerCount, nil); !bytes.Equal(got, u32(1)) {
+		t.Errorf("got controller count %x, want 1", got)
+	}
+
+	data := c.request(RequestControllerData, u32(3))
+	d := &decoder{b: data}
+	str := func() string {
+		b := d.next(int(d.uint16()))
+		return string(bytes.TrimSuffix(b, []byte{0}))
+	}
+
+	if size := d.uint32(); int(size) != len(data) {
+		t.Errorf("got size %d, want %d", size, len(data))
+	}
+	if typ := DeviceType(d.uint32()); typ != DeviceLEDStrip {
+		t.Errorf("got type %d", typ)
+	}
+	if name, vendor := str(), str(); name != "test" || vendor != "catglow" {
+		t.Errorf("got name %q and vendor %q", name, vendor)
+	}
+	str() // description
+	str() // version
+	str() // serial
+	str() // location
+
+	var modes []string
+	numModes := int(d.uint16())
+	if active := d.uint32(); active != 1 {
+		t.Errorf("got active mode %d, want the music scene", active)
+	}
+	for i := 0; i < numModes; i++ {
+		modes = append(modes, str())
+		d.next(4 * 9) // value to color mode
+		d.colors()
+	}
+	if want := []string{"Direct", "music", "off"}; !reflect.DeepEqual(modes, want) {
+		t.Errorf("got modes %v, want %v", modes, want)
+	}
+
+	var zones []Zone
+	numZones := int(d.uint16())
+	for i := 0; i < numZones; i++ {
+		zone := Zone{Name: str()}
+		d.next(4 * 3) // type, LEDs min and max
+		zone.NumLEDs = int(d.uint32())
+		d.next(int(d.uint16())) // matrix map
+		zones = append(zones, zone)
+	}
+	if want := []Zone{{"desk", 2}, {"shelf", 3}}; !reflect.DeepEqual(zones, want) {
+		t.Errorf("got zones %v, want %v", zones, want)
+	}
+
+	numLEDs := int(d.uint16())
+	for i := 0; i < numLEDs; i++ {
+		str()
+		d.uint32()
+	}
+	if colors := d.colors(); numLEDs != 5 || len(colors) != 5 {
+		t.Errorf("got %d LEDs and %d colors, want 5", numLEDs, len(colors))
+	}
+
+	if d.err != nil || len(d.b) != 0 {
+		t.Errorf("got error %v with %d bytes left", d.err, len(d.b))
+	}
+}
+
+func TestDirect(t *testing.T) {
+	ctrl, c := startServer(t)
+
+	// UpdateLEDs as sent by OpenRGB, with two colors for the first zone.
+	c.conn.Write([]byte{
+		'O', 'R', 'G', 'B',
+		0x00, 0x00, 0x00, 0x00, // device 0
+		0x1a, 0x04, 0x00, 0x00, // UpdateLEDs
+		0x0e, 0x00, 0x00, 0x00, // 14 bytes
+		0x0e, 0x00, 0x00, 0x00, // size
+		0x02, 0x00, // 2 colors
+		0xff, 0x00, 0x00, 0x00,
+		0x00, 0xff, 0x00, 0x00,
+	})
+	c.sync()
+
+	want := led.LEDs{{255, 0, 0}, {0, 255, 0}, {}, {}, {}}
+	if got := ctrl.directColors(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// The second zone starts after the first.
+	zone := append(u32(0), u32(1)...)
+	zone = append(zone, 1, 0, 0, 0, 255, 0)
+	c.send(UpdateZoneLEDs, zone)
+	c.send(UpdateSingleLED, append(u32(4), 1, 2, 3, 0))
+	c.sync()
+
+	want[2] = led.RGBColor{0, 0, 255}
+	want[4] = led.RGBColor{1, 2, 3}
+	if got := ctrl.directColors(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Switching to a scene's mode stops direct control.
+	c.send(UpdateMode, append(u32(0), u32(2)...))
+	c.sync()
+	if ctrl.directColors() != nil || ctrl.Scene() != "off" {
+		t.Errorf("got direct %v and scene %q, want the off scene", ctrl.directColors(), ctrl.Scene())
+	}
+
+	// Custom mode goes back to the colors that were set.
+	c.send(SetCustomMode, nil)
+	c.sync()
+	if got := ctrl.directColors(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Direct mode stops once the client disconnects.
+	c.conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for ctrl.directColors() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("direct mode did not stop after the client disconnected")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,274 @@
+// Package openrgb implements the server side of the OpenRGB network SDK
+// protocol, so that OpenRGB and other SDK clients see the strip as a device.
+package openrgb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"libdb.so/catglow/internal/led"
+)
+
+// Port is the default port of the SDK server.
+const Port = 6742
+
+// ProtocolVersion is the highest protocol version that the server speaks.
+// Version 1 adds the vendor to devices.
+const ProtocolVersion = 1
+
+// PacketID is the ID of a packet.
+type PacketID uint32
+
+const (
+	RequestControllerCount PacketID = 0
+	RequestControllerData  PacketID = 1
+	RequestProtocolVersion PacketID = 40
+	SetClientName          PacketID = 50
+	DeviceListUpdated      PacketID = 100
+	ResizeZone             PacketID = 1000
+	UpdateLEDs             PacketID = 1050
+	UpdateZoneLEDs         PacketID = 1051
+	UpdateSingleLED        PacketID = 1052
+	SetCustomMode          PacketID = 1100
+	UpdateMode             PacketID = 1101
+	SaveMode               PacketID = 1102
+)
+
+var magic = [4]byte{'O', 'R', 'G', 'B'}
+
+// headerSize is the size of a packet header.
+const headerSize = 16
+
+// maxDataSize limits the size of packets that are read, which is plenty for
+// the colors of a strip.
+const maxDataSize = 1 << 20
+
+// Header is the header of a packet.
+type Header struct {
+	// Device is the index of the device that the packet is for.
+	Device uint32
+	// ID is the ID of the packet.
+	ID PacketID
+	// Size is the size of the data that follows.
+	Size uint32
+}
+
+// ErrInvalid is returned when reading something that is not a packet.
+var ErrInvalid = errors.New("not an OpenRGB packet")
+
+// ReadPacket reads a packet.
+func ReadPacket(r io.Reader) (Header, []byte, error) {
+	var b [headerSize]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return Header{}, nil, err
+	}
+	if !bytes.Equal(b[:4], magic[:]) {
+		return Header{}, nil, ErrInvalid
+	}
+
+	h := Header{
+		Device: binary.LittleEndian.Uint32(b[4:]),
+		ID:     PacketID(binary.LittleEndian.Uint32(b[8:])),
+		Size:   binary.LittleEndian.Uint32(b[12:]),
+	}
+	if h.Size > maxDataSize {
+		return Header{}, nil, fmt.Errorf("packet too large: %d bytes", h.Size)
+	}
+
+	data := make([]byte, h.Size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return Header{}, nil, err
+	}
+	return h, data, nil
+}
+
+// WritePacket writes a packet with the given data.
+func WritePacket(w io.Writer, device uint32, id PacketID, data []byte) error {
+	b := make([]byte, headerSize, headerSize+len(data))
+	copy(b, magic[:])
+	binary.LittleEndian.PutUint32(b[4:], device)
+	binary.LittleEndian.PutUint32(b[8:], uint32(id))
+	binary.LittleEndian.PutUint32(b[12:], uint32(len(data)))
+	_, err := w.Write(append(b, data...))
+	return err
+}
+
+// DeviceType is the type of a device.
+type DeviceType int32
+
+// DeviceLEDStrip is the type of LED strips.
+const DeviceLEDStrip DeviceType = 4
+
+// ZoneType is the type of a zone.
+type ZoneType int32
+
+// ZoneLinear is the type of zones whose LEDs are in a line.
+const ZoneLinear ZoneType = 1
+
+// ModeFlags are the capabilities of a mode.
+type ModeFlags uint32
+
+// ModeHasPerLEDColor marks modes that show the colors of the LEDs.
+const ModeHasPerLEDColor ModeFlags = 1 << 5
+
+// ColorMode is how a mode uses colors.
+type ColorMode uint32
+
+const (
+	ColorModeNone   ColorMode = 0
+	ColorModePerLED ColorMode = 1
+)
+
+// Device describes the device that the server exposes.
+type Device struct {
+	Name        string
+	Vendor      string
+	Description string
+	Version     string
+	Serial      string
+	Location    string
+	// Modes are the modes of the device.
+	Modes []Mode
+	// ActiveMode is the index of the active mode.
+	ActiveMode int
+	// Zones are the zones of the device, which make up its LEDs in order.
+	Zones []Zone
+	// Colors are the colors of the LEDs.
+	Colors []led.RGBColor
+}
+
+// Mode is a mode of a device.
+type Mode struct {
+	Name      string
+	Flags     ModeFlags
+	ColorMode ColorMode
+}
+
+// Zone is a zone of a device.
+type Zone struct {
+	Name string
+	// NumLEDs is the number of LEDs in the zone.
+	NumLEDs int
+}
+
+// encoder encodes the little-endian data of packets.
+type encoder struct {
+	bytes.Buffer
+}
+
+func (e *encoder) uint16(v uint16) { binary.Write(&e.Buffer, binary.LittleEndian, v) }
+func (e *encoder) uint32(v uint32) { binary.Write(&e.Buffer, binary.LittleEndian, v) }
+
+// string writes a null-terminated string prefixed by its length.
+func (e *encoder) string(s string) {
+	e.uint16(uint16(len(s) + 1))
+	e.WriteString(s)
+	e.WriteByte(0)
+}
+
+func (e *encoder) colors(colors []led.RGBColor) {
+	e.uint16(uint16(len(colors)))
+	for _, c := range colors {
+		e.Write([]byte{c[0], c[1], c[2], 0})
+	}
+}
+
+// MarshalVersion encodes the device for the given protocol version, which is
+// the data of a RequestControllerData reply.
+func (d Device) MarshalVersion(version uint32) []byte {
+	var e encoder
+	e.uint32(0) // size, filled in below
+	e.uint32(uint32(DeviceLEDStrip))
+	e.string(d.Name)
+	if version >= 1 {
+		e.string(d.Vendor)
+	}
+	e.string(d.Description)
+	e.string(d.Version)
+	e.string(d.Serial)
+	e.string(d.Location)
+
+	e.uint16(uint16(len(d.Modes)))
+	e.uint32(uint32(int32(d.ActiveMode)))
+	for i, mode := range d.Modes {
+		e.string(mode.Name)
+		e.uint32(uint32(i)) // value
+		e.uint32(uint32(mode.Flags))
+		e.uint32(0) // speed min
+		e.uint32(0) // speed max
+		e.uint32(0) // colors min
+		e.uint32(0) // colors max
+		e.uint32(0) // speed
+		e.uint32(0) // direction
+		e.uint32(uint32(mode.ColorMode))
+		e.colors(nil)
+	}
+
+	e.uint16(uint16(len(d.Zones)))
+	for _, zone := range d.Zones {
+		e.string(zone.Name)
+		e.uint32(uint32(ZoneLinear))
+		e.uint32(uint32(zone.NumLEDs)) // LEDs min
+		e.uint32(uint32(zone.NumLEDs)) // LEDs max
+		e.uint32(uint32(zone.NumLEDs)) // LEDs count
+		e.uint16(0)                    // no matrix map
+	}
+
+	e.uint16(uint16(len(d.Colors)))
+	for i := range d.Colors {
+		e.string(fmt.Sprintf("LED %d", i+1))
+		e.uint32(uint32(i))
+	}
+	e.colors(d.Colors)
+
+	b := e.Bytes()
+	binary.LittleEndian.PutUint32(b, uint32(len(b)))
+	return b
+}
+
+// decoder decodes the little-endian data of packets.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.b) < n {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *decoder) uint16() uint16 {
+	if b := d.next(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) uint32() uint32 {
+	if b := d.next(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) colors() []led.RGBColor {
+	n := int(d.uint16())
+	colors := make([]led.RGBColor, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		if b := d.next(4); b != nil {
+			colors = append(colors, led.RGBColor{b[0], b[1], b[2]})
+		}
+	}
+	return colors
+}
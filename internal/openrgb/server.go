@@ -0,0 +1,286 @@
+package openrgb
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"net"
+	"sync"
+
+	"github.com/pkg/errors"
+	"libdb.so/catglow/internal/led"
+)
+
+// Controller is what the server controls.
+type Controller interface {
+	// Scenes returns the names of the scenes, which are the modes of the
+	// device besides direct mode.
+	Scenes() []string
+	// Scene returns the name of the current scene.
+	Scene() string
+	// SetScene switches to the scene with the given name.
+	SetScene(name string) error
+	// DrawDirect shows the colors instead of the scene until StopDirect is
+	// called. StopDirect is called at the latest when the client that set
+	// the colors disconnects.
+	DrawDirect(colors []led.RGBColor)
+	// StopDirect goes back to showing the scene.
+	StopDirect()
+}
+
+// Info describes the device to clients.
+type Info struct {
+	// Name is the name of the device.
+	Name string
+	// Zones are the zones of the device, which make up all of its LEDs.
+	Zones []Zone
+}
+
+// directMode is the index of direct mode, which comes before the scenes.
+const directMode = 0
+
+// Server serves a single device over the OpenRGB SDK protocol. The device
+// has a mode for each scene and a direct mode, which shows the colors set
+// by clients. Setting colors switches to direct mode.
+type Server struct {
+	ctrl Controller
+	info Info
+
+	mu     sync.Mutex
+	direct bool
+	colors led.LEDs
+	// owner is the client that last switched to direct mode.
+	owner net.Conn
+}
+
+// NewServer creates a new server for the controller.
+func NewServer(ctrl Controller, info Info) *Server {
+	var numLEDs int
+	for _, zone := range info.Zones {
+		numLEDs += zone.NumLEDs
+	}
+	return &Server{
+		ctrl:   ctrl,
+		info:   info,
+		colors: led.NewLEDs(numLEDs),
+	}
+}
+
+// Device returns the device as it is currently described to clients.
+func (s *Server) Device() Device {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := Device{
+		Name:        s.info.Name,
+		Vendor:      "catglow",
+		Description: "catglow LED strip",
+		Location:    "catglow",
+		Modes:       []Mode{{Name: "Direct", Flags: ModeHasPerLEDColor, ColorMode: ColorModePerLED}},
+		ActiveMode:  directMode,
+		Zones:       s.info.Zones,
+		Colors:      append([]led.RGBColor(nil), s.colors...),
+	}
+
+	current := s.ctrl.Scene()
+	for i, scene := range s.ctrl.Scenes() {
+		d.Modes = append(d.Modes, Mode{Name: scene, ColorMode: ColorModeNone})
+		if !s.direct && scene == current {
+			d.ActiveMode = i + 1
+		}
+	}
+
+	return d
+}
+
+// ListenAndServe serves clients on the given address until the context is
+// canceled.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen")
+	}
+	return s.Serve(ctx, l)
+}
+
+// Serve serves clients on the listener until the context is canceled. The
+// listener is closed once Serve returns.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return errors.Wrap(err, "failed to accept")
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// serveConn serves a client until it disconnects, sends something invalid
+// or the context is canceled.
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	defer s.disconnect(conn)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		h, data, err := ReadPacket(r)
+		if err != nil {
+			return
+		}
+		if err := s.handle(conn, h, data); err != nil {
+			return
+		}
+	}
+}
+
+// disconnect stops direct mode if the client that switched to it disconnects,
+// so that the strip does not stay on its colors forever.
+func (s *Server) disconnect(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.direct && s.owner == conn {
+		s.direct = false
+		s.owner = nil
+		s.ctrl.StopDirect()
+	}
+}
+
+// handle handles a packet, writing the reply, if any, to w.
+func (s *Server) handle(w net.Conn, h Header, data []byte) error {
+	d := &decoder{b: data}
+
+	switch h.ID {
+	case RequestControllerCount:
+		return WritePacket(w, h.Device, h.ID, binary.LittleEndian.AppendUint32(nil, 1))
+
+	case RequestProtocolVersion:
+		return WritePacket(w, h.Device, h.ID, binary.LittleEndian.AppendUint32(nil, ProtocolVersion))
+
+	case RequestControllerData:
+		if h.Device != 0 {
+			return nil
+		}
+		// Clients from before protocol versions don't send one.
+		var version uint32
+		if len(data) >= 4 {
+			version = d.uint32()
+		}
+		if version > ProtocolVersion {
+			version = ProtocolVersion
+		}
+		return WritePacket(w, h.Device, h.ID, s.Device().MarshalVersion(version))
+
+	case UpdateLEDs:
+		d.uint32() // size
+		colors := d.colors()
+		if d.err == nil && h.Device == 0 {
+			s.setColors(w, 0, colors)
+		}
+
+	case UpdateZoneLEDs:
+		d.uint32() // size
+		zone := int(d.uint32())
+		colors := d.colors()
+		if d.err == nil && h.Device == 0 && zone < len(s.info.Zones) {
+			start := 0
+			for _, z := range s.info.Zones[:zone] {
+				start += z.NumLEDs
+			}
+			if len(colors) > s.info.Zones[zone].NumLEDs {
+				colors = colors[:s.info.Zones[zone].NumLEDs]
+			}
+			s.setColors(w, start, colors)
+		}
+
+	case UpdateSingleLED:
+		i := int(int32(d.uint32()))
+		b := d.next(4)
+		if d.err == nil && h.Device == 0 && i >= 0 {
+			s.setColors(w, i, []led.RGBColor{{b[0], b[1], b[2]}})
+		}
+
+	case SetCustomMode:
+		if h.Device == 0 {
+			s.setMode(w, directMode)
+		}
+
+	case UpdateMode:
+		d.uint32() // size
+		mode := int(int32(d.uint32()))
+		if d.err == nil && h.Device == 0 {
+			s.setMode(w, mode)
+		}
+
+	default:
+		// Client names, zone resizes and saving modes don't apply.
+	}
+
+	return nil
+}
+
+// setColors sets the colors of the LEDs from start on and switches to direct
+// mode on behalf of the client.
+func (s *Server) setColors(client net.Conn, start int, colors []led.RGBColor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if start < len(s.colors) {
+		copy(s.colors[start:], colors)
+	}
+	s.direct = true
+	s.owner = client
+	s.ctrl.DrawDirect(s.colors)
+}
+
+// setMode switches to the mode with the given index on behalf of the client.
+func (s *Server) setMode(client net.Conn, mode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if mode == directMode {
+		s.direct = true
+		s.owner = client
+		s.ctrl.DrawDirect(s.colors)
+		return
+	}
+
+	scenes := s.ctrl.Scenes()
+	if mode < 1 || mode > len(scenes) {
+		return
+	}
+
+	s.direct = false
+	s.owner = nil
+	s.ctrl.StopDirect()
+	s.ctrl.SetScene(scenes[mode-1])
+}
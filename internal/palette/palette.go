@@ -0,0 +1,209 @@
+// Package palette extracts the dominant colors of images, such as to color
+// visualizers after the cover art of the playing track.
+package palette
+
+import (
+	"image"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"libdb.so/catglow/internal/led"
+
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// maxSamples is about the most pixels that are sampled from an image. Larger
+// images are sampled evenly, which is plenty for a handful of colors.
+const maxSamples = 128 * 128
+
+// minValue is the value in HSV below which pixels are left out, since dark
+// colors barely light up LEDs. They are kept if the image is all dark.
+const minValue = 0.15
+
+// Load decodes the PNG or JPEG image at the given path or file:// URL.
+func Load(pathOrURL string) (image.Image, error) {
+	path := pathOrURL
+	if strings.Contains(pathOrURL, "://") {
+		u, err := url.Parse(pathOrURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid image URL")
+		}
+		if u.Scheme != "file" {
+			return nil, errors.Errorf("unsupported image URL scheme %q", u.Scheme)
+		}
+		path = u.Path
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s", path)
+	}
+	return img, nil
+}
+
+// Extract returns up to n dominant colors of the image using median cut,
+// from the most to the least common. It returns nil if the image has no
+// opaque pixels.
+func Extract(img image.Image, n int) []led.RGBColor {
+	pixels := sample(img)
+
+	bright := pixels[:0:0]
+	for _, c := range pixels {
+		if value(c) >= minValue {
+			bright = append(bright, c)
+		}
+	}
+	if len(bright) > 0 {
+		pixels = bright
+	}
+
+	if len(pixels) == 0 || n <= 0 {
+		return nil
+	}
+
+	boxes := []box{pixels}
+	for len(boxes) < n {
+		// Split the box with the widest range of any channel.
+		widest, channel, width := -1, 0, uint8(0)
+		for i, b := range boxes {
+			if ch, w := b.widestChannel(); w > width {
+				widest, channel, width = i, ch, w
+			}
+		}
+		if widest == -1 {
+			// Every box is a single color.
+			break
+		}
+
+		lo, hi := boxes[widest].split(channel)
+		boxes[widest] = lo
+		boxes = append(boxes, hi)
+	}
+
+	sort.SliceStable(boxes, func(i, j int) bool { return len(boxes[i]) > len(boxes[j]) })
+
+	colors := make([]led.RGBColor, len(boxes))
+	for i, b := range boxes {
+		colors[i] = b.average()
+	}
+	return colors
+}
+
+// sample returns the opaque pixels of the image, sampled evenly if it is
+// large.
+func sample(img image.Image) []led.RGBColor {
+	bounds := img.Bounds()
+
+	step := 1
+	for (bounds.Dx()/step)*(bounds.Dy()/step) > maxSamples {
+		step++
+	}
+
+	pixels := make([]led.RGBColor, 0, (bounds.Dx()/step+1)*(bounds.Dy()/step+1))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+			// Undo the premultiplied alpha.
+			pixels = append(pixels, led.RGBColor{
+				uint8(r * 0xff / a),
+				uint8(g * 0xff / a),
+				uint8(b * 0xff / a),
+			})
+		}
+	}
+	return pixels
+}
+
+func value(c led.RGBColor) float64 {
+	return float64(max(c[0], max(c[1], c[2]))) / 255
+}
+
+// box is a box of colors in RGB space.
+type box []led.RGBColor
+
+// widestChannel returns the channel with the widest range of values and its
+// width.
+func (b box) widestChannel() (channel int, width uint8) {
+	for ch := 0; ch < 3; ch++ {
+		lo, hi := uint8(255), uint8(0)
+		for _, c := range b {
+			lo = min(lo, c[ch])
+			hi = max(hi, c[ch])
+		}
+		if lo <= hi && hi-lo > width {
+			channel, width = ch, hi-lo
+		}
+	}
+	return channel, width
+}
+
+// split splits the box at the median of the channel. Colors with the same
+// value of the channel stay in the same box, so that areas of one color are
+// not split in two.
+func (b box) split(channel int) (lo, hi box) {
+	sort.Slice(b, func(i, j int) bool { return b[i][channel] < b[j][channel] })
+
+	mid := len(b) / 2
+	// Move the split to the closest change of value.
+	up := mid
+	for up < len(b) && b[up][channel] == b[up-1][channel] {
+		up++
+	}
+	down := mid
+	for down > 0 && b[down][channel] == b[down-1][channel] {
+		down--
+	}
+	switch {
+	case down == 0:
+		mid = up
+	case up == len(b):
+		mid = down
+	case mid-down <= up-mid:
+		mid = down
+	default:
+		mid = up
+	}
+
+	return b[:mid:mid], b[mid:]
+}
+
+func (b box) average() led.RGBColor {
+	var sum [3]int
+	for _, c := range b {
+		for ch := range sum {
+			sum[ch] += int(c[ch])
+		}
+	}
+	var avg led.RGBColor
+	for ch := range avg {
+		avg[ch] = uint8((sum[ch] + len(b)/2) / len(b))
+	}
+	return avg
+}
+
+func min(a, b uint8) uint8 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b uint8) uint8 {
+	if a > b {
+		return a
+	}
+	return b
+}
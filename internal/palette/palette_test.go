@@ -0,0 +1,132 @@
+package palette
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"libdb.so/catglow/internal/led"
+)
+
+var (
+	red   = led.RGBColor{220, 20, 30}
+	blue  = led.RGBColor{20, 40, 200}
+	green = led.RGBColor{30, 180, 60}
+)
+
+// stripes returns an image of horizontal stripes, each as tall as its weight.
+func stripes(width int, colors []led.RGBColor, weights []int) *image.RGBA {
+	height := 0
+	for _, w := range weights {
+		height += w
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	y := 0
+	for i, c := range colors {
+		for end := y + weights[i]; y < end; y++ {
+			for x := 0; x < width; x++ {
+				img.Set(x, y, color.RGBA{c[0], c[1], c[2], 255})
+			}
+		}
+	}
+	return img
+}
+
+func TestExtract(t *testing.T) {
+	img := stripes(10, []led.RGBColor{green, red, blue}, []int{20, 50, 30})
+
+	colors := Extract(img, 3)
+	want := []led.RGBColor{red, blue, green}
+	if len(colors) != len(want) {
+		t.Fatalf("got %v, want %v", colors, want)
+	}
+	for i := range want {
+		if colors[i] != want[i] {
+			t.Errorf("color %d: got %v, want %v", i, colors[i], want[i])
+		}
+	}
+
+	// An image with fewer colors than asked for gives fewer colors.
+	if colors := Extract(stripes(4, []led.RGBColor{red}, []int{4}), 3); len(colors) != 1 || colors[0] != red {
+		t.Errorf("got %v, want only red", colors)
+	}
+}
+
+func TestExtractDark(t *testing.T) {
+	black := led.RGBColor{5, 5, 5}
+
+	// Dark colors are left out, even if they are the most common.
+	colors := Extract(stripes(10, []led.RGBColor{black, red}, []int{90, 10}), 2)
+	if len(colors) != 1 || colors[0] != red {
+		t.Errorf("got %v, want only red", colors)
+	}
+
+	// They are only used if there is nothing else.
+	colors = Extract(stripes(10, []led.RGBColor{black}, []int{10}), 2)
+	if len(colors) != 1 || colors[0] != black {
+		t.Errorf("got %v, want black", colors)
+	}
+
+	if colors := Extract(image.NewNRGBA(image.Rect(0, 0, 4, 4)), 2); colors != nil {
+		t.Errorf("got %v for a transparent image, want nothing", colors)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	img := stripes(64, []led.RGBColor{red, blue}, []int{40, 24})
+	dir := filepath.Join(t.TempDir(), "cover art")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(name string, encode func(*os.File) error) string {
+		path := filepath.Join(dir, name)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := encode(f); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	pngPath := write("cover.png", func(f *os.File) error { return png.Encode(f, img) })
+	jpegPath := write("cover.jpg", func(f *os.File) error { return jpeg.Encode(f, img, &jpeg.Options{Quality: 95}) })
+
+	fileURL := (&url.URL{Scheme: "file", Path: jpegPath}).String()
+
+	for _, src := range []string{pngPath, fileURL} {
+		img, err := Load(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		colors := Extract(img, 2)
+		if len(colors) != 2 || !near(colors[0], red) || !near(colors[1], blue) {
+			t.Errorf("%s: got %v, want about %v and %v", src, colors, red, blue)
+		}
+	}
+
+	if _, err := Load("https://example.com/cover.png"); err == nil {
+		t.Error("expected an error for an HTTPS URL")
+	}
+}
+
+// near returns true if the colors are about the same, since JPEG is lossy
+// and blurs colors into each other where they meet.
+func near(a, b led.RGBColor) bool {
+	for ch := range a {
+		if d := int(a[ch]) - int(b[ch]); d < -24 || d > 24 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,227 @@
+package wled
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"libdb.so/catglow/internal/led"
+)
+
+// numColorSlots is the number of colors that WLED segments have.
+const numColorSlots = 3
+
+type stateJSON struct {
+	On         bool           `json:"on"`
+	Bri        int            `json:"bri"`
+	Transition int            `json:"transition"`
+	PS         int            `json:"ps"`
+	PL         int            `json:"pl"`
+	Live       bool           `json:"live"`
+	LOR        int            `json:"lor"`
+	MainSeg    int            `json:"mainseg"`
+	Nightlight nightlightJSON `json:"nl"`
+	UDPN       udpnJSON       `json:"udpn"`
+	Seg        []segmentJSON  `json:"seg"`
+}
+
+type nightlightJSON struct {
+	On   bool `json:"on"`
+	Dur  int  `json:"dur"`
+	Mode int  `json:"mode"`
+	TBri int  `json:"tbri"`
+	Rem  int  `json:"rem"`
+}
+
+type udpnJSON struct {
+	Send bool `json:"send"`
+	Recv bool `json:"recv"`
+}
+
+type segmentJSON struct {
+	ID    int     `json:"id"`
+	Name  string  `json:"n"`
+	Start int     `json:"start"`
+	Stop  int     `json:"stop"`
+	Len   int     `json:"len"`
+	On    bool    `json:"on"`
+	Bri   int     `json:"bri"`
+	Col   [][]int `json:"col"`
+	FX    int     `json:"fx"`
+	SX    int     `json:"sx"`
+	IX    int     `json:"ix"`
+	Pal   int     `json:"pal"`
+	Sel   bool    `json:"sel"`
+	Rev   bool    `json:"rev"`
+	Mi    bool    `json:"mi"`
+	CCT   int     `json:"cct"`
+}
+
+type infoJSON struct {
+	Ver      string       `json:"ver"`
+	VID      int          `json:"vid"`
+	LEDs     ledsInfoJSON `json:"leds"`
+	Str      bool         `json:"str"`
+	Name     string       `json:"name"`
+	UDPPort  int          `json:"udpport"`
+	Live     bool         `json:"live"`
+	LM       string       `json:"lm"`
+	LIP      string       `json:"lip"`
+	WS       int          `json:"ws"`
+	FXCount  int          `json:"fxcount"`
+	PalCount int          `json:"palcount"`
+	Wifi     wifiJSON     `json:"wifi"`
+	Arch     string       `json:"arch"`
+	Core     string       `json:"core"`
+	FreeHeap int          `json:"freeheap"`
+	Uptime   int          `json:"uptime"`
+	Brand    string       `json:"brand"`
+	Product  string       `json:"product"`
+	MAC      string       `json:"mac"`
+	IP       string       `json:"ip"`
+}
+
+type ledsInfoJSON struct {
+	Count  int   `json:"count"`
+	FPS    int   `json:"fps"`
+	RGBW   bool  `json:"rgbw"`
+	WV     int   `json:"wv"`
+	CCT    int   `json:"cct"`
+	Pwr    int   `json:"pwr"`
+	MaxPwr int   `json:"maxpwr"`
+	MaxSeg int   `json:"maxseg"`
+	LC     int   `json:"lc"`
+	SegLC  []int `json:"seglc"`
+}
+
+type wifiJSON struct {
+	BSSID   string `json:"bssid"`
+	RSSI    int    `json:"rssi"`
+	Signal  int    `json:"signal"`
+	Channel int    `json:"channel"`
+}
+
+// stateRequest is a change to the state. Fields that are not set are left
+// unchanged.
+type stateRequest struct {
+	On         *toggle         `json:"on"`
+	Bri        *int            `json:"bri"`
+	Transition *int            `json:"transition"`
+	TT         *int            `json:"tt"`
+	Live       *bool           `json:"live"`
+	Seg        segmentRequests `json:"seg"`
+	// V asks for the state to be returned.
+	V bool `json:"v"`
+}
+
+type segmentRequest struct {
+	ID  *int           `json:"id"`
+	On  *toggle        `json:"on"`
+	Bri *int           `json:"bri"`
+	Col []colorRequest `json:"col"`
+	FX  *int           `json:"fx"`
+}
+
+// toggle is either a boolean or "t", which toggles the current value.
+type toggle struct {
+	value  bool
+	toggle bool
+}
+
+func (t *toggle) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		if s != "t" {
+			return fmt.Errorf("invalid toggle %q", s)
+		}
+		t.toggle = true
+		return nil
+	}
+	return json.Unmarshal(b, &t.value)
+}
+
+func (t toggle) apply(current bool) bool {
+	if t.toggle {
+		return !current
+	}
+	return t.value
+}
+
+// segmentRequests is either a list of segments or a single segment, which
+// applies to the main segment.
+type segmentRequests []segmentRequest
+
+func (s *segmentRequests) UnmarshalJSON(b []byte) error {
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '{' {
+		var seg segmentRequest
+		if err := json.Unmarshal(b, &seg); err != nil {
+			return err
+		}
+		*s = segmentRequests{seg}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]segmentRequest)(s))
+}
+
+// colorRequest is a color slot, which is either an [r, g, b(, w)] array or a
+// "RRGGBB" hex string. An empty array leaves the slot unchanged.
+type colorRequest struct {
+	color led.RGBColor
+	set   bool
+}
+
+func (c *colorRequest) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		// WLED also accepts "WWRRGGBB", whose white channel we drop.
+		if len(s) == 8 {
+			s = s[2:]
+		}
+		rgb, err := hex.DecodeString(strings.TrimPrefix(s, "#"))
+		if err != nil || len(rgb) != 3 {
+			return fmt.Errorf("invalid hex color %q", s)
+		}
+		*c = colorRequest{led.RGBColor{rgb[0], rgb[1], rgb[2]}, true}
+		return nil
+	}
+
+	var channels []int
+	if err := json.Unmarshal(b, &channels); err != nil {
+		return err
+	}
+	switch len(channels) {
+	case 0:
+		*c = colorRequest{}
+		return nil
+	case 3, 4:
+		*c = colorRequest{
+			led.RGBColor{byteValue(channels[0]), byteValue(channels[1]), byteValue(channels[2])},
+			true,
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid color %v", channels)
+	}
+}
+
+func byteValue(v int) uint8 {
+	switch {
+	case v < 0:
+		return 0
+	case v > 255:
+		return 255
+	default:
+		return uint8(v)
+	}
+}
+
+func toByte(v float64) int {
+	return int(math.Round(math.Max(0, math.Min(1, v)) * 255))
+}
+
+func fromByte(v int) float64 {
+	return float64(byteValue(v)) / 255
+}
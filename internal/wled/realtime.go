@@ -0,0 +1,130 @@
+package wled
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	"libdb.so/catglow/internal/led"
+)
+
+// Protocol is a realtime protocol, which is the first byte of a packet.
+type Protocol uint8
+
+const (
+	// WARLS sets individual LEDs by their index, up to 255.
+	WARLS Protocol = 1
+	// DRGB sets LEDs from the start of the strip.
+	DRGB Protocol = 2
+	// DRGBW is DRGB with a white channel, which is ignored.
+	DRGBW Protocol = 3
+	// DNRGB sets LEDs from a 16-bit start index.
+	DNRGB Protocol = 4
+)
+
+func (p Protocol) String() string {
+	switch p {
+	case WARLS:
+		return "WARLS"
+	case DRGB:
+		return "DRGB"
+	case DRGBW:
+		return "DRGBW"
+	case DNRGB:
+		return "DNRGB"
+	default:
+		return fmt.Sprintf("Protocol(%d)", uint8(p))
+	}
+}
+
+// NoTimeout is the timeout byte that keeps realtime colors until something
+// else is shown.
+const NoTimeout = 255
+
+// noTimeout is the timeout used for NoTimeout. It is long enough to never
+// pass in practice.
+const noTimeout = 365 * 24 * time.Hour
+
+// HandleRealtime draws a realtime packet. The second byte of each packet is
+// how many seconds the colors are shown for after the last packet.
+func (s *Server) HandleRealtime(packet []byte) error {
+	if len(packet) < 2 {
+		return errors.New("packet too short")
+	}
+
+	timeout := time.Duration(packet[1]) * time.Second
+	if packet[1] == NoTimeout {
+		timeout = noTimeout
+	}
+
+	data := packet[2:]
+
+	switch Protocol(packet[0]) {
+	case WARLS:
+		for ; len(data) >= 4; data = data[4:] {
+			color := led.RGBColor{data[1], data[2], data[3]}
+			s.ctrl.DrawRealtime(int(data[0]), []led.RGBColor{color}, timeout)
+		}
+	case DRGB:
+		s.ctrl.DrawRealtime(0, readColors(data, 3), timeout)
+	case DRGBW:
+		s.ctrl.DrawRealtime(0, readColors(data, 4), timeout)
+	case DNRGB:
+		if len(data) < 2 {
+			return errors.New("DNRGB packet too short")
+		}
+		start := int(binary.BigEndian.Uint16(data))
+		s.ctrl.DrawRealtime(start, readColors(data[2:], 3), timeout)
+	default:
+		return fmt.Errorf("unsupported protocol %d", packet[0])
+	}
+
+	return nil
+}
+
+// readColors reads the colors of LEDs that are stride bytes each.
+func readColors(data []byte, stride int) []led.RGBColor {
+	colors := make([]led.RGBColor, len(data)/stride)
+	for i := range colors {
+		copy(colors[i][:], data[i*stride:])
+	}
+	return colors
+}
+
+// ListenRealtime receives realtime packets on the given UDP address until
+// the context is canceled. Invalid packets are dropped.
+func (s *Server) ListenRealtime(ctx context.Context, addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen")
+	}
+	return s.ServeRealtime(ctx, conn)
+}
+
+// ServeRealtime receives realtime packets on the connection until the context
+// is canceled. The connection is closed when ServeRealtime returns.
+func (s *Server) ServeRealtime(ctx context.Context, conn net.PacketConn) error {
+	defer conn.Close()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return errors.Wrap(err, "failed to read packet")
+		}
+		// Packets of other protocols on the same port, such as WLED's sync
+		// notifications, are dropped as well.
+		s.HandleRealtime(buf[:n])
+	}
+}
@@ -0,0 +1,339 @@
+package wled
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/pkg/errors"
+	"libdb.so/catglow/internal/led"
+)
+
+// Server serves the JSON API over HTTP and receives the realtime protocols
+// over UDP.
+type Server struct {
+	ctrl    Controller
+	info    Info
+	started time.Time
+	mux     *http.ServeMux
+}
+
+var _ http.Handler = (*Server)(nil)
+
+// NewServer creates a new server for the controller.
+func NewServer(ctrl Controller, info Info) *Server {
+	if info.MAC == "" {
+		sum := sha1.Sum([]byte(info.Name))
+		// Mark the address as locally administered so that it can't clash
+		// with real hardware.
+		sum[0] = sum[0]&^1 | 2
+		info.MAC = hex.EncodeToString(sum[:6])
+	}
+	if info.UDPPort == 0 {
+		info.UDPPort = RealtimePort
+	}
+
+	s := &Server{
+		ctrl:    ctrl,
+		info:    info,
+		started: time.Now(),
+		mux:     http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("/json", s.handleAll)
+	s.mux.HandleFunc("/json/", s.handleAll)
+	s.mux.HandleFunc("/json/state", s.handleState)
+	s.mux.HandleFunc("/json/info", s.handleInfo)
+	s.mux.HandleFunc("/json/si", s.handleStateInfo)
+	s.mux.HandleFunc("/json/eff", s.handleEffects)
+	s.mux.HandleFunc("/json/pal", s.handlePalettes)
+
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// WLED's own web UI and apps may be served from elsewhere.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves the JSON API on the given address until the context
+// is canceled.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen")
+	}
+	return s.Serve(ctx, l)
+}
+
+// Serve serves the JSON API on the listener until the context is canceled.
+// The listener is closed when Serve returns.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	server := &http.Server{
+		Handler:     s,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	if err := server.Serve(l); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (s *Server) handleAll(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/json", "/json/":
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		s.handleState(w, r)
+		return
+	}
+
+	writeJSON(w, struct {
+		State    stateJSON `json:"state"`
+		Info     infoJSON  `json:"info"`
+		Effects  []string  `json:"effects"`
+		Palettes []string  `json:"palettes"`
+	}{
+		s.state(),
+		s.infoFor(r),
+		s.ctrl.Effects(),
+		palettes,
+	})
+}
+
+func (s *Server) handleStateInfo(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, struct {
+		State stateJSON `json:"state"`
+		Info  infoJSON  `json:"info"`
+	}{
+		s.state(),
+		s.infoFor(r),
+	})
+}
+
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.infoFor(r))
+}
+
+func (s *Server) handleEffects(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.ctrl.Effects())
+}
+
+// palettes is the list of palettes. Effects use the segment's colors, so
+// there is only the default.
+var palettes = []string{"Default"}
+
+func (s *Server) handlePalettes(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, palettes)
+}
+
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		writeJSON(w, s.state())
+
+	case http.MethodPost:
+		var req stateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := s.apply(req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if req.V {
+			writeJSON(w, s.state())
+		} else {
+			writeJSON(w, map[string]bool{"success": true})
+		}
+
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// apply applies the state request to the controller.
+func (s *Server) apply(req stateRequest) error {
+	transition := DefaultTransition
+	switch {
+	case req.TT != nil:
+		transition = time.Duration(*req.TT) * 100 * time.Millisecond
+	case req.Transition != nil:
+		transition = time.Duration(*req.Transition) * 100 * time.Millisecond
+	}
+
+	segments := s.ctrl.Segments()
+	for i, seg := range req.Seg {
+		id := i
+		if seg.ID != nil {
+			id = *seg.ID
+		}
+		if id < 0 || id >= len(segments) {
+			return fmt.Errorf("unknown segment %d", id)
+		}
+		if seg.FX != nil && (*seg.FX < 0 || *seg.FX >= len(s.ctrl.Effects())) {
+			return fmt.Errorf("unknown effect %d", *seg.FX)
+		}
+
+		seg := seg
+		err := s.ctrl.UpdateSegment(id, func(segment *Segment) {
+			if seg.On != nil {
+				segment.On = seg.On.apply(segment.On)
+			}
+			if seg.Bri != nil {
+				segment.Brightness = fromByte(*seg.Bri)
+			}
+			for i, col := range seg.Col {
+				if !col.set || i >= numColorSlots {
+					continue
+				}
+				for len(segment.Colors) <= i {
+					segment.Colors = append(segment.Colors, led.RGBColor{})
+				}
+				segment.Colors[i] = col.color
+			}
+			if seg.FX != nil {
+				segment.Effect = *seg.FX
+			}
+		})
+		if err != nil {
+			return errors.Wrapf(err, "segment %d", id)
+		}
+	}
+
+	if req.Bri != nil {
+		s.ctrl.SetBrightness(fromByte(*req.Bri), transition)
+	}
+	if req.On != nil {
+		s.ctrl.SetOn(req.On.apply(s.ctrl.On()), transition)
+	}
+	if req.Live != nil && !*req.Live {
+		s.ctrl.StopRealtime()
+	}
+
+	return nil
+}
+
+func (s *Server) state() stateJSON {
+	state := stateJSON{
+		On:         s.ctrl.On(),
+		Bri:        toByte(s.ctrl.Brightness()),
+		Transition: int(DefaultTransition / (100 * time.Millisecond)),
+		PS:         -1,
+		PL:         -1,
+		Live:       s.ctrl.Realtime(),
+		Nightlight: nightlightJSON{Dur: 60, Mode: 1, Rem: -1},
+		Seg:        []segmentJSON{},
+	}
+
+	for i, segment := range s.ctrl.Segments() {
+		col := make([][]int, numColorSlots)
+		for j := range col {
+			var c led.RGBColor
+			switch {
+			case j < len(segment.Colors):
+				c = segment.Colors[j]
+			case j == 0:
+				c = led.RGBColor{255, 255, 255}
+			}
+			col[j] = []int{int(c[0]), int(c[1]), int(c[2])}
+		}
+
+		state.Seg = append(state.Seg, segmentJSON{
+			ID:    i,
+			Name:  segment.Name,
+			Start: segment.Start,
+			Stop:  segment.Stop,
+			Len:   segment.Stop - segment.Start,
+			On:    segment.On,
+			Bri:   toByte(segment.Brightness),
+			Col:   col,
+			FX:    segment.Effect,
+			SX:    128,
+			IX:    128,
+			Sel:   i == 0,
+			CCT:   127,
+		})
+	}
+
+	return state
+}
+
+func (s *Server) infoFor(r *http.Request) infoJSON {
+	var ip string
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		if tcp, ok := addr.(*net.TCPAddr); ok {
+			ip = tcp.IP.String()
+		}
+	}
+
+	numSegments := len(s.ctrl.Segments())
+	segmentCapabilities := make([]int, numSegments)
+	for i := range segmentCapabilities {
+		segmentCapabilities[i] = 1 // RGB
+	}
+
+	live := s.ctrl.Realtime()
+	var liveMode string
+	if live {
+		liveMode = "UDP"
+	}
+
+	return infoJSON{
+		Ver: Version,
+		VID: 2310130,
+		LEDs: ledsInfoJSON{
+			Count:  s.info.NumLEDs,
+			FPS:    s.info.FPS,
+			MaxSeg: numSegments,
+			LC:     1,
+			SegLC:  segmentCapabilities,
+		},
+		Name:     s.info.Name,
+		UDPPort:  s.info.UDPPort,
+		Live:     live,
+		LM:       liveMode,
+		WS:       -1, // no WebSocket API
+		FXCount:  len(s.ctrl.Effects()),
+		PalCount: len(palettes),
+		Wifi:     wifiJSON{Signal: 100},
+		Arch:     runtime.GOARCH,
+		Core:     runtime.Version(),
+		Uptime:   int(time.Since(s.started).Seconds()),
+		Brand:    "WLED",
+		Product:  "FOSS",
+		MAC:      s.info.MAC,
+		IP:       ip,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
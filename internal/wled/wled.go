@@ -0,0 +1,81 @@
+// Package wled implements a subset of the WLED JSON API and its UDP realtime
+// protocols, so that WLED apps and Home Assistant's WLED integration can
+// control catglow.
+package wled
+
+import (
+	"time"
+
+	"libdb.so/catglow/internal/led"
+)
+
+// RealtimePort is the default UDP port of the realtime protocols.
+const RealtimePort = 21324
+
+// Version is the WLED version that is reported to clients. Clients gate
+// features on it, so it is that of the API subset that is implemented.
+const Version = "0.14.0"
+
+// DefaultTransition is the transition used when a request doesn't have one,
+// which is WLED's default of 700ms.
+const DefaultTransition = 700 * time.Millisecond
+
+// Controller is what the API controls.
+type Controller interface {
+	// On returns true if the strip is on.
+	On() bool
+	// SetOn turns the strip on or off.
+	SetOn(on bool, transition time.Duration)
+	// Brightness returns the brightness of the strip within [0, 1].
+	Brightness() float64
+	// SetBrightness sets the brightness of the strip within [0, 1].
+	SetBrightness(brightness float64, transition time.Duration)
+
+	// Segments returns the segments of the strip.
+	Segments() []Segment
+	// UpdateSegment changes the segment with the given ID, which is its
+	// index in Segments.
+	UpdateSegment(id int, update func(*Segment)) error
+	// Effects returns the names of the effects that segments can show. The
+	// ID of an effect is its index.
+	Effects() []string
+
+	// DrawRealtime draws the colors starting at the given LED until the
+	// timeout passes.
+	DrawRealtime(start int, colors []led.RGBColor, timeout time.Duration)
+	// StopRealtime stops showing realtime colors.
+	StopRealtime()
+	// Realtime returns true if realtime colors are shown.
+	Realtime() bool
+}
+
+// Segment is a segment of the strip.
+type Segment struct {
+	// Name is the name of the segment.
+	Name string
+	// Start and Stop are the half-open range of LEDs in the segment.
+	Start, Stop int
+	// On is false if the segment is turned off.
+	On bool
+	// Brightness is the brightness of the segment within [0, 1].
+	Brightness float64
+	// Colors are the colors of the segment's effect.
+	Colors []led.RGBColor
+	// Effect is the ID of the effect that the segment shows.
+	Effect int
+}
+
+// Info describes the device to clients.
+type Info struct {
+	// Name is the name that clients show.
+	Name string
+	// NumLEDs is the number of LEDs on the strip.
+	NumLEDs int
+	// FPS is the frame rate of the strip.
+	FPS int
+	// UDPPort is the port of the realtime protocols.
+	UDPPort int
+	// MAC is the MAC address that clients identify the device by, as 12
+	// lowercase hex digits. If empty, then one is made up from the name.
+	MAC string
+}
@@ -0,0 +1,266 @@
+package wled
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/led"
+)
+
+type fakeController struct {
+	on         bool
+	brightness float64
+	transition time.Duration
+	segments   []Segment
+	leds       led.LEDs
+	timeout    time.Duration
+	realtime   bool
+}
+
+func newFakeController() *fakeController {
+	return &fakeController{
+		on:         true,
+		brightness: 1,
+		segments: []Segment{
+			{Name: "desk", Start: 0, Stop: 4, On: true, Brightness: 1, Effect: 1},
+			{Name: "shelf", Start: 4, Stop: 8, On: true, Brightness: 1, Effect: 1},
+		},
+		leds: led.NewLEDs(8),
+	}
+}
+
+func (c *fakeController) On() bool            { return c.on }
+func (c *fakeController) Brightness() float64 { return c.brightness }
+func (c *fakeController) Segments() []Segment { return append([]Segment(nil), c.segments...) }
+func (c *fakeController) Effects() []string   { return []string{"solid", "scene", "rainbow"} }
+func (c *fakeController) Realtime() bool      { return c.realtime }
+func (c *fakeController) StopRealtime()       { c.realtime = false }
+
+func (c *fakeController) SetOn(on bool, transition time.Duration) {
+	c.on = on
+	c.transition = transition
+}
+
+func (c *fakeController) SetBrightness(brightness float64, transition time.Duration) {
+	c.brightness = brightness
+	c.transition = transition
+}
+
+func (c *fakeController) UpdateSegment(id int, update func(*Segment)) error {
+	update(&c.segments[id])
+	return nil
+}
+
+func (c *fakeController) DrawRealtime(start int, colors []led.RGBColor, timeout time.Duration) {
+	copy(c.leds[start:], colors)
+	c.timeout = timeout
+	c.realtime = true
+}
+
+func request(t *testing.T, s *Server, method, path, body string, v any) {
+	t.Helper()
+
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s %s: status %d: %s", method, path, w.Code, w.Body)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("%s %s: invalid JSON: %v", method, path, err)
+	}
+}
+
+func TestState(t *testing.T) {
+	ctrl := newFakeController()
+	s := NewServer(ctrl, Info{Name: "test", NumLEDs: 8, FPS: 60})
+
+	var state stateJSON
+	request(t, s, "GET", "/json/state", "", &state)
+
+	if !state.On || state.Bri != 255 {
+		t.Errorf("got on=%v bri=%d, want on at 255", state.On, state.Bri)
+	}
+	if len(state.Seg) != 2 {
+		t.Fatalf("got %d segments, want 2", len(state.Seg))
+	}
+	if seg := state.Seg[1]; seg.ID != 1 || seg.Name != "shelf" || seg.Start != 4 || seg.Stop != 8 || seg.Len != 4 {
+		t.Errorf("unexpected segment %+v", seg)
+	}
+
+	request(t, s, "POST", "/json/state", `{
+		"on": "t",
+		"bri": 51,
+		"tt": 5,
+		"seg": [{"id": 1, "col": [[255, 0, 0], [], "0000FF"], "fx": 2, "bri": 0}],
+		"v": true
+	}`, &state)
+
+	if ctrl.on || state.On {
+		t.Error("strip was not toggled off")
+	}
+	if ctrl.brightness != 0.2 || state.Bri != 51 {
+		t.Errorf("got brightness %v (%d), want 0.2", ctrl.brightness, state.Bri)
+	}
+	if ctrl.transition != 500*time.Millisecond {
+		t.Errorf("got transition %v, want 500ms", ctrl.transition)
+	}
+
+	seg := ctrl.segments[1]
+	wantColors := []led.RGBColor{{255, 0, 0}, {}, {0, 0, 255}}
+	if !reflect.DeepEqual(seg.Colors, wantColors) {
+		t.Errorf("got colors %v, want %v", seg.Colors, wantColors)
+	}
+	if seg.Effect != 2 || seg.Brightness != 0 {
+		t.Errorf("got effect %d and brightness %v, want 2 and 0", seg.Effect, seg.Brightness)
+	}
+	if want := [][]int{{255, 0, 0}, {0, 0, 0}, {0, 0, 255}}; !reflect.DeepEqual(state.Seg[1].Col, want) {
+		t.Errorf("got col %v, want %v", state.Seg[1].Col, want)
+	}
+
+	// A single segment object applies to the main segment.
+	var success map[string]bool
+	request(t, s, "POST", "/json/state", `{"on": true, "seg": {"on": false}}`, &success)
+	if !success["success"] {
+		t.Errorf("got %v, want success", success)
+	}
+	if !ctrl.on || ctrl.segments[0].On {
+		t.Errorf("got on=%v and segment on=%v, want on and segment off", ctrl.on, ctrl.segments[0].On)
+	}
+	if ctrl.transition != DefaultTransition {
+		t.Errorf("got transition %v, want the default", ctrl.transition)
+	}
+}
+
+func TestInvalidState(t *testing.T) {
+	s := NewServer(newFakeController(), Info{Name: "test"})
+
+	for _, body := range []string{
+		`{"on": "x"}`,
+		`{"seg": [{"id": 5}]}`,
+		`{"seg": [{"fx": 3}]}`,
+		`{"seg": [{"col": ["nothex"]}]}`,
+	} {
+		r := httptest.NewRequest("POST", "/json/state", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func TestInfo(t *testing.T) {
+	s := NewServer(newFakeController(), Info{Name: "test", NumLEDs: 8, FPS: 60})
+
+	var all struct {
+		State   stateJSON `json:"state"`
+		Info    infoJSON  `json:"info"`
+		Effects []string  `json:"effects"`
+	}
+	request(t, s, "GET", "/json", "", &all)
+
+	info := all.Info
+	if info.Brand != "WLED" || info.Ver != Version || info.Name != "test" {
+		t.Errorf("unexpected info %+v", info)
+	}
+	if info.LEDs.Count != 8 || info.LEDs.FPS != 60 || info.LEDs.MaxSeg != 2 {
+		t.Errorf("unexpected LED info %+v", info.LEDs)
+	}
+	if info.UDPPort != RealtimePort || info.FXCount != 3 {
+		t.Errorf("got udpport %d and fxcount %d", info.UDPPort, info.FXCount)
+	}
+	if len(info.MAC) != 12 {
+		t.Errorf("got MAC %q, want 12 hex digits", info.MAC)
+	}
+	if len(all.Effects) != 3 || len(all.State.Seg) != 2 {
+		t.Errorf("got %d effects and %d segments", len(all.Effects), len(all.State.Seg))
+	}
+
+	var again infoJSON
+	request(t, NewServer(newFakeController(), Info{Name: "test"}), "GET", "/json/info", "", &again)
+	if again.MAC != info.MAC {
+		t.Errorf("MAC is not stable: %q != %q", again.MAC, info.MAC)
+	}
+}
+
+func TestRealtime(t *testing.T) {
+	tests := []struct {
+		name    string
+		packet  []byte
+		leds    led.LEDs
+		timeout time.Duration
+	}{
+		{
+			name: "WARLS",
+			packet: []byte{
+				0x01, 0x02,
+				0x01, 0xff, 0x00, 0x00,
+				0x06, 0x00, 0x00, 0xff,
+			},
+			leds:    led.LEDs{1: {255, 0, 0}, 6: {0, 0, 255}, 7: {}},
+			timeout: 2 * time.Second,
+		},
+		{
+			name: "DRGB",
+			packet: []byte{
+				0x02, 0x01,
+				0x10, 0x20, 0x30,
+				0x40, 0x50, 0x60,
+			},
+			leds:    led.LEDs{{0x10, 0x20, 0x30}, {0x40, 0x50, 0x60}, 7: {}},
+			timeout: time.Second,
+		},
+		{
+			name: "DRGBW",
+			packet: []byte{
+				0x03, 0xff,
+				0x10, 0x20, 0x30, 0xff,
+				0x40, 0x50, 0x60, 0xff,
+			},
+			leds:    led.LEDs{{0x10, 0x20, 0x30}, {0x40, 0x50, 0x60}, 7: {}},
+			timeout: noTimeout,
+		},
+		{
+			name: "DNRGB",
+			packet: []byte{
+				0x04, 0x05,
+				0x00, 0x06,
+				0xaa, 0xbb, 0xcc,
+				0x11, 0x22, 0x33,
+			},
+			leds:    led.LEDs{6: {0xaa, 0xbb, 0xcc}, 7: {0x11, 0x22, 0x33}},
+			timeout: 5 * time.Second,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := newFakeController()
+			s := NewServer(ctrl, Info{})
+
+			if err := s.HandleRealtime(test.packet); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ctrl.leds, test.leds) {
+				t.Errorf("got LEDs %v, want %v", ctrl.leds, test.leds)
+			}
+			if ctrl.timeout != test.timeout {
+				t.Errorf("got timeout %v, want %v", ctrl.timeout, test.timeout)
+			}
+		})
+	}
+
+	s := NewServer(newFakeController(), Info{})
+	for _, packet := range [][]byte{{}, {0x01}, {0x00, 0x01}, {0x04, 0x01, 0x00}} {
+		if err := s.HandleRealtime(packet); err == nil {
+			t.Errorf("packet %x: expected error", packet)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package catglow
+
+import "sync"
+
+// latest is a channel that only keeps the latest value sent to it. Sending
+// never blocks, and older values that have not been received yet are dropped.
+type latest[T any] struct {
+	mu *sync.Mutex
+	ch chan T
+}
+
+func newLatest[T any]() latest[T] {
+	return latest[T]{
+		mu: new(sync.Mutex),
+		ch: make(chan T, 1),
+	}
+}
+
+func (l latest[T]) send(v T) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	select {
+	case <-l.ch:
+	default:
+	}
+	l.ch <- v
+}
+
+func (l latest[T]) recv() <-chan T {
+	return l.ch
+}
@@ -0,0 +1,303 @@
+package catglow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/pkg/errors"
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+	"libdb.so/catglow/internal/mpris"
+	"libdb.so/catglow/internal/palette"
+)
+
+func (c *MPRISConfig) validate() error {
+	switch c.TrackChange {
+	case "", FlashTrackChange, GradientTrackChange:
+	default:
+		return fmt.Errorf("unknown track change action %q", c.TrackChange)
+	}
+	if c.FlashDuration < 0 {
+		return errors.New("flash duration must not be negative")
+	}
+	return nil
+}
+
+func (c *MPRISConfig) flashColor() led.RGBColor {
+	if c.FlashColor == nil {
+		return led.RGBColor{255, 255, 255}
+	}
+	return *c.FlashColor
+}
+
+func (c *MPRISConfig) artColors() int {
+	if c.ArtColors <= 0 {
+		return 4
+	}
+	return c.ArtColors
+}
+
+func (c *MPRISConfig) flashDuration() time.Duration {
+	if c.FlashDuration == 0 {
+		return 500 * time.Millisecond
+	}
+	return time.Duration(c.FlashDuration)
+}
+
+// runMPRIS follows the media players on the session bus until the context is
+// canceled.
+func (d *Daemon) runMPRIS(ctx context.Context) error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to the session bus")
+	}
+	defer conn.Close()
+
+	d.logger.Info("following media players on the session bus")
+	return mpris.Watch(ctx, conn, mpris.Config{
+		Players: d.cfg.MPRIS.Players,
+		OnChange: func(player *mpris.Player) {
+			if player != nil {
+				d.logger.Debug(
+					"active media player changed",
+					"player", player.Name,
+					"status", player.Status,
+					"title", player.Track.Title)
+			}
+			if err := d.players.update(player); err != nil {
+				d.logger.Warn("failed to use the cover art", "error", err)
+			}
+		},
+		OnError: func(err error) {
+			d.logger.Warn("MPRIS error", "error", err)
+		},
+	})
+}
+
+// players is the state of the active media player, which visualizers follow.
+// It is safe for concurrent use.
+type players struct {
+	cfg   *MPRISConfig
+	clock clock.Clock
+	// albumArt is true if any visualizer takes its gradient from the cover
+	// art, which is only loaded then.
+	albumArt bool
+
+	mu     sync.Mutex
+	player *mpris.Player
+	// flashed is when the track last changed, if it is flashed.
+	flashed time.Time
+	// art is the URL of the cover art that palette was taken from.
+	art     string
+	palette []led.RGBColor
+	// visualizers are the running visualizers, mapped to whether they take
+	// their gradient from the cover art.
+	visualizers map[visualizer]bool
+}
+
+func newPlayers(cfg *Config, clock clock.Clock) *players {
+	p := &players{
+		cfg:         cfg.MPRIS,
+		clock:       clock,
+		visualizers: make(map[visualizer]bool),
+	}
+	for _, scene := range cfg.AllScenes() {
+		for _, ledCfg := range scene.LEDs {
+			if ledCfg.Visualizer != nil && ledCfg.Visualizer.GradientSource == AlbumArtGradientSource {
+				p.albumArt = true
+			}
+		}
+	}
+	return p
+}
+
+// update changes the active player, which is nil if there is none. An error
+// is returned if its cover art cannot be used, after which the configured
+// gradients are used.
+func (p *players) update(player *mpris.Player) error {
+	var art string
+	if player != nil && p.albumArt {
+		art = player.Track.ArtURL
+	}
+
+	p.mu.Lock()
+	artChanged := art != p.art
+	p.mu.Unlock()
+
+	// Load the cover art outside the lock, since it takes a while.
+	var colors []led.RGBColor
+	var err error
+	if artChanged {
+		colors, err = loadPalette(art, p.cfg.artColors())
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if artChanged {
+		p.art = art
+		p.palette = colors
+		for vis, albumArt := range p.visualizers {
+			if albumArt {
+				vis.SetGradient(colors)
+			}
+		}
+	}
+
+	trackChanged := p.player != nil && player != nil && !p.player.Track.Same(player.Track)
+	p.player = player
+
+	if trackChanged {
+		switch p.cfg.TrackChange {
+		case FlashTrackChange:
+			p.flashed = p.clock.Now()
+		case GradientTrackChange:
+			for vis := range p.visualizers {
+				vis.NextColor()
+			}
+		}
+	}
+
+	if p.cfg.FollowPlayer {
+		device := p.device()
+		for vis := range p.visualizers {
+			vis.SetDevice(device)
+		}
+	}
+
+	return err
+}
+
+// loadPalette returns the colors of the cover art at the given URL. It
+// returns nil without an error if there is no art or it is not a local file,
+// such as the HTTPS URLs of streaming services.
+func loadPalette(art string, n int) ([]led.RGBColor, error) {
+	if art == "" || (strings.Contains(art, "://") && !strings.HasPrefix(art, "file://")) {
+		return nil, nil
+	}
+	img, err := palette.Load(art)
+	if err != nil {
+		return nil, err
+	}
+	return palette.Extract(img, n), nil
+}
+
+// device returns the device of the active player, or an empty string if
+// there is none. It must be called with the players locked.
+func (p *players) device() string {
+	if p.player == nil {
+		return ""
+	}
+	if device, ok := p.cfg.Devices[p.player.Name]; ok {
+		return device
+	}
+	return p.player.Name
+}
+
+// paused returns true if visualizers should be idle because the player is
+// paused or stopped.
+func (p *players) paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cfg.PauseIdle && p.player != nil && p.player.Status != mpris.Playing
+}
+
+// flash returns how much of the flash color to mix in within [0, 1].
+func (p *players) flash(now time.Time) float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.flashed.IsZero() {
+		return 0
+	}
+	elapsed := now.Sub(p.flashed)
+	if elapsed < 0 || elapsed >= p.cfg.flashDuration() {
+		return 0
+	}
+	return 1 - float64(elapsed)/float64(p.cfg.flashDuration())
+}
+
+// visualizer wraps the visualizer to follow the active player. If albumArt is
+// true, then its gradient is taken from the cover art. If blank is true, then
+// it draws nothing while the player is paused, which is for visualizers
+// without an idle animation to fall back to.
+func (p *players) visualizer(vis visualizer, albumArt, blank bool, clock clock.Clock) visualizer {
+	return &playerVisualizer{
+		visualizer: vis,
+		players:    p,
+		albumArt:   albumArt,
+		blank:      blank,
+		clock:      clock,
+	}
+}
+
+// playerVisualizer is a visualizer that follows the active player: it goes
+// silent while the player is paused, flashes on track changes and takes its
+// gradient from the cover art if configured.
+type playerVisualizer struct {
+	visualizer
+	players  *players
+	albumArt bool
+	blank    bool
+	clock    clock.Clock
+	buf      led.LEDs
+}
+
+// Run runs the visualizer, following the player while it runs.
+func (v *playerVisualizer) Run(ctx context.Context) error {
+	p := v.players
+
+	p.mu.Lock()
+	p.visualizers[v.visualizer] = v.albumArt
+	if p.cfg.FollowPlayer {
+		v.visualizer.SetDevice(p.device())
+	}
+	if v.albumArt {
+		v.visualizer.SetGradient(p.palette)
+	}
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.visualizers, v.visualizer)
+		p.mu.Unlock()
+	}()
+
+	return v.visualizer.Run(ctx)
+}
+
+// Silent returns true if the visualizer is silent or the player is paused.
+func (v *playerVisualizer) Silent() bool {
+	return v.visualizer.Silent() || v.players.paused()
+}
+
+// AcquireFrame implements Animator.
+func (v *playerVisualizer) AcquireFrame(f func(led.LEDs)) {
+	if v.blank && v.players.paused() {
+		v.visualizer.AcquireFrame(func(leds led.LEDs) {
+			v.buf = copyFrame(v.buf, leds)
+		})
+		v.buf.SetRange(0, len(v.buf), led.RGBColor{})
+		f(v.buf)
+		return
+	}
+
+	t := v.players.flash(v.clock.Now())
+	if t == 0 {
+		v.visualizer.AcquireFrame(f)
+		return
+	}
+
+	color := v.players.cfg.flashColor()
+	v.visualizer.AcquireFrame(func(leds led.LEDs) {
+		v.buf = copyFrame(v.buf, leds)
+	})
+	for i, c := range v.buf {
+		v.buf[i] = c.Mix(color, t)
+	}
+	f(v.buf)
+}
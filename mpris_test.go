@@ -0,0 +1,276 @@
+package catglow
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+	"libdb.so/catglow/internal/ledvis"
+	"libdb.so/catglow/internal/mpris"
+)
+
+type fakeVisualizer struct {
+	mu       sync.Mutex
+	color    led.RGBColor
+	device   string
+	colors   int
+	gradient []led.RGBColor
+	running  chan struct{}
+}
+
+func newFakeVisualizer(color led.RGBColor) *fakeVisualizer {
+	return &fakeVisualizer{color: color, running: make(chan struct{})}
+}
+
+func (v *fakeVisualizer) AcquireFrame(f func(led.LEDs)) { f(led.LEDs{v.color, v.color}) }
+func (v *fakeVisualizer) Silent() bool                  { return false }
+func (v *fakeVisualizer) LastBeat() ledvis.Beat         { return ledvis.Beat{} }
+func (v *fakeVisualizer) Tempo() float64                { return 0 }
+
+func (v *fakeVisualizer) Run(ctx context.Context) error {
+	close(v.running)
+	<-ctx.Done()
+	return nil
+}
+
+func (v *fakeVisualizer) SetDevice(device string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.device = device
+}
+
+func (v *fakeVisualizer) NextColor() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.colors++
+}
+
+func (v *fakeVisualizer) SetGradient(colors []led.RGBColor) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.gradient = colors
+}
+
+func (v *fakeVisualizer) state() (string, int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.device, v.colors
+}
+
+func TestPlayers(t *testing.T) {
+	clk := clock.NewManual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	players := newPlayers(&Config{MPRIS: &MPRISConfig{
+		PauseIdle:     true,
+		TrackChange:   FlashTrackChange,
+		FlashColor:    &led.RGBColor{255, 255, 255},
+		FlashDuration: TOMLDuration(time.Second),
+		FollowPlayer:  true,
+		Devices:       map[string]string{"firefox": "Firefox"},
+	}}, clk)
+
+	fake := newFakeVisualizer(led.RGBColor{0, 0, 0})
+	vis := players.visualizer(fake, false, false, clk)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go vis.Run(ctx)
+	<-fake.running
+
+	frame := func() led.RGBColor {
+		var color led.RGBColor
+		vis.AcquireFrame(func(leds led.LEDs) { color = leds[0] })
+		return color
+	}
+
+	song := mpris.Track{ID: "/track/1", Title: "Song"}
+	players.update(&mpris.Player{Name: "spotify", Status: mpris.Paused, Track: song})
+	if !vis.Silent() {
+		t.Error("the visualizer is not silent while the player is paused")
+	}
+	if device, _ := fake.state(); device != "spotify" {
+		t.Errorf("got device %q, want spotify", device)
+	}
+	if c := frame(); c != (led.RGBColor{}) {
+		t.Errorf("got %v before any track change, want no flash", c)
+	}
+
+	players.update(&mpris.Player{Name: "spotify", Status: mpris.Playing, Track: song})
+	if vis.Silent() {
+		t.Error("the visualizer is silent while the player is playing")
+	}
+
+	players.update(&mpris.Player{Name: "spotify", Status: mpris.Playing, Track: mpris.Track{ID: "/track/2"}})
+	if c := frame(); c != (led.RGBColor{255, 255, 255}) {
+		t.Errorf("got %v right after the track changed, want white", c)
+	}
+	clk.Advance(500 * time.Millisecond)
+	if c := frame(); c != (led.RGBColor{128, 128, 128}) {
+		t.Errorf("got %v halfway through the flash, want gray", c)
+	}
+	clk.Advance(500 * time.Millisecond)
+	if c := frame(); c != (led.RGBColor{}) {
+		t.Errorf("got %v after the flash, want the visualizer", c)
+	}
+
+	players.update(&mpris.Player{Name: "firefox", Status: mpris.Playing})
+	if device, _ := fake.state(); device != "Firefox" {
+		t.Errorf("got device %q, want Firefox", device)
+	}
+
+	players.update(nil)
+	if device, _ := fake.state(); device != "" {
+		t.Errorf("got device %q without a player, want its own", device)
+	}
+	if vis.Silent() {
+		t.Error("the visualizer is silent without a player")
+	}
+}
+
+func TestPlayersPauseBlank(t *testing.T) {
+	players := newPlayers(&Config{MPRIS: &MPRISConfig{PauseIdle: true}}, clock.Real)
+
+	red := led.RGBColor{255, 0, 0}
+	blank := players.visualizer(newFakeVisualizer(red), false, true, clock.Real)
+	idle := players.visualizer(newFakeVisualizer(red), false, false, clock.Real)
+
+	frame := func(vis visualizer) led.RGBColor {
+		var color led.RGBColor
+		vis.AcquireFrame(func(leds led.LEDs) { color = leds[0] })
+		return color
+	}
+
+	players.update(&mpris.Player{Name: "spotify", Status: mpris.Playing})
+	if c := frame(blank); c != red {
+		t.Errorf("got %v while playing, want the visualizer", c)
+	}
+
+	// Without an idle animation to fall back to, the visualizer goes dark
+	// rather than freezing on its last frame.
+	players.update(&mpris.Player{Name: "spotify", Status: mpris.Paused})
+	if c := frame(blank); c != (led.RGBColor{}) {
+		t.Errorf("got %v while paused, want it off", c)
+	}
+	if c := frame(idle); c != red {
+		t.Errorf("got %v while paused, want the idle animator to take over", c)
+	}
+}
+
+func TestPlayersGradient(t *testing.T) {
+	players := newPlayers(&Config{MPRIS: &MPRISConfig{TrackChange: GradientTrackChange}}, clock.Real)
+
+	fake := newFakeVisualizer(led.RGBColor{})
+	vis := players.visualizer(fake, false, false, clock.Real)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go vis.Run(ctx)
+	<-fake.running
+
+	players.update(&mpris.Player{Name: "mpv", Status: mpris.Playing, Track: mpris.Track{Title: "A"}})
+	players.update(&mpris.Player{Name: "mpv", Status: mpris.Paused, Track: mpris.Track{Title: "A"}})
+	players.update(&mpris.Player{Name: "mpv", Status: mpris.Playing, Track: mpris.Track{Title: "B"}})
+
+	if device, colors := fake.state(); colors != 1 || device != "" {
+		t.Errorf("got %d color changes and device %q, want 1 and none", colors, device)
+	}
+}
+
+const albumArtConfig = `
+[mpris]
+  art_colors = 2
+
+[[led]]
+  range = [0, 8]
+  [led.visualizer]
+    kind = "glowing"
+    gradients = [[255, 255, 255]]
+    gradient_source = "album-art"
+`
+
+func TestPlayersAlbumArt(t *testing.T) {
+	cfg, err := ParseConfig(strings.NewReader(albumArtConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	red := led.RGBColor{255, 0, 0}
+	blue := led.RGBColor{0, 0, 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			c := blue
+			if y < 3 {
+				c = red
+			}
+			img.Set(x, y, color.RGBA{c[0], c[1], c[2], 255})
+		}
+	}
+	path := filepath.Join(t.TempDir(), "cover.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	players := newPlayers(cfg, clock.Real)
+	if !players.albumArt {
+		t.Fatal("album art is not used")
+	}
+
+	fake := newFakeVisualizer(led.RGBColor{})
+	vis := players.visualizer(fake, true, false, clock.Real)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go vis.Run(ctx)
+	<-fake.running
+
+	gradient := func() []led.RGBColor {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		return fake.gradient
+	}
+
+	track := mpris.Track{Title: "A", ArtURL: "file://" + path}
+	if err := players.update(&mpris.Player{Name: "mpv", Status: mpris.Playing, Track: track}); err != nil {
+		t.Fatal(err)
+	}
+	if got := gradient(); len(got) != 2 || got[0] != red || got[1] != blue {
+		t.Errorf("got gradient %v, want red and blue", got)
+	}
+
+	// Art that is not a local file falls back to the configured gradients.
+	track = mpris.Track{Title: "B", ArtURL: "https://example.com/cover.png"}
+	if err := players.update(&mpris.Player{Name: "mpv", Status: mpris.Playing, Track: track}); err != nil {
+		t.Fatal(err)
+	}
+	if got := gradient(); got != nil {
+		t.Errorf("got gradient %v, want the configured one", got)
+	}
+
+	track = mpris.Track{Title: "C", ArtURL: "file:///nonexistent.png"}
+	if err := players.update(&mpris.Player{Name: "mpv", Status: mpris.Playing, Track: track}); err == nil {
+		t.Error("expected an error for missing art")
+	}
+
+	cfg.MPRIS = nil
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an error for album art without mpris")
+	}
+}
@@ -0,0 +1,121 @@
+package catglow
+
+import (
+	"context"
+	"strings"
+
+	"libdb.so/catglow/internal/hass"
+	"libdb.so/catglow/internal/led"
+)
+
+// runMQTT keeps the segments in sync with Home Assistant over MQTT until the
+// context is canceled.
+func (d *Daemon) runMQTT(ctx context.Context) error {
+	cfg := d.cfg.MQTT
+
+	broker := cfg.Broker
+	if broker == "" {
+		broker = hass.DefaultBroker
+	}
+	d.logger.Info("connecting to the MQTT broker", "broker", broker)
+
+	return hass.Run(ctx, hass.Config{
+		Broker:          broker,
+		Username:        cfg.Username,
+		Password:        cfg.Password,
+		ID:              cfg.ID,
+		Name:            cfg.Name,
+		Topic:           cfg.Topic,
+		DiscoveryPrefix: cfg.DiscoveryPrefix,
+		OnError: func(err error) {
+			d.logger.Warn("MQTT error", "error", err)
+		},
+	}, hassController{d})
+}
+
+// sceneEffectPrefix prefixes the scenes in the effect lists of lights.
+// Picking one switches to the scene and makes the segment follow it.
+const sceneEffectPrefix = "scene:"
+
+// hassController adapts the daemon to Home Assistant, with a light for each
+// segment.
+type hassController struct {
+	*Daemon
+}
+
+var _ hass.Controller = hassController{}
+
+func (c hassController) Lights() []hass.Light {
+	scene := c.Scene()
+	segments := c.Segments()
+
+	lights := make([]hass.Light, len(segments))
+	for i, segment := range segments {
+		light := hass.Light{
+			Name:       segment.Name,
+			On:         segment.On,
+			Brightness: segment.Brightness,
+			Color:      defaultSegmentColor,
+			Effect:     segment.Effect,
+		}
+		if len(segment.Colors) > 0 {
+			light.Color = segment.Colors[0]
+		}
+		if segment.Effect == SceneEffect {
+			light.Effect = sceneEffectPrefix + scene
+		}
+		lights[i] = light
+	}
+	return lights
+}
+
+func (c hassController) Effects() []string {
+	var effects []string
+	for _, effect := range Effects() {
+		if effect != SceneEffect {
+			effects = append(effects, effect)
+			continue
+		}
+		for _, scene := range c.Scenes() {
+			effects = append(effects, sceneEffectPrefix+scene)
+		}
+	}
+	return effects
+}
+
+func (c hassController) UpdateLight(name string, cmd hass.Command) error {
+	effect := ""
+	if cmd.Effect != nil {
+		effect = *cmd.Effect
+		if scene, ok := strings.CutPrefix(effect, sceneEffectPrefix); ok {
+			if err := c.SetScene(scene); err != nil {
+				return err
+			}
+			effect = SceneEffect
+		}
+	}
+
+	return c.UpdateSegment(name, func(segment *SegmentState) {
+		if cmd.On != nil {
+			segment.On = *cmd.On
+		}
+		if cmd.Brightness != nil {
+			segment.Brightness = *cmd.Brightness
+		}
+		if cmd.Color != nil {
+			if len(segment.Colors) == 0 {
+				segment.Colors = []led.RGBColor{*cmd.Color}
+			} else {
+				segment.Colors[0] = *cmd.Color
+			}
+			// Picking a color for a segment that follows the scene should
+			// show that color.
+			if segment.Effect == SceneEffect {
+				segment.Effect = SolidEffect
+			}
+		}
+		if effect != "" {
+			segment.Effect = effect
+		}
+	})
+}
@@ -0,0 +1,232 @@
+package catglow
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/pkg/errors"
+	"libdb.so/catglow/internal/dbusapi"
+	"libdb.so/catglow/internal/desktopnotify"
+	"libdb.so/catglow/internal/httpapi"
+	"libdb.so/catglow/internal/led"
+)
+
+// DefaultNotificationDuration is how long each flash of a notification takes
+// unless set.
+const DefaultNotificationDuration = 500 * time.Millisecond
+
+const (
+	// maxNotificationCount is the most times a notification can flash.
+	maxNotificationCount = 100
+	// maxNotifications is the most notifications that can wait to be shown.
+	maxNotifications = 16
+)
+
+// Notification flashes the strip over whatever it shows, such as when a build
+// fails or a message arrives. The strip goes back to normal once it is done.
+type Notification struct {
+	// Color is the color to flash.
+	Color led.RGBColor
+	// Count is how many times to flash. If zero, then the strip flashes
+	// once.
+	Count int
+	// Duration is how long each flash takes. If zero, then
+	// DefaultNotificationDuration is used.
+	Duration time.Duration
+	// Priority decides which notification is shown first. A notification
+	// interrupts one of a lower priority, which is shown again from the start
+	// afterwards. Notifications of the same priority are shown in turn.
+	Priority int
+}
+
+// length returns how long the notification is shown.
+func (n Notification) length() time.Duration {
+	return time.Duration(n.Count) * n.Duration
+}
+
+// draw mixes the color of the notification into the LEDs, after it was shown
+// for the elapsed time. Each flash fades in and out.
+func (n Notification) draw(leds led.LEDs, elapsed time.Duration) {
+	phase := float64(elapsed%n.Duration) / float64(n.Duration)
+	t := math.Sin(math.Pi * phase)
+	for i, c := range leds {
+		leds[i] = c.Mix(n.Color, t)
+	}
+}
+
+// Notify flashes the strip once the notifications of a higher or the same
+// priority are done. Notifications are drawn over the scene, segments and
+// realtime frames at full brightness, even while the strip is dimmed or off.
+func (d *Daemon) Notify(n Notification) error {
+	if n.Count == 0 {
+		n.Count = 1
+	}
+	if n.Duration == 0 {
+		n.Duration = DefaultNotificationDuration
+	}
+	if n.Count < 0 || n.Count > maxNotificationCount {
+		return fmt.Errorf("count must be within [1, %d]", maxNotificationCount)
+	}
+	if n.Duration < 0 {
+		return errors.New("duration must not be negative")
+	}
+
+	d.control.mu.Lock()
+	defer d.control.mu.Unlock()
+
+	queue := d.control.notifications
+	if len(queue) >= maxNotifications {
+		return errors.New("too many notifications are waiting")
+	}
+
+	i := len(queue)
+	for i > 0 && queue[i-1].Priority < n.Priority {
+		i--
+	}
+	if i == 0 {
+		// Interrupt the notification being shown, which starts over once it
+		// is its turn again.
+		d.control.notified = time.Time{}
+	}
+
+	queue = append(queue, Notification{})
+	copy(queue[i+1:], queue[i:])
+	queue[i] = n
+	d.control.notifications = queue
+	return nil
+}
+
+// notification returns the notification to show and how long it has been
+// shown for, dropping those that are done. It must be called with the control
+// locked.
+func (c *control) notification(now time.Time) (Notification, time.Duration, bool) {
+	for len(c.notifications) > 0 {
+		n := c.notifications[0]
+		if c.notified.IsZero() {
+			c.notified = now
+		}
+		if elapsed := now.Sub(c.notified); elapsed < n.length() {
+			return n, elapsed, true
+		}
+		c.notifications = c.notifications[1:]
+		c.notified = time.Time{}
+	}
+	return Notification{}, 0, false
+}
+
+// SendNotification sends the notification to the running daemon over its
+// HTTP API, or over D-Bus if only that is enabled in the configuration.
+func SendNotification(ctx context.Context, cfg *Config, n Notification) error {
+	switch {
+	case cfg.HTTP != nil:
+		return httpapi.Notify(ctx, "http://"+dialAddr(cfg.HTTP.listen()), httpapi.Notification(n))
+
+	case cfg.DBus != nil:
+		conn, err := dbus.ConnectSessionBus()
+		if err != nil {
+			return errors.Wrap(err, "failed to connect to the session bus")
+		}
+		defer conn.Close()
+
+		return dbusapi.Notify(ctx, conn, cfg.DBus.Name, dbusapi.Notification{
+			Color:    dbusapi.Color{R: n.Color[0], G: n.Color[1], B: n.Color[2]},
+			Count:    n.Count,
+			Duration: n.Duration,
+			Priority: n.Priority,
+		})
+
+	default:
+		return errors.New("neither the HTTP API nor the D-Bus service is enabled")
+	}
+}
+
+// dialAddr returns the address to reach a server that listens on the given
+// address, which is the loopback address if it listens on all addresses.
+func dialAddr(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return listen
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+func (c *NotificationsConfig) validate() error {
+	for i, rule := range c.Rules {
+		if rule.Count < 0 || rule.Count > maxNotificationCount {
+			return fmt.Errorf("rule %d: count must be within [1, %d]", i, maxNotificationCount)
+		}
+		if rule.Duration < 0 {
+			return fmt.Errorf("rule %d: duration must not be negative", i)
+		}
+	}
+	return nil
+}
+
+// match returns the first rule that matches the notification, or nil if
+// there is none.
+func (c *NotificationsConfig) match(n desktopnotify.Notification) *NotificationRule {
+	for i, rule := range c.Rules {
+		if rule.App != "" && !strings.EqualFold(rule.App, n.App) {
+			continue
+		}
+		if rule.Summary != "" && !strings.Contains(strings.ToLower(n.Summary), strings.ToLower(rule.Summary)) {
+			continue
+		}
+		return &c.Rules[i]
+	}
+	return nil
+}
+
+func (r *NotificationRule) notification() Notification {
+	color := led.RGBColor{255, 255, 255}
+	if r.Color != nil {
+		color = *r.Color
+	}
+	return Notification{
+		Color:    color,
+		Count:    r.Count,
+		Duration: time.Duration(r.Duration),
+		Priority: r.Priority,
+	}
+}
+
+// runNotifications flashes the strip for desktop notifications until the
+// context is canceled.
+func (d *Daemon) runNotifications(ctx context.Context) error {
+	// Watching turns the connection into a monitor, so it cannot be shared.
+	conn, err := dbus.SessionBusPrivate()
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to the session bus")
+	}
+	defer conn.Close()
+
+	if err := conn.Auth(nil); err != nil {
+		return errors.Wrap(err, "failed to authenticate to the session bus")
+	}
+	if err := conn.Hello(); err != nil {
+		return errors.Wrap(err, "failed to connect to the session bus")
+	}
+
+	d.logger.Info("watching desktop notifications on the session bus")
+	return desktopnotify.Watch(ctx, conn, func(n desktopnotify.Notification) {
+		rule := d.cfg.Notifications.match(n)
+		if rule == nil {
+			return
+		}
+		d.logger.Debug(
+			"flashing for a desktop notification",
+			"app", n.App,
+			"summary", n.Summary)
+		if err := d.Notify(rule.notification()); err != nil {
+			d.logger.Warn("failed to flash for a notification", "error", err)
+		}
+	})
+}
@@ -0,0 +1,187 @@
+package catglow
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/desktopnotify"
+	"libdb.so/catglow/internal/httpapi"
+	"libdb.so/catglow/internal/led"
+)
+
+func TestNotify(t *testing.T) {
+	d := newControlDaemon(t)
+	overrides := newOverrides(d.control, clock.Real)
+
+	green := led.RGBColor{0, 255, 0}
+	red := led.RGBColor{255, 0, 0}
+	blue := led.RGBColor{0, 0, 255}
+
+	start := time.Now()
+	draw := func(at time.Duration) led.RGBColor {
+		leds := led.NewLEDs(8)
+		leds.SetRange(0, 8, green)
+		overrides.draw(leds, start.Add(at))
+		overrides.drawNotification(leds, start.Add(at))
+		return leds[0]
+	}
+
+	if err := d.Notify(Notification{Color: red, Count: 2, Duration: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Notify(Notification{Color: blue}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each flash fades in and out over the scene.
+	for _, test := range []struct {
+		at   time.Duration
+		want led.RGBColor
+	}{
+		{0, green},
+		{500 * time.Millisecond, red},
+		{1000 * time.Millisecond, green},
+		{1500 * time.Millisecond, red},
+		// The blue notification follows once the red one is done.
+		{2000 * time.Millisecond, green},
+		{2250 * time.Millisecond, blue},
+		// Then the scene is back.
+		{2500 * time.Millisecond, green},
+		{3000 * time.Millisecond, green},
+	} {
+		if got := draw(test.at); got != test.want {
+			t.Errorf("at %v: got %v, want %v", test.at, got, test.want)
+		}
+	}
+
+	// A notification of a higher priority interrupts the one being shown,
+	// which starts over afterwards.
+	if err := d.Notify(Notification{Color: red, Duration: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	// Notifications start once they are first drawn.
+	draw(3000 * time.Millisecond)
+	if got := draw(3500 * time.Millisecond); got != red {
+		t.Errorf("got %v, want the red notification", got)
+	}
+	if err := d.Notify(Notification{Color: blue, Priority: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := draw(3750 * time.Millisecond); got != green {
+		t.Errorf("got %v at the start of the interruption, want it to fade in", got)
+	}
+	if got := draw(4000 * time.Millisecond); got != blue {
+		t.Errorf("got %v, want the interrupting blue notification", got)
+	}
+	if got := draw(4750 * time.Millisecond); got != green {
+		t.Errorf("got %v at the start of the interrupted notification, want it to fade in", got)
+	}
+	if got := draw(5250 * time.Millisecond); got != red {
+		t.Errorf("got %v, want the red notification again", got)
+	}
+
+	// Notifications are drawn over a dark strip at full brightness.
+	if err := d.Notify(Notification{Color: red, Priority: 2}); err != nil {
+		t.Fatal(err)
+	}
+	dark := led.NewLEDs(8)
+	overrides.drawNotification(dark, start.Add(6000*time.Millisecond))
+	overrides.drawNotification(dark, start.Add(6250*time.Millisecond))
+	if got := dark[0]; got != red {
+		t.Errorf("got %v on a dark strip, want the red notification", got)
+	}
+
+	if err := d.Notify(Notification{Count: -1}); err == nil {
+		t.Error("expected an error for a negative count")
+	}
+	for i := 0; i < maxNotifications; i++ {
+		d.Notify(Notification{})
+	}
+	if err := d.Notify(Notification{}); err == nil {
+		t.Error("expected an error once too many notifications wait")
+	}
+}
+
+const notificationsConfig = `
+[[led]]
+  range = [0, 8]
+  color = [0, 0, 0]
+
+[notifications]
+  [[notifications.rule]]
+    app = "slack"
+    color = [74, 21, 75]
+    count = 3
+  [[notifications.rule]]
+    summary = "build failed"
+    color = [255, 0, 0]
+    priority = 1
+`
+
+func TestNotificationRules(t *testing.T) {
+	cfg, err := ParseConfig(strings.NewReader(notificationsConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := cfg.Notifications
+	if rule := rules.match(desktopnotify.Notification{App: "Slack", Summary: "New message"}); rule == nil || rule.Count != 3 {
+		t.Errorf("got rule %+v, want the Slack one", rule)
+	}
+	if rule := rules.match(desktopnotify.Notification{App: "CI", Summary: "Build failed on main"}); rule == nil || rule.Priority != 1 {
+		t.Errorf("got rule %+v, want the failed build one", rule)
+	}
+	if rule := rules.match(desktopnotify.Notification{App: "Firefox", Summary: "Download finished"}); rule != nil {
+		t.Errorf("got rule %+v, want none", rule)
+	}
+
+	n := (&NotificationRule{}).notification()
+	if n.Color != (led.RGBColor{255, 255, 255}) {
+		t.Errorf("got color %v by default, want white", n.Color)
+	}
+
+	cfg.Notifications.Rules[0].Count = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an error for a negative count")
+	}
+}
+
+func TestSendNotification(t *testing.T) {
+	d := newControlDaemon(t)
+	server := httptest.NewServer(httpapi.NewServer(httpController{d}))
+	defer server.Close()
+
+	cfg := &Config{HTTP: &HTTPConfig{Listen: strings.TrimPrefix(server.URL, "http://")}}
+	n := Notification{Color: led.RGBColor{255, 0, 0}, Count: 3, Duration: time.Second, Priority: 2}
+	if err := SendNotification(context.Background(), cfg, n); err != nil {
+		t.Fatal(err)
+	}
+
+	d.control.mu.Lock()
+	queue := d.control.notifications
+	d.control.mu.Unlock()
+	if len(queue) != 1 || queue[0] != n {
+		t.Errorf("got notifications %+v, want %+v", queue, n)
+	}
+
+	if err := SendNotification(context.Background(), &Config{}, n); err == nil {
+		t.Error("expected an error without an API to send to")
+	}
+
+	for listen, want := range map[string]string{
+		":8080":          "127.0.0.1:8080",
+		"0.0.0.0:8080":   "127.0.0.1:8080",
+		"192.0.2.1:8080": "192.0.2.1:8080",
+	} {
+		if got := dialAddr(listen); got != want {
+			t.Errorf("dialAddr(%q) = %q, want %q", listen, got, want)
+		}
+	}
+}
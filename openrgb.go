@@ -0,0 +1,82 @@
+package catglow
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"libdb.so/catglow/internal/openrgb"
+)
+
+func (c *OpenRGBConfig) listen() string {
+	if c.Listen == "" {
+		return fmt.Sprintf("127.0.0.1:%d", openrgb.Port)
+	}
+	return c.Listen
+}
+
+func (c *OpenRGBConfig) name() string {
+	if c.Name == "" {
+		return "catglow"
+	}
+	return c.Name
+}
+
+// runOpenRGB serves the OpenRGB SDK until the context is canceled. If the
+// address cannot be bound, then the server is disabled with a warning.
+func (d *Daemon) runOpenRGB(ctx context.Context) error {
+	cfg := d.cfg.OpenRGB
+
+	server := openrgb.NewServer(openRGBController{d}, openrgb.Info{
+		Name:  cfg.name(),
+		Zones: openRGBZones(d.cfg),
+	})
+
+	d.logger.Info("serving the OpenRGB SDK", "listen", cfg.listen())
+	err := server.ListenAndServe(ctx, cfg.listen())
+	return d.optionalServer("OpenRGB", cfg.listen(), err)
+}
+
+// openRGBZones returns the zones of the strip, which are the LED ranges of
+// the initial scene. LEDs that no range covers get zones of their own, since
+// zones must make up the whole strip.
+func openRGBZones(cfg *Config) []openrgb.Zone {
+	var ranges [][2]int
+	if scene := cfg.Scene(cfg.InitialScene()); scene != nil {
+		for _, led := range scene.LEDs {
+			ranges = append(ranges, led.Range)
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+
+	var zones []openrgb.Zone
+	addZone := func(start, end int) {
+		zones = append(zones, openrgb.Zone{
+			Name:    fmt.Sprintf("LEDs %d-%d", start, end-1),
+			NumLEDs: end - start,
+		})
+	}
+
+	var next int
+	for _, r := range ranges {
+		if r[0] > next {
+			addZone(next, r[0])
+		}
+		addZone(r[0], r[1])
+		next = r[1]
+	}
+	if numLEDs := cfg.NumLEDs(); next < numLEDs {
+		addZone(next, numLEDs)
+	}
+
+	return zones
+}
+
+// openRGBController adapts the daemon to the OpenRGB SDK. Direct mode draws
+// over the scene like realtime frames of the WLED API, but is ended only by
+// OpenRGB clients.
+type openRGBController struct {
+	*Daemon
+}
+
+var _ openrgb.Controller = openRGBController{}
@@ -0,0 +1,58 @@
+package catglow
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"libdb.so/catglow/internal/openrgb"
+)
+
+func TestOpenRGBZones(t *testing.T) {
+	cfg, err := ParseConfig(strings.NewReader(`
+[[led]]
+  range = [40, 100]
+  color = [255, 0, 0]
+
+[[led]]
+  range = [10, 40]
+  color = [0, 255, 0]
+
+[[scene]]
+  name = "long"
+  [[scene.led]]
+    range = [0, 120]
+    color = [0, 0, 255]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []openrgb.Zone{
+		{Name: "LEDs 0-9", NumLEDs: 10},
+		{Name: "LEDs 10-39", NumLEDs: 30},
+		{Name: "LEDs 40-99", NumLEDs: 60},
+		{Name: "LEDs 100-119", NumLEDs: 20},
+	}
+	if got := openRGBZones(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("got zones %v, want %v", got, want)
+	}
+}
+
+func TestOpenRGBListenFailure(t *testing.T) {
+	d := newControlDaemon(t)
+
+	// Take the address so that the daemon cannot bind it.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	d.cfg.OpenRGB = &OpenRGBConfig{Listen: l.Addr().String()}
+	if err := d.runOpenRGB(context.Background()); err != nil {
+		t.Errorf("got %v, want the OpenRGB server to be disabled", err)
+	}
+}
@@ -0,0 +1,117 @@
+package catglow
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+	"libdb.so/catglow/internal/audioin"
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+	"libdb.so/catglow/internal/ledvis"
+)
+
+// RenderOptions are options for Render.
+type RenderOptions struct {
+	// Scene is the name of the scene to render. If empty, then the scene that
+	// the daemon starts with is rendered.
+	Scene string
+	// Audio is the audio that all visualizers hear, regardless of their
+	// backend. If nil, then they hear silence.
+	Audio audioin.Decoder
+	// Duration is how long to render for.
+	Duration time.Duration
+	// Rate is the frame rate to render at. If zero, then the configured rate
+	// is used.
+	Rate int
+}
+
+// renderEpoch is the simulated time that rendering starts at, so that the
+// same configuration always renders the same frames.
+var renderEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Render draws a scene on a simulated clock as fast as possible, without any
+// hardware or audio device. draw is called with each frame, which must not be
+// kept after draw returns.
+func Render(ctx context.Context, cfg *Config, opts RenderOptions, draw func(led.LEDs) error) error {
+	if err := cfg.Validate(); err != nil {
+		return errors.Wrap(err, "invalid configuration")
+	}
+
+	rate := opts.Rate
+	if rate <= 0 {
+		rate = cfg.Rate
+	}
+	if rate <= 0 {
+		rate = 60
+	}
+
+	name := opts.Scene
+	if name == "" {
+		name = cfg.InitialScene()
+	}
+	sceneCfg := cfg.Scene(name)
+	if sceneCfg == nil {
+		return fmt.Errorf("unknown scene %q", name)
+	}
+
+	clock := clock.NewManual(renderEpoch)
+	sources := ledvis.NewOfflineSources(opts.Audio, rate)
+
+	s, err := newScene(*sceneCfg, cfg.NumLEDs(), animatorEnv{
+		rate:    rate,
+		clock:   clock,
+		sources: sources,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "scene %q", name)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.activate(ctx, slog.Default())
+	defer s.deactivate()
+
+	// Wait for the visualizers to attach to the audio before feeding it.
+	// Network receivers receive nothing while rendering.
+	var visualizers int
+	for _, animator := range s.animators {
+		a := animator.Animator
+		if idle, ok := a.(*idleAnimator); ok {
+			a = idle.vis
+		}
+		if _, ok := a.(visualizer); ok {
+			visualizers++
+		}
+	}
+	if err := sources.WaitOutputs(ctx, visualizers); err != nil {
+		return err
+	}
+
+	leds := led.NewLEDs(cfg.NumLEDs())
+	frameDuration := time.Second / time.Duration(rate)
+	frames := int(math.Round(opts.Duration.Seconds() * float64(rate)))
+
+	for i := 0; i < frames; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := sources.Step(); err != nil {
+			return errors.Wrap(err, "failed to read audio")
+		}
+
+		s.draw(leds)
+		if err := draw(leds); err != nil {
+			return err
+		}
+
+		clock.Advance(frameDuration)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package catglow
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/audioin"
+	"libdb.so/catglow/internal/led"
+)
+
+const renderConfig = `
+rate = 20
+
+[[led]]
+  range = [0, 4]
+  [led.cycle]
+    colors = [[255, 0, 0], [0, 0, 255]]
+    interval = "100ms"
+
+[[led]]
+  range = [4, 8]
+  [led.visualizer]
+    kind = "blinking"
+    backend = "no-such-backend"
+`
+
+func TestRender(t *testing.T) {
+	cfg, err := ParseConfig(strings.NewReader(renderConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	render := func() []led.LEDs {
+		var frames []led.LEDs
+		err := Render(context.Background(), cfg, RenderOptions{Duration: time.Second}, func(leds led.LEDs) error {
+			frames = append(frames, append(led.LEDs(nil), leds...))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return frames
+	}
+
+	frames := render()
+	if len(frames) != 20 {
+		t.Fatalf("got %d frames, want 20", len(frames))
+	}
+
+	// The cycle switches colors every two frames on the simulated clock.
+	red := led.RGBColor{255, 0, 0}
+	blue := led.RGBColor{0, 0, 255}
+	for i, want := range []led.RGBColor{red, red, blue, blue, red} {
+		if got := frames[i][0]; got != want {
+			t.Errorf("frame %d: got %v, want %v", i, got, want)
+		}
+	}
+
+	// Rendering is deterministic.
+	again := render()
+	for i := range frames {
+		if !bytes.Equal(frames[i].AsPixels(), again[i].AsPixels()) {
+			t.Fatalf("frame %d differs between renders", i)
+		}
+	}
+}
+
+const renderIdleConfig = `
+rate = 20
+
+[[led]]
+  range = [0, 4]
+  [led.visualizer]
+    kind = "blinking"
+    silence_duration = "500ms"
+  [led.idle]
+    color = [1, 2, 3]
+`
+
+// toneDecoder is a decoder of a loud tone that lasts for the given duration.
+type toneDecoder struct {
+	duration time.Duration
+	frame    int
+}
+
+func (d *toneDecoder) Format() audioin.Format {
+	return audioin.Format{Encoding: audioin.F32LE, SampleRate: 44100, Channels: 2}
+}
+
+func (d *toneDecoder) ReadFrame(dst []float64) error {
+	if time.Duration(d.frame)*time.Second/44100 >= d.duration {
+		return io.EOF
+	}
+	v := 0.8 * math.Sin(2*math.Pi*100*float64(d.frame)/44100)
+	for i := range dst {
+		dst[i] = v
+	}
+	d.frame++
+	return nil
+}
+
+func TestRenderIdle(t *testing.T) {
+	cfg, err := ParseConfig(strings.NewReader(renderIdleConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idle := led.RGBColor{1, 2, 3}
+
+	for _, test := range []struct {
+		name  string
+		audio audioin.Decoder
+		// idleFrom is the first frame that shows the idle animation.
+		idleFrom int
+	}{
+		// Silence is detected after the silence duration on the simulated
+		// clock, not the real one.
+		{"silence", nil, 10},
+		// The idle animation only starts once the audio ended and was silent
+		// for the silence duration.
+		{"tone", &toneDecoder{duration: time.Second}, 30},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var frames []led.LEDs
+			err := Render(context.Background(), cfg, RenderOptions{
+				Audio:    test.audio,
+				Duration: 2 * time.Second,
+			}, func(leds led.LEDs) error {
+				frames = append(frames, append(led.LEDs(nil), leds...))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, i := range []int{0, test.idleFrom - 2, test.idleFrom + 1, len(frames) - 1} {
+				if isIdle := frames[i][0] == idle; isIdle != (i >= test.idleFrom) {
+					t.Errorf("frame %d: got %v, want idle to be %v", i, frames[i][0], i >= test.idleFrom)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package catglow
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+	"libdb.so/catglow/internal/led"
+)
+
+// scene is the runtime state of a SceneConfig.
+type scene struct {
+	name string
+	// base is the frame that animators draw on top of. It contains all the
+	// static colors of the scene.
+	base      led.LEDs
+	animators []trackedAnimator
+	// stop stops the scene's background runners. It is nil if the scene is
+	// not active.
+	stop context.CancelFunc
+}
+
+func newScene(cfg SceneConfig, numLEDs int, env animatorEnv) (*scene, error) {
+	s := &scene{
+		name: cfg.Name,
+		base: led.NewLEDs(numLEDs),
+	}
+
+	for _, led := range cfg.LEDs {
+		if led.Color != nil {
+			// Pre-initialize with static colors and skip the animator.
+			s.base.SetRange(led.Range[0], led.Range[1], *led.Color)
+			continue
+		}
+
+		animator, err := newAnimator(led, env)
+		if err != nil {
+			return nil, errors.Wrapf(err, "LED range %v", led.Range)
+		}
+		if animator != nil {
+			s.animators = append(s.animators, trackedAnimator{animator, led})
+		}
+	}
+
+	return s, nil
+}
+
+// activate starts the background runners of the scene's animators, such as
+// visualizers. It does nothing if the scene is already active.
+func (s *scene) activate(ctx context.Context, logger *slog.Logger) {
+	if s.stop != nil {
+		return
+	}
+
+	ctx, s.stop = context.WithCancel(ctx)
+
+	for _, animator := range s.animators {
+		runner, ok := animator.Animator.(animatorRunner)
+		if !ok {
+			continue
+		}
+
+		go func(animator trackedAnimator) {
+			if err := runner.Run(ctx); err != nil && ctx.Err() == nil {
+				logger.Error(
+					"animator stopped",
+					"scene", s.name,
+					"range", animator.cfg.Range,
+					"error", err)
+			}
+		}(animator)
+	}
+}
+
+// deactivate stops the background runners of the scene's animators.
+func (s *scene) deactivate() {
+	if s.stop != nil {
+		s.stop()
+		s.stop = nil
+	}
+}
+
+// draw draws the scene into dst.
+func (s *scene) draw(dst led.LEDs) {
+	copy(dst, s.base)
+	for _, animator := range s.animators {
+		animator.AcquireFrame(func(f led.LEDs) {
+			end := animator.cfg.Range[1] - animator.cfg.Range[0]
+			if end > len(f) {
+				end = len(f)
+			}
+			dst.Draw(animator.cfg.Range[0], f[:end])
+		})
+	}
+}
+
+// transition is a linear transition that starts at a certain time.
+type transition struct {
+	start    time.Time
+	duration time.Duration
+}
+
+// progress returns the progress of the transition in the range [0, 1].
+func (t transition) progress(now time.Time) float64 {
+	if t.duration <= 0 {
+		return 1
+	}
+	p := float64(now.Sub(t.start)) / float64(t.duration)
+	return math.Max(0, math.Min(1, p))
+}
+
+// sceneFade is an ongoing crossfade between two scenes.
+type sceneFade struct {
+	transition
+	// from is the outgoing scene. It is nil if the scene was switched during
+	// another crossfade, in which case buf holds the frame shown at the time.
+	from *scene
+	buf  led.LEDs
+}
+
+// sceneMixer draws the current scene, crossfading from the previous scene if
+// the scene was recently switched. It keeps the scenes that are visible active.
+type sceneMixer struct {
+	ctx     context.Context
+	logger  *slog.Logger
+	current *scene
+	fade    *sceneFade
+}
+
+// switchTo switches to the given scene. If crossfade is non-zero, then the
+// outgoing frame is faded into the incoming one. If the scene is switched
+// during another crossfade, then the fade starts from the mixed frame.
+func (m *sceneMixer) switchTo(s *scene, now time.Time, crossfade time.Duration) {
+	if m.current == s {
+		return
+	}
+
+	var frame led.LEDs
+	if m.fade != nil && crossfade > 0 {
+		frame = led.NewLEDs(len(s.base))
+		m.draw(frame, now)
+		if m.fade == nil {
+			// The crossfade has just finished, so fade from the scene itself.
+			frame = nil
+		}
+	}
+	if m.fade != nil {
+		if m.fade.from != nil && m.fade.from != s {
+			m.fade.from.deactivate()
+		}
+		m.fade = nil
+	}
+
+	s.activate(m.ctx, m.logger)
+
+	if m.current != nil {
+		switch {
+		case frame != nil:
+			m.fade = &sceneFade{
+				transition: transition{now, crossfade},
+				buf:        frame,
+			}
+			m.current.deactivate()
+		case crossfade > 0:
+			m.fade = &sceneFade{
+				transition: transition{now, crossfade},
+				from:       m.current,
+				buf:        led.NewLEDs(len(s.base)),
+			}
+		default:
+			m.current.deactivate()
+		}
+	}
+
+	m.current = s
+}
+
+// draw draws the mixed frame into dst.
+func (m *sceneMixer) draw(dst led.LEDs, now time.Time) {
+	m.current.draw(dst)
+
+	if m.fade == nil {
+		return
+	}
+
+	t := m.fade.progress(now)
+	if t >= 1 {
+		if m.fade.from != nil {
+			m.fade.from.deactivate()
+		}
+		m.fade = nil
+		return
+	}
+
+	if m.fade.from != nil {
+		m.fade.from.draw(m.fade.buf)
+	}
+	dst.Blend(m.fade.buf, dst, t)
+}
+
+// brightnessFader fades the brightness of the strip.
+type brightnessFader struct {
+	transition
+	from, to float64
+}
+
+// set starts a transition from the current brightness to the given one.
+func (f *brightnessFader) set(brightness float64, now time.Time, duration time.Duration) {
+	f.from = f.at(now)
+	f.to = brightness
+	f.transition = transition{now, duration}
+}
+
+// at returns the brightness at the given time.
+func (f *brightnessFader) at(now time.Time) float64 {
+	return f.from + (f.to-f.from)*f.progress(now)
+}
@@ -0,0 +1,212 @@
+package catglow
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/led"
+)
+
+func newColorScene(t *testing.T, name string, color led.RGBColor) *scene {
+	t.Helper()
+
+	s, err := newScene(SceneConfig{
+		Name: name,
+		LEDs: []LEDConfig{{Range: [2]int{0, 1}, Color: &color}},
+	}, 1, animatorEnv{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSceneMixer(t *testing.T) {
+	red := newColorScene(t, "red", led.RGBColor{255, 0, 0})
+	green := newColorScene(t, "green", led.RGBColor{0, 255, 0})
+	blue := newColorScene(t, "blue", led.RGBColor{0, 0, 255})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clk := clock.NewManual(time.Date(2023, 6, 1, 20, 0, 0, 0, time.UTC))
+	mixer := sceneMixer{ctx: ctx, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	expect := func(want led.RGBColor) {
+		t.Helper()
+		leds := led.NewLEDs(1)
+		mixer.draw(leds, clk.Now())
+		if leds[0] != want {
+			t.Errorf("at %v: got %v, want %v", clk.Now().Format(time.TimeOnly), leds[0], want)
+		}
+	}
+	expectActive := func(s *scene, want bool) {
+		t.Helper()
+		if active := s.stop != nil; active != want {
+			t.Errorf("scene %q: got active %v, want %v", s.name, active, want)
+		}
+	}
+
+	mixer.switchTo(red, clk.Now(), 0)
+	expect(led.RGBColor{255, 0, 0})
+
+	mixer.switchTo(green, clk.Now(), time.Second)
+	expect(led.RGBColor{255, 0, 0})
+	expectActive(red, true)
+
+	clk.Advance(500 * time.Millisecond)
+	expect(led.RGBColor{128, 128, 0})
+
+	// Switching mid-crossfade fades from the mixed frame, not from the
+	// scene that was fading out.
+	mixer.switchTo(blue, clk.Now(), time.Second)
+	expect(led.RGBColor{128, 128, 0})
+	expectActive(red, false)
+	expectActive(green, false)
+	expectActive(blue, true)
+
+	clk.Advance(500 * time.Millisecond)
+	expect(led.RGBColor{64, 64, 128})
+
+	clk.Advance(500 * time.Millisecond)
+	expect(led.RGBColor{0, 0, 255})
+
+	// Switching back to the outgoing scene mid-crossfade keeps it active.
+	mixer.switchTo(red, clk.Now(), time.Second)
+	clk.Advance(500 * time.Millisecond)
+	mixer.switchTo(blue, clk.Now(), time.Second)
+	expectActive(red, false)
+	expectActive(blue, true)
+
+	mixer.switchTo(red, clk.Now(), 0)
+	expect(led.RGBColor{255, 0, 0})
+	expectActive(red, true)
+	expectActive(blue, false)
+}
+
+func TestSceneValidation(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{
+			name: "scenes",
+			config: `
+default_scene = "night"
+
+[[led]]
+  range = [0, 8]
+  color = [255, 255, 255]
+
+[[scene]]
+  name = "night"
+  [[scene.led]]
+    range = [0, 8]
+    color = [64, 0, 0]
+
+[[schedule]]
+  at = "22:00"
+  scene = "night"
+`,
+		},
+		{
+			name: "unnamed scene",
+			config: `
+[[scene]]
+  [[scene.led]]
+    range = [0, 8]
+    color = [64, 0, 0]
+`,
+			wantErr: "scene 0 has no name",
+		},
+		{
+			name: "duplicate scene",
+			config: `
+[[led]]
+  range = [0, 8]
+  color = [255, 255, 255]
+
+[[scene]]
+  name = "default"
+  [[scene.led]]
+    range = [0, 8]
+    color = [64, 0, 0]
+`,
+			wantErr: `duplicate scene "default"`,
+		},
+		{
+			name: "invalid scene LEDs",
+			config: `
+[[led]]
+  range = [0, 8]
+  color = [255, 255, 255]
+
+[[scene]]
+  name = "night"
+  [[scene.led]]
+    range = [8, 0]
+    color = [64, 0, 0]
+`,
+			wantErr: `scene "night"`,
+		},
+		{
+			name: "empty LED range",
+			config: `
+[[led]]
+  range = [0, 8]
+  color = [255, 255, 255]
+
+[[led]]
+  range = [8, 8]
+  color = [64, 0, 0]
+`,
+			wantErr: "invalid LED range [8 8]",
+		},
+		{
+			name: "unknown default scene",
+			config: `
+default_scene = "night"
+
+[[led]]
+  range = [0, 8]
+  color = [255, 255, 255]
+`,
+			wantErr: `default scene "night" does not exist`,
+		},
+		{
+			name: "unknown scheduled scene",
+			config: `
+[[led]]
+  range = [0, 8]
+  color = [255, 255, 255]
+
+[[schedule]]
+  at = "22:00"
+  scene = "night"
+`,
+			wantErr: `schedule rule 0: scene "night" does not exist`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, err := ParseConfig(strings.NewReader(test.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = cfg.Validate()
+			switch {
+			case test.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case test.wantErr != "" && err == nil:
+				t.Errorf("expected an error containing %q", test.wantErr)
+			case test.wantErr != "" && !strings.Contains(err.Error(), test.wantErr):
+				t.Errorf("got error %q, want one containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package catglow
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+	"libdb.so/catglow/internal/cron"
+)
+
+// scheduleTarget is what the scheduler controls. It is implemented by Daemon.
+type scheduleTarget interface {
+	setScene(name string, crossfade time.Duration) error
+	SetBrightness(brightness float64, transition time.Duration)
+}
+
+type scheduleRule struct {
+	ScheduleConfig
+	schedule *cron.Schedule
+}
+
+// scheduler applies schedule rules to a target as they fire.
+type scheduler struct {
+	rules  []scheduleRule
+	clock  clock.Clock
+	target scheduleTarget
+	logger *slog.Logger
+}
+
+func newScheduler(cfgs []ScheduleConfig, clock clock.Clock, target scheduleTarget, logger *slog.Logger) (*scheduler, error) {
+	rules := make([]scheduleRule, len(cfgs))
+	for i, cfg := range cfgs {
+		schedule, err := cfg.Parse()
+		if err != nil {
+			return nil, err
+		}
+		rules[i] = scheduleRule{cfg, schedule}
+	}
+
+	return &scheduler{
+		rules:  rules,
+		clock:  clock,
+		target: target,
+		logger: logger,
+	}, nil
+}
+
+// maxScheduleWait is the longest the scheduler sleeps before checking the time
+// again. The system clock may jump, e.g. after a suspend.
+const maxScheduleWait = time.Minute
+
+// run runs the scheduler until the context is canceled. The scene and the
+// brightness of the rules that fired most recently are applied immediately
+// without a transition, so that the daemon starts in the state it would have
+// been in had it been running all along.
+func (s *scheduler) run(ctx context.Context) error {
+	if len(s.rules) == 0 {
+		return nil
+	}
+
+	last := s.clock.Now()
+	s.replay(last)
+
+	for {
+		next, rules := s.next(last)
+		if next.IsZero() {
+			return nil
+		}
+
+		wait := next.Sub(s.clock.Now())
+		if wait > maxScheduleWait {
+			wait = maxScheduleWait
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.clock.After(wait):
+		}
+
+		if s.clock.Now().Before(next) {
+			continue
+		}
+
+		for _, rule := range rules {
+			s.apply(rule, time.Duration(rule.Transition))
+		}
+
+		last = next
+	}
+}
+
+// replay applies the scene and the brightness that the rules last set at or
+// before now. They may come from different rules, such as when a rule dims
+// the strip hours after another one switched the scene.
+func (s *scheduler) replay(now time.Time) {
+	var rule scheduleRule
+	if r := s.previous(now, func(r scheduleRule) bool { return r.Scene != "" }); r != nil {
+		rule.Scene = r.Scene
+	}
+	if r := s.previous(now, func(r scheduleRule) bool { return r.Brightness != nil }); r != nil {
+		rule.Brightness = r.Brightness
+	}
+	s.apply(rule, 0)
+}
+
+// previous returns the rule matching the filter that fired most recently at
+// or before now. If several rules fired at the same time, the last one in the
+// configuration wins.
+func (s *scheduler) previous(now time.Time, filter func(scheduleRule) bool) *scheduleRule {
+	var last *scheduleRule
+	var lastTime time.Time
+
+	for i, rule := range s.rules {
+		if !filter(rule) {
+			continue
+		}
+		t := rule.schedule.Prev(now)
+		if t.IsZero() {
+			continue
+		}
+		if last == nil || !t.Before(lastTime) {
+			last = &s.rules[i]
+			lastTime = t
+		}
+	}
+
+	return last
+}
+
+// next returns the next time after now that any rule fires and the rules that
+// fire at that time.
+func (s *scheduler) next(now time.Time) (time.Time, []scheduleRule) {
+	var nextTime time.Time
+	var rules []scheduleRule
+
+	for _, rule := range s.rules {
+		t := rule.schedule.Next(now)
+		switch {
+		case t.IsZero():
+			continue
+		case nextTime.IsZero() || t.Before(nextTime):
+			nextTime = t
+			rules = append(rules[:0], rule)
+		case t.Equal(nextTime):
+			rules = append(rules, rule)
+		}
+	}
+
+	return nextTime, rules
+}
+
+func (s *scheduler) apply(rule scheduleRule, transition time.Duration) {
+	s.logger.Debug(
+		"applying schedule rule",
+		"at", rule.At,
+		"cron", rule.Cron,
+		"scene", rule.Scene,
+		"brightness", rule.Brightness)
+
+	if rule.Scene != "" {
+		if err := s.target.setScene(rule.Scene, transition); err != nil {
+			s.logger.Warn(
+				"failed to apply scheduled scene",
+				"scene", rule.Scene,
+				"error", err)
+		}
+	}
+
+	if rule.Brightness != nil {
+		s.target.SetBrightness(*rule.Brightness, transition)
+	}
+}
+
+func (d *internalDaemon) runScheduler(ctx context.Context) error {
+	s, err := newScheduler(d.cfg.Schedule, clock.Real, d.Daemon, d.logger)
+	if err != nil {
+		return err
+	}
+	return s.run(ctx)
+}
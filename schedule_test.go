@@ -0,0 +1,167 @@
+package catglow
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"libdb.so/catglow/internal/clock"
+)
+
+type scheduleEvent struct {
+	scene      string
+	brightness float64
+	transition time.Duration
+}
+
+type fakeScheduleTarget struct {
+	mu     sync.Mutex
+	events []scheduleEvent
+}
+
+func (t *fakeScheduleTarget) setScene(name string, crossfade time.Duration) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, scheduleEvent{scene: name, transition: crossfade})
+	return nil
+}
+
+func (t *fakeScheduleTarget) SetBrightness(brightness float64, transition time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, scheduleEvent{brightness: brightness, transition: transition})
+}
+
+func (t *fakeScheduleTarget) take() []scheduleEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	events := t.events
+	t.events = nil
+	return events
+}
+
+func TestScheduler(t *testing.T) {
+	dim := 0.3
+	full := 1.0
+
+	cfgs := []ScheduleConfig{
+		{At: "08:00", Scene: "work", Brightness: &full, Transition: TOMLDuration(time.Minute)},
+		{At: "21:00", Brightness: &dim, Transition: TOMLDuration(10 * time.Minute)},
+		{Cron: "30 23 * * *", Scene: "bedtime", Transition: TOMLDuration(time.Minute)},
+	}
+
+	start := time.Date(2023, 6, 1, 20, 0, 0, 0, time.UTC)
+	clk := clock.NewManual(start)
+	target := &fakeScheduleTarget{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	s, err := newScheduler(cfgs, clk, target, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- s.run(ctx) }()
+
+	// advance moves the clock to the given time once the scheduler is
+	// waiting on it, minute by minute so that the scheduler catches up.
+	advance := func(to time.Time) {
+		for clk.Now().Before(to) {
+			waitForWaiter(t, clk)
+			clk.Advance(time.Minute)
+		}
+		waitForWaiter(t, clk)
+	}
+
+	waitForWaiter(t, clk)
+	expectEvents(t, target, []scheduleEvent{
+		{scene: "work"},
+		{brightness: 1},
+	})
+
+	advance(time.Date(2023, 6, 1, 21, 0, 0, 0, time.UTC))
+	expectEvents(t, target, []scheduleEvent{
+		{brightness: 0.3, transition: 10 * time.Minute},
+	})
+
+	advance(time.Date(2023, 6, 1, 23, 30, 0, 0, time.UTC))
+	expectEvents(t, target, []scheduleEvent{
+		{scene: "bedtime", transition: time.Minute},
+	})
+
+	advance(time.Date(2023, 6, 2, 8, 0, 0, 0, time.UTC))
+	expectEvents(t, target, []scheduleEvent{
+		{scene: "work", transition: time.Minute},
+		{brightness: 1, transition: time.Minute},
+	})
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("run returned %v, want context.Canceled", err)
+	}
+}
+
+func TestSchedulerReplay(t *testing.T) {
+	dim := 0.3
+	full := 1.0
+
+	cfgs := []ScheduleConfig{
+		{At: "08:00", Scene: "work", Brightness: &full},
+		{At: "21:00", Brightness: &dim},
+		{At: "22:00", Scene: "evening"},
+	}
+
+	// Starting after the rule that only dims the strip still switches to the
+	// scene of the rule before it.
+	start := time.Date(2023, 6, 1, 21, 30, 0, 0, time.UTC)
+	clk := clock.NewManual(start)
+	target := &fakeScheduleTarget{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	s, err := newScheduler(cfgs, clk, target, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- s.run(ctx) }()
+
+	waitForWaiter(t, clk)
+	expectEvents(t, target, []scheduleEvent{
+		{scene: "work"},
+		{brightness: 0.3},
+	})
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("run returned %v, want context.Canceled", err)
+	}
+}
+
+func waitForWaiter(t *testing.T, clk *clock.Manual) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for clk.Waiters() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the scheduler")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func expectEvents(t *testing.T, target *fakeScheduleTarget, want []scheduleEvent) {
+	t.Helper()
+	if got := target.take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %+v, want %+v", got, want)
+	}
+}
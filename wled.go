@@ -0,0 +1,155 @@
+package catglow
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	"golang.org/x/sync/errgroup"
+	"libdb.so/catglow/internal/led"
+	"libdb.so/catglow/internal/wled"
+)
+
+func (c *WLEDConfig) listen() string {
+	if c.Listen == "" {
+		return ":80"
+	}
+	return c.Listen
+}
+
+func (c *WLEDConfig) realtime() string {
+	if c.Realtime == "" {
+		return fmt.Sprintf(":%d", wled.RealtimePort)
+	}
+	return c.Realtime
+}
+
+func (c *WLEDConfig) name() string {
+	if c.Name == "" {
+		return "catglow"
+	}
+	return c.Name
+}
+
+// runWLED serves the WLED API until the context is canceled. If neither the
+// API nor the realtime protocols can listen, then it returns right away.
+func (d *Daemon) runWLED(ctx context.Context) error {
+	cfg := d.cfg.WLED
+
+	info := wled.Info{
+		Name:    cfg.name(),
+		NumLEDs: d.cfg.NumLEDs(),
+		FPS:     d.cfg.Rate,
+	}
+	if _, port, err := net.SplitHostPort(cfg.realtime()); err == nil {
+		info.UDPPort, _ = strconv.Atoi(port)
+	}
+
+	server := wled.NewServer(wledController{d}, info)
+
+	// WLED clients expect the API on port 80, which the daemon may not be
+	// allowed to bind. Rather than taking the daemon down, the API or the
+	// realtime protocols are disabled if their address cannot be bound.
+	errg, ctx := errgroup.WithContext(ctx)
+
+	if l, err := net.Listen("tcp", cfg.listen()); err != nil {
+		d.logger.Warn(
+			"failed to listen for the WLED API, disabling it",
+			"listen", cfg.listen(),
+			"error", err)
+	} else {
+		d.logger.Info("serving the WLED API", "listen", cfg.listen())
+		errg.Go(func() error { return server.Serve(ctx, l) })
+	}
+
+	if conn, err := net.ListenPacket("udp", cfg.realtime()); err != nil {
+		d.logger.Warn(
+			"failed to listen for WLED realtime packets, disabling them",
+			"realtime", cfg.realtime(),
+			"error", err)
+	} else {
+		d.logger.Info("receiving WLED realtime packets", "realtime", cfg.realtime())
+		errg.Go(func() error { return server.ServeRealtime(ctx, conn) })
+	}
+
+	return errg.Wait()
+}
+
+// wledController adapts the daemon to the WLED API. Segments are identified
+// by their index, and effects by their index in Effects, which starts with
+// solid like WLED's.
+type wledController struct {
+	*Daemon
+}
+
+var _ wled.Controller = wledController{}
+
+func (c wledController) Segments() []wled.Segment {
+	segments := c.Daemon.Segments()
+	wledSegments := make([]wled.Segment, len(segments))
+	for i, segment := range segments {
+		wledSegments[i] = toWLEDSegment(segment)
+	}
+	return wledSegments
+}
+
+func (c wledController) UpdateSegment(id int, update func(*wled.Segment)) error {
+	segments := c.Daemon.Segments()
+	if id < 0 || id >= len(segments) {
+		return fmt.Errorf("unknown segment %d", id)
+	}
+
+	effects := Effects()
+
+	var err error
+	updateErr := c.Daemon.UpdateSegment(segments[id].Name, func(segment *SegmentState) {
+		old := toWLEDSegment(*segment)
+		updated := old
+		updated.Colors = append([]led.RGBColor(nil), old.Colors...)
+		update(&updated)
+
+		if updated.Effect < 0 || updated.Effect >= len(effects) {
+			err = fmt.Errorf("unknown effect %d", updated.Effect)
+			return
+		}
+
+		segment.On = updated.On
+		segment.Brightness = updated.Brightness
+		segment.Colors = updated.Colors
+		segment.Effect = effects[updated.Effect]
+
+		// Picking a color for a segment that follows the scene should show
+		// that color, like it would on WLED.
+		if segment.Effect == SceneEffect && updated.Effect == old.Effect && !sameColors(old.Colors, updated.Colors) {
+			segment.Effect = SolidEffect
+		}
+	})
+	if updateErr != nil {
+		return updateErr
+	}
+	return err
+}
+
+func (c wledController) Effects() []string {
+	return Effects()
+}
+
+func toWLEDSegment(segment SegmentState) wled.Segment {
+	effect := 0
+	for i, e := range Effects() {
+		if e == segment.Effect {
+			effect = i
+		}
+	}
+
+	return wled.Segment{
+		Name:       segment.Name,
+		Start:      segment.Range[0],
+		Stop:       segment.Range[1],
+		On:         segment.On,
+		Brightness: segment.Brightness,
+		Colors:     segment.Colors,
+		Effect:     effect,
+	}
+}